@@ -0,0 +1,18 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/e-wrobel/sync-service/internal/sync"
+)
+
+// decrypt writes the plaintext of the target file at path, encrypted with passphrase, to out.
+func decrypt(path, passphrase string, out io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return sync.Decrypt(out, f, passphrase)
+}
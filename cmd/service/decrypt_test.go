@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/e-wrobel/sync-service/internal/sync"
+)
+
+func TestDecrypt(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("plain"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rep := sync.Sync(sync.Options{Source: src, Target: dst, EncryptPassphrase: "pw", Logger: log.New(io.Discard, "", 0)})
+	if len(rep.Errors) != 0 {
+		t.Fatalf("sync: %v", rep.Errors)
+	}
+
+	var out bytes.Buffer
+	if err := decrypt(filepath.Join(dst, "a.txt"), "pw", &out); err != nil || out.String() != "plain" {
+		t.Fatalf("got %q, %v", out.String(), err)
+	}
+	if err := decrypt(filepath.Join(dst, "a.txt"), "nope", io.Discard); err == nil {
+		t.Fatal("expected an error for the wrong passphrase")
+	}
+}
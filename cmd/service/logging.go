@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const textLogFlags = log.Ldate | log.Ltime | log.Lmicroseconds
+
+// newLogger builds the logger selected by --log-file and --log-format.
+// An empty file means stderr and "-" means stdout; files are appended to.
+// The returned closer is nil unless a file was opened.
+func newLogger(file, format string) (*log.Logger, io.Closer, error) {
+	var w io.Writer = os.Stderr
+	var c io.Closer
+	switch file {
+	case "":
+	case "-":
+		w = os.Stdout
+	default:
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		w, c = f, f
+	}
+
+	switch format {
+	case "text":
+		return log.New(w, "", textLogFlags), c, nil
+	case "json":
+		return log.New(&jsonLines{w: w}, "", 0), c, nil
+	}
+	if c != nil {
+		_ = c.Close()
+	}
+	return nil, nil, fmt.Errorf("unknown log format %q", format)
+}
+
+// perFileLines are the prefixes of the sync package's log lines about single files, left out
+// with --summary-only. Errors, warnings and lines about the whole run (ABORT, RETRY, ...) are kept.
+var perFileLines = []string{
+	"COPY:", "OVERWRITE:", "APPEND:", "DELETE:", "MKDIR:", "SKIP:", "TRASH:", "REPLACE:", "RENAME:",
+	"RECASE:", "CHMOD:", "POLICY:", "CLEAN:", "PRUNE:", "ORPHAN:",
+}
+
+// summaryOnlyLogger returns the sync logger for --summary-only: it drops the per-file lines
+// and passes every other line on to l.
+func summaryOnlyLogger(l *log.Logger) *log.Logger {
+	return log.New(perFileFilter{l: l}, "", 0)
+}
+
+type perFileFilter struct {
+	l *log.Logger
+}
+
+func (f perFileFilter) Write(p []byte) (int, error) {
+	msg := strings.TrimPrefix(string(p), "DRY-RUN ")
+	for _, prefix := range perFileLines {
+		if strings.HasPrefix(msg, prefix) {
+			return len(p), nil
+		}
+	}
+	if err := f.l.Output(2, string(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// jsonLines turns every log line into a JSON object {"time": ..., "msg": ...} on a line of its own.
+// log.Logger hands over exactly one line per Write.
+type jsonLines struct {
+	w io.Writer
+}
+
+type jsonLine struct {
+	Time string `json:"time"`
+	Msg  string `json:"msg"`
+}
+
+func (j *jsonLines) Write(p []byte) (int, error) {
+	b, err := json.Marshal(jsonLine{
+		Time: time.Now().Format(time.RFC3339Nano),
+		Msg:  strings.TrimSuffix(string(p), "\n"),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := j.w.Write(append(b, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewLogger(t *testing.T) {
+	t.Run("text_file", func(t *testing.T) {
+		p := filepath.Join(t.TempDir(), "sync.log")
+		for i := 0; i < 2; i++ {
+			l, c, err := newLogger(p, "text")
+			if err != nil {
+				t.Fatal(err)
+			}
+			l.Printf("COPY: run %d", i)
+			c.Close()
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Appended, not truncated
+		if !strings.Contains(string(b), "COPY: run 0") || !strings.Contains(string(b), "COPY: run 1") {
+			t.Fatalf("unexpected log file:\n%s", b)
+		}
+	})
+
+	t.Run("json_file", func(t *testing.T) {
+		p := filepath.Join(t.TempDir(), "sync.json")
+		l, c, err := newLogger(p, "json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Printf("COPY: %s -> %s", "a \"quoted\".txt", "b.txt")
+		l.Printf("DONE – copied=1")
+		c.Close()
+
+		f, err := os.Open(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		var msgs []string
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			var line jsonLine
+			if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+				t.Fatalf("unparseable line %q: %v", sc.Text(), err)
+			}
+			if _, err := time.Parse(time.RFC3339Nano, line.Time); err != nil {
+				t.Fatalf("bad time in %q: %v", sc.Text(), err)
+			}
+			msgs = append(msgs, line.Msg)
+		}
+		want := []string{`COPY: a "quoted".txt -> b.txt`, "DONE – copied=1"}
+		if strings.Join(msgs, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("got %q want %q", msgs, want)
+		}
+	})
+
+	t.Run("stdout", func(t *testing.T) {
+		l, c, err := newLogger("-", "text")
+		if err != nil || c != nil || l.Writer() != os.Stdout {
+			t.Fatalf("expected stdout logger, got %v, %v, %v", l.Writer(), c, err)
+		}
+	})
+
+	t.Run("bad_format", func(t *testing.T) {
+		p := filepath.Join(t.TempDir(), "sync.log")
+		if _, _, err := newLogger(p, "xml"); err == nil {
+			t.Fatalf("expected error for unknown format")
+		}
+	})
+}
@@ -3,53 +3,647 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/e-wrobel/sync-service/internal/sync"
 	"github.com/e-wrobel/sync-service/internal/validators"
 )
 
 func main() {
-	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
+	log.SetFlags(textLogFlags)
+	if len(os.Args) > 1 && os.Args[1] == "server" {
+		os.Exit(runServer(os.Args[2:]))
+	}
 
-	var src string
-	var dst string
+	var srcs stringList
+	var dsts stringList
+	var hashExts, noHashExts stringList
 	var deleteMissing bool
+	var reportOrphans bool
+	var cleanTarget bool
+	var skipHidden bool
+	var defaultExcludes bool
+	var sidecarIgnore string
+	var maxErrors int
+	var categorizeErrors bool
+	var coalesceErrors, trackAllErrors bool
+	var subtreeCheck bool
+	var checksumDB string
+	var transactional bool
+	var oneFileSystem bool
+	var walkOrder string
+	var completionMarker string
+	var ignoreModTime bool
+	var hashConcurrency int
+	var sizeOnly bool
+	var checksumXattr bool
+	var trashDir string
+	var trashTimestamped bool
+	var trashRetention time.Duration
+	var sanitizeNames string
+	var dryRun bool
+	var planFirst bool
+	var planOut, applyPlan string
+	var yes bool
+	var cleanStaleTemps bool
+	var staleTempAge time.Duration
+
+	var sourceConflict string
+	var targetEmpty bool
+	var estimate bool
+	var verify bool
+	var useSyslog bool
+	var syslogFacility string
+	var syslogTag string
+	var preserveACLs bool
+	var preserveOwner bool
+	var userMap, groupMap string
+	var readahead int
+	var ioUring bool
+	var skipLocked bool
+	var respectImmutable bool
+	var detectConcurrent bool
+	var perDirStats bool
+	var maxPathLen int
+	var appendOnly bool
+	var computeChurn bool
+	var nice int
+	var ioNice string
+	var onStatError string
+	var verifyBeforeDelete bool
+	var manifest string
+	var csvReport string
+	var deferMetadata bool
+	var preserveAllTimes bool
+	var warnNewerTarget bool
+	var onConflict string
+	var conflictNameTemplate string
+	var dirsOnly bool
+	var structureOnly stringList
+	var repairPerms bool
+	var heartbeatFile string
+	var lineEndings string
+	var textfileMetrics string
+	var versionHeader string
+	var encryptPassFile string
+	var decryptFile string
+	var dereferenceRoots bool
+	var guardWalk bool
+	var fileListPath string
+	var gitSince string
+	var heartbeatInterval time.Duration
+	var fixCase bool
+	var maxDeletes int
+	var maxTotalBytes int64
+	var deleteLimit string
+	var maxTargetFiles int
+	var targetLimit string
+	var reconcile bool
+	var postSyncSample float64
+	var logFile string
+	var logFormat string
+	var summaryOnly bool
+	var quietOnSuccess bool
+	var reportFormat string
+	var mtimeTolerance time.Duration
+	var pauseOnENOSPC time.Duration
+	var checkFreeSpace bool
+	var enospcRetries int
+	var retryWholeSync int
+	var retryWholeSyncDelay time.Duration
+	var renameStrategy string
+	var tempDevice string
+	var targetPolicy string
+	var busyTargetRetries int
+	var busyTargetBackoff time.Duration
+	var busyTargetReplaceAside bool
+	var streamVisible bool
+	var streamVisibleMinSize int64
+	var skipReasons bool
+	var spotCheck float64
+	var targetSymlink string
 
-	flag.StringVar(&src, "source", "", "Path to source folder")
-	flag.StringVar(&dst, "target", "", "Path to target folder")
+	flag.Var(&srcs, "source", "Path to source folder or .tar/.tar.gz/.zip archive (repeat to merge several sources into the target)")
+	flag.Var(&hashExts, "hash-ext", "With --ignore-mtime, hash only files with this extension (repeatable); others use size and mod-time")
+	flag.Var(&noHashExts, "no-hash-ext", "With --ignore-mtime, compare files with this extension by size and mod-time (repeatable)")
+	flag.IntVar(&hashConcurrency, "hash-concurrency", 0, "With --ignore-mtime, hash up to N files in parallel (0 = one at a time)")
+	flag.Var(&dsts, "target", "Path to target folder or .tar/.tar.gz/.zip archive to create (repeat to mirror into several targets)")
 	flag.BoolVar(&deleteMissing, "delete-missing", false, "Remove files missing in source folder")
+	flag.BoolVar(&reportOrphans, "report-orphans", false, "List files present only in target without removing them (unless --delete-missing)")
+	flag.BoolVar(&cleanTarget, "clean-target", false, "Empty the target (into --trash-dir when set) before syncing, for an exact mirror")
+	flag.BoolVar(&skipHidden, "skip-hidden", false, "Skip hidden (dot-prefixed) files and directories")
+	flag.BoolVar(&defaultExcludes, "default-excludes", false, "Skip common junk such as .git, node_modules, __pycache__, .DS_Store, Thumbs.db and *.swp")
+	flag.StringVar(&sidecarIgnore, "sidecar-ignore-suffix", "", "Skip every source file or directory X next to which X<suffix> exists, e.g. .sync-ignore")
+	flag.IntVar(&maxErrors, "max-errors", 0, "Maximum number of errors kept for the final report (0 = unlimited)")
+	flag.BoolVar(&categorizeErrors, "categorize-errors", false, "Group the errors in the final report by cause: permission, space, io, not-found, other")
+	flag.BoolVar(&coalesceErrors, "coalesce-errors", false, "Report identical error messages once with their count")
+	flag.BoolVar(&trackAllErrors, "track-all-errors", false, "With --coalesce-errors, still keep every error in the report")
+	flag.BoolVar(&subtreeCheck, "subtree-check", false, "Skip directories unchanged since the last clean run (requires --checksum-db)")
+	flag.StringVar(&checksumDB, "checksum-db", "", "Path to the checksum database file")
+	flag.BoolVar(&transactional, "transactional", false, "Apply all changes only if the whole run succeeds")
+	flag.BoolVar(&oneFileSystem, "one-file-system", false, "Do not descend into source directories on other filesystems")
+	flag.StringVar(&walkOrder, "walk-order", "default", "Order of processing within directories: default, name, size, mtime")
+	flag.StringVar(&completionMarker, "completion-marker", "", "Name of a marker file written into the target after a run without errors")
+	flag.BoolVar(&ignoreModTime, "ignore-mtime", false, "Compare files by size and content hash instead of modification time")
+	flag.BoolVar(&sizeOnly, "size-only", false, "Compare files by size only (no mod-time, no content)")
+	flag.BoolVar(&checksumXattr, "checksum-xattr", false, "Store the SHA-256 of copied files in the user.sync.sha256 xattr and use it in content comparisons (Linux)")
+	flag.StringVar(&trashDir, "trash-dir", "", "Target-relative directory that deleted files are moved into")
+	flag.BoolVar(&trashTimestamped, "trash-timestamped", false, "Keep a timestamped trash snapshot per run")
+	flag.DurationVar(&trashRetention, "trash-retention", 0, "Prune timestamped trash snapshots older than this (0 = keep)")
+	flag.StringVar(&sanitizeNames, "sanitize-names", "off", "Handling of names illegal on the target: off, error, skip, replace")
+	flag.BoolVar(&dryRun, "dry-run", false, "Only report what would be changed")
+	flag.BoolVar(&planFirst, "plan-then-apply", false, "Show the planned changes and ask for confirmation before applying them")
+	flag.BoolVar(&yes, "yes", false, "Do not ask for confirmation with --plan-then-apply")
+	flag.StringVar(&planOut, "plan-out", "", "Write the planned actions to this JSON file and exit without changing anything")
+	flag.StringVar(&applyPlan, "apply", "", "Perform exactly the actions of a plan written by --plan-out")
+	flag.BoolVar(&cleanStaleTemps, "clean-stale-temps", false, "Remove temp files left in the target by crashed runs")
+	flag.DurationVar(&staleTempAge, "stale-temp-age", time.Hour, "Minimum age of a temp file to be considered stale")
+	flag.StringVar(&sourceConflict, "source-conflict", "first-wins", "Which of several sources provides a shared path: first-wins, last-wins, error")
+	flag.BoolVar(&targetEmpty, "target-empty", false, "Fast path for seeding an empty target (verified at startup)")
+	flag.BoolVar(&estimate, "estimate", false, "Print how many files and bytes would be copied, overwritten and deleted, then exit")
+	flag.BoolVar(&verify, "verify-only", false, "Compare target with source without writing; exit 0 if in sync, 3 if not")
+	flag.BoolVar(&useSyslog, "syslog", false, "Send the final report to syslog (Unix)")
+	flag.StringVar(&syslogFacility, "syslog-facility", "user", "Syslog facility, e.g. daemon or local0")
+	flag.StringVar(&syslogTag, "syslog-tag", "sync-service", "Syslog tag")
+	flag.BoolVar(&preserveACLs, "preserve-acls", false, "Replicate POSIX ACLs of copied files (Linux)")
+	flag.BoolVar(&preserveOwner, "preserve-owner", false, "Give copied files the owner and group of their source (Unix, usually root only)")
+	flag.StringVar(&userMap, "usermap", "", "With --preserve-owner, translate uids, e.g. 0:1000,1000-1999:100000")
+	flag.StringVar(&groupMap, "groupmap", "", "With --preserve-owner, translate gids like --usermap")
+	flag.IntVar(&readahead, "readahead", 0, "Prefetch up to N upcoming small source files while writing (0 = off)")
+	flag.BoolVar(&ioUring, "io-uring", false, "Experimental: copy file data through io_uring (Linux 5.6+; falls back to normal copying)")
+	flag.BoolVar(&skipLocked, "skip-locked", false, "Skip source files locked by another process")
+	flag.BoolVar(&respectImmutable, "respect-immutable", false, "Do not overwrite target files with the immutable or append-only attribute (Linux)")
+	flag.BoolVar(&detectConcurrent, "detect-concurrent-modification", false, "Warn about source files that changed while being copied and copy them once more")
+	flag.BoolVar(&perDirStats, "per-dir-stats", false, "Print copied/overwritten/deleted counts per top-level directory")
+	flag.IntVar(&maxPathLen, "max-path-len", 0, "Skip files whose target path is longer than N bytes (0 = no limit)")
+	flag.BoolVar(&appendOnly, "append-only", false, "Append only the new tail when a target file is a prefix of its source (growing logs)")
+	flag.BoolVar(&computeChurn, "compute-churn", false, "Sum how many bytes overwritten files grew and shrank in the summary")
+	flag.IntVar(&nice, "nice", 0, "Run at this nice value, e.g. 10 (Linux; 0 = unchanged)")
+	flag.StringVar(&ioNice, "ionice", "default", "I/O scheduling class: default, best-effort (lowest level), idle (Linux)")
+	flag.StringVar(&onStatError, "delete-on-stat-error", "keep", "What --delete-missing does when the source check fails: keep, error (stop deleting), delete (dangerous)")
+	flag.BoolVar(&verifyBeforeDelete, "verify-before-delete", false, "Re-check the source with a fresh lstat right before each delete; keep the file if anything is found")
+	flag.StringVar(&manifest, "manifest", "", "JSON file recording the target state; report what changed since the previous run")
+	flag.StringVar(&versionHeader, "version-header", "", "Regexp finding a version token (first group) in the file header; keep targets whose version is not older")
+	flag.StringVar(&textfileMetrics, "textfile-metrics", "", "Write run metrics to this .prom file for the node_exporter textfile collector")
+	flag.StringVar(&lineEndings, "line-endings", "preserve", "Convert line endings of copied text files: preserve, lf, crlf")
+	flag.StringVar(&heartbeatFile, "heartbeat-file", "", "Rewrite this file with the time and counters while the run progresses")
+	flag.DurationVar(&heartbeatInterval, "heartbeat-interval", 10*time.Second, "Minimum time between heartbeat file updates")
+	flag.BoolVar(&dirsOnly, "dirs-only", false, "Replicate the directory tree only, copying no files")
+	flag.Var(&structureOnly, "structure-only", "Create directories matching this pattern (e.g. cache) but sync nothing below them (repeatable)")
+	flag.BoolVar(&repairPerms, "repair-perms-only", false, "Only set the permission bits of target files that differ from their source; copy and delete nothing")
+	flag.StringVar(&targetPolicy, "target-policy", "", "Finally give every target file and directory this mode and owner, e.g. mode=0640,dirmode=0750,owner=www-data,group=www-data")
+	flag.BoolVar(&warnNewerTarget, "warn-newer-target", false, "Log a conflict for every target file overwritten although newer than its source")
+	flag.StringVar(&onConflict, "on-conflict", "overwrite", "Target files newer than their source: overwrite, or keep-both (renamed after --conflict-name-template)")
+	flag.StringVar(&conflictNameTemplate, "conflict-name-template", sync.DefaultConflictNameTemplate, "Name of the copies kept by --on-conflict keep-both, with {name}, {ext}, {host} and {ts}")
+	flag.BoolVar(&deferMetadata, "defer-metadata", false, "Set the mod-times of copied files in one sorted pass after copying")
+	flag.BoolVar(&preserveAllTimes, "preserve-all-times", false, "Give copied files the access time of their source as well as its mod-time")
+	flag.StringVar(&csvReport, "csv-report", "", "Write one action,rel,bytes,error row per processed file to this CSV file")
+	flag.BoolVar(&fixCase, "fix-case", false, "On a case-insensitive target, rename identical files to the source's case (File.txt -> file.txt)")
+	flag.IntVar(&maxDeletes, "max-deletes", 0, "With --delete-missing, delete at most N files per run (0 = no limit)")
+	flag.StringVar(&deleteLimit, "delete-limit", "abort", "Over --max-deletes: abort (delete nothing) or stop (delete up to the limit)")
+	flag.IntVar(&maxTargetFiles, "max-target-files", 0, "Check after the run that the target holds at most N files (0 = no limit)")
+	flag.StringVar(&targetLimit, "target-limit", "report", "Over --max-target-files: report (an error) or prune (delete the oldest files)")
+	flag.Int64Var(&maxTotalBytes, "max-total-bytes", 0, "Stop copying once N bytes were copied in this run, leaving the rest for the next (0 = no limit)")
+	flag.BoolVar(&reconcile, "reconcile", false, "Compare source and target again after the run and report remaining differences as errors")
+	flag.Float64Var(&postSyncSample, "post-sync-sample", 0, "After the run, read back this fraction (0-1) of synced target files and report those whose content differs from the source")
+	flag.StringVar(&logFile, "log-file", "", "Append log output to this file (\"-\" = stdout; default stderr)")
+	flag.StringVar(&logFormat, "log-format", "text", "Log format: text or json (one object per line)")
+	flag.BoolVar(&summaryOnly, "summary-only", false, "Log no per-file lines, only errors, warnings and the final summary line (e.g. for cron mails)")
+	flag.BoolVar(&quietOnSuccess, "quiet-on-success", false, "With --summary-only (required), log no summary when the run had no errors")
+	flag.StringVar(&reportFormat, "report-format", "text", "Also write the final report to stdout: text (log summary only), json or msgpack")
+	flag.BoolVar(&checkFreeSpace, "check-free-space", false, "Abort before changing anything when the target lacks the space or inodes the run needs (Linux)")
+	flag.DurationVar(&pauseOnENOSPC, "pause-on-enospc", 0, "When the target is full, wait this long and retry the file (0 = fail right away)")
+	flag.IntVar(&enospcRetries, "enospc-retries", 3, "How often --pause-on-enospc retries a file")
+	flag.IntVar(&retryWholeSync, "retry-whole-sync", 0, "Run the whole sync again up to N times while a pass ends with errors")
+	flag.DurationVar(&retryWholeSyncDelay, "retry-whole-sync-delay", 10*time.Second, "Wait before each pass of --retry-whole-sync")
+	flag.DurationVar(&mtimeTolerance, "mtime-tolerance", 0, "Treat mod-times at most this far apart as equal, e.g. 1s or 2s for FAT (0 = whole-second comparison)")
+	flag.StringVar(&renameStrategy, "rename-strategy", "atomic", "How copies replace target files: atomic, remove-then-rename, copy-in-place (network mounts)")
+	flag.StringVar(&tempDevice, "temp-device", "assume", "Check temp files share the target's device before renaming: assume (no check), error, or copy (in place)")
+	flag.IntVar(&busyTargetRetries, "busy-target-retries", 0, "Retry replacing a target file that another process has open or mapped up to N times (Windows)")
+	flag.DurationVar(&busyTargetBackoff, "busy-target-backoff", 100*time.Millisecond, "First wait of --busy-target-retries, doubled after each retry")
+	flag.BoolVar(&busyTargetReplaceAside, "busy-target-replace-aside", false, "Then move a busy target aside and delete it once closed (Windows; mapped or delete-shared files)")
+	flag.BoolVar(&streamVisible, "stream-visible", false, "Write copies directly to the target, synced periodically, so readers tailing it see progress (not atomic)")
+	flag.Int64Var(&streamVisibleMinSize, "stream-visible-min-size", 0, "With --stream-visible, stream only files of at least N bytes")
+	flag.BoolVar(&skipReasons, "skip-reasons", false, "Break the skipped count in the summary down by reason")
+	flag.Float64Var(&spotCheck, "spot-check", 0, "Hash this fraction (0-1) of files that look identical and overwrite those whose content differs")
+	flag.StringVar(&targetSymlink, "target-symlink", "follow", "When the target is a symlink to a directory: follow, replace (with a real directory), error")
+	flag.StringVar(&encryptPassFile, "encrypt-passphrase-file", "", "Encrypt copied files with AES-GCM under the passphrase read from this file")
+	flag.StringVar(&decryptFile, "decrypt", "", "Decrypt this file written with --encrypt-passphrase-file to stdout and exit")
+	flag.StringVar(&fileListPath, "file-list", "", "Sync only the source paths listed in this file, one per line (\"-\" = stdin), instead of walking the tree")
+	flag.StringVar(&gitSince, "git-since", "", "Sync only the files changed between this git revision and HEAD of the source repository, deleting removed ones with --delete-missing")
+	flag.BoolVar(&dereferenceRoots, "dereference-roots", false, "Resolve symlinks in the source and target paths before the run and sync the real directories")
+	flag.BoolVar(&guardWalk, "guard-walk", false, "Skip source directories that resolve into the target or were already walked, e.g. after a symlink swap")
 	flag.Parse()
 
-	if src == "" || dst == "" {
-		fmt.Fprintln(os.Stderr, "Usage: sync --source <dir> --target <dir> [--delete-missing]")
+	var passphrase string
+	if encryptPassFile != "" {
+		b, err := os.ReadFile(encryptPassFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		passphrase = strings.TrimRight(string(b), "\r\n")
+		if passphrase == "" {
+			fmt.Fprintf(os.Stderr, "empty passphrase in %s\n", encryptPassFile)
+			os.Exit(2)
+		}
+	}
+	if decryptFile != "" {
+		if passphrase == "" {
+			fmt.Fprintln(os.Stderr, "--decrypt needs --encrypt-passphrase-file")
+			os.Exit(2)
+		}
+		if err := decrypt(decryptFile, passphrase, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(srcs) == 0 || len(dsts) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: sync --source <dir> [--source <dir>...] --target <dir> [--target <dir>...] [options]")
 		flag.PrintDefaults()
 		os.Exit(2)
 	}
 
-	if err := validators.MustDir(src); err != nil {
-		log.Fatalf("source error: %v", err)
+	if quietOnSuccess && !summaryOnly {
+		fmt.Fprintln(os.Stderr, "--quiet-on-success needs --summary-only")
+		os.Exit(2)
+	}
+
+	if err := checkReportFormat(reportFormat, logFile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	logger, logCloser, err := newLogger(logFile, logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	if err := validators.MustDir(dst); err != nil {
-		log.Fatalf("target error: %v", err)
+	if logCloser != nil {
+		defer logCloser.Close()
 	}
+	// The summary below goes through the standard logger as well
+	log.SetOutput(logger.Writer())
+	log.SetFlags(logger.Flags())
 
-	rep := sync.Sync(sync.Options{
-		Source:        src,
-		Target:        dst,
-		DeleteMissing: deleteMissing,
-		Logger:        log.Default(),
-	})
+	order, err := sync.ParseWalkOrder(walkOrder)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	log.Printf("DONE – copied=%d overwritten=%d deleted=%d skipped=%d errors=%d",
-		rep.Copied, rep.Overwritten, rep.Deleted, rep.Skipped, len(rep.Errors))
+	sanitize, err := sync.ParseSanitizeMode(sanitizeNames)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	conflict, err := sync.ParseSourceConflict(sourceConflict)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	statErrPolicy, err := sync.ParseStatErrorPolicy(onStatError)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	ioClass, err := sync.ParseIOClass(ioNice)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	deleteLimitPolicy, err := sync.ParseDeleteLimitPolicy(deleteLimit)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	targetLimitPolicy, err := sync.ParseTargetLimitPolicy(targetLimit)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	conflictRes, err := sync.ParseConflictResolution(onConflict)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	var policy *sync.TargetPolicy
+	if targetPolicy != "" {
+		if policy, err = sync.ParseTargetPolicy(targetPolicy); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	tempDevicePolicy, err := sync.ParseTempDevicePolicy(tempDevice)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	renameStrat, err := sync.ParseRenameStrategy(renameStrategy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	targetLinkPolicy, err := sync.ParseTargetSymlinkPolicy(targetSymlink)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	eol, err := sync.ParseLineEndings(lineEndings)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	uidMap, err := sync.ParseIDMap(userMap)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	gidMap, err := sync.ParseIDMap(groupMap)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var fileList []string
+	if fileListPath != "" {
+		if fileList, err = readFileList(fileListPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
 
-	if len(rep.Errors) > 0 {
-		log.Println("Encountered errors:")
-		for _, e := range rep.Errors {
-			log.Printf("  - %v", e)
+	for _, src := range srcs {
+		if sync.DetectArchive(src) != sync.NotArchive {
+			continue
 		}
+		if err := validators.MustDir(src); err != nil {
+			log.Fatalf("source error: %v", err)
+		}
+	}
+	for _, dst := range dsts {
+		if sync.ArchiveTargetKind(dst) != sync.NotArchive {
+			continue
+		}
+		if err := validators.MustDir(dst); err != nil {
+			log.Fatalf("target error: %v", err)
+		}
+	}
+
+	opt := sync.Options{
+		Sources:                      srcs,
+		SourceConflict:               conflict,
+		Target:                       dsts[0],
+		DeleteMissing:                deleteMissing,
+		ReportOrphans:                reportOrphans,
+		CleanTarget:                  cleanTarget,
+		SkipHidden:                   skipHidden,
+		UseDefaultExcludes:           defaultExcludes,
+		MaxStoredErrors:              maxErrors,
+		CategorizeErrors:             categorizeErrors,
+		CoalesceErrors:               coalesceErrors,
+		TrackAllErrors:               trackAllErrors,
+		SubtreeCheck:                 subtreeCheck,
+		ChecksumDB:                   checksumDB,
+		Transactional:                transactional,
+		OneFileSystem:                oneFileSystem,
+		WalkOrder:                    order,
+		CompletionMarker:             completionMarker,
+		IgnoreModTime:                ignoreModTime,
+		CompareSizeOnly:              sizeOnly,
+		StoreChecksumXattr:           checksumXattr,
+		TrashDir:                     trashDir,
+		TrashTimestamped:             trashTimestamped,
+		TrashRetention:               trashRetention,
+		SanitizeNames:                sanitize,
+		DryRun:                       dryRun,
+		CleanStaleTemps:              cleanStaleTemps,
+		StaleTempAge:                 staleTempAge,
+		TargetKnownEmpty:             targetEmpty,
+		Syslog:                       useSyslog,
+		SyslogFacility:               syslogFacility,
+		SyslogTag:                    syslogTag,
+		PreserveACLs:                 preserveACLs,
+		PreserveOwner:                preserveOwner,
+		UIDMap:                       uidMap,
+		GIDMap:                       gidMap,
+		Readahead:                    readahead,
+		IOUring:                      ioUring,
+		SkipLockedFiles:              skipLocked,
+		RespectImmutable:             respectImmutable,
+		PerDirStats:                  perDirStats,
+		MaxPathLen:                   maxPathLen,
+		AppendOnly:                   appendOnly,
+		ComputeChurn:                 computeChurn,
+		Nice:                         nice,
+		IONice:                       ioClass,
+		DeleteOnStatError:            statErrPolicy,
+		VerifyBeforeDelete:           verifyBeforeDelete,
+		Manifest:                     manifest,
+		CSVReport:                    csvReport,
+		DeferMetadata:                deferMetadata,
+		PreserveAllTimes:             preserveAllTimes,
+		WarnOnNewerTarget:            warnNewerTarget,
+		OnConflict:                   conflictRes,
+		ConflictNameTemplate:         conflictNameTemplate,
+		DirsOnly:                     dirsOnly,
+		StructureOnlyDirs:            structureOnly,
+		RepairPermsOnly:              repairPerms,
+		TargetPolicy:                 policy,
+		HeartbeatFile:                heartbeatFile,
+		HeartbeatInterval:            heartbeatInterval,
+		LineEndings:                  eol,
+		TextfileMetrics:              textfileMetrics,
+		HashExtensions:               hashExts,
+		HashConcurrency:              hashConcurrency,
+		NoHashExtensions:             noHashExts,
+		VersionHeaderRegex:           versionHeader,
+		EncryptPassphrase:            passphrase,
+		FixCase:                      fixCase,
+		MaxDeletes:                   maxDeletes,
+		MaxTotalBytes:                maxTotalBytes,
+		DeleteLimit:                  deleteLimitPolicy,
+		MaxTargetFiles:               maxTargetFiles,
+		TargetLimit:                  targetLimitPolicy,
+		ReconcileAfter:               reconcile,
+		PostSyncSample:               postSyncSample,
+		ModTimeTolerance:             mtimeTolerance,
+		DetectConcurrentModification: detectConcurrent,
+		CheckFreeSpace:               checkFreeSpace,
+		PauseOnENOSPC:                pauseOnENOSPC,
+		ENOSPCRetries:                enospcRetries,
+		RetryWholeSync:               retryWholeSync,
+		RetryWholeSyncDelay:          retryWholeSyncDelay,
+		RenameStrategy:               renameStrat,
+		TempDevice:                   tempDevicePolicy,
+		StreamVisible:                streamVisible,
+		StreamVisibleMinSize:         streamVisibleMinSize,
+		BusyTargetRetries:            busyTargetRetries,
+		BusyTargetBackoff:            busyTargetBackoff,
+		BusyTargetReplaceAside:       busyTargetReplaceAside,
+		CollectSkipReasons:           skipReasons,
+		SpotCheckRatio:               spotCheck,
+		TargetSymlink:                targetLinkPolicy,
+		DereferenceRoots:             dereferenceRoots,
+		GuardWalk:                    guardWalk,
+		SidecarIgnoreSuffix:          sidecarIgnore,
+		FileList:                     fileList,
+		GitSince:                     gitSince,
+		Logger:                       log.Default(),
+	}
+	if len(dsts) > 1 {
+		opt.Targets = dsts
+	}
+	if summaryOnly {
+		opt.Logger = summaryOnlyLogger(log.Default())
+	}
+
+	if estimate {
+		est, err := sync.New(opt).Estimate()
+		fmt.Printf("copy: %d files, %d bytes\noverwrite: %d files, %d bytes\ndelete: %d files, %d bytes\n",
+			est.CopyFiles, est.CopyBytes, est.OverwriteFiles, est.OverwriteBytes, est.DeleteFiles, est.DeleteBytes)
+		if err != nil {
+			log.Printf("estimate incomplete: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if verify {
+		os.Exit(verifyOnly(opt, os.Stdout))
+	}
+
+	if planOut != "" {
+		if err := writePlanFile(opt, planOut, os.Stdout); err != nil {
+			log.Print(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var rep *sync.Report
+	if planFirst {
+		if rep = planThenApply(opt, yes, os.Stdin, os.Stderr); rep == nil {
+			return
+		}
+	} else if applyPlan != "" {
+		var err error
+		if rep, err = applyPlanFile(opt, applyPlan); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	} else {
+		rep = sync.Sync(opt)
+	}
+
+	if err := writeReport(os.Stdout, rep, reportFormat); err != nil {
+		log.Printf("ERR: write report: %v", err)
+	}
+	if summaryOnly && quietOnSuccess && rep.ErrorCount() == 0 {
+		return
+	}
+	logSummary(log.Default(), rep, dsts, summaryOnly)
+	if rep.ErrorCount() > 0 {
 		os.Exit(1)
 	}
 }
+
+// logSummary logs the end-of-run summary and the errors, if any. With summaryOnly it logs
+// just the DONE line and the errors.
+func logSummary(l *log.Logger, rep *sync.Report, dsts []string, summaryOnly bool) {
+	if !summaryOnly {
+		for _, dst := range dsts {
+			if r, ok := rep.PerTarget[dst]; ok {
+				l.Printf("TARGET %s – %s", dst, r)
+			}
+		}
+	}
+	l.Printf("DONE – %s", rep)
+	if !summaryOnly {
+		if table := rep.DirStatsTable(); table != "" {
+			l.Printf("Per-directory changes:\n%s", table)
+		}
+		if c := rep.Changes; c != nil {
+			l.Printf("Changes since last run: %d added, %d modified, %d removed", len(c.Added), len(c.Modified), len(c.Removed))
+			for _, name := range c.Added {
+				l.Printf("  + %s", name)
+			}
+			for _, name := range c.Modified {
+				l.Printf("  ~ %s", name)
+			}
+			for _, name := range c.Removed {
+				l.Printf("  - %s", name)
+			}
+		}
+	}
+
+	if rep.ErrorCount() == 0 {
+		return
+	}
+	l.Println("Encountered errors:")
+	if counts := rep.ErrorCategoryCounts(); counts != "" {
+		l.Printf("  by category: %s", counts)
+	}
+	if len(rep.ErrorSummary) > 0 {
+		// The summary covers every error, stored or not
+		for _, g := range rep.ErrorSummary {
+			if g.Count > 1 {
+				l.Printf("  - %s (%d times)", g.Message, g.Count)
+			} else {
+				l.Printf("  - %s", g.Message)
+			}
+		}
+		return
+	}
+	for _, e := range rep.Errors {
+		l.Printf("  - %v", e)
+	}
+	if rep.DroppedErrors > 0 {
+		l.Printf("  ... and %d more", rep.DroppedErrors)
+	}
+}
+
+// readFileList reads the --file-list paths, one per line, skipping blank lines.
+func readFileList(name string) ([]string, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read file list: %w", err)
+	}
+	var list []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			list = append(list, filepath.ToSlash(line))
+		}
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("file list %s is empty", name)
+	}
+	return list, nil
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
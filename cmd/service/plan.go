@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/e-wrobel/sync-service/internal/sync"
+)
+
+// planThenApply performs a dry run, prints the planned changes and runs the real sync
+// only after the user confirms (or when yes is set). It returns nil if the user declines.
+func planThenApply(opt sync.Options, yes bool, in io.Reader, out io.Writer) *sync.Report {
+	dry := opt
+	dry.DryRun = true
+	plan := sync.Sync(dry)
+
+	fmt.Fprintf(out, "Planned changes: copy=%d overwrite=%d delete=%d errors=%d\n",
+		plan.Copied, plan.Overwritten, plan.Deleted, plan.ErrorCount())
+	if plan.Copied+plan.Overwritten+plan.Deleted == 0 {
+		fmt.Fprintln(out, "Nothing to do.")
+		return plan
+	}
+	if !yes && !confirm(in, out, "Apply these changes? [y/N] ") {
+		fmt.Fprintln(out, "Aborted, nothing was changed.")
+		return nil
+	}
+	return sync.Sync(opt)
+}
+
+// confirm prints prompt and reports whether the answer read from in is yes.
+func confirm(in io.Reader, out io.Writer, prompt string) bool {
+	fmt.Fprint(out, prompt)
+	line, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// writePlanFile plans the sync and writes the actions to path for a later --apply,
+// printing the number of planned changes to out.
+func writePlanFile(opt sync.Options, path string, out io.Writer) error {
+	actions, err := sync.Plan(opt)
+	if err != nil {
+		return fmt.Errorf("plan incomplete, not written: %w", err)
+	}
+	counts := map[sync.ActionType]int{}
+	for _, a := range actions {
+		counts[a.Type]++
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := sync.WritePlan(f, actions); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Planned changes written to %s: copy=%d overwrite=%d delete=%d mkdir=%d\n", path,
+		counts[sync.ActionCopy], counts[sync.ActionOverwrite], counts[sync.ActionDelete], counts[sync.ActionMkdir])
+	return nil
+}
+
+// applyPlanFile applies the actions of a plan written by --plan-out.
+func applyPlanFile(opt sync.Options, path string) (*sync.Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	actions, err := sync.ReadPlan(f)
+	if err != nil {
+		return nil, err
+	}
+	return sync.Apply(actions, opt), nil
+}
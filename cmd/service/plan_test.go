@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/e-wrobel/sync-service/internal/sync"
+)
+
+func TestPlanThenApply(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		yes       bool
+		wantApply bool
+	}{
+		{name: "confirmed", input: "y\n", wantApply: true},
+		{name: "confirmed_long", input: "YES\n", wantApply: true},
+		{name: "declined", input: "n\n", wantApply: false},
+		{name: "empty_answer", input: "\n", wantApply: false},
+		{name: "no_input", input: "", wantApply: false},
+		{name: "yes_flag", input: "", yes: true, wantApply: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := t.TempDir()
+			dst := t.TempDir()
+			if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			var out bytes.Buffer
+			opt := sync.Options{Source: src, Target: dst, Logger: log.New(io.Discard, "", 0)}
+			rep := planThenApply(opt, tt.yes, strings.NewReader(tt.input), &out)
+
+			if !strings.Contains(out.String(), "Planned changes: copy=1") {
+				t.Fatalf("expected plan summary, got %q", out.String())
+			}
+			_, err := os.Stat(filepath.Join(dst, "a.txt"))
+			if tt.wantApply {
+				if rep == nil || rep.Copied != 1 || err != nil {
+					t.Fatalf("expected apply, rep=%v err=%v", rep, err)
+				}
+				return
+			}
+			if rep != nil || !os.IsNotExist(err) {
+				t.Fatalf("expected no apply, rep=%v err=%v", rep, err)
+			}
+		})
+	}
+}
+
+func TestPlanFile(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dst, "old.txt"), []byte("old"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	opt := sync.Options{Source: src, Target: dst, DeleteMissing: true, Logger: log.New(io.Discard, "", 0)}
+
+	var out bytes.Buffer
+	if err := writePlanFile(opt, planPath, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "copy=1 overwrite=0 delete=1") {
+		t.Fatalf("unexpected summary %q", out.String())
+	}
+	if _, err := os.Stat(filepath.Join(dst, "old.txt")); err != nil {
+		t.Fatalf("--plan-out changed the target: %v", err)
+	}
+
+	rep, err := applyPlanFile(opt, planPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Errors) != 0 || rep.Copied != 1 || rep.Deleted != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if _, err := applyPlanFile(opt, filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("applying a missing plan file must fail")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/e-wrobel/sync-service/internal/sync"
+)
+
+// checkReportFormat validates --report-format; the binary format cannot share stdout with the log.
+func checkReportFormat(format, logFile string) error {
+	switch format {
+	case "text":
+		return nil
+	case "json", "msgpack":
+		if logFile == "-" {
+			return fmt.Errorf("--report-format %s writes to stdout; choose another --log-file", format)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
+
+// writeReport writes rep to out in the sync.ReportMessage schema: one JSON object per line,
+// or a single MessagePack map. The text format is the log summary and writes nothing here.
+func writeReport(out io.Writer, rep *sync.Report, format string) error {
+	var b []byte
+	var err error
+	switch format {
+	case "text":
+		return nil
+	case "json":
+		b, err = json.Marshal(rep.Message())
+		b = append(b, '\n')
+	case "msgpack":
+		b, err = rep.Message().MarshalMsgpack()
+	default:
+		err = fmt.Errorf("unknown report format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(b)
+	return err
+}
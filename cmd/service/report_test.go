@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/e-wrobel/sync-service/internal/sync"
+)
+
+func TestWriteReport(t *testing.T) {
+	rep := &sync.Report{Copied: 2, Deleted: 1, Errors: []error{errors.New("copy a: boom")}}
+	want := rep.Message()
+
+	var out bytes.Buffer
+	if err := writeReport(&out, rep, "msgpack"); err != nil {
+		t.Fatal(err)
+	}
+	var got sync.ReportMessage
+	if err := got.UnmarshalMsgpack(out.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("msgpack: got %+v want %+v", got, want)
+	}
+
+	out.Reset()
+	if err := writeReport(&out, rep, "json"); err != nil {
+		t.Fatal(err)
+	}
+	got = sync.ReportMessage{}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("json: got %+v want %+v", got, want)
+	}
+
+	out.Reset()
+	if err := writeReport(&out, rep, "text"); err != nil || out.Len() != 0 {
+		t.Fatalf("text wrote %q, err=%v", out.String(), err)
+	}
+	if err := checkReportFormat("msgpack", "-"); err == nil {
+		t.Fatal("expected msgpack on the stdout log to be rejected")
+	}
+	if err := checkReportFormat("xml", ""); err == nil {
+		t.Fatal("expected an unknown format to be rejected")
+	}
+}
+
+func TestLogSummaryOnly(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out bytes.Buffer
+	l := log.New(&out, "", 0)
+	// As main does with --summary-only
+	rep := sync.Sync(sync.Options{Source: src, Target: dst, Logger: summaryOnlyLogger(l)})
+	logSummary(l, rep, []string{dst}, true)
+	if lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); len(lines) != 1 || !strings.HasPrefix(lines[0], "DONE – ") {
+		t.Fatalf("expected only the summary line, got %q", out.String())
+	}
+
+	out.Reset()
+	rep.Errors = []error{errors.New("copy c: boom")}
+	logSummary(l, rep, []string{dst}, true)
+	if !strings.Contains(out.String(), "Encountered errors:\n  - copy c: boom\n") {
+		t.Fatalf("expected the error list, got %q", out.String())
+	}
+
+	// Errors and warnings of the run are kept, also in dry runs
+	out.Reset()
+	sl := summaryOnlyLogger(l)
+	sl.Printf("COPY: a")
+	sl.Printf("ERR: copy b: boom")
+	dry := log.New(sl.Writer(), "DRY-RUN ", 0)
+	dry.Printf("DELETE: c")
+	dry.Printf("WARN: d")
+	if want := "ERR: copy b: boom\nDRY-RUN WARN: d\n"; out.String() != want {
+		t.Fatalf("got %q want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	gosync "sync"
+	"time"
+
+	"github.com/e-wrobel/sync-service/internal/sync"
+	"github.com/e-wrobel/sync-service/internal/validators"
+)
+
+// maxRequestBody caps the size of a POST /sync body.
+const maxRequestBody = 1 << 20
+
+// runServer implements the server subcommand: it serves the HTTP API until the listener fails.
+func runServer(args []string) int {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	addr := fs.String("listen", "127.0.0.1:8080", "Address to serve the HTTP API on")
+	readTimeout := fs.Duration("read-timeout", 30*time.Second, "Longest time to read a request, headers and body")
+	writeTimeout := fs.Duration("write-timeout", time.Hour, "Longest time from reading a request to writing its response, i.e. the longest sync whose report is returned")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           newServer(log.Default()),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       *readTimeout,
+		WriteTimeout:      *writeTimeout,
+	}
+	log.Printf("Serving sync API on %s", *addr)
+	if err := srv.ListenAndServe(); err != nil {
+		log.Printf("server: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// syncRequest is the JSON body of POST /sync.
+type syncRequest struct {
+	Source             string   `json:"source"`
+	Sources            []string `json:"sources"`
+	Target             string   `json:"target"`
+	DeleteMissing      bool     `json:"delete_missing"`
+	DryRun             bool     `json:"dry_run"`
+	SkipHidden         bool     `json:"skip_hidden"`
+	UseDefaultExcludes bool     `json:"default_excludes"`
+	IgnoreModTime      bool     `json:"ignore_mtime"`
+	CompareSizeOnly    bool     `json:"size_only"`
+	Transactional      bool     `json:"transactional"`
+	MaxDeletes         int      `json:"max_deletes"`
+}
+
+// syncResponse is the report returned by POST /sync.
+type syncResponse struct {
+	Copied        int      `json:"copied"`
+	Overwritten   int      `json:"overwritten"`
+	Deleted       int      `json:"deleted"`
+	Skipped       int      `json:"skipped"`
+	BytesCopied   int64    `json:"bytes_copied"`
+	Errors        []string `json:"errors"`
+	DroppedErrors int      `json:"dropped_errors"`
+	Summary       string   `json:"summary"`
+}
+
+// server drives syncs over HTTP, one at a time per target.
+type server struct {
+	logger *log.Logger
+	mux    *http.ServeMux
+	mu     gosync.Mutex
+	busy   map[string]bool
+}
+
+func newServer(logger *log.Logger) *server {
+	s := &server{logger: logger, mux: http.NewServeMux(), busy: map[string]bool{}}
+	s.mux.HandleFunc("/health", s.handleHealth)
+	s.mux.HandleFunc("/sync", s.handleSync)
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mux.ServeHTTP(w, req)
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("use GET"))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *server) handleSync(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("use POST"))
+		return
+	}
+	var body syncRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decode request: %w", err))
+		return
+	}
+	opt, err := body.options()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	target := targetKey(opt.Target)
+	if !s.acquire(target) {
+		writeError(w, http.StatusConflict, fmt.Errorf("a sync of %s is already running", opt.Target))
+		return
+	}
+	defer s.release(target)
+
+	opt.Logger = s.logger
+	// A client that hangs up cancels its run
+	rep, err := sync.NewService().Sync(req.Context(), opt)
+	if rep == nil {
+		s.logger.Printf("CANCELLED %s – %v", opt.Target, err)
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	s.logger.Printf("DONE %s – %s", opt.Target, rep)
+
+	resp := syncResponse{
+		Copied:        rep.Copied,
+		Overwritten:   rep.Overwritten,
+		Deleted:       rep.Deleted,
+		Skipped:       rep.Skipped,
+		BytesCopied:   rep.BytesCopied,
+		Errors:        []string{},
+		DroppedErrors: rep.DroppedErrors,
+		Summary:       rep.String(),
+	}
+	for _, e := range rep.Errors {
+		resp.Errors = append(resp.Errors, e.Error())
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// options validates the request like the command line and turns it into sync options.
+func (b syncRequest) options() (sync.Options, error) {
+	sources := b.Sources
+	if b.Source != "" {
+		sources = append([]string{b.Source}, sources...)
+	}
+	if len(sources) == 0 || b.Target == "" {
+		return sync.Options{}, errors.New("source and target are required")
+	}
+	for _, src := range sources {
+		if sync.DetectArchive(src) != sync.NotArchive {
+			continue
+		}
+		if err := validators.MustDir(src); err != nil {
+			return sync.Options{}, fmt.Errorf("source error: %w", err)
+		}
+	}
+	if sync.ArchiveTargetKind(b.Target) == sync.NotArchive {
+		if err := validators.MustDir(b.Target); err != nil {
+			return sync.Options{}, fmt.Errorf("target error: %w", err)
+		}
+	}
+	return sync.Options{
+		Sources:            sources,
+		Target:             b.Target,
+		DeleteMissing:      b.DeleteMissing,
+		DryRun:             b.DryRun,
+		SkipHidden:         b.SkipHidden,
+		UseDefaultExcludes: b.UseDefaultExcludes,
+		IgnoreModTime:      b.IgnoreModTime,
+		CompareSizeOnly:    b.CompareSizeOnly,
+		Transactional:      b.Transactional,
+		MaxDeletes:         b.MaxDeletes,
+	}, nil
+}
+
+// targetKey names target for the busy set, so that relative paths and symlinks to the same
+// target collide. An archive that does not exist yet is named through its parent directory.
+func targetKey(target string) string {
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return filepath.Clean(target)
+	}
+	if p, err := filepath.EvalSymlinks(abs); err == nil {
+		return p
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		return filepath.Join(dir, filepath.Base(abs))
+	}
+	return abs
+}
+
+// acquire marks target busy and reports whether it was free.
+func (s *server) acquire(target string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.busy[target] {
+		return false
+	}
+	s.busy[target] = true
+	return true
+}
+
+func (s *server) release(target string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.busy, target)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
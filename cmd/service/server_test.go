@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	s := httptest.NewServer(newServer(log.New(io.Discard, "", 0)))
+	defer s.Close()
+
+	post := func(t *testing.T, body any) (*http.Response, map[string]any) {
+		t.Helper()
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.Post(s.URL+"/sync", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		return resp, out
+	}
+
+	t.Run("health", func(t *testing.T) {
+		resp, err := http.Get(s.URL + "/health")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		src, dst := t.TempDir(), t.TempDir()
+		if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		resp, out := post(t, map[string]any{"source": src, "target": dst})
+		if resp.StatusCode != http.StatusOK || out["copied"] != 1.0 || len(out["errors"].([]any)) != 0 {
+			t.Fatalf("status %d, report %v", resp.StatusCode, out)
+		}
+		if _, err := os.Stat(filepath.Join(dst, "a.txt")); err != nil {
+			t.Fatalf("file not copied: %v", err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, body := range []any{
+			map[string]any{"target": t.TempDir()},
+			map[string]any{"source": filepath.Join(t.TempDir(), "missing"), "target": t.TempDir()},
+			map[string]any{"source": t.TempDir(), "target": t.TempDir(), "bogus": true},
+		} {
+			if resp, out := post(t, body); resp.StatusCode != http.StatusBadRequest || out["error"] == nil {
+				t.Fatalf("%v: status %d, body %v", body, resp.StatusCode, out)
+			}
+		}
+		big := map[string]any{"source": t.TempDir(), "target": t.TempDir(), "sources": []string{strings.Repeat("x", maxRequestBody)}}
+		if resp, out := post(t, big); resp.StatusCode != http.StatusBadRequest || !strings.Contains(fmt.Sprint(out["error"]), "too large") {
+			t.Fatalf("oversized body: status %d, body %v", resp.StatusCode, out)
+		}
+	})
+}
+
+func TestServerRejectsOverlappingSyncs(t *testing.T) {
+	srv := newServer(log.New(io.Discard, "", 0))
+	src, dst := t.TempDir(), t.TempDir()
+	// A sync of dst is in progress
+	if !srv.acquire(targetKey(dst)) {
+		t.Fatal("target unexpectedly busy")
+	}
+
+	body := fmt.Sprintf(`{"source": %q, "target": %q}`, src, dst)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/sync", strings.NewReader(body)))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body)
+	}
+
+	srv.release(targetKey(dst))
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/sync", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 once released, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestServerBusyKeyResolvesTarget(t *testing.T) {
+	srv := newServer(log.New(io.Discard, "", 0))
+	src, dst := t.TempDir(), t.TempDir()
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(dst, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	if !srv.acquire(targetKey(dst)) {
+		t.Fatal("target unexpectedly busy")
+	}
+	defer srv.release(targetKey(dst))
+
+	// A symlink to a busy target is the same target
+	body := fmt.Sprintf(`{"source": %q, "target": %q}`, src, link)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/sync", strings.NewReader(body)))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestServerCancelledRequest(t *testing.T) {
+	srv := newServer(log.New(io.Discard, "", 0))
+	src, dst := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A client gone before the run starts copies nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	body := fmt.Sprintf(`{"source": %q, "target": %q}`, src, dst)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/sync", strings.NewReader(body)).WithContext(ctx))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "a.txt")); !os.IsNotExist(err) {
+		t.Fatalf("file copied by a cancelled request: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/e-wrobel/sync-service/internal/sync"
+)
+
+// exitOutOfSync is the exit code of --verify-only when the target differs from the source.
+// It is distinct from 1 (errors) and 2 (usage) so monitoring can tell them apart.
+const exitOutOfSync = 3
+
+// verifyOnly compares source and target(s) without writing anything, prints every differing path
+// to out and returns the exit code: 0 when in sync, exitOutOfSync when anything differs,
+// and 1 when errors kept the comparison from completing.
+func verifyOnly(opt sync.Options, out io.Writer) int {
+	v, err := sync.New(opt).Verify()
+	for _, p := range v.Missing {
+		fmt.Fprintf(out, "MISSING: %s\n", p)
+	}
+	for _, p := range v.Changed {
+		fmt.Fprintf(out, "CHANGED: %s\n", p)
+	}
+	for _, p := range v.Orphans {
+		fmt.Fprintf(out, "ORPHAN: %s\n", p)
+	}
+	if !v.InSync() {
+		fmt.Fprintf(out, "OUT OF SYNC – missing=%d changed=%d orphans=%d\n", len(v.Missing), len(v.Changed), len(v.Orphans))
+		return exitOutOfSync
+	}
+	if err != nil {
+		log.Printf("verify incomplete: %v", err)
+		return 1
+	}
+	fmt.Fprintln(out, "IN SYNC")
+	return 0
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/e-wrobel/sync-service/internal/sync"
+)
+
+func TestVerifyOnly(t *testing.T) {
+	write := func(t *testing.T, p, data string, mtime time.Time) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name     string
+		prepare  func(t *testing.T, src, dst string)
+		wantCode int
+		wantOut  []string
+	}{
+		{
+			name:     "in_sync",
+			prepare:  func(t *testing.T, src, dst string) {},
+			wantCode: 0,
+			wantOut:  []string{"IN SYNC"},
+		},
+		{
+			name: "missing",
+			prepare: func(t *testing.T, src, dst string) {
+				write(t, filepath.Join(src, "new.txt"), "n", mtime)
+			},
+			wantCode: exitOutOfSync,
+			wantOut:  []string{"MISSING: " + filepath.Join("DST", "new.txt")},
+		},
+		{
+			name: "changed",
+			prepare: func(t *testing.T, src, dst string) {
+				write(t, filepath.Join(dst, "dir", "b.txt"), "bb", mtime)
+			},
+			wantCode: exitOutOfSync,
+			wantOut:  []string{"CHANGED: " + filepath.Join("DST", "dir", "b.txt")},
+		},
+		{
+			name: "orphan",
+			prepare: func(t *testing.T, src, dst string) {
+				write(t, filepath.Join(dst, "old.txt"), "o", mtime)
+			},
+			wantCode: exitOutOfSync,
+			wantOut:  []string{"ORPHAN: " + filepath.Join("DST", "old.txt")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dst := t.TempDir(), t.TempDir()
+			for _, root := range []string{src, dst} {
+				write(t, filepath.Join(root, "a.txt"), "a", mtime)
+				write(t, filepath.Join(root, "dir", "b.txt"), "b", mtime)
+			}
+			tt.prepare(t, src, dst)
+			before := snapshotTree(t, dst)
+
+			var out bytes.Buffer
+			code := verifyOnly(sync.Options{Source: src, Target: dst}, &out)
+			if code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d; output:\n%s", code, tt.wantCode, out.String())
+			}
+			got := strings.ReplaceAll(out.String(), dst, "DST")
+			for _, want := range tt.wantOut {
+				if !strings.Contains(got, want) {
+					t.Fatalf("expected %q in output:\n%s", want, got)
+				}
+			}
+			if after := snapshotTree(t, dst); after != before {
+				t.Fatalf("target modified:\nbefore %s\nafter  %s", before, after)
+			}
+		})
+	}
+}
+
+// snapshotTree renders names, sizes and mod-times of every entry below root.
+func snapshotTree(t *testing.T, root string) string {
+	t.Helper()
+	var b strings.Builder
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		fmt.Fprintf(&b, "%s %d %v %v;", rel, info.Size(), info.Mode(), info.ModTime())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	return b.String()
+}
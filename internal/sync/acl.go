@@ -0,0 +1,30 @@
+package sync
+
+// copyACL replicates the POSIX ACL of a copied source file onto dstName when PreserveACLs is set.
+// Only OS-backed source and target trees carry ACLs. Failures are logged and counted
+// in Report.ACLFailures but do not fail the run.
+func (r *runner) copyACL(rel, dstName string) {
+	if !r.opt.PreserveACLs {
+		return
+	}
+	src, ok := r.src.(dirFS)
+	if !ok {
+		return
+	}
+	dst, ok := r.dst.(dirFS)
+	if !ok {
+		return
+	}
+	sp, err := src.path("acl", rel)
+	if err != nil {
+		return
+	}
+	dp, err := dst.path("acl", dstName)
+	if err != nil {
+		return
+	}
+	if err := copyACL(sp, dp); err != nil {
+		r.opt.Logger.Printf("WARN: acl %s: %v", r.dstPath(dstName), err)
+		r.rep.ACLFailures++
+	}
+}
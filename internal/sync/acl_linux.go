@@ -0,0 +1,29 @@
+//go:build linux
+
+package sync
+
+import (
+	"errors"
+	"syscall"
+)
+
+// aclAccessXattr holds the access ACL of a file in the kernel's binary format.
+const aclAccessXattr = "system.posix_acl_access"
+
+// copyACL copies the access ACL of src onto dst, or removes dst's ACL when src has none
+// (a new file may have inherited one from a default ACL of its directory).
+// A source filesystem without ACL support has nothing to copy.
+func copyACL(src, dst string) error {
+	acl, err := getxattr(src, aclAccessXattr)
+	switch {
+	case errors.Is(err, syscall.ENODATA), errors.Is(err, syscall.ENOTSUP):
+		if err := syscall.Removexattr(dst, aclAccessXattr); err != nil &&
+			!errors.Is(err, syscall.ENODATA) && !errors.Is(err, syscall.ENOTSUP) {
+			return err
+		}
+		return nil
+	case err != nil:
+		return err
+	}
+	return syscall.Setxattr(dst, aclAccessXattr, acl, 0)
+}
@@ -0,0 +1,87 @@
+//go:build linux
+
+package sync
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// posixACL encodes an access ACL in the kernel's xattr format: a version header followed by
+// (tag, perm, id) entries sorted by tag.
+func posixACL(uid uint32) []byte {
+	const (
+		userObj  = 0x01
+		user     = 0x02
+		groupObj = 0x04
+		mask     = 0x10
+		other    = 0x20
+		undefID  = ^uint32(0)
+	)
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint32(2))
+	for _, e := range []struct {
+		tag  uint16
+		perm uint16
+		id   uint32
+	}{
+		{userObj, 6, undefID},
+		{user, 4, uid},
+		{groupObj, 4, undefID},
+		{mask, 4, undefID},
+		{other, 0, undefID},
+	} {
+		binary.Write(&b, binary.LittleEndian, e)
+	}
+	return b.Bytes()
+}
+
+func TestPreserveACLs(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	p := filepath.Join(src, "dir", "a.txt")
+	mustWrite(t, p, "alpha")
+	acl := posixACL(12345)
+	if err := syscall.Setxattr(p, aclAccessXattr, acl, 0); err != nil {
+		if errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EPERM) {
+			t.Skipf("POSIX ACLs not supported here: %v", err)
+		}
+		t.Fatal(err)
+	}
+	mustWrite(t, filepath.Join(src, "plain.txt"), "plain")
+
+	for i, transactional := range []bool{false, true} {
+		dst := filepath.Join(dst, fmt.Sprint(i))
+		rep := Sync(Options{Source: src, Target: dst, PreserveACLs: true, Transactional: transactional})
+		if len(rep.Errors) != 0 || rep.ACLFailures != 0 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		got, err := getxattr(filepath.Join(dst, "dir", "a.txt"), aclAccessXattr)
+		if err != nil {
+			t.Fatalf("transactional=%v: read target ACL: %v", transactional, err)
+		}
+		if !bytes.Equal(got, acl) {
+			t.Fatalf("transactional=%v: ACL mismatch: got %x want %x", transactional, got, acl)
+		}
+		if _, err := getxattr(filepath.Join(dst, "plain.txt"), aclAccessXattr); !errors.Is(err, syscall.ENODATA) {
+			t.Fatalf("transactional=%v: expected no ACL on plain.txt, got %v", transactional, err)
+		}
+	}
+}
+
+func TestPreserveACLsOff(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	p := filepath.Join(src, "a.txt")
+	mustWrite(t, p, "alpha")
+	if err := syscall.Setxattr(p, aclAccessXattr, posixACL(12345), 0); err != nil {
+		t.Skipf("POSIX ACLs not supported here: %v", err)
+	}
+	Sync(Options{Source: src, Target: dst})
+	if _, err := getxattr(filepath.Join(dst, "a.txt"), aclAccessXattr); !errors.Is(err, syscall.ENODATA) {
+		t.Fatalf("expected no ACL without PreserveACLs, got %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package sync
+
+// copyACL is a no-op: POSIX ACLs are only replicated on Linux.
+func copyACL(src, dst string) error {
+	return nil
+}
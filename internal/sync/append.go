@@ -0,0 +1,134 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// AppendFS is implemented by targets that can append to an existing file,
+// which Options.AppendOnly needs. DirFS implements it.
+type AppendFS interface {
+	WritableFS
+	// Append opens name for writing at its end.
+	Append(name string) (io.WriteCloser, error)
+}
+
+func (d dirFS) Append(name string) (io.WriteCloser, error) {
+	p, err := d.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(p, os.O_WRONLY|os.O_APPEND, 0)
+}
+
+// appendTail handles an outdated target file in AppendOnly mode: when the target is a
+// strict prefix of the source, only the missing tail is appended. It reports whether the
+// file was handled (appended, or an error was recorded); false means a full copy is needed.
+// Unlike a full copy the append is not atomic, but an interrupted append leaves a prefix
+// that the next run completes.
+func (r *runner) appendTail(rel, dstRel string, info, tst fs.FileInfo) bool {
+	if info.Size() <= tst.Size() || r.txn != nil || r.est != nil || r.ver != nil || r.plan != nil || r.opt.ContentValidator != nil ||
+		r.opt.LineEndings != LineEndingsPreserve || len(r.opt.Transforms) > 0 || r.enc != nil {
+		return false
+	}
+	dst, ok := r.dst.(AppendFS)
+	if !ok {
+		return false
+	}
+	if r.opt.SkipLockedFiles && r.locked(rel) {
+		// copyEntry reports the skip
+		return false
+	}
+	path, targetPath := r.srcPath(rel), r.dstPath(dstRel)
+
+	f, err := r.src.Open(rel)
+	if err != nil {
+		r.opt.Logger.Printf("ERR: append %s -> %s: %v", path, targetPath, err)
+		r.rep.addErr(err)
+		return true
+	}
+	defer f.Close()
+
+	same, err := hasPrefix(f, dst, dstRel, tst.Size())
+	if err != nil {
+		r.opt.Logger.Printf("ERR: append %s -> %s: %v", path, targetPath, err)
+		r.rep.addErr(err)
+		return true
+	}
+	if !same {
+		// Rotated or truncated: the target is no prefix of the source
+		return false
+	}
+
+	tail := info.Size() - tst.Size()
+	if !r.opt.DryRun {
+		err := appendFrom(f, dst, dstRel, tail)
+		if err == nil {
+			// The times are set like those of a full copy, or deferred to the metadata pass
+			err = r.writeTarget(info).Chtimes(dstRel, time.Now(), info.ModTime())
+		}
+		if err != nil {
+			r.opt.Logger.Printf("ERR: append %s -> %s: %v", path, targetPath, err)
+			r.rep.addErr(err)
+			r.record(CSVAppend, dstRel, 0, err)
+			return true
+		}
+		r.deferTimes(dstRel, info)
+	}
+	r.markSynced(rel, dstRel, info)
+	r.rep.BytesCopied += tail
+	if r.opt.ComputeChurn {
+		r.rep.BytesAdded += tail
+	}
+	r.opt.Logger.Printf("APPEND: %s -> %s (%d bytes)", path, targetPath, tail)
+	r.rep.Appended++
+	r.record(CSVAppend, dstRel, tail, nil)
+	return true
+}
+
+// hasPrefix reports whether the first n bytes read from src equal the content of name in dst.
+// src is left positioned after those n bytes.
+func hasPrefix(src io.Reader, dst fs.FS, name string, n int64) (bool, error) {
+	df, err := dst.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer df.Close()
+
+	a := make([]byte, 32*1024)
+	b := make([]byte, len(a))
+	for n > 0 {
+		chunk := int64(len(a))
+		if n < chunk {
+			chunk = n
+		}
+		if _, err := io.ReadFull(src, a[:chunk]); err != nil {
+			return false, fmt.Errorf("read source: %w", err)
+		}
+		if _, err := io.ReadFull(df, b[:chunk]); err != nil {
+			return false, fmt.Errorf("read target: %w", err)
+		}
+		if !bytes.Equal(a[:chunk], b[:chunk]) {
+			return false, nil
+		}
+		n -= chunk
+	}
+	return true, nil
+}
+
+// appendFrom appends the next n bytes of src to name.
+func appendFrom(src io.Reader, dst AppendFS, name string, n int64) error {
+	w, err := dst.Append(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(w, src, n); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
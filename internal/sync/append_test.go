@@ -0,0 +1,108 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendOnly(t *testing.T) {
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	now := old.Add(time.Minute)
+
+	t.Run("append", func(t *testing.T) {
+		src, dst := t.TempDir(), t.TempDir()
+		writeWithModTime(t, filepath.Join(dst, "app.log"), "line1\n", 0o644, old)
+		before, err := os.Stat(filepath.Join(dst, "app.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeWithModTime(t, filepath.Join(src, "app.log"), "line1\nline2\n", 0o644, now)
+
+		rep := Sync(Options{Source: src, Target: dst, AppendOnly: true})
+		if len(rep.Errors) != 0 {
+			t.Fatalf("unexpected errors: %v", rep.Errors)
+		}
+		if rep.Appended != 1 || rep.Overwritten != 0 || rep.BytesCopied != 6 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		after, err := os.Stat(filepath.Join(dst, "app.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !os.SameFile(before, after) {
+			t.Fatalf("expected the target to be appended in place, not replaced")
+		}
+		if b, _ := os.ReadFile(filepath.Join(dst, "app.log")); string(b) != "line1\nline2\n" {
+			t.Fatalf("unexpected content %q", b)
+		}
+		if !after.ModTime().Equal(now) {
+			t.Fatalf("mtime mismatch: got %v want %v", after.ModTime(), now)
+		}
+
+		// In sync now
+		rep = Sync(Options{Source: src, Target: dst, AppendOnly: true})
+		if rep.Appended != 0 || rep.Skipped != 1 {
+			t.Fatalf("unexpected second rep: %+v", *rep)
+		}
+	})
+
+	t.Run("times", func(t *testing.T) {
+		src, dst := t.TempDir(), t.TempDir()
+		writeWithModTime(t, filepath.Join(dst, "app.log"), "line1\n", 0o644, old)
+		writeWithModTime(t, filepath.Join(src, "app.log"), "line1\nline2\n", 0o644, now)
+		start := time.Now().Add(-time.Second)
+
+		// Times are deferred to the metadata pass, and the access time is the time of the append
+		tr := &fakeTracer{}
+		rep := Sync(Options{Source: src, Target: dst, AppendOnly: true, DeferMetadata: true, Tracer: tr})
+		if len(rep.Errors) != 0 || rep.Appended != 1 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if len(tr.find("sync.metadata")) != 1 {
+			t.Fatalf("the append's times were not deferred: %v", tr.tree())
+		}
+		info, err := os.Stat(filepath.Join(dst, "app.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(now) {
+			t.Fatalf("mtime mismatch: got %v want %v", info.ModTime(), now)
+		}
+		if atime, ok := accessTime(info); ok && atime.Before(start) {
+			t.Fatalf("atime %v is not the time of the append", atime)
+		}
+	})
+
+	t.Run("rotation", func(t *testing.T) {
+		src, dst := t.TempDir(), t.TempDir()
+		writeWithModTime(t, filepath.Join(dst, "app.log"), "old entries\n", 0o644, old)
+		writeWithModTime(t, filepath.Join(src, "app.log"), "new file after rotation\n", 0o644, now)
+
+		rep := Sync(Options{Source: src, Target: dst, AppendOnly: true})
+		if len(rep.Errors) != 0 {
+			t.Fatalf("unexpected errors: %v", rep.Errors)
+		}
+		if rep.Appended != 0 || rep.Overwritten != 1 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if b, _ := os.ReadFile(filepath.Join(dst, "app.log")); string(b) != "new file after rotation\n" {
+			t.Fatalf("unexpected content %q", b)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		src, dst := t.TempDir(), t.TempDir()
+		writeWithModTime(t, filepath.Join(dst, "app.log"), "line1\n", 0o644, old)
+		writeWithModTime(t, filepath.Join(src, "app.log"), "line1\nline2\n", 0o644, now)
+
+		rep := Sync(Options{Source: src, Target: dst, AppendOnly: true, DryRun: true})
+		if rep.Appended != 1 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if b, _ := os.ReadFile(filepath.Join(dst, "app.log")); string(b) != "line1\n" {
+			t.Fatalf("dry run modified the target: %q", b)
+		}
+	})
+}
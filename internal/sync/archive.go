@@ -0,0 +1,285 @@
+package sync
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArchiveKind identifies a supported archive format.
+type ArchiveKind int
+
+const (
+	NotArchive ArchiveKind = iota
+	ArchiveTar
+	ArchiveTarGz
+	ArchiveZip
+)
+
+// DetectArchive reports the archive format of the file at p, by magic bytes first and extension second.
+// Directories and unrecognized files yield NotArchive.
+func DetectArchive(p string) ArchiveKind {
+	f, err := os.Open(p)
+	if err != nil {
+		return NotArchive
+	}
+	defer f.Close()
+	if st, err := f.Stat(); err != nil || !st.Mode().IsRegular() {
+		return NotArchive
+	}
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return ArchiveZip
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return ArchiveTarGz
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return ArchiveTar
+	}
+	return archiveKindByExt(p)
+}
+
+// archiveKindByExt reports the archive format implied by the extension of p.
+func archiveKindByExt(p string) ArchiveKind {
+	lower := strings.ToLower(p)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return ArchiveZip
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return ArchiveTarGz
+	case strings.HasSuffix(lower, ".tar"):
+		return ArchiveTar
+	}
+	return NotArchive
+}
+
+// OpenArchive opens a .zip, .tar or .tar.gz file as a read-only fs.FS.
+// Entry mod-times and permissions come from the archive metadata.
+// Tar hard links read the data of the earlier entry they link to.
+// A .tar.gz is decompressed into a temporary file, removed again by Close.
+func OpenArchive(p string) (fs.FS, io.Closer, error) {
+	switch DetectArchive(p) {
+	case ArchiveZip:
+		zr, err := zip.OpenReader(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr, nil
+	case ArchiveTar:
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		tfs, err := newTarFS(f)
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("read tar %s: %w", p, err)
+		}
+		return tfs, f, nil
+	case ArchiveTarGz:
+		return openTarGz(p)
+	}
+	return nil, nil, fmt.Errorf("%s is not a supported archive", p)
+}
+
+// openTarGz decompresses p into a temp file so entries can be read at random offsets.
+func openTarGz(p string) (fs.FS, io.Closer, error) {
+	in, err := os.Open(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer in.Close()
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, nil, fmt.Errorf("gunzip %s: %w", p, err)
+	}
+	tmp, err := os.CreateTemp("", "sync-archive-*.tar")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := tempFileCloser{tmp}
+	if _, err := io.Copy(tmp, zr); err != nil {
+		cleanup.Close()
+		return nil, nil, fmt.Errorf("gunzip %s: %w", p, err)
+	}
+	tfs, err := newTarFS(tmp)
+	if err != nil {
+		cleanup.Close()
+		return nil, nil, fmt.Errorf("read tar %s: %w", p, err)
+	}
+	return tfs, cleanup, nil
+}
+
+// tempFileCloser closes and removes a temp file.
+type tempFileCloser struct {
+	f *os.File
+}
+
+func (c tempFileCloser) Close() error {
+	err := c.f.Close()
+	return errors.Join(err, os.Remove(c.f.Name()))
+}
+
+// tarFS is a read-only fs.FS over an uncompressed tar file.
+// Only the index is kept in memory; file data is read in place through section readers.
+type tarFS struct {
+	ra      io.ReaderAt
+	entries map[string]*tarEntry
+}
+
+type tarEntry struct {
+	name     string
+	hdr      *tar.Header
+	off      int64
+	children []string
+}
+
+// countingReader tracks the offset of the underlying reader, which tar.Reader leaves at the
+// start of an entry's data after Next.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func newTarFS(f *os.File) (*tarFS, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	cr := &countingReader{r: f}
+	tr := tar.NewReader(cr)
+	t := &tarFS{ra: f, entries: map[string]*tarEntry{}}
+	t.entries["."] = &tarEntry{name: ".", hdr: &tar.Header{Typeflag: tar.TypeDir, Mode: 0o755, ModTime: time.Now()}}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		if hdr.Typeflag == tar.TypeLink {
+			// A hard link has no data of its own; it shares that of an earlier entry.
+			target, ok := t.entries[path.Clean(strings.TrimPrefix(hdr.Linkname, "/"))]
+			if !ok || !target.hdr.FileInfo().Mode().IsRegular() {
+				return nil, fmt.Errorf("hard link %s: no earlier regular file %s", hdr.Name, hdr.Linkname)
+			}
+			link := *target.hdr
+			link.Name = hdr.Name
+			t.add(name, &tarEntry{name: name, hdr: &link, off: target.off})
+			continue
+		}
+		t.add(name, &tarEntry{name: name, hdr: hdr, off: cr.n})
+	}
+	for _, e := range t.entries {
+		sort.Strings(e.children)
+	}
+	return t, nil
+}
+
+// add indexes an entry, synthesizing any parent directories missing from the archive.
+func (t *tarFS) add(name string, e *tarEntry) {
+	if old, ok := t.entries[name]; ok {
+		// Later entries replace earlier ones, like tar extraction; keep the known children.
+		e.children = old.children
+		t.entries[name] = e
+		return
+	}
+	t.entries[name] = e
+	parent := path.Dir(name)
+	if _, ok := t.entries[parent]; !ok {
+		t.add(parent, &tarEntry{name: parent, hdr: &tar.Header{Typeflag: tar.TypeDir, Mode: 0o755, ModTime: e.hdr.ModTime}})
+	}
+	p := t.entries[parent]
+	p.children = append(p.children, path.Base(name))
+}
+
+func (t *tarFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := t.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	info := tarInfo{name: path.Base(name), hdr: e.hdr}
+	if info.IsDir() {
+		return &tarDir{fs: t, e: e, info: info}, nil
+	}
+	return &tarFile{info: info, r: io.NewSectionReader(t.ra, e.off, e.hdr.Size)}, nil
+}
+
+// tarInfo adapts a tar header, reporting the base name instead of the full path.
+type tarInfo struct {
+	name string
+	hdr  *tar.Header
+}
+
+func (i tarInfo) Name() string       { return i.name }
+func (i tarInfo) Size() int64        { return i.hdr.Size }
+func (i tarInfo) Mode() fs.FileMode  { return i.hdr.FileInfo().Mode() }
+func (i tarInfo) ModTime() time.Time { return i.hdr.ModTime }
+func (i tarInfo) IsDir() bool        { return i.Mode().IsDir() }
+func (i tarInfo) Sys() any           { return i.hdr }
+
+type tarFile struct {
+	info tarInfo
+	r    *io.SectionReader
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *tarFile) Read(p []byte) (int, error) { return f.r.Read(p) }
+func (f *tarFile) Close() error               { return nil }
+
+type tarDir struct {
+	fs   *tarFS
+	e    *tarEntry
+	info tarInfo
+	pos  int
+}
+
+func (d *tarDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *tarDir) Close() error               { return nil }
+
+func (d *tarDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.e.name, Err: errors.New("is a directory")}
+}
+
+func (d *tarDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.e.children[d.pos:]
+	if n > 0 && len(rest) > n {
+		rest = rest[:n]
+	}
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	out := make([]fs.DirEntry, len(rest))
+	for i, child := range rest {
+		e := d.fs.entries[path.Join(d.e.name, child)]
+		out[i] = fs.FileInfoToDirEntry(tarInfo{name: child, hdr: e.hdr})
+	}
+	d.pos += len(rest)
+	return out, nil
+}
@@ -0,0 +1,234 @@
+package sync
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type archiveEntry struct {
+	name string
+	body string
+	mode os.FileMode
+}
+
+var archiveEntries = []archiveEntry{
+	{"a.txt", "alpha", 0o644},
+	{"bin/run.sh", "#!/bin/sh\n", 0o755},
+	{"dir/deep/secret.txt", "gamma", 0o600},
+}
+
+func tarBytes(t *testing.T, mtime time.Time) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	// An explicit directory entry next to implicit ones.
+	if err := tw.WriteHeader(&tar.Header{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0o755, ModTime: mtime}); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range archiveEntries {
+		hdr := &tar.Header{Name: e.name, Typeflag: tar.TypeReg, Mode: int64(e.mode), Size: int64(len(e.body)), ModTime: mtime}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, e.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipBytes(t *testing.T, mtime time.Time) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range archiveEntries {
+		fh := &zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: mtime}
+		fh.SetMode(e.mode)
+		w, err := zw.CreateHeader(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, e.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSyncFromArchive(t *testing.T) {
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	cases := []struct {
+		name string
+		file string
+		data []byte
+		kind ArchiveKind
+	}{
+		{"tar", "src.tar", tarBytes(t, mtime), ArchiveTar},
+		{"tar.gz", "src.tar.gz", gzipBytes(t, tarBytes(t, mtime)), ArchiveTarGz},
+		{"zip", "src.zip", zipBytes(t, mtime), ArchiveZip},
+		// Magic bytes win over a missing extension.
+		{"zip without extension", "src.bin", zipBytes(t, mtime), ArchiveZip},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), tc.file)
+			if err := os.WriteFile(archive, tc.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if got := DetectArchive(archive); got != tc.kind {
+				t.Fatalf("DetectArchive = %v, want %v", got, tc.kind)
+			}
+			dst := t.TempDir()
+			mustWrite(t, filepath.Join(dst, "orphan.txt"), "x")
+
+			rep := Sync(Options{Source: archive, Target: dst, DeleteMissing: true})
+			if len(rep.Errors) != 0 {
+				t.Fatalf("unexpected errors: %v", rep.Errors)
+			}
+			if rep.Copied != len(archiveEntries) || rep.Deleted != 1 {
+				t.Fatalf("unexpected rep: %+v", *rep)
+			}
+			for _, e := range archiveEntries {
+				p := filepath.Join(dst, filepath.FromSlash(e.name))
+				b, err := os.ReadFile(p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != e.body {
+					t.Fatalf("%s content mismatch: %q", e.name, b)
+				}
+				info, err := os.Stat(p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Mode().Perm() != e.mode {
+					t.Fatalf("%s perm mismatch: got %v want %v", e.name, info.Mode().Perm(), e.mode)
+				}
+				if !info.ModTime().Equal(mtime) {
+					t.Fatalf("%s mtime mismatch: got %v want %v", e.name, info.ModTime(), mtime)
+				}
+			}
+
+			rep2 := Sync(Options{Source: archive, Target: dst, DeleteMissing: true})
+			if rep2.Copied != 0 || rep2.Overwritten != 0 || rep2.Deleted != 0 || rep2.Skipped != len(archiveEntries) {
+				t.Fatalf("expected all skipped on second run, got %+v", *rep2)
+			}
+		})
+	}
+}
+
+func TestSyncFromCorruptArchiveAborts(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "src.tar.gz")
+	if err := os.WriteFile(archive, []byte{0x1f, 0x8b, 0, 0}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dst := t.TempDir()
+	mustWrite(t, filepath.Join(dst, "keep.txt"), "x")
+
+	rep := Sync(Options{Source: archive, Target: dst, DeleteMissing: true})
+	if len(rep.Errors) != 1 {
+		t.Fatalf("expected one error, got %v", rep.Errors)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "keep.txt")); err != nil {
+		t.Fatalf("target must be left untouched: %v", err)
+	}
+}
+
+func TestDetectArchiveDirectory(t *testing.T) {
+	if got := DetectArchive(t.TempDir()); got != NotArchive {
+		t.Fatalf("DetectArchive(dir) = %v", got)
+	}
+}
+
+func TestTarFSConformance(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "src.tar")
+	if err := os.WriteFile(archive, tarBytes(t, time.Now()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fsys, c, err := OpenArchive(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := fstest.TestFS(fsys, "a.txt", "bin/run.sh", "dir/deep/secret.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTarHardLink(t *testing.T) {
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "a.txt", Typeflag: tar.TypeReg, Mode: 0o644, Size: 5, ModTime: mtime},
+		{Name: "dir/b.txt", Typeflag: tar.TypeLink, Linkname: "a.txt", Mode: 0o644, ModTime: mtime},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := io.WriteString(tw, "alpha"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(t.TempDir(), "src.tar")
+	if err := os.WriteFile(archive, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := t.TempDir()
+	rep := Sync(Options{Source: archive, Target: dst})
+	if len(rep.Errors) != 0 || rep.Copied != 2 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if got := treeContents(t, dst); got["a.txt"] != "alpha" || got["dir/b.txt"] != "alpha" {
+		t.Fatalf("hard link not resolved: %v", got)
+	}
+
+	// A link to nothing earlier in the archive cannot be resolved
+	buf.Reset()
+	tw = tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "b.txt", Typeflag: tar.TypeLink, Linkname: "missing.txt", ModTime: mtime}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archive, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := OpenArchive(archive); err == nil {
+		t.Fatal("OpenArchive accepted a dangling hard link")
+	}
+}
@@ -0,0 +1,26 @@
+package sync
+
+import (
+	"io/fs"
+	"time"
+)
+
+// atimeFS gives every file whose times are set the access time of its source
+// (Options.PreserveAllTimes) instead of the current time.
+type atimeFS struct {
+	WritableFS
+	atime time.Time
+}
+
+func (a atimeFS) Chtimes(name string, _, mtime time.Time) error {
+	return a.WritableFS.Chtimes(name, a.atime, mtime)
+}
+
+// sourceAtime returns the access time of the source file described by info when
+// Options.PreserveAllTimes is set and the platform reports it.
+func (r *runner) sourceAtime(info fs.FileInfo) (time.Time, bool) {
+	if !r.opt.PreserveAllTimes {
+		return time.Time{}, false
+	}
+	return accessTime(info)
+}
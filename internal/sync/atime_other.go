@@ -0,0 +1,13 @@
+//go:build !linux && !openbsd && !darwin && !freebsd && !netbsd && !dragonfly && !windows
+
+package sync
+
+import (
+	"io/fs"
+	"time"
+)
+
+// accessTime is not available on this platform; copied files get the current access time.
+func accessTime(fs.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
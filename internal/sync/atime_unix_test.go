@@ -0,0 +1,39 @@
+//go:build unix
+
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPreserveAllTimes(t *testing.T) {
+	for _, deferMeta := range []bool{false, true} {
+		src, dst := t.TempDir(), t.TempDir()
+		p := filepath.Join(src, "a.txt")
+		mustWrite(t, p, "a")
+		atime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		mtime := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+		if err := os.Chtimes(p, atime, mtime); err != nil {
+			t.Fatal(err)
+		}
+
+		rep := Sync(Options{Source: src, Target: dst, PreserveAllTimes: true, DeferMetadata: deferMeta})
+		if len(rep.Errors) != 0 || rep.Copied != 1 {
+			t.Fatalf("defer=%v: unexpected rep: %+v", deferMeta, *rep)
+		}
+		info, err := os.Stat(filepath.Join(dst, "a.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := accessTime(info)
+		if !ok {
+			t.Skip("access times not available on this platform")
+		}
+		if !got.Equal(atime) || !info.ModTime().Equal(mtime) {
+			t.Fatalf("defer=%v: target atime %v mtime %v, want %v and %v", deferMeta, got, info.ModTime(), atime, mtime)
+		}
+	}
+}
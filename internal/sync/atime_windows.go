@@ -0,0 +1,19 @@
+//go:build windows
+
+package sync
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// accessTime returns the last access time of the file described by info. NTFS updates it
+// lazily (at most hourly) or not at all, depending on the system's last-access setting.
+func accessTime(info fs.FileInfo) (time.Time, bool) {
+	d, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, d.LastAccessTime.Nanoseconds()), true
+}
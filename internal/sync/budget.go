@@ -0,0 +1,31 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrByteBudgetExhausted is wrapped by the error recorded when a run stops copying because it
+// reached Options.MaxTotalBytes.
+var ErrByteBudgetExhausted = errors.New("byte budget exhausted")
+
+// overBudget reports whether the run has copied Options.MaxTotalBytes and must not start
+// another copy. Files queued by Readahead count as copied. The error is recorded the first time.
+func (r *runner) overBudget() bool {
+	if r.budgetSpent {
+		return true
+	}
+	spent := r.rep.BytesCopied
+	if r.ra != nil {
+		spent += r.ra.queued
+	}
+	if r.opt.MaxTotalBytes <= 0 || spent < r.opt.MaxTotalBytes {
+		return false
+	}
+	r.budgetSpent = true
+	err := fmt.Errorf("%w: copied %d of at most %d bytes; remaining files are left for the next run",
+		ErrByteBudgetExhausted, spent, r.opt.MaxTotalBytes)
+	r.opt.Logger.Printf("ERR: %v", err)
+	r.rep.addErr(err)
+	return true
+}
@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMaxTotalBytes(t *testing.T) {
+	src := fstest.MapFS{}
+	for i := 0; i < 5; i++ {
+		src[fmt.Sprintf("dir/f%d", i)] = &fstest.MapFile{Data: bytes.Repeat([]byte{'x'}, 100)}
+	}
+	dst := newMemFS()
+
+	// The third file crosses the budget and is completed; the rest wait for the next run
+	rep := SyncFS(src, dst, Options{MaxTotalBytes: 250})
+	if rep.Copied != 3 || rep.BytesCopied != 300 || len(rep.Errors) != 1 || !errors.Is(rep.Errors[0], ErrByteBudgetExhausted) {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	for i := 0; i < 5; i++ {
+		_, ok := dst.MapFS[fmt.Sprintf("dir/f%d", i)]
+		if ok != (i < 3) {
+			t.Fatalf("f%d copied: %v", i, ok)
+		}
+	}
+
+	rep = SyncFS(src, dst, Options{MaxTotalBytes: 250})
+	if rep.Copied != 2 || rep.Skipped != 3 || len(rep.Errors) != 0 {
+		t.Fatalf("unexpected second rep: %+v", *rep)
+	}
+}
+
+func TestMaxTotalBytesReadahead(t *testing.T) {
+	src := fstest.MapFS{}
+	for i := 0; i < 5; i++ {
+		src[fmt.Sprintf("f%d", i)] = &fstest.MapFile{Data: bytes.Repeat([]byte{'x'}, 100)}
+	}
+
+	// Queued files count against the budget before they are written
+	rep := SyncFS(src, newMemFS(), Options{MaxTotalBytes: 250, Readahead: 8})
+	if rep.Copied != 3 || rep.BytesCopied != 300 || len(rep.Errors) != 1 || !errors.Is(rep.Errors[0], ErrByteBudgetExhausted) {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+}
+
+func TestMaxTotalBytesTransactional(t *testing.T) {
+	src := fstest.MapFS{"a": &fstest.MapFile{Data: []byte("a")}}
+	dst := newMemFS()
+	rep := SyncFS(src, dst, Options{MaxTotalBytes: 250, Transactional: true})
+	if rep.Copied != 0 || len(rep.Errors) != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if _, ok := dst.MapFS["a"]; ok {
+		t.Fatal("a rejected run copied a")
+	}
+}
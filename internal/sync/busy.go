@@ -0,0 +1,41 @@
+package sync
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultBusyTargetBackoff is the first wait of BusyTargetRetries without BusyTargetBackoff.
+const defaultBusyTargetBackoff = 100 * time.Millisecond
+
+// replaceBusy handles err, the failed rename of tmp onto the target file name, when the target
+// is in use by another process (Windows): it retries with a doubling wait up to
+// Options.BusyTargetRetries times, then with Options.BusyTargetReplaceAside moves the busy
+// target aside. A failure still due to the busy target says so.
+func (r *runner) replaceBusy(tmp, name string, err error) error {
+	wait := r.opt.BusyTargetBackoff
+	if wait <= 0 {
+		wait = defaultBusyTargetBackoff
+	}
+	for i := 1; i <= r.opt.BusyTargetRetries && targetBusy(err); i++ {
+		r.opt.Logger.Printf("WARN: %s is in use; retrying in %v (%d/%d)", r.dstPath(name), wait, i, r.opt.BusyTargetRetries)
+		time.Sleep(wait)
+		wait *= 2
+		r.beat()
+		err = r.dst.Rename(tmp, name)
+	}
+	if !targetBusy(err) {
+		return err
+	}
+	if d, ok := r.dst.(dirFS); ok && r.opt.BusyTargetReplaceAside {
+		aside := tempName(name)
+		if err = replaceAside(d, tmp, name, aside); err == nil {
+			r.opt.Logger.Printf("REPLACE: %s was in use; old file moved to %s until it is closed", r.dstPath(name), r.dstPath(aside))
+			return nil
+		}
+	}
+	if targetBusy(err) {
+		err = fmt.Errorf("%w (target file in use by another process; see BusyTargetRetries and BusyTargetReplaceAside)", err)
+	}
+	return err
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package sync
+
+// targetBusy is always false outside Windows, where open files do not prevent renames.
+func targetBusy(error) bool {
+	return false
+}
+
+// replaceAside is never reached outside Windows; it renames like the Rename it replaces.
+func replaceAside(d dirFS, tmp, name, _ string) error {
+	return d.Rename(tmp, name)
+}
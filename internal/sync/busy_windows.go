@@ -0,0 +1,61 @@
+//go:build windows
+
+package sync
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+const (
+	errorUserMappedFile   syscall.Errno = 1224
+	fileFlagDeleteOnClose               = 0x04000000
+	accessDelete                        = 0x00010000
+)
+
+// targetBusy reports whether a rename onto a target file failed because another process has it
+// open without sharing it for deletion, locked, or memory-mapped. ERROR_ACCESS_DENIED is not
+// counted: it also means a read-only file or missing permissions, which moving aside would mask.
+func targetBusy(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation) ||
+		errors.Is(err, errorUserMappedFile)
+}
+
+// replaceAside renames the busy target file name to aside, renames tmp onto name and marks
+// aside for deletion once the last process closes it (FILE_FLAG_DELETE_ON_CLOSE). Renaming
+// works for files that are memory-mapped or opened with FILE_SHARE_DELETE. If aside cannot be
+// marked, it stays behind under its temp name, for CleanStaleTemps.
+func replaceAside(d dirFS, tmp, name, aside string) error {
+	tp, err := d.path("rename", tmp)
+	if err != nil {
+		return err
+	}
+	np, err := d.path("rename", name)
+	if err != nil {
+		return err
+	}
+	ap, err := d.path("rename", aside)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(np, ap); err != nil {
+		return err
+	}
+	if err := os.Rename(tp, np); err != nil {
+		// Put the old file back rather than leave no target at all
+		_ = os.Rename(ap, np)
+		return err
+	}
+	p, err := syscall.UTF16PtrFromString(ap)
+	if err != nil {
+		return nil
+	}
+	h, err := syscall.CreateFile(p, accessDelete,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE, nil,
+		syscall.OPEN_EXISTING, fileFlagDeleteOnClose, 0)
+	if err == nil {
+		syscall.CloseHandle(h)
+	}
+	return nil
+}
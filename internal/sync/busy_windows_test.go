@@ -0,0 +1,60 @@
+//go:build windows
+
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// holdOpen opens p for reading and writing, sharing it for reading and writing but not for
+// deletion, so that it cannot be replaced until the returned handle is closed.
+func holdOpen(t *testing.T, p string) syscall.Handle {
+	t.Helper()
+	name, err := syscall.UTF16PtrFromString(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE, nil, syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestBusyTarget(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "app.db"), "new content")
+	busy := filepath.Join(dst, "app.db")
+	mustWrite(t, busy, "old")
+
+	t.Run("error", func(t *testing.T) {
+		h := holdOpen(t, busy)
+		defer syscall.CloseHandle(h)
+		rep := Sync(Options{Source: src, Target: dst})
+		if len(rep.Errors) != 1 || !strings.Contains(rep.Errors[0].Error(), "in use by another process") {
+			t.Fatalf("expected an in-use error, got %+v", *rep)
+		}
+		if entries, _ := os.ReadDir(dst); len(entries) != 1 {
+			t.Fatalf("temp file left behind: %v", entries)
+		}
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		h := holdOpen(t, busy)
+		released := time.AfterFunc(150*time.Millisecond, func() { syscall.CloseHandle(h) })
+		defer released.Stop()
+		rep := Sync(Options{Source: src, Target: dst, BusyTargetRetries: 6, BusyTargetBackoff: 20 * time.Millisecond})
+		if len(rep.Errors) != 0 || rep.Overwritten != 1 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if data, err := os.ReadFile(busy); err != nil || string(data) != "new content" {
+			t.Fatalf("target not replaced: %q, %v", data, err)
+		}
+	})
+}
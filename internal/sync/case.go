@@ -0,0 +1,136 @@
+package sync
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// detectCaseInsensitive reports whether the target folds case, judged by looking up an entry
+// of the target root under its swapped-case name. Nothing is written, so it works in dry runs.
+// A target without suitable entries holds no mis-cased files either and counts as case-sensitive.
+func (r *runner) detectCaseInsensitive() bool {
+	entries, err := fs.ReadDir(r.dst, ".")
+	if err != nil {
+		r.opt.Logger.Printf("WARN: detect case sensitivity: read %s: %v; assuming case-sensitive", r.dstPath("."), err)
+		return false
+	}
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		names[e.Name()] = true
+	}
+	for _, e := range entries {
+		swapped := swapCase(e.Name())
+		if swapped == e.Name() || names[swapped] {
+			// No letters, or both spellings exist side by side
+			continue
+		}
+		_, err := r.dst.Stat(swapped)
+		return err == nil
+	}
+	return false
+}
+
+func swapCase(s string) string {
+	return strings.Map(func(c rune) rune {
+		if unicode.IsUpper(c) {
+			return unicode.ToLower(c)
+		}
+		return unicode.ToUpper(c)
+	}, s)
+}
+
+// targetCase returns the name the case-insensitive target actually stores dstRel under.
+// Directory listings are read once per directory.
+func (r *runner) targetCase(dstRel string) (string, error) {
+	dir, base := path.Split(dstRel)
+	dir = path.Clean(dir)
+	names, ok := r.caseNames[dir]
+	if !ok {
+		entries, err := fs.ReadDir(r.dst, dir)
+		if err != nil {
+			return "", err
+		}
+		names = make(map[string]string, len(entries))
+		for _, e := range entries {
+			names[strings.ToLower(e.Name())] = e.Name()
+		}
+		r.caseNames[dir] = names
+	}
+	actual, ok := names[strings.ToLower(base)]
+	if !ok {
+		return dstRel, nil
+	}
+	return path.Join(dir, actual), nil
+}
+
+// fixCase renames the target file matching identical source file rel only case-insensitively
+// to the source's spelling dstRel (Options.FixCase). It reports whether the target was misspelled;
+// failures are logged and recorded.
+func (r *runner) fixCase(rel, dstRel string) bool {
+	actual, err := r.targetCase(dstRel)
+	if err != nil {
+		r.opt.Logger.Printf("ERR: read %s: %v", r.dstPath(path.Dir(dstRel)), err)
+		r.rep.addErr(err)
+		return false
+	}
+	if actual == dstRel {
+		return false
+	}
+	if !r.opt.DryRun {
+		if err := r.dst.Rename(actual, dstRel); err != nil {
+			r.opt.Logger.Printf("ERR: recase %s -> %s: %v", r.dstPath(actual), r.dstPath(dstRel), err)
+			r.rep.addErr(err)
+			return true
+		}
+		dir, base := path.Split(dstRel)
+		r.caseNames[path.Clean(dir)][strings.ToLower(base)] = base
+	}
+	r.opt.Logger.Printf("RECASE: %s -> %s", r.dstPath(actual), r.dstPath(dstRel))
+	r.rep.Recased++
+	return true
+}
+
+// sourceCaseTwin returns the name of a source file matching the target name rel only
+// case-insensitively, in any source. On a case-insensitive target, such a target file is the
+// copy of that source file rather than an orphan. Source listings are read once per directory.
+func (r *runner) sourceCaseTwin(rel string) (string, bool) {
+	if r.srcCaseNames == nil {
+		r.srcCaseNames = make([]map[string]map[string]string, len(r.sources))
+	}
+	for i, s := range r.sources {
+		if r.srcCaseNames[i] == nil {
+			r.srcCaseNames[i] = map[string]map[string]string{}
+		}
+		cache := r.srcCaseNames[i]
+		cur := "."
+		for _, elem := range strings.Split(rel, "/") {
+			names, ok := cache[cur]
+			if !ok {
+				entries, err := fs.ReadDir(s.fsys, cur)
+				if err != nil {
+					// Unreadable or not a directory: no twin here
+					names = nil
+				}
+				for _, e := range entries {
+					if names == nil {
+						names = map[string]string{}
+					}
+					names[strings.ToLower(e.Name())] = e.Name()
+				}
+				cache[cur] = names
+			}
+			actual, ok := names[strings.ToLower(elem)]
+			if !ok {
+				cur = ""
+				break
+			}
+			cur = path.Join(cur, actual)
+		}
+		if cur != "" {
+			return cur, true
+		}
+	}
+	return "", false
+}
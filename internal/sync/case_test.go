@@ -0,0 +1,170 @@
+package sync
+
+import (
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// foldFS is a case-insensitive, case-preserving memFS, like NTFS or APFS.
+type foldFS struct {
+	*memFS
+	creates int
+}
+
+func (f *foldFS) resolve(name string) string {
+	if _, ok := f.MapFS[name]; ok {
+		return name
+	}
+	for stored := range f.MapFS {
+		if strings.EqualFold(stored, name) {
+			return stored
+		}
+	}
+	return name
+}
+
+func (f *foldFS) Open(name string) (fs.File, error) { return f.MapFS.Open(f.resolve(name)) }
+
+func (f *foldFS) Stat(name string) (fs.FileInfo, error) { return f.MapFS.Stat(f.resolve(name)) }
+
+func (f *foldFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	f.creates++
+	return f.memFS.Create(f.resolve(name), perm)
+}
+
+func (f *foldFS) Rename(oldname, newname string) error {
+	oldname = f.resolve(oldname)
+	if target := f.resolve(newname); target != oldname {
+		delete(f.MapFS, target)
+	}
+	return f.memFS.Rename(oldname, newname)
+}
+
+func (f *foldFS) Remove(name string) error { return f.memFS.Remove(f.resolve(name)) }
+
+func (f *foldFS) Chtimes(name string, atime, mtime time.Time) error {
+	return f.memFS.Chtimes(f.resolve(name), atime, mtime)
+}
+
+func TestFixCase(t *testing.T) {
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	src := fstest.MapFS{
+		"file.txt":       {Data: []byte("same"), ModTime: mtime},
+		"dir/Report.pdf": {Data: []byte("pdf"), ModTime: mtime},
+	}
+	newTarget := func() *foldFS {
+		dst := &foldFS{memFS: newMemFS()}
+		dst.MapFS["File.txt"] = &fstest.MapFile{Data: []byte("same"), ModTime: mtime}
+		dst.MapFS["dir"] = &fstest.MapFile{Mode: fs.ModeDir | 0o755}
+		dst.MapFS["dir/report.pdf"] = &fstest.MapFile{Data: []byte("pdf"), ModTime: mtime}
+		return dst
+	}
+
+	t.Run("recase", func(t *testing.T) {
+		dst := newTarget()
+		rep := SyncFS(src, dst, Options{FixCase: true, DeleteMissing: true})
+		if len(rep.Errors) != 0 {
+			t.Fatalf("unexpected errors: %v", rep.Errors)
+		}
+		if rep.Recased != 2 || rep.Copied+rep.Overwritten+rep.Deleted != 0 || dst.creates != 0 {
+			t.Fatalf("unexpected rep: %+v (creates=%d)", *rep, dst.creates)
+		}
+		for _, name := range []string{"file.txt", "dir/Report.pdf"} {
+			if _, ok := dst.MapFS[name]; !ok {
+				t.Fatalf("expected target spelled %s, have %v", name, dst.MapFS)
+			}
+		}
+
+		rep = SyncFS(src, dst, Options{FixCase: true})
+		if rep.Recased != 0 || rep.Skipped != 2 {
+			t.Fatalf("unexpected second rep: %+v", *rep)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		dst := newTarget()
+		rep := SyncFS(src, dst, Options{FixCase: true, DryRun: true})
+		if rep.Recased != 2 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if _, ok := dst.MapFS["File.txt"]; !ok {
+			t.Fatalf("dry run renamed the target")
+		}
+	})
+
+	t.Run("off", func(t *testing.T) {
+		dst := newTarget()
+		rep := SyncFS(src, dst, Options{})
+		if rep.Recased != 0 || rep.Skipped != 2 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+	})
+
+	t.Run("case-sensitive target", func(t *testing.T) {
+		dst := newMemFS()
+		dst.MapFS["File.txt"] = &fstest.MapFile{Data: []byte("same"), ModTime: mtime}
+		rep := SyncFS(src, dst, Options{FixCase: true})
+		if rep.Recased != 0 || rep.Copied != 2 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+	})
+}
+
+func TestDeleteMissingCaseOnly(t *testing.T) {
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	src := fstest.MapFS{
+		"File.txt":      {Data: []byte("same"), ModTime: mtime},
+		"Dir/Notes.txt": {Data: []byte("notes"), ModTime: mtime},
+	}
+	newTarget := func() *foldFS {
+		dst := &foldFS{memFS: newMemFS()}
+		dst.MapFS["file.txt"] = &fstest.MapFile{Data: []byte("same"), ModTime: mtime}
+		dst.MapFS["dir"] = &fstest.MapFile{Mode: fs.ModeDir | 0o755}
+		dst.MapFS["dir/notes.txt"] = &fstest.MapFile{Data: []byte("notes"), ModTime: mtime}
+		dst.MapFS["orphan.txt"] = &fstest.MapFile{Data: []byte("orphan"), ModTime: mtime}
+		return dst
+	}
+
+	t.Run("kept", func(t *testing.T) {
+		dst := newTarget()
+		rep := SyncFS(src, dst, Options{DeleteMissing: true})
+		if len(rep.Errors) != 0 || rep.Deleted != 1 || rep.Skipped != 2 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		for _, name := range []string{"file.txt", "dir/notes.txt"} {
+			if _, ok := dst.MapFS[name]; !ok {
+				t.Fatalf("%s was deleted: %v", name, dst.MapFS)
+			}
+		}
+		if _, ok := dst.MapFS["orphan.txt"]; ok {
+			t.Fatal("orphan.txt was not deleted")
+		}
+	})
+
+	t.Run("recased", func(t *testing.T) {
+		dst := newTarget()
+		rep := SyncFS(src, dst, Options{FixCase: true, DeleteMissing: true})
+		if len(rep.Errors) != 0 || rep.Recased != 1 || rep.Deleted != 1 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if _, ok := dst.MapFS["File.txt"]; !ok {
+			t.Fatalf("expected target spelled File.txt, have %v", dst.MapFS)
+		}
+		if _, ok := dst.MapFS["dir/notes.txt"]; !ok {
+			t.Fatal("dir/notes.txt was deleted")
+		}
+	})
+
+	t.Run("case-sensitive target", func(t *testing.T) {
+		dst := newMemFS()
+		dst.MapFS["file.txt"] = &fstest.MapFile{Data: []byte("same"), ModTime: mtime}
+		rep := SyncFS(src, dst, Options{DeleteMissing: true})
+		if len(rep.Errors) != 0 || rep.Deleted != 1 || rep.Copied != 2 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+	})
+}
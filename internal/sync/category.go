@@ -0,0 +1,35 @@
+package sync
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"syscall"
+)
+
+// Error categories of Report.ErrorsByCategory (Options.CategorizeErrors).
+const (
+	ErrCategoryPermission = "permission"
+	ErrCategorySpace      = "space"
+	ErrCategoryIO         = "io"
+	ErrCategoryNotFound   = "not-found"
+	ErrCategoryOther      = "other"
+)
+
+// ErrorCategory classifies err by its cause: ErrCategoryPermission for fs.ErrPermission
+// (EACCES, EPERM), ErrCategorySpace for a full target or exhausted quota (ENOSPC, EDQUOT),
+// ErrCategoryIO for device and transport failures (EIO, ESTALE, truncated reads),
+// ErrCategoryNotFound for fs.ErrNotExist, and ErrCategoryOther for anything else.
+func ErrorCategory(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return ErrCategoryPermission
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EDQUOT):
+		return ErrCategorySpace
+	case errors.Is(err, syscall.EIO), errors.Is(err, syscall.ESTALE), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrCategoryIO
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrCategoryNotFound
+	}
+	return ErrCategoryOther
+}
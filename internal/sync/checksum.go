@@ -0,0 +1,35 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"io/fs"
+)
+
+// hashFile returns the SHA-256 of the content of name in fsys.
+func hashFile(fsys fs.FS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// sameContent reports whether srcName in src and dstName in dst have identical content.
+func sameContent(src fs.FS, srcName string, dst fs.FS, dstName string) (bool, error) {
+	sh, err := hashFile(src, srcName)
+	if err != nil {
+		return false, err
+	}
+	dh, err := hashFile(dst, dstName)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(sh, dh), nil
+}
@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// scrambleFS is a memFS whose Chtimes ignores the requested mtime, like some SMB mounts.
+type scrambleFS struct {
+	*memFS
+}
+
+func (s scrambleFS) Chtimes(name string, atime, _ time.Time) error {
+	return s.memFS.Chtimes(name, atime, time.Now().Add(time.Duration(len(name))*time.Hour))
+}
+
+func TestIgnoreModTime(t *testing.T) {
+	mtime := time.Now().Add(-24 * time.Hour)
+	src := fstest.MapFS{
+		"a.txt": {Data: []byte("same content"), Mode: 0o644, ModTime: mtime},
+	}
+	dst := scrambleFS{newMemFS()}
+
+	rep := SyncFS(src, dst, Options{IgnoreModTime: true})
+	if rep.Copied != 1 {
+		t.Fatalf("expected copied=1, got %+v", *rep)
+	}
+
+	// The scrambled mtime alone would force an overwrite on every run.
+	rep2 := SyncFS(src, dst, Options{})
+	if rep2.Overwritten != 1 {
+		t.Fatalf("expected spurious overwrite without IgnoreModTime, got %+v", *rep2)
+	}
+
+	for i := 0; i < 3; i++ {
+		rep := SyncFS(src, dst, Options{IgnoreModTime: true})
+		if rep.Overwritten != 0 || rep.Skipped != 1 {
+			t.Fatalf("run %d: expected identical content skipped, got %+v", i, *rep)
+		}
+	}
+
+	// Same size but different content is still detected.
+	src["a.txt"] = &fstest.MapFile{Data: []byte("SAME CONTENT"), Mode: 0o644, ModTime: mtime}
+	rep3 := SyncFS(src, dst, Options{IgnoreModTime: true})
+	if rep3.Overwritten != 1 {
+		t.Fatalf("expected content change overwritten, got %+v", *rep3)
+	}
+}
@@ -0,0 +1,29 @@
+package sync
+
+// noteChurnBase remembers the size of the target file dstRel before it is overwritten
+// (Options.ComputeChurn), for addChurn once the overwrite is done.
+func (r *runner) noteChurnBase(dstRel string) {
+	info, err := r.dst.Stat(dstRel)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	if r.churnBase == nil {
+		r.churnBase = map[string]int64{}
+	}
+	r.churnBase[dstRel] = info.Size()
+}
+
+// addChurn counts the net change in size of the target file dstRel, now size bytes long,
+// in Report.BytesAdded or Report.BytesRemoved.
+func (r *runner) addChurn(dstRel string, size int64) {
+	old, ok := r.churnBase[dstRel]
+	if !ok {
+		return
+	}
+	delete(r.churnBase, dstRel)
+	if size > old {
+		r.rep.BytesAdded += size - old
+	} else {
+		r.rep.BytesRemoved += old - size
+	}
+}
@@ -0,0 +1,38 @@
+package sync
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestComputeChurn(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "grow.txt"), "0123456789")
+	mustWrite(t, filepath.Join(src, "shrink.txt"), "012")
+	mustWrite(t, filepath.Join(src, "new.txt"), "new")
+	writeWithModTime(t, filepath.Join(dst, "grow.txt"), "0123", 0o644, old)
+	writeWithModTime(t, filepath.Join(dst, "shrink.txt"), "0123456", 0o644, old)
+
+	opt := Options{Source: src, Target: dst, ComputeChurn: true}
+	rep := Sync(opt)
+	if len(rep.Errors) != 0 || rep.Overwritten != 2 || rep.Copied != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if rep.BytesAdded != 6 || rep.BytesRemoved != 4 {
+		t.Fatalf("churn: added %d removed %d, want 6 and 4", rep.BytesAdded, rep.BytesRemoved)
+	}
+
+	// Nothing changed: no churn
+	if rep := Sync(opt); rep.BytesAdded != 0 || rep.BytesRemoved != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+
+	// Appended tails count as added
+	mustWrite(t, filepath.Join(src, "grow.txt"), "0123456789abc")
+	opt.AppendOnly = true
+	if rep := Sync(opt); rep.Appended != 1 || rep.BytesAdded != 3 || rep.BytesRemoved != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+}
@@ -0,0 +1,109 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// cleanTarget empties the target for Options.CleanTarget, moving its content into the trash
+// when TrashDir is set. It returns false if the run must stop; the error has been recorded.
+func (r *runner) cleanTarget() bool {
+	if err := r.checkSourceOutsideTarget(); err != nil {
+		r.opt.Logger.Printf("ERR: %v", err)
+		r.rep.addErr(err)
+		return false
+	}
+	if !r.cleanDir(".") {
+		return false
+	}
+	r.emptyTarget = true
+	return true
+}
+
+// cleanDir removes the entries of the target directory dir, keeping the trash.
+func (r *runner) cleanDir(dir string) bool {
+	entries, err := fs.ReadDir(r.dst, dir)
+	if err != nil {
+		if dir == "." && errors.Is(err, fs.ErrNotExist) {
+			// A missing target is created as usual
+			return true
+		}
+		return r.cleanFailed(dir, err)
+	}
+	trash := path.Clean(r.opt.TrashDir)
+	for _, e := range entries {
+		rel := path.Join(dir, e.Name())
+		switch {
+		case r.isTrash(rel):
+			continue
+		case r.opt.TrashDir != "" && strings.HasPrefix(trash, rel+"/"):
+			// The trash lives below: empty around it
+			if !r.cleanDir(rel) {
+				return false
+			}
+			continue
+		}
+		if r.opt.DryRun {
+			r.opt.Logger.Printf("CLEAN: %s", r.dstPath(rel))
+			continue
+		}
+		if r.opt.TrashDir != "" {
+			dest, err := r.moveToTrash(rel)
+			if err != nil {
+				return r.cleanFailed(rel, err)
+			}
+			r.opt.Logger.Printf("TRASH: %s -> %s (clean target)", r.dstPath(rel), r.dstPath(dest))
+			continue
+		}
+		if err := removeAllFS(r.dst, rel); err != nil {
+			return r.cleanFailed(rel, err)
+		}
+		r.opt.Logger.Printf("CLEAN: %s", r.dstPath(rel))
+	}
+	return true
+}
+
+func (r *runner) cleanFailed(rel string, err error) bool {
+	err = fmt.Errorf("clean target %s: %w", r.dstPath(rel), err)
+	r.opt.Logger.Printf("ERR: %v", err)
+	r.rep.addErr(err)
+	return false
+}
+
+// checkSourceOutsideTarget refuses a source at or below the target, which cleaning would
+// destroy. Same directories under other paths are caught by checkSameRoots.
+func (r *runner) checkSourceOutsideTarget() error {
+	if r.dstRoot == "" {
+		return nil
+	}
+	dst, err := realPath(r.dstRoot)
+	if err != nil {
+		return nil
+	}
+	for _, src := range r.sources {
+		if src.root == "" {
+			continue
+		}
+		p, err := realPath(src.root)
+		if err != nil {
+			continue
+		}
+		if within(dst, p) {
+			return fmt.Errorf("CleanTarget: source %s is inside target %s", src.root, r.dstRoot)
+		}
+	}
+	return nil
+}
+
+// realPath returns the absolute path of p with symlinks resolved.
+func realPath(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
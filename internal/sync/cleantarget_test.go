@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCleanTarget(t *testing.T) {
+	setup := func(t *testing.T) (src, dst string) {
+		src, dst = t.TempDir(), t.TempDir()
+		mustWrite(t, filepath.Join(src, "a.txt"), "a")
+		mustWrite(t, filepath.Join(src, "dir", "b.txt"), "b")
+		// Same size and mod-time as the source would pass a plain comparison
+		mustWrite(t, filepath.Join(dst, "a.txt"), "x")
+		mustWrite(t, filepath.Join(dst, "stale.txt"), "stale")
+		mustWrite(t, filepath.Join(dst, "old", "deep", "c.txt"), "c")
+		return src, dst
+	}
+	want := map[string]string{"a.txt": "a", "dir": "/", "dir/b.txt": "b"}
+
+	t.Run("remove", func(t *testing.T) {
+		src, dst := setup(t)
+		rep := Sync(Options{Source: src, Target: dst, CleanTarget: true})
+		if len(rep.Errors) != 0 || rep.Copied != 2 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if got := treeContents(t, dst); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v want %v", got, want)
+		}
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		src, dst := setup(t)
+		rep := Sync(Options{Source: src, Target: dst, CleanTarget: true, TrashDir: ".trash/sync"})
+		if len(rep.Errors) != 0 || rep.Copied != 2 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		got := treeContents(t, dst)
+		for name := range got {
+			if strings.HasPrefix(name, ".trash") {
+				delete(got, name)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v want %v", got, want)
+		}
+		if data, err := os.ReadFile(filepath.Join(dst, ".trash", "sync", "old", "deep", "c.txt")); err != nil || string(data) != "c" {
+			t.Fatalf("expected the old content in the trash: %q, %v", data, err)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		src, dst := setup(t)
+		before := treeContents(t, dst)
+		rep := Sync(Options{Source: src, Target: dst, CleanTarget: true, DryRun: true})
+		if len(rep.Errors) != 0 || rep.Copied != 2 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if got := treeContents(t, dst); !reflect.DeepEqual(got, before) {
+			t.Fatalf("dry run changed the target: %v", got)
+		}
+	})
+
+	t.Run("source inside target", func(t *testing.T) {
+		dst := t.TempDir()
+		src := filepath.Join(dst, "src")
+		mustWrite(t, filepath.Join(src, "a.txt"), "a")
+		rep := Sync(Options{Source: src, Target: dst, CleanTarget: true})
+		if len(rep.Errors) != 1 || !strings.Contains(rep.Errors[0].Error(), "inside target") {
+			t.Fatalf("expected a refusal, got %v", rep.Errors)
+		}
+		if _, err := os.Stat(filepath.Join(src, "a.txt")); err != nil {
+			t.Fatalf("source damaged: %v", err)
+		}
+	})
+}
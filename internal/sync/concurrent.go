@@ -0,0 +1,37 @@
+package sync
+
+import "io/fs"
+
+// copyStable runs write, the copy of rel as described by info, and with
+// Options.DetectConcurrentModification checks that the source did not change meanwhile,
+// copying it once more if it did. It returns the source info the final copy was made with.
+func (r *runner) copyStable(rel string, info fs.FileInfo, write func(fs.FileInfo) error) (fs.FileInfo, error) {
+	err := r.retryNoSpace(rel, func() error { return write(info) })
+	if err != nil || !r.opt.DetectConcurrentModification {
+		return info, err
+	}
+	now, changed := r.sourceChanged(rel, info)
+	if !changed {
+		return info, nil
+	}
+	r.opt.Logger.Printf("WARN: source %s modified during copy; copying again", r.srcPath(rel))
+	info = now
+	if err := r.retryNoSpace(rel, func() error { return write(info) }); err != nil {
+		return info, err
+	}
+	if _, changed := r.sourceChanged(rel, info); changed {
+		r.opt.Logger.Printf("WARN: source %s modified during copy again; target may be inconsistent", r.srcPath(rel))
+		r.rep.ModifiedDuringCopy++
+	}
+	return info, nil
+}
+
+// sourceChanged stats rel again and reports whether its size or mod-time differ from info.
+// A source that cannot be stat-ed any more counts as unchanged; the next run handles it.
+func (r *runner) sourceChanged(rel string, info fs.FileInfo) (fs.FileInfo, bool) {
+	now, err := fs.Stat(r.src, rel)
+	if err != nil {
+		return info, false
+	}
+	return now, now.Size() != info.Size() || !now.ModTime().Equal(info.ModTime())
+}
@@ -0,0 +1,81 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// mutatingFS is a MapFS source whose file a.txt is rewritten each time a reader closes it,
+// for the first mutations closes, like a log still being appended to.
+type mutatingFS struct {
+	fstest.MapFS
+	mutations int
+	closes    int
+}
+
+func (m *mutatingFS) Open(name string) (fs.File, error) {
+	f, err := m.MapFS.Open(name)
+	if err != nil || name != "a.txt" {
+		return f, err
+	}
+	return &mutatingFile{File: f, fsys: m}, nil
+}
+
+type mutatingFile struct {
+	fs.File
+	fsys *mutatingFS
+}
+
+func (f *mutatingFile) Close() error {
+	m := f.fsys
+	m.closes++
+	if m.closes <= m.mutations {
+		old := m.MapFS["a.txt"]
+		m.MapFS["a.txt"] = &fstest.MapFile{
+			Data:    []byte(fmt.Sprintf("version %d", m.closes+1)),
+			ModTime: old.ModTime.Add(time.Second),
+		}
+	}
+	return f.File.Close()
+}
+
+func TestDetectConcurrentModification(t *testing.T) {
+	tests := []struct {
+		name         string
+		mutations    int
+		wantData     string
+		wantModified int
+	}{
+		{name: "settles", mutations: 1, wantData: "version 2"},
+		{name: "keeps changing", mutations: 100, wantData: "version 2", wantModified: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &mutatingFS{MapFS: fstest.MapFS{"a.txt": {Data: []byte("version 1"), ModTime: time.Now()}}, mutations: tt.mutations}
+			dst := newMemFS()
+			var logs bytes.Buffer
+			rep := SyncFS(src, dst, Options{DetectConcurrentModification: true, Logger: log.New(&logs, "", 0)})
+			if len(rep.Errors) != 0 || rep.ModifiedDuringCopy != tt.wantModified {
+				t.Fatalf("unexpected rep: %+v", *rep)
+			}
+			if got := string(dst.MapFS["a.txt"].Data); got != tt.wantData {
+				t.Fatalf("target holds %q, want %q", got, tt.wantData)
+			}
+			if !strings.Contains(logs.String(), "modified during copy") {
+				t.Fatalf("no warning logged:\n%s", logs.String())
+			}
+		})
+	}
+
+	src := &mutatingFS{MapFS: fstest.MapFS{"a.txt": {Data: []byte("version 1"), ModTime: time.Now()}}, mutations: 1}
+	dst := newMemFS()
+	if rep := SyncFS(src, dst, Options{}); len(rep.Errors) != 0 || string(dst.MapFS["a.txt"].Data) != "version 1" {
+		t.Fatalf("without the option the first copy must stand: %+v", *rep)
+	}
+}
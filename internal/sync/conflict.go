@@ -0,0 +1,183 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ConflictResolution is the Options.OnConflict.
+type ConflictResolution int
+
+const (
+	// ResolveOverwrite replaces a target file newer than its source like any other.
+	ResolveOverwrite ConflictResolution = iota
+	// ResolveKeepBoth first renames a target file newer than its source after
+	// Options.ConflictNameTemplate, so both versions end up side by side.
+	ResolveKeepBoth
+)
+
+var conflictResolutionNames = map[ConflictResolution]string{
+	ResolveOverwrite: "overwrite",
+	ResolveKeepBoth:  "keep-both",
+}
+
+func (c ConflictResolution) String() string {
+	if name, ok := conflictResolutionNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("ConflictResolution(%d)", int(c))
+}
+
+// ParseConflictResolution parses the CLI spelling of a ConflictResolution ("overwrite", "keep-both").
+func ParseConflictResolution(s string) (ConflictResolution, error) {
+	for c, name := range conflictResolutionNames {
+		if name == s {
+			return c, nil
+		}
+	}
+	return ResolveOverwrite, fmt.Errorf("unknown conflict resolution %q", s)
+}
+
+// DefaultConflictNameTemplate is used by ResolveKeepBoth when Options.ConflictNameTemplate is empty.
+const DefaultConflictNameTemplate = "{name}.conflict-{host}-{ts}{ext}"
+
+// conflictTimeFormat formats the {ts} placeholder.
+const conflictTimeFormat = "20060102-150405"
+
+// conflictPlaceholders maps each template placeholder to the pattern matching its values.
+var conflictPlaceholders = map[string]string{
+	"name": ".*",
+	"ext":  ".*",
+	"host": ".*",
+	"ts":   "[0-9]{8}-[0-9]{6}",
+}
+
+// parseConflictTemplate validates a ConflictNameTemplate and returns a pattern matching
+// the base names it produces.
+func parseConflictTemplate(tmpl string) (*regexp.Regexp, error) {
+	if strings.ContainsAny(tmpl, `/\`) {
+		return nil, errors.New("must not contain a path separator")
+	}
+	var pat strings.Builder
+	pat.WriteString("^")
+	hasName := false
+	for rest := tmpl; rest != ""; {
+		open := strings.IndexByte(rest, '{')
+		if close := strings.IndexByte(rest, '}'); close >= 0 && (open < 0 || close < open) {
+			return nil, errors.New("unbalanced '}'")
+		}
+		if open < 0 {
+			pat.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		pat.WriteString(regexp.QuoteMeta(rest[:open]))
+		close := strings.IndexByte(rest[open:], '}')
+		if close < 0 {
+			return nil, errors.New("unbalanced '{'")
+		}
+		key := rest[open+1 : open+close]
+		p, ok := conflictPlaceholders[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown placeholder {%s}", key)
+		}
+		hasName = hasName || key == "name"
+		pat.WriteString(p)
+		rest = rest[open+close+1:]
+	}
+	if !hasName {
+		return nil, errors.New("missing {name}")
+	}
+	pat.WriteString("$")
+	return regexp.Compile(pat.String())
+}
+
+// conflictName returns the name the target file dstRel is kept under by ResolveKeepBoth,
+// in the same directory.
+func (r *runner) conflictName(dstRel string) string {
+	dir, base := path.Split(dstRel)
+	ext := path.Ext(base)
+	if ext == base {
+		// A dot file such as .profile has no extension
+		ext = ""
+	}
+	return dir + strings.NewReplacer(
+		"{name}", strings.TrimSuffix(base, ext),
+		"{ext}", ext,
+		"{host}", r.hostname,
+		"{ts}", r.start.Format(conflictTimeFormat),
+	).Replace(r.conflictTemplate())
+}
+
+// conflictTemplate returns the Options.ConflictNameTemplate in effect.
+func (r *runner) conflictTemplate() string {
+	if r.opt.ConflictNameTemplate != "" {
+		return r.opt.ConflictNameTemplate
+	}
+	return DefaultConflictNameTemplate
+}
+
+// isConflictCopy reports whether a target name was produced by ResolveKeepBoth,
+// which DeleteMissing then leaves alone.
+func (r *runner) isConflictCopy(rel string) bool {
+	return r.conflictRe != nil && r.conflictRe.MatchString(path.Base(rel))
+}
+
+// keepBoth renames the target file dstRel aside when it is newer than its source
+// (Options.OnConflict ResolveKeepBoth), and reports whether it may be overwritten.
+func (r *runner) keepBoth(rel, dstRel string, src, dst fs.FileInfo) bool {
+	if !targetNewer(src, dst, r.opt.ModTimeTolerance) {
+		return true
+	}
+	name := r.conflictName(dstRel)
+	r.opt.Logger.Printf("CONFLICT: %s is newer than %s (%s > %s); keeping it as %s",
+		r.dstPath(dstRel), r.srcPath(rel),
+		dst.ModTime().Format(time.RFC3339), src.ModTime().Format(time.RFC3339), r.dstPath(name))
+	r.rep.Conflicts++
+	if r.opt.DryRun {
+		return true
+	}
+	if err := r.dst.Rename(dstRel, name); err != nil {
+		err = fmt.Errorf("keep conflicting %s: %w", r.dstPath(dstRel), err)
+		r.opt.Logger.Printf("ERR: %v", err)
+		r.rep.addErr(err)
+		return false
+	}
+	return true
+}
+
+// hostname returns the host name for the {host} placeholder.
+func hostname() string {
+	h, err := os.Hostname()
+	if err != nil || h == "" {
+		return "unknown"
+	}
+	return h
+}
+
+// warnNewerTarget logs and counts a conflict when the target file about to be replaced
+// is newer than its source (Options.WarnOnNewerTarget), which may mean an edit made
+// in the target is lost.
+func (r *runner) warnNewerTarget(rel, dstRel string, src, dst fs.FileInfo) {
+	if !targetNewer(src, dst, r.opt.ModTimeTolerance) {
+		return
+	}
+	r.opt.Logger.Printf("CONFLICT: %s is newer than %s (%s > %s); overwriting",
+		r.dstPath(dstRel), r.srcPath(rel),
+		dst.ModTime().Format(time.RFC3339), src.ModTime().Format(time.RFC3339))
+	r.rep.Conflicts++
+}
+
+// targetNewer reports whether dst was modified after src, by more than tolerance
+// or, without one, in a later second.
+func targetNewer(src, dst fs.FileInfo, tolerance time.Duration) bool {
+	if tolerance > 0 {
+		return dst.ModTime().Sub(src.ModTime()) > tolerance
+	}
+	return truncateToSeconds(dst.ModTime()).After(truncateToSeconds(src.ModTime()))
+}
@@ -0,0 +1,90 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestWarnOnNewerTarget(t *testing.T) {
+	now := time.Now()
+	for _, tc := range []struct {
+		name      string
+		dstMtime  time.Time
+		warn      bool
+		conflicts int
+	}{
+		{"newer target", now, true, 1},
+		{"older target", now.Add(-2 * time.Hour), true, 0},
+		{"option off", now, false, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src, dst := t.TempDir(), t.TempDir()
+			writeWithModTime(t, filepath.Join(src, "doc.txt"), "source", 0o644, now.Add(-time.Hour))
+			// Same size, edited in the target
+			writeWithModTime(t, filepath.Join(dst, "doc.txt"), "target", 0o644, tc.dstMtime)
+
+			rep := Sync(Options{Source: src, Target: dst, WarnOnNewerTarget: tc.warn})
+			if len(rep.Errors) != 0 || rep.Overwritten != 1 || rep.Conflicts != tc.conflicts {
+				t.Fatalf("unexpected rep: %+v", *rep)
+			}
+			if b, err := os.ReadFile(filepath.Join(dst, "doc.txt")); err != nil || string(b) != "source" {
+				t.Fatalf("target not overwritten: %q, %v", b, err)
+			}
+		})
+	}
+}
+
+func TestConflictNameTemplate(t *testing.T) {
+	now := time.Now()
+	src, dst := t.TempDir(), t.TempDir()
+	for _, root := range []string{src, dst} {
+		if err := os.Mkdir(filepath.Join(root, "dir"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeWithModTime(t, filepath.Join(src, "dir", "doc.txt"), "source", 0o644, now.Add(-time.Hour))
+	writeWithModTime(t, filepath.Join(dst, "dir", "doc.txt"), "target", 0o644, now)
+
+	opt := Options{Source: src, Target: dst, DeleteMissing: true, OnConflict: ResolveKeepBoth,
+		ConflictNameTemplate: "{name} (from {host} at {ts}){ext}"}
+	rep := Sync(opt)
+	if len(rep.Errors) != 0 || rep.Overwritten != 1 || rep.Conflicts != 1 || rep.Deleted != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	host, _ := os.Hostname()
+	entries, err := os.ReadDir(filepath.Join(dst, "dir"))
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("expected the incoming file and one conflict copy: %v, %v", entries, err)
+	}
+	re := regexp.MustCompile(`^doc \(from ` + regexp.QuoteMeta(host) + ` at [0-9]{8}-[0-9]{6}\)\.txt$`)
+	var kept string
+	for _, e := range entries {
+		if e.Name() != "doc.txt" {
+			kept = e.Name()
+		}
+	}
+	if !re.MatchString(kept) {
+		t.Fatalf("conflict copy %q does not follow the template", kept)
+	}
+	if b, err := os.ReadFile(filepath.Join(dst, "dir", kept)); err != nil || string(b) != "target" {
+		t.Fatalf("conflict copy: %q, %v", b, err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dst, "dir", "doc.txt")); err != nil || string(b) != "source" {
+		t.Fatalf("incoming file: %q, %v", b, err)
+	}
+
+	// The copy survives the next run's DeleteMissing
+	if rep := Sync(opt); len(rep.Errors) != 0 || rep.Deleted != 0 || rep.Conflicts != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+
+	for _, tmpl := range []string{"{base}.old", "{name", "name}{ext}", "conflict/{name}", "{ext}.bak"} {
+		opt.ConflictNameTemplate = tmpl
+		if rep := Sync(opt); len(rep.Errors) != 1 || rep.Copied+rep.Overwritten != 0 {
+			t.Fatalf("template %q: expected a startup error, got %+v", tmpl, *rep)
+		}
+	}
+}
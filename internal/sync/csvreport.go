@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"strconv"
+)
+
+// CSV report actions, the values of the action column of Options.CSVReport.
+const (
+	CSVCopy      = "copy"
+	CSVOverwrite = "overwrite"
+	CSVAppend    = "append"
+	CSVDelete    = "delete"
+	CSVSkip      = "skip"
+)
+
+// csvReport writes one row per processed entry to the file named by Options.CSVReport.
+type csvReport struct {
+	f *os.File
+	w *csv.Writer
+}
+
+// openCSVReport creates (or truncates) the CSV file at p and writes the header row.
+func openCSVReport(p string) (*csvReport, error) {
+	f, err := os.Create(p)
+	if err != nil {
+		return nil, err
+	}
+	c := &csvReport{f: f, w: csv.NewWriter(f)}
+	if err := c.w.Write([]string{"action", "rel", "bytes", "error"}); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *csvReport) row(action, rel string, bytes int64, err error) error {
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	return c.w.Write([]string{action, rel, strconv.FormatInt(bytes, 10), msg})
+}
+
+// close flushes the rows and closes the file.
+func (c *csvReport) close() error {
+	c.w.Flush()
+	return errors.Join(c.w.Error(), c.f.Close())
+}
+
+// record adds a row for the target name rel to the CSV report, if one is being written.
+// A failing write is reported once, when the report is closed.
+func (r *runner) record(action, rel string, bytes int64, err error) {
+	if r.csv == nil {
+		return
+	}
+	_ = r.csv.row(action, rel, bytes, err)
+}
+
+// recordCopy adds a copy or overwrite row.
+func (r *runner) recordCopy(dstRel string, bytes int64, overwrite bool, err error) {
+	if overwrite {
+		r.record(CSVOverwrite, dstRel, bytes, err)
+	} else {
+		r.record(CSVCopy, dstRel, bytes, err)
+	}
+}
+
+// closeCSVReport finishes the CSV report.
+func (r *runner) closeCSVReport() {
+	if err := r.csv.close(); err != nil {
+		r.opt.Logger.Printf("ERR: write CSV report %s: %v", r.opt.CSVReport, err)
+		r.rep.addErr(err)
+	}
+	r.csv = nil
+}
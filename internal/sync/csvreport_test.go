@@ -0,0 +1,115 @@
+package sync
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func readCSVReport(t *testing.T, p string) [][]string {
+	t.Helper()
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("parse %s: %v", p, err)
+	}
+	if len(rows) == 0 || !reflect.DeepEqual(rows[0], []string{"action", "rel", "bytes", "error"}) {
+		t.Fatalf("missing header: %v", rows)
+	}
+	return rows[1:]
+}
+
+func TestCSVReport(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	report := filepath.Join(t.TempDir(), "report.csv")
+
+	mustWrite(t, filepath.Join(src, "new.txt"), "new")
+	mustWrite(t, filepath.Join(src, "dir", "a, \"quoted\" name.txt"), "comma")
+	mustWrite(t, filepath.Join(src, "same.txt"), "same")
+	mustWrite(t, filepath.Join(src, ".hidden"), "h")
+	mustWrite(t, filepath.Join(src, "edit.txt"), "v2")
+	mustWrite(t, filepath.Join(dst, "same.txt"), "same")
+	mustWrite(t, filepath.Join(dst, "orphan.txt"), "o")
+	writeWithModTime(t, filepath.Join(dst, "edit.txt"), "v1", 0o644, time.Now().Add(-time.Hour))
+	now := time.Now()
+	if err := os.Chtimes(filepath.Join(dst, "same.txt"), now, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(src, "same.txt"), now, now); err != nil {
+		t.Fatal(err)
+	}
+
+	rep := Sync(Options{Source: src, Target: dst, DeleteMissing: true, SkipHidden: true, CSVReport: report})
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+
+	counts := map[string]int{}
+	var bytes int64
+	names := map[string]string{}
+	for _, row := range readCSVReport(t, report) {
+		if row[3] != "" {
+			t.Fatalf("unexpected error row: %v", row)
+		}
+		n, err := strconv.ParseInt(row[2], 10, 64)
+		if err != nil {
+			t.Fatalf("bytes of %v: %v", row, err)
+		}
+		counts[row[0]]++
+		bytes += n
+		names[row[1]] = row[0]
+	}
+	want := map[string]int{CSVCopy: rep.Copied, CSVOverwrite: rep.Overwritten, CSVDelete: rep.Deleted, CSVSkip: rep.Skipped}
+	if !reflect.DeepEqual(counts, want) {
+		t.Fatalf("rows %v do not match report %v", counts, want)
+	}
+	if rep.Copied != 2 || rep.Overwritten != 1 || rep.Deleted != 1 || rep.Skipped != 2 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if bytes != rep.BytesCopied {
+		t.Fatalf("bytes: got %d want %d", bytes, rep.BytesCopied)
+	}
+	if names["dir/a, \"quoted\" name.txt"] != CSVCopy || names["orphan.txt"] != CSVDelete {
+		t.Fatalf("unexpected rows: %v", names)
+	}
+}
+
+func TestCSVReportErrors(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	report := filepath.Join(t.TempDir(), "report.csv")
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+	// A directory in the way makes the copy fail
+	if err := os.MkdirAll(filepath.Join(dst, "a.txt", "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	rep := Sync(Options{Source: src, Target: dst, CSVReport: report})
+	if len(rep.Errors) != 1 {
+		t.Fatalf("expected one error, got %v", rep.Errors)
+	}
+	rows := readCSVReport(t, report)
+	if len(rows) != 1 || rows[0][0] != CSVOverwrite || rows[0][1] != "a.txt" || rows[0][3] == "" {
+		t.Fatalf("expected a failed overwrite row, got %v", rows)
+	}
+}
+
+func TestCSVReportUnwritable(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+
+	rep := Sync(Options{Source: src, Target: dst, CSVReport: filepath.Join(t.TempDir(), "missing", "report.csv")})
+	if len(rep.Errors) != 1 || rep.Copied != 0 {
+		t.Fatalf("expected the run to stop before copying: %+v", *rep)
+	}
+}
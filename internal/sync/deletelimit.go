@@ -0,0 +1,58 @@
+package sync
+
+import "fmt"
+
+// DeleteLimitPolicy is what DeleteMissing does when more than Options.MaxDeletes files would be deleted.
+type DeleteLimitPolicy int
+
+const (
+	// DeleteLimitAbort deletes nothing and records an error.
+	DeleteLimitAbort DeleteLimitPolicy = iota
+	// DeleteLimitStop deletes the first MaxDeletes files in walk order and records an error for the rest.
+	DeleteLimitStop
+)
+
+var deleteLimitPolicyNames = map[DeleteLimitPolicy]string{
+	DeleteLimitAbort: "abort",
+	DeleteLimitStop:  "stop",
+}
+
+func (p DeleteLimitPolicy) String() string {
+	if s, ok := deleteLimitPolicyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("DeleteLimitPolicy(%d)", int(p))
+}
+
+// ParseDeleteLimitPolicy parses the CLI spelling of a DeleteLimitPolicy ("abort", "stop").
+func ParseDeleteLimitPolicy(s string) (DeleteLimitPolicy, error) {
+	for p, name := range deleteLimitPolicyNames {
+		if name == s {
+			return p, nil
+		}
+	}
+	return DeleteLimitAbort, fmt.Errorf("unknown delete limit policy %q", s)
+}
+
+// removeLimited deletes the orphans collected by the delete pass, honoring Options.MaxDeletes.
+func (r *runner) removeLimited(orphans []string) {
+	limit := r.opt.MaxDeletes
+	if len(orphans) <= limit {
+		for _, rel := range orphans {
+			r.removeEntry(rel)
+		}
+		return
+	}
+	if r.opt.DeleteLimit == DeleteLimitStop {
+		for _, rel := range orphans[:limit] {
+			r.removeEntry(rel)
+		}
+		err := fmt.Errorf("delete limit of %d files reached; %d files missing in source not deleted", limit, len(orphans)-limit)
+		r.opt.Logger.Printf("ERR: %v", err)
+		r.rep.addErr(err)
+		return
+	}
+	err := fmt.Errorf("%d files missing in source exceed the delete limit of %d; nothing deleted", len(orphans), limit)
+	r.opt.Logger.Printf("ERR: %v", err)
+	r.rep.addErr(err)
+}
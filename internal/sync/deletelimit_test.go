@@ -0,0 +1,73 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMaxDeletes(t *testing.T) {
+	setup := func(t *testing.T) (string, string) {
+		src, dst := t.TempDir(), t.TempDir()
+		mustWrite(t, filepath.Join(src, "keep.txt"), "k")
+		mustWrite(t, filepath.Join(dst, "keep.txt"), "k")
+		for i := 0; i < 5; i++ {
+			mustWrite(t, filepath.Join(dst, fmt.Sprintf("orphan%d.txt", i)), "o")
+		}
+		return src, dst
+	}
+	remaining := func(t *testing.T, dst string) int {
+		matches, err := filepath.Glob(filepath.Join(dst, "orphan*.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(matches)
+	}
+
+	t.Run("abort", func(t *testing.T) {
+		src, dst := setup(t)
+		rep := Sync(Options{Source: src, Target: dst, DeleteMissing: true, MaxDeletes: 3})
+		if rep.Deleted != 0 || len(rep.Errors) != 1 || !strings.Contains(rep.Errors[0].Error(), "nothing deleted") {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if n := remaining(t, dst); n != 5 {
+			t.Fatalf("expected all 5 orphans kept, %d left", n)
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		src, dst := setup(t)
+		rep := Sync(Options{Source: src, Target: dst, DeleteMissing: true, MaxDeletes: 3, DeleteLimit: DeleteLimitStop})
+		if rep.Deleted != 3 || len(rep.Errors) != 1 || !strings.Contains(rep.Errors[0].Error(), "2 files missing in source not deleted") {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if n := remaining(t, dst); n != 2 {
+			t.Fatalf("expected 2 orphans kept, %d left", n)
+		}
+	})
+
+	t.Run("within limit", func(t *testing.T) {
+		src, dst := setup(t)
+		rep := Sync(Options{Source: src, Target: dst, DeleteMissing: true, MaxDeletes: 5})
+		if rep.Deleted != 5 || len(rep.Errors) != 0 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if _, err := os.Stat(filepath.Join(dst, "keep.txt")); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestParseDeleteLimitPolicy(t *testing.T) {
+	for _, p := range []DeleteLimitPolicy{DeleteLimitAbort, DeleteLimitStop} {
+		got, err := ParseDeleteLimitPolicy(p.String())
+		if err != nil || got != p {
+			t.Fatalf("round trip %v: got %v, %v", p, got, err)
+		}
+	}
+	if _, err := ParseDeleteLimitPolicy("later"); err == nil {
+		t.Fatalf("expected error for unknown policy")
+	}
+}
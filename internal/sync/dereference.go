@@ -0,0 +1,53 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// dereferenceRoots returns opt with its directory roots resolved for Options.DereferenceRoots.
+// Archive roots keep their names, which their format is detected from.
+func dereferenceRoots(opt Options) (Options, error) {
+	resolve := func(root string) (string, error) {
+		real, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			return root, fmt.Errorf("resolve %s: %w", root, err)
+		}
+		return real, nil
+	}
+
+	var err error
+	if len(opt.Sources) > 0 {
+		sources := make([]string, len(opt.Sources))
+		for i, root := range opt.Sources {
+			sources[i] = root
+			if DetectArchive(root) != NotArchive {
+				continue
+			}
+			if sources[i], err = resolve(root); err != nil {
+				return opt, err
+			}
+		}
+		opt.Sources = sources
+	} else if DetectArchive(opt.Source) == NotArchive {
+		if opt.Source, err = resolve(opt.Source); err != nil {
+			return opt, err
+		}
+	}
+
+	if opt.TargetSymlink != TargetSymlinkFollow || ArchiveTargetKind(opt.Target) != NotArchive {
+		return opt, nil
+	}
+	target, err := resolve(opt.Target)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Missing targets are created as usual
+		return opt, nil
+	}
+	if err != nil {
+		return opt, err
+	}
+	opt.Target = target
+	return opt, nil
+}
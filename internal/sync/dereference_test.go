@@ -0,0 +1,74 @@
+package sync
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDereferenceRoots(t *testing.T) {
+	base := t.TempDir()
+	real := filepath.Join(base, "data", "real")
+	mustWrite(t, filepath.Join(real, "dir", "a.txt"), "a")
+	mustWrite(t, filepath.Join(base, "data", "top.txt"), "t")
+	links := filepath.Join(base, "links")
+	if err := os.MkdirAll(links, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	srcLink := filepath.Join(links, "src")
+	if err := os.Symlink(real, srcLink); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	dst := filepath.Join(base, "dst")
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dstLink := filepath.Join(links, "dst")
+	if err := os.Symlink(dst, dstLink); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("symlinked roots", func(t *testing.T) {
+		var logs bytes.Buffer
+		rep := Sync(Options{Source: srcLink, Target: dstLink, DereferenceRoots: true, Logger: log.New(&logs, "", 0)})
+		if len(rep.Errors) != 0 || rep.Copied != 1 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		want := map[string]string{"dir": "/", "dir/a.txt": "a"}
+		if got := treeContents(t, dst); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v want %v", got, want)
+		}
+		// Paths are reported below the resolved roots
+		realDst, err := filepath.EvalSymlinks(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(logs.String(), filepath.Join(realDst, "dir", "a.txt")) {
+			t.Fatalf("expected resolved target path in log:\n%s", logs.String())
+		}
+	})
+
+	t.Run("dot-dot through a symlink", func(t *testing.T) {
+		// The OS resolves links/src/.. to data; a lexical join would make it links
+		out := t.TempDir()
+		rep := Sync(Options{Source: srcLink + string(filepath.Separator) + "..", Target: out, DereferenceRoots: true})
+		if len(rep.Errors) != 0 {
+			t.Fatalf("unexpected errors: %v", rep.Errors)
+		}
+		want := map[string]string{"top.txt": "t", "real": "/", "real/dir": "/", "real/dir/a.txt": "a"}
+		if got := treeContents(t, out); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v want %v", got, want)
+		}
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		rep := Sync(Options{Source: filepath.Join(links, "nope"), Target: t.TempDir(), DereferenceRoots: true})
+		if len(rep.Errors) != 1 || rep.Copied != 0 {
+			t.Fatalf("expected one error, got %+v", *rep)
+		}
+	})
+}
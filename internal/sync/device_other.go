@@ -0,0 +1,15 @@
+//go:build !unix
+
+package sync
+
+import "io/fs"
+
+// deviceID is not available on this platform, which makes OneFileSystem a no-op.
+func deviceID(fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+// fileID is not available on this platform, which disables the same-root check.
+func fileID(fs.FileInfo) (dev, ino uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,110 @@
+//go:build unix
+
+package sync
+
+import (
+	"io/fs"
+	"path"
+	"reflect"
+	"syscall"
+	"testing"
+	"testing/fstest"
+)
+
+// devFS reports fake device ids for directories: entries below a prefix in mounts live on that device.
+type devFS struct {
+	fstest.MapFS
+	mounts map[string]uint64
+}
+
+func (d devFS) dev(name string) uint64 {
+	for p := name; ; p = path.Dir(p) {
+		if dev, ok := d.mounts[p]; ok {
+			return dev
+		}
+		if p == "." {
+			return 1
+		}
+	}
+}
+
+func (d devFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := d.MapFS.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return devInfo{FileInfo: info, dev: d.dev(name)}, nil
+}
+
+func (d devFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := d.MapFS.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range entries {
+		entries[i] = devEntry{DirEntry: e, dev: d.dev(path.Join(name, e.Name()))}
+	}
+	return entries, nil
+}
+
+type devEntry struct {
+	fs.DirEntry
+	dev uint64
+}
+
+func (e devEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return devInfo{FileInfo: info, dev: e.dev}, nil
+}
+
+type devInfo struct {
+	fs.FileInfo
+	dev uint64
+}
+
+func (i devInfo) Sys() any {
+	st := &syscall.Stat_t{}
+	// Dev is signed on some platforms, so set it without assuming its type.
+	v := reflect.ValueOf(&st.Dev).Elem()
+	if v.CanUint() {
+		v.SetUint(i.dev)
+	} else {
+		v.SetInt(int64(i.dev))
+	}
+	return st
+}
+
+func TestOneFileSystem(t *testing.T) {
+	src := devFS{
+		MapFS: fstest.MapFS{
+			"local.txt":         {Data: []byte("l")},
+			"home/user.txt":     {Data: []byte("u")},
+			"mnt/nfs/share.txt": {Data: []byte("s")},
+			"proc/1/status":     {Data: []byte("p")},
+		},
+		mounts: map[string]uint64{"mnt/nfs": 2, "proc": 3},
+	}
+
+	dst := newMemFS()
+	rep := SyncFS(src, dst, Options{OneFileSystem: true})
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+	if rep.Copied != 2 {
+		t.Fatalf("expected copied=2, got %+v", *rep)
+	}
+	for _, name := range []string{"mnt/nfs/share.txt", "proc/1/status", "proc"} {
+		if _, ok := dst.MapFS[name]; ok {
+			t.Fatalf("expected %s pruned", name)
+		}
+	}
+
+	// Without the option every device is descended into.
+	rep2 := SyncFS(src, newMemFS(), Options{})
+	if rep2.Copied != 4 {
+		t.Fatalf("expected copied=4 without OneFileSystem, got %+v", *rep2)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package sync
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// deviceID returns the id of the device holding the file described by info.
+func deviceID(info fs.FileInfo) (uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	// Dev is not uint64 on every platform.
+	return uint64(st.Dev), true
+}
+
+// fileID returns the device and inode numbers of the file described by info.
+func fileID(info fs.FileInfo) (dev, ino uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(st.Dev), uint64(st.Ino), true
+}
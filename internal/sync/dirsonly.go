@@ -0,0 +1,76 @@
+package sync
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// mkdirOnly creates a missing target directory in a DirsOnly run and counts it in Report.DirsCreated.
+// It returns false if that failed; the error has been recorded.
+func (r *runner) mkdirOnly(rel string) bool {
+	if _, err := r.dst.Stat(rel); err == nil {
+		return true
+	}
+	if !r.mkdir(rel) {
+		return false
+	}
+	r.opt.Logger.Printf("MKDIR: %s", r.dstPath(rel))
+	r.rep.DirsCreated++
+	return true
+}
+
+// deleteExtraDirs is the delete pass of a DirsOnly run: it removes target directories missing
+// in every source, deepest first. Directories that still hold files are kept with a warning.
+func (r *runner) deleteExtraDirs() {
+	opt, rep := r.opt, r.rep
+	var extra []string
+	err := fs.WalkDir(r.dst, ".", func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			opt.Logger.Printf("ERR: read %s: %v", r.dstPath(rel), err)
+			rep.addErr(err)
+			return nil
+		}
+		if rel == "." || !d.IsDir() {
+			return nil
+		}
+		if r.isTrash(rel) || (opt.SkipHidden && isHidden(d)) || (opt.UseDefaultExcludes && isExcluded(d)) {
+			return fs.SkipDir
+		}
+		if r.sanitized[rel] {
+			return nil
+		}
+		err = r.statSources(rel)
+		switch {
+		case err == nil:
+			if r.structureOnly(rel) {
+				return fs.SkipDir
+			}
+			return nil
+		case errors.Is(err, fs.ErrNotExist):
+			// Everything below is missing in the source as well
+			extra = append(extra, rel)
+			return nil
+		default:
+			opt.Logger.Printf("ERR: stat %s: %v", r.srcPath(rel), err)
+			rep.addErr(err)
+			return fs.SkipDir
+		}
+	})
+	if err != nil {
+		opt.Logger.Printf("ERR: walk target %s: %v", r.dstPath("."), err)
+		rep.addErr(err)
+	}
+
+	// The walk lists parents before their children
+	for i := len(extra) - 1; i >= 0; i-- {
+		rel := extra[i]
+		if !opt.DryRun {
+			if err := r.dst.Remove(rel); err != nil {
+				opt.Logger.Printf("WARN: keep dir %s: %v", r.dstPath(rel), err)
+				continue
+			}
+		}
+		opt.Logger.Printf("DELETE: dir %s (missing in source)", r.dstPath(rel))
+		r.countDeleted(rel)
+	}
+}
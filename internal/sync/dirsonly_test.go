@@ -0,0 +1,41 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDirsOnly(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "top.txt"), "t")
+	mustWrite(t, filepath.Join(src, "a", "b", "c", "deep.txt"), "d")
+	if err := os.MkdirAll(filepath.Join(src, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Existing in the target: a (kept), x/y (extra, empty), z (extra, holds a file)
+	mustWrite(t, filepath.Join(dst, "a", "old.txt"), "o")
+	if err := os.MkdirAll(filepath.Join(dst, "x", "y"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, filepath.Join(dst, "z", "keep.txt"), "k")
+
+	rep := Sync(Options{Source: src, Target: dst, DirsOnly: true, DeleteMissing: true})
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+	if rep.Copied != 0 || rep.Overwritten != 0 || rep.DirsCreated != 3 || rep.Deleted != 2 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+
+	want := map[string]string{"a": "/", "a/b": "/", "a/b/c": "/", "empty": "/", "z": "/", "a/old.txt": "o", "z/keep.txt": "k"}
+	if got := treeContents(t, dst); !reflect.DeepEqual(got, want) {
+		t.Fatalf("target: got %v want %v", got, want)
+	}
+
+	rep = Sync(Options{Source: src, Target: dst, DirsOnly: true})
+	if rep.DirsCreated != 0 || len(rep.Errors) != 0 {
+		t.Fatalf("second run: %+v", *rep)
+	}
+}
@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// DirStat counts the changes below one top-level target directory (Options.PerDirStats).
+type DirStat struct {
+	Copied      int
+	Overwritten int
+	Deleted     int
+	BytesCopied int64
+}
+
+// topDir returns the first segment of a target name; files directly in the root map to ".".
+func topDir(rel string) string {
+	if i := strings.IndexByte(rel, '/'); i >= 0 {
+		return rel[:i]
+	}
+	return "."
+}
+
+// addDirStat applies fn to the stats of rel's top-level directory when PerDirStats is set.
+func (r *runner) addDirStat(rel string, fn func(*DirStat)) {
+	if !r.opt.PerDirStats {
+		return
+	}
+	if r.rep.DirStats == nil {
+		r.rep.DirStats = map[string]DirStat{}
+	}
+	key := topDir(rel)
+	st := r.rep.DirStats[key]
+	fn(&st)
+	r.rep.DirStats[key] = st
+}
+
+// DirStatsTable renders DirStats as an aligned table sorted by directory,
+// or returns "" when there are none.
+func (r *Report) DirStatsTable() string {
+	if len(r.DirStats) == 0 {
+		return ""
+	}
+	dirs := make([]string, 0, len(r.DirStats))
+	for dir := range r.DirStats {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "DIR\tCOPIED\tOVERWRITTEN\tDELETED\tBYTES\t")
+	for _, dir := range dirs {
+		st := r.DirStats[dir]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t\n", dir, st.Copied, st.Overwritten, st.Deleted, st.BytesCopied)
+	}
+	w.Flush()
+	return b.String()
+}
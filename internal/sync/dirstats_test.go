@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestPerDirStats(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	mtime := time.Now().Add(-time.Hour)
+	src := fstest.MapFS{
+		"root.txt":          {Data: []byte("r"), ModTime: mtime},
+		"photos/a.jpg":      {Data: []byte("aaaa"), ModTime: mtime},
+		"photos/2024/b.jpg": {Data: []byte("bb"), ModTime: mtime},
+		"docs/c.txt":        {Data: []byte("ccc"), ModTime: mtime},
+		"music/same.mp3":    {Data: []byte("s"), ModTime: mtime},
+	}
+	dst := newMemFS()
+	dst.MapFS["docs/c.txt"] = &fstest.MapFile{Data: []byte("old"), ModTime: old}
+	dst.MapFS["docs/gone.txt"] = &fstest.MapFile{Data: []byte("g")}
+	dst.MapFS["music/same.mp3"] = &fstest.MapFile{Data: []byte("s"), ModTime: mtime}
+	dst.MapFS["videos/x.mp4"] = &fstest.MapFile{Data: []byte("x")}
+	dst.MapFS["videos/y.mp4"] = &fstest.MapFile{Data: []byte("y")}
+
+	rep := SyncFS(src, dst, Options{DeleteMissing: true, PerDirStats: true})
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+	want := map[string]DirStat{
+		".":      {Copied: 1, BytesCopied: 1},
+		"photos": {Copied: 2, BytesCopied: 6},
+		"docs":   {Overwritten: 1, Deleted: 1, BytesCopied: 3},
+		"videos": {Deleted: 2},
+	}
+	if !reflect.DeepEqual(rep.DirStats, want) {
+		t.Fatalf("DirStats:\n got %+v\nwant %+v", rep.DirStats, want)
+	}
+
+	table := rep.DirStatsTable()
+	lines := strings.Split(strings.TrimSpace(table), "\n")
+	if len(lines) != 5 || !strings.Contains(lines[0], "OVERWRITTEN") {
+		t.Fatalf("unexpected table:\n%s", table)
+	}
+	for i, dir := range []string{".", "docs", "photos", "videos"} {
+		if !strings.HasPrefix(strings.TrimSpace(lines[i+1]), dir+" ") {
+			t.Fatalf("row %d should be %q:\n%s", i+1, dir, table)
+		}
+	}
+}
+
+func TestPerDirStatsOff(t *testing.T) {
+	rep := SyncFS(fstest.MapFS{"a/b.txt": {Data: []byte("b")}}, newMemFS(), Options{})
+	if rep.DirStats != nil || rep.DirStatsTable() != "" {
+		t.Fatalf("expected no DirStats, got %v", rep.DirStats)
+	}
+}
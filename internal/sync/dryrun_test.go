@@ -0,0 +1,44 @@
+package sync
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	mustWrite(t, filepath.Join(src, "new.txt"), "n")
+	mustWrite(t, filepath.Join(src, "sub", "changed.txt"), "changed content")
+	mustWrite(t, filepath.Join(dst, "sub", "changed.txt"), "old")
+	mustWrite(t, filepath.Join(dst, "orphan.txt"), "o")
+
+	var buf bytes.Buffer
+	rep := Sync(Options{Source: src, Target: dst, DeleteMissing: true, DryRun: true, Logger: log.New(&buf, "", 0)})
+	if rep.Copied != 1 || rep.Overwritten != 1 || rep.Deleted != 1 || len(rep.Errors) != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if !strings.Contains(buf.String(), "DRY-RUN COPY: ") {
+		t.Fatalf("expected dry-run log prefix, got:\n%s", buf.String())
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "new.txt")); !os.IsNotExist(err) {
+		t.Fatalf("dry run must not copy, err=%v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dst, "sub", "changed.txt")); string(b) != "old" {
+		t.Fatalf("dry run must not overwrite, got %q", b)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "orphan.txt")); err != nil {
+		t.Fatalf("dry run must not delete, err=%v", err)
+	}
+
+	// The real run does exactly what the dry run announced.
+	real := Sync(Options{Source: src, Target: dst, DeleteMissing: true})
+	if real.Copied != rep.Copied || real.Overwritten != rep.Overwritten || real.Deleted != rep.Deleted {
+		t.Fatalf("dry run %+v does not match real run %+v", *rep, *real)
+	}
+}
@@ -0,0 +1,365 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted target files start with a header followed by the content sealed with AES-256-GCM in
+// chunks of encChunkSize bytes:
+//
+//	magic (8) | salt (16) | plaintext size (8) | plaintext HMAC-SHA256 (32) | nonce prefix (7)
+//
+// The key is derived from the passphrase and the salt with scrypt. Each chunk's nonce is the
+// prefix, the chunk counter (4) and a last-chunk flag (1); the header is the additional data of
+// every chunk, so neither the header nor the order and number of chunks can be tampered with.
+// The HMAC, keyed from the passphrase, lets a run tell unchanged files without decrypting them
+// and without storing a plain hash of the content on the target.
+const (
+	encMagic       = "SYNCENC1"
+	encSaltLen     = 16
+	encPrefixLen   = 7
+	encHeaderLen   = len(encMagic) + encSaltLen + 8 + sha256.Size + encPrefixLen
+	encChunkSize   = 64 * 1024
+	encScryptN     = 1 << 15
+	encScryptR     = 8
+	encScryptP     = 1
+	encKeyMaterial = 64
+)
+
+// errNotEncrypted is returned for target files without the encryption header.
+var errNotEncrypted = errors.New("not an encrypted file")
+
+type encHeader struct {
+	salt   [encSaltLen]byte
+	size   int64
+	mac    [sha256.Size]byte
+	prefix [encPrefixLen]byte
+}
+
+func (h *encHeader) marshal() []byte {
+	b := make([]byte, 0, encHeaderLen)
+	b = append(b, encMagic...)
+	b = append(b, h.salt[:]...)
+	b = binary.BigEndian.AppendUint64(b, uint64(h.size))
+	b = append(b, h.mac[:]...)
+	return append(b, h.prefix[:]...)
+}
+
+func readEncHeader(r io.Reader) (*encHeader, []byte, error) {
+	b := make([]byte, encHeaderLen)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil, errNotEncrypted
+		}
+		return nil, nil, err
+	}
+	if string(b[:len(encMagic)]) != encMagic {
+		return nil, nil, errNotEncrypted
+	}
+	h := &encHeader{}
+	rest := b[len(encMagic):]
+	rest = rest[copy(h.salt[:], rest):]
+	h.size = int64(binary.BigEndian.Uint64(rest))
+	rest = rest[8:]
+	rest = rest[copy(h.mac[:], rest):]
+	copy(h.prefix[:], rest)
+	return h, b, nil
+}
+
+// encKeys holds the keys derived from a passphrase and one salt.
+type encKeys struct {
+	aead cipher.AEAD
+	mac  []byte
+}
+
+func deriveKeys(passphrase string, salt []byte) (*encKeys, error) {
+	k, err := scrypt.Key([]byte(passphrase), salt, encScryptN, encScryptR, encScryptP, encKeyMaterial)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(k[:32])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &encKeys{aead: aead, mac: k[32:]}, nil
+}
+
+func (k *encKeys) newMAC() hash.Hash {
+	return hmac.New(sha256.New, k.mac)
+}
+
+func chunkNonce(prefix [encPrefixLen]byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, 12)
+	nonce = append(nonce, prefix[:]...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// encryptor encrypts files for one run with a salt of its own and verifies files written with
+// other salts, deriving (and caching) their keys as needed.
+type encryptor struct {
+	passphrase string
+	salt       [encSaltLen]byte
+	keys       map[[encSaltLen]byte]*encKeys
+}
+
+func newEncryptor(passphrase string) (*encryptor, error) {
+	e := &encryptor{passphrase: passphrase, keys: map[[encSaltLen]byte]*encKeys{}}
+	if _, err := rand.Read(e.salt[:]); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *encryptor) keysFor(salt [encSaltLen]byte) (*encKeys, error) {
+	if k, ok := e.keys[salt]; ok {
+		return k, nil
+	}
+	k, err := deriveKeys(e.passphrase, salt[:])
+	if err != nil {
+		return nil, err
+	}
+	e.keys[salt] = k
+	return k, nil
+}
+
+// plaintextMAC returns the HMAC and size of name in fsys under the keys of salt.
+func (e *encryptor) plaintextMAC(fsys fs.FS, name string, salt [encSaltLen]byte) ([]byte, int64, error) {
+	k, err := e.keysFor(salt)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	m := k.newMAC()
+	n, err := io.Copy(m, f)
+	if err != nil {
+		return nil, 0, err
+	}
+	return m.Sum(nil), n, nil
+}
+
+// sameAsEncrypted reports whether the encrypted target dstName holds the content of the source
+// name, by comparing the HMAC stored in its header. Files that are not encrypted differ.
+func (e *encryptor) sameAsEncrypted(src fs.FS, name string, dst fs.FS, dstName string) (bool, error) {
+	h, err := statEncrypted(dst, dstName)
+	if errors.Is(err, errNotEncrypted) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	mac, size, err := e.plaintextMAC(src, name, h.salt)
+	if err != nil {
+		return false, err
+	}
+	return size == h.size && hmac.Equal(mac, h.mac[:]), nil
+}
+
+// statEncrypted reads the header of the encrypted file name.
+func statEncrypted(fsys fs.FS, name string) (*encHeader, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h, _, err := readEncHeader(f)
+	return h, err
+}
+
+// encryptFS encrypts every file opened from the wrapped FS.
+type encryptFS struct {
+	fs.FS
+	enc *encryptor
+}
+
+func (e encryptFS) Open(name string) (fs.File, error) {
+	k, err := e.enc.keysFor(e.enc.salt)
+	if err != nil {
+		return nil, err
+	}
+	// The header carries the MAC of the whole content, so it is read once up front
+	mac, size, err := e.enc.plaintextMAC(e.FS, name, e.enc.salt)
+	if err != nil {
+		return nil, err
+	}
+	h := &encHeader{salt: e.enc.salt, size: size}
+	copy(h.mac[:], mac)
+	if _, err := rand.Read(h.prefix[:]); err != nil {
+		return nil, err
+	}
+	f, err := e.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptFile{File: f, er: newEncryptReader(f, h, k)}, nil
+}
+
+// encryptFile reads the encrypted content. Stat still reports the plaintext size.
+type encryptFile struct {
+	fs.File
+	er *encryptReader
+}
+
+func (f *encryptFile) Read(p []byte) (int, error) {
+	return f.er.Read(p)
+}
+
+// encryptReader produces the header and sealed chunks of the plaintext read from r.
+// It fails at the end if the plaintext no longer matches the header (the source changed).
+type encryptReader struct {
+	r       *bufio.Reader
+	h       *encHeader
+	aad     []byte
+	keys    *encKeys
+	mac     hash.Hash
+	size    int64
+	counter uint32
+	chunk   []byte
+	out     []byte
+	done    bool
+}
+
+func newEncryptReader(r io.Reader, h *encHeader, k *encKeys) *encryptReader {
+	aad := h.marshal()
+	return &encryptReader{
+		r:     bufio.NewReaderSize(r, encChunkSize),
+		h:     h,
+		aad:   aad,
+		keys:  k,
+		mac:   k.newMAC(),
+		chunk: make([]byte, encChunkSize),
+		out:   append([]byte(nil), aad...),
+	}
+}
+
+func (e *encryptReader) Read(p []byte) (int, error) {
+	for len(e.out) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		if err := e.seal(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+// seal encrypts the next chunk into out.
+func (e *encryptReader) seal() error {
+	n, err := io.ReadFull(e.r, e.chunk)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	last := n < len(e.chunk)
+	if !last {
+		if _, err := e.r.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		}
+	}
+	e.mac.Write(e.chunk[:n])
+	e.size += int64(n)
+	e.out = e.keys.aead.Seal(e.out[:0], chunkNonce(e.h.prefix, e.counter, last), e.chunk[:n], e.aad)
+	e.counter++
+	if last {
+		e.done = true
+		if e.size != e.h.size || !hmac.Equal(e.mac.Sum(nil), e.h.mac[:]) {
+			return errors.New("source changed while encrypting")
+		}
+	}
+	return nil
+}
+
+// Decrypt writes the plaintext of a file encrypted by Sync with Options.EncryptPassphrase
+// from r to w. It fails if the passphrase is wrong or the file was truncated or tampered with;
+// w may then have received part of the content.
+func Decrypt(w io.Writer, r io.Reader, passphrase string) error {
+	h, aad, err := readEncHeader(r)
+	if err != nil {
+		return err
+	}
+	k, err := deriveKeys(passphrase, h.salt[:])
+	if err != nil {
+		return err
+	}
+	br := bufio.NewReaderSize(r, encChunkSize+k.aead.Overhead())
+	sealed := make([]byte, encChunkSize+k.aead.Overhead())
+	var plain []byte
+	mac := k.newMAC()
+	var size int64
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(br, sealed)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+		last := n < len(sealed)
+		if !last {
+			if _, err := br.Peek(1); errors.Is(err, io.EOF) {
+				last = true
+			}
+		}
+		plain, err = k.aead.Open(plain[:0], chunkNonce(h.prefix, counter, last), sealed[:n], aad)
+		if err != nil {
+			return fmt.Errorf("decrypt: wrong passphrase or damaged file: %w", err)
+		}
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+		mac.Write(plain)
+		size += int64(len(plain))
+		if last {
+			break
+		}
+	}
+	if size != h.size || !bytes.Equal(mac.Sum(nil), h.mac[:]) {
+		return errors.New("decrypt: content does not match header")
+	}
+	return nil
+}
+
+// differEncrypted compares a source file with its encrypted target: by the content hash in the
+// header with IgnoreModTime, otherwise by mod-time and, without Transforms, plaintext size.
+// Targets that are not encrypted always differ.
+func (r *runner) differEncrypted(rel, dstRel string, src, dst fs.FileInfo) (bool, error) {
+	if r.opt.IgnoreModTime {
+		same, err := r.sameContent(rel, dstRel)
+		return !same, err
+	}
+	if modTimeDiffers(src, dst, r.opt.ModTimeTolerance) {
+		return true, nil
+	}
+	h, err := statEncrypted(r.dst, dstRel)
+	if errors.Is(err, errNotEncrypted) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(r.transforms()) == 0 && h.size != src.Size(), nil
+}
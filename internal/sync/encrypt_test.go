@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decryptFile(t *testing.T, path, passphrase string) (string, error) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var buf bytes.Buffer
+	err = Decrypt(&buf, f, passphrase)
+	return buf.String(), err
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	// Spans several chunks, the last one partial; the empty file is a single empty chunk
+	big := strings.Repeat("secret payload\n", 10000)
+	mtime := time.Now().Add(-time.Hour)
+	writeWithModTime(t, filepath.Join(src, "big.txt"), big, 0o644, mtime)
+	writeWithModTime(t, filepath.Join(src, "empty.txt"), "", 0o644, mtime)
+
+	rep := Sync(Options{Source: src, Target: dst, EncryptPassphrase: "hunter2"})
+	if len(rep.Errors) != 0 || rep.Copied != 2 || rep.BytesCopied != int64(len(big)) {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+
+	stored, err := os.ReadFile(filepath.Join(dst, "big.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored, []byte("secret payload")) {
+		t.Fatal("target holds plaintext")
+	}
+	for name, want := range map[string]string{"big.txt": big, "empty.txt": ""} {
+		got, err := decryptFile(t, filepath.Join(dst, name), "hunter2")
+		if err != nil || got != want {
+			t.Fatalf("%s: round trip failed (%d bytes): %v", name, len(got), err)
+		}
+	}
+	if _, err := decryptFile(t, filepath.Join(dst, "big.txt"), "wrong"); err == nil {
+		t.Fatal("expected an error for the wrong passphrase")
+	}
+
+	// Tampering with any chunk is detected
+	stored[len(stored)-1] ^= 1
+	if err := Decrypt(&bytes.Buffer{}, bytes.NewReader(stored), "hunter2"); err == nil {
+		t.Fatal("expected an error for a damaged file")
+	}
+}
+
+func TestEncryptStableResync(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+	writeWithModTime(t, filepath.Join(src, "a.txt"), "alpha", 0o644, mtime)
+	// A plain copy left by an earlier unencrypted sync is replaced
+	writeWithModTime(t, filepath.Join(dst, "a.txt"), "alpha", 0o644, mtime)
+
+	opt := Options{Source: src, Target: dst, EncryptPassphrase: "pw"}
+	rep := Sync(opt)
+	if len(rep.Errors) != 0 || rep.Overwritten != 1 {
+		t.Fatalf("expected the plain target to be encrypted: %+v", *rep)
+	}
+
+	hashed := opt
+	hashed.IgnoreModTime = true
+	for _, o := range []Options{opt, hashed} {
+		rep = Sync(o)
+		if len(rep.Errors) != 0 || rep.Copied+rep.Overwritten != 0 || rep.Skipped != 1 {
+			t.Fatalf("re-sync (IgnoreModTime=%v) not stable: %+v", o.IgnoreModTime, *rep)
+		}
+	}
+
+	// Same size and mod-time, different content: only the content comparison sees it
+	writeWithModTime(t, filepath.Join(src, "a.txt"), "alphA", 0o644, mtime)
+	if rep = Sync(opt); rep.Overwritten != 0 {
+		t.Fatalf("mod-time comparison should keep the file: %+v", *rep)
+	}
+	if rep = Sync(hashed); len(rep.Errors) != 0 || rep.Overwritten != 1 {
+		t.Fatalf("expected the changed file to be overwritten: %+v", *rep)
+	}
+	if got, err := decryptFile(t, filepath.Join(dst, "a.txt"), "pw"); err != nil || got != "alphA" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,55 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	mustWrite(t, filepath.Join(src, "new.txt"), strings.Repeat("n", 100))
+	mustWrite(t, filepath.Join(src, "sub", "new2.txt"), strings.Repeat("m", 50))
+	mustWrite(t, filepath.Join(src, "changed.txt"), strings.Repeat("c", 30))
+	mustWrite(t, filepath.Join(dst, "changed.txt"), "old")
+	mustWrite(t, filepath.Join(src, "same.txt"), "s")
+	mustWrite(t, filepath.Join(dst, "orphan.txt"), strings.Repeat("o", 7))
+	// same.txt is already in sync (identical size and mod-time).
+	if err := copyFile(filepath.Join(src, "same.txt"), filepath.Join(dst, "same.txt"), mustStat(t, filepath.Join(src, "same.txt"))); err != nil {
+		t.Fatalf("seed same.txt: %v", err)
+	}
+
+	opt := Options{Source: src, Target: dst, DeleteMissing: true}
+	est, err := New(opt).Estimate()
+	if err != nil {
+		t.Fatalf("estimate: %v", err)
+	}
+	want := Estimate{CopyFiles: 2, CopyBytes: 150, OverwriteFiles: 1, OverwriteBytes: 30, DeleteFiles: 1, DeleteBytes: 7}
+	if *est != want {
+		t.Fatalf("estimate mismatch: got %+v want %+v", *est, want)
+	}
+
+	// Estimating changes nothing.
+	if _, err := os.Stat(filepath.Join(dst, "new.txt")); !os.IsNotExist(err) {
+		t.Fatalf("estimate must not copy, err=%v", err)
+	}
+
+	rep := New(opt).Run()
+	if rep.Copied != est.CopyFiles || rep.Overwritten != est.OverwriteFiles || rep.Deleted != est.DeleteFiles {
+		t.Fatalf("estimate %+v does not match run %+v", *est, *rep)
+	}
+	if rep.BytesCopied != est.CopyBytes+est.OverwriteBytes {
+		t.Fatalf("bytes mismatch: run %d, estimate %d", rep.BytesCopied, est.CopyBytes+est.OverwriteBytes)
+	}
+}
+
+func mustStat(t *testing.T, p string) os.FileInfo {
+	t.Helper()
+	fi, err := os.Stat(p)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	return fi
+}
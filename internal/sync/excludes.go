@@ -0,0 +1,27 @@
+package sync
+
+import (
+	"io/fs"
+	"path"
+)
+
+// DefaultExcludes are the base-name patterns (path.Match syntax) skipped with
+// Options.UseDefaultExcludes: VCS metadata, dependency and cache directories,
+// OS thumbnails and editor swap files. Matching directories are pruned.
+var DefaultExcludes = []string{
+	".git", ".hg", ".svn",
+	"node_modules", "__pycache__",
+	".DS_Store", "Thumbs.db", "desktop.ini",
+	"*.swp", "*.swo",
+}
+
+// isExcluded reports whether a walked entry matches one of DefaultExcludes.
+// Malformed patterns never match.
+func isExcluded(d fs.DirEntry) bool {
+	for _, pattern := range DefaultExcludes {
+		if ok, _ := path.Match(pattern, d.Name()); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUseDefaultExcludes(t *testing.T) {
+	excluded := []string{
+		filepath.Join(".git", "HEAD"),
+		filepath.Join("web", "node_modules", "left-pad", "index.js"),
+		filepath.Join("app", "__pycache__", "main.cpython-311.pyc"),
+		".DS_Store",
+		filepath.Join("photos", "Thumbs.db"),
+		filepath.Join("docs", ".notes.txt.swp"),
+	}
+	kept := []string{"a.txt", filepath.Join("web", "index.html"), filepath.Join("app", "main.py")}
+
+	src := t.TempDir()
+	for _, p := range append(excluded, kept...) {
+		mustWrite(t, filepath.Join(src, p), p)
+	}
+
+	for _, on := range []bool{true, false} {
+		dst := t.TempDir()
+		rep := Sync(Options{Source: src, Target: dst, UseDefaultExcludes: on})
+		if len(rep.Errors) != 0 {
+			t.Fatalf("unexpected errors: %v", rep.Errors)
+		}
+		for _, p := range kept {
+			if _, err := os.Stat(filepath.Join(dst, p)); err != nil {
+				t.Fatalf("excludes=%v: expected %s copied, err=%v", on, p, err)
+			}
+		}
+		for _, p := range excluded {
+			_, err := os.Stat(filepath.Join(dst, p))
+			if on && !os.IsNotExist(err) {
+				t.Fatalf("expected %s excluded, err=%v", p, err)
+			}
+			if !on && err != nil {
+				t.Fatalf("expected %s copied without excludes, err=%v", p, err)
+			}
+		}
+		// .git, node_modules and __pycache__ are pruned; the three files are counted
+		if on && (rep.Copied != len(kept) || rep.Skipped != 3) {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+	}
+}
+
+func TestUseDefaultExcludesKeepsTargetEntriesOnDelete(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+	mustWrite(t, filepath.Join(dst, "node_modules", "x.js"), "x")
+	mustWrite(t, filepath.Join(dst, ".DS_Store"), "ds")
+	mustWrite(t, filepath.Join(dst, "stale.txt"), "s")
+
+	rep := Sync(Options{Source: src, Target: dst, DeleteMissing: true, UseDefaultExcludes: true})
+	if rep.Deleted != 1 {
+		t.Fatalf("expected deleted=1, got %+v", *rep)
+	}
+	for _, p := range []string{filepath.Join("node_modules", "x.js"), ".DS_Store"} {
+		if _, err := os.Stat(filepath.Join(dst, p)); err != nil {
+			t.Fatalf("expected excluded target entry %s to remain, err=%v", p, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// walkFileList visits the entries named in Options.FileList in list order, like fs.WalkDir
+// visits a tree: listed directories are passed to fn but not descended into. Entries below a
+// directory the walk would prune (hidden, excluded or sidecar-ignored) are skipped.
+// Names are cleaned first; duplicates are visited once.
+func (r *runner) walkFileList(fn fs.WalkDirFunc) error {
+	seen := map[string]bool{}
+	pruned := map[string]bool{}
+	for _, name := range r.opt.FileList {
+		rel := path.Clean(strings.TrimPrefix(name, "./"))
+		if seen[rel] || rel == "." {
+			continue
+		}
+		seen[rel] = true
+		if !fs.ValidPath(rel) {
+			if err := fn(rel, nil, fmt.Errorf("file list entry %q: %w", name, fs.ErrInvalid)); err != nil && err != fs.SkipDir {
+				return err
+			}
+			continue
+		}
+		if r.underPrunedDir(rel, pruned) {
+			continue
+		}
+		info, err := fs.Stat(r.src, rel)
+		if errors.Is(err, fs.ErrNotExist) && len(r.sources) > 1 {
+			// Another source may provide it
+			continue
+		}
+		var d fs.DirEntry
+		if err == nil {
+			d = fs.FileInfoToDirEntry(info)
+		}
+		if err := fn(rel, d, err); err != nil && err != fs.SkipDir {
+			if err == fs.SkipAll {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// listed reports whether the run is restricted to the entries of Options.FileList, which
+// Options.GitSince may have left empty.
+func (r *runner) listed() bool {
+	return len(r.opt.FileList) > 0 || r.opt.GitSince != ""
+}
+
+// restrictToFileList turns off the features that need the whole tree when Options.FileList is set.
+func (r *runner) restrictToFileList() {
+	if !r.listed() {
+		return
+	}
+	if r.opt.GitSince != "" {
+		// Removed files are known from git; see deleteGitRemoved
+		r.opt.DeleteMissing = false
+	}
+	if r.opt.DeleteMissing {
+		r.opt.Logger.Printf("WARN: DeleteMissing is not supported with FileList; deleting nothing")
+		r.opt.DeleteMissing = false
+	}
+	if r.opt.CleanTarget {
+		r.opt.Logger.Printf("WARN: CleanTarget is not supported with FileList; keeping the target")
+		r.opt.CleanTarget = false
+	}
+	if r.opt.SubtreeCheck {
+		r.opt.Logger.Printf("WARN: SubtreeCheck is not supported with FileList")
+		r.opt.SubtreeCheck = false
+	}
+}
+
+// underPrunedDir reports whether a parent directory of rel is one the walk would prune.
+// Results are cached in pruned so that each directory is checked and logged once.
+func (r *runner) underPrunedDir(rel string, pruned map[string]bool) bool {
+	var parents []string
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		parents = append(parents, dir)
+	}
+	for i := len(parents) - 1; i >= 0; i-- {
+		dir := parents[i]
+		skip, ok := pruned[dir]
+		if !ok {
+			if info, err := fs.Stat(r.src, dir); err == nil && info.IsDir() {
+				skip = r.prunedDir(dir, fs.FileInfoToDirEntry(info))
+			}
+			pruned[dir] = skip
+		}
+		if skip {
+			return true
+		}
+	}
+	return false
+}
+
+// prunedDir reports whether the walk prunes the source directory rel entirely, logging why.
+func (r *runner) prunedDir(rel string, d fs.DirEntry) bool {
+	opt := r.opt
+	switch {
+	case opt.SkipHidden && isHidden(d):
+		opt.Logger.Printf("SKIP: hidden dir %s", rel)
+	case opt.UseDefaultExcludes && isExcluded(d):
+		opt.Logger.Printf("SKIP: excluded dir %s", rel)
+	default:
+		why := r.sidecarSkip(rel)
+		if why == "" {
+			return false
+		}
+		opt.Logger.Printf("SKIP: %s (%s)", r.srcPath(rel), why)
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileList(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	now := time.Now()
+	mustWrite(t, filepath.Join(src, "dir", "listed.txt"), "listed")
+	mustWrite(t, filepath.Join(src, "dir", "unlisted.txt"), "unlisted")
+	mustWrite(t, filepath.Join(src, "empty", "inner.txt"), "inner")
+	writeWithModTime(t, filepath.Join(src, "changed.txt"), "new", 0o644, now)
+	writeWithModTime(t, filepath.Join(src, "other.txt"), "new", 0o644, now)
+	writeWithModTime(t, filepath.Join(dst, "changed.txt"), "old", 0o644, now.Add(-time.Hour))
+	writeWithModTime(t, filepath.Join(dst, "other.txt"), "old", 0o644, now.Add(-time.Hour))
+	mustWrite(t, filepath.Join(dst, "orphan.txt"), "orphan")
+
+	rep := Sync(Options{
+		Source:        src,
+		Target:        dst,
+		FileList:      []string{"dir/listed.txt", "./changed.txt", "empty", "dir/listed.txt"},
+		DeleteMissing: true,
+	})
+	if len(rep.Errors) != 0 || rep.Copied != 1 || rep.Overwritten != 1 || rep.Deleted != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	want := map[string]string{
+		"changed.txt":    "new",
+		"dir":            "/",
+		"dir/listed.txt": "listed",
+		"empty":          "/",
+		"orphan.txt":     "orphan",
+		"other.txt":      "old",
+	}
+	if got := treeContents(t, dst); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+
+	rep = Sync(Options{Source: src, Target: dst, FileList: []string{"missing.txt", "../escape.txt"}})
+	if len(rep.Errors) != 2 || rep.Copied != 0 {
+		t.Fatalf("expected two errors, got %+v", *rep)
+	}
+	if !strings.Contains(rep.Errors[1].Error(), "../escape.txt") {
+		t.Fatalf("unexpected error: %v", rep.Errors[1])
+	}
+}
+
+func TestFileListPrunedParents(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, ".git", "config"), "config")
+	mustWrite(t, filepath.Join(src, "node_modules", "x", "y.js"), "y")
+	mustWrite(t, filepath.Join(src, "ignored", "z.txt"), "z")
+	mustWrite(t, filepath.Join(src, "ignored.syncignore"), "")
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+
+	rep := Sync(Options{
+		Source:              src,
+		Target:              dst,
+		FileList:            []string{".git/config", "node_modules/x/y.js", "ignored/z.txt", "a.txt"},
+		SkipHidden:          true,
+		UseDefaultExcludes:  true,
+		SidecarIgnoreSuffix: ".syncignore",
+	})
+	if len(rep.Errors) != 0 || rep.Copied != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if got, want := treeContents(t, dst), map[string]string{"a.txt": "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// WritableFS is the write side of a synchronization target.
+// Names follow io/fs conventions: slash-separated and relative to the target root ("." is the root).
+// The embedded fs.StatFS is used to inspect existing target entries and to walk the target during the delete pass.
+type WritableFS interface {
+	fs.StatFS
+	MkdirAll(name string, perm fs.FileMode) error
+	Create(name string, perm fs.FileMode) (io.WriteCloser, error)
+	Rename(oldname, newname string) error
+	Remove(name string) error
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// DirFS returns a WritableFS backed by the OS directory rooted at dir.
+// It is the target implementation used by Sync.
+func DirFS(dir string) WritableFS {
+	return dirFS(dir)
+}
+
+type dirFS string
+
+// path maps an io/fs name onto the OS path below the root.
+// Errors carry the full OS path so that reported errors stay meaningful to the user.
+func (d dirFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) || runtime.GOOS == "windows" && strings.ContainsAny(name, `\:`) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(string(d), filepath.FromSlash(name)), nil
+}
+
+func (d dirFS) Open(name string) (fs.File, error) {
+	p, err := d.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (d dirFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := d.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+// Lstat is like Stat but does not follow a final symlink.
+func (d dirFS) Lstat(name string) (fs.FileInfo, error) {
+	p, err := d.path("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(p)
+}
+
+func (d dirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := d.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadDir(p)
+}
+
+func (d dirFS) MkdirAll(name string, perm fs.FileMode) error {
+	p, err := d.path("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(p, perm)
+}
+
+func (d dirFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	p, err := d.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+}
+
+func (d dirFS) Rename(oldname, newname string) error {
+	op, err := d.path("rename", oldname)
+	if err != nil {
+		return err
+	}
+	np, err := d.path("rename", newname)
+	if err != nil {
+		return err
+	}
+	return os.Rename(op, np)
+}
+
+func (d dirFS) Remove(name string) error {
+	p, err := d.path("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+func (d dirFS) Chtimes(name string, atime, mtime time.Time) error {
+	p, err := d.path("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(p, atime, mtime)
+}
@@ -0,0 +1,179 @@
+package sync
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// memFS is an in-memory WritableFS built on top of fstest.MapFS.
+type memFS struct {
+	fstest.MapFS
+}
+
+func newMemFS() *memFS {
+	return &memFS{MapFS: fstest.MapFS{}}
+}
+
+func (m *memFS) MkdirAll(name string, perm fs.FileMode) error {
+	for p := name; p != "."; p = path.Dir(p) {
+		if f, ok := m.MapFS[p]; ok {
+			if !f.Mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: p, Err: fs.ErrExist}
+			}
+			continue
+		}
+		m.MapFS[p] = &fstest.MapFile{Mode: fs.ModeDir | perm, ModTime: time.Now()}
+	}
+	return nil
+}
+
+func (m *memFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	return &memFile{fs: m, name: name, perm: perm}, nil
+}
+
+func (m *memFS) Rename(oldname, newname string) error {
+	f, ok := m.MapFS[oldname]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+	delete(m.MapFS, oldname)
+	m.MapFS[newname] = f
+	return nil
+}
+
+func (m *memFS) Remove(name string) error {
+	if _, ok := m.MapFS[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m.MapFS, name)
+	return nil
+}
+
+func (m *memFS) Chtimes(name string, _, mtime time.Time) error {
+	f, ok := m.MapFS[name]
+	if !ok {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrNotExist}
+	}
+	f.ModTime = mtime
+	return nil
+}
+
+// memFile buffers writes and publishes the file into the memFS on Close.
+type memFile struct {
+	fs   *memFS
+	name string
+	perm fs.FileMode
+	buf  bytes.Buffer
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	return f.buf.Write(p)
+}
+
+func (f *memFile) Close() error {
+	f.fs.MapFS[f.name] = &fstest.MapFile{Data: f.buf.Bytes(), Mode: f.perm, ModTime: time.Now()}
+	return nil
+}
+
+func TestSyncFS(t *testing.T) {
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	src := fstest.MapFS{
+		"a.txt":          {Data: []byte("alpha"), Mode: 0o644, ModTime: mtime},
+		"dir/b.txt":      {Data: []byte("beta"), Mode: 0o600, ModTime: mtime},
+		"dir/deep/c.txt": {Data: []byte("gamma"), Mode: 0o644, ModTime: mtime},
+	}
+	dst := newMemFS()
+	dst.MapFS["orphan.txt"] = &fstest.MapFile{Data: []byte("x")}
+
+	rep := SyncFS(src, dst, Options{DeleteMissing: true})
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+	if rep.Copied != 3 || rep.Deleted != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+
+	for name, want := range src {
+		got, ok := dst.MapFS[name]
+		if !ok {
+			t.Fatalf("%s missing in target", name)
+		}
+		if string(got.Data) != string(want.Data) {
+			t.Fatalf("%s content mismatch: %q", name, got.Data)
+		}
+		if got.Mode.Perm() != want.Mode.Perm() {
+			t.Fatalf("%s perm mismatch: got %v want %v", name, got.Mode.Perm(), want.Mode.Perm())
+		}
+		if !got.ModTime.Equal(mtime) {
+			t.Fatalf("%s mtime mismatch: got %v want %v", name, got.ModTime, mtime)
+		}
+	}
+	for name := range dst.MapFS {
+		if strings.HasSuffix(name, ".tmp~") {
+			t.Fatalf("temp file left behind: %s", name)
+		}
+	}
+	if _, ok := dst.MapFS["orphan.txt"]; ok {
+		t.Fatalf("expected orphan.txt removed")
+	}
+
+	// A second run finds everything identical.
+	rep2 := SyncFS(src, dst, Options{DeleteMissing: true})
+	if rep2.Copied != 0 || rep2.Overwritten != 0 || rep2.Skipped != 3 {
+		t.Fatalf("expected all skipped on second run, got %+v", *rep2)
+	}
+}
+
+func TestDirFSRejectsInvalidNames(t *testing.T) {
+	d := DirFS(t.TempDir())
+	for _, name := range []string{"../escape", "/abs", "a/../b"} {
+		if _, err := d.Stat(name); err == nil {
+			t.Fatalf("expected error for %q", name)
+		}
+	}
+}
+
+// failMkdirFS fails to create the directory fail and everything below it.
+type failMkdirFS struct {
+	*memFS
+	fail string
+}
+
+func (f failMkdirFS) MkdirAll(name string, perm fs.FileMode) error {
+	if name == f.fail || strings.HasPrefix(name, f.fail+"/") {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrPermission}
+	}
+	return f.memFS.MkdirAll(name, perm)
+}
+
+func TestSyncFSMkdirFailurePrunesSubtree(t *testing.T) {
+	src := fstest.MapFS{
+		"broken/a.txt":       {Data: []byte("a")},
+		"broken/b.txt":       {Data: []byte("b")},
+		"broken/sub/c.txt":   {Data: []byte("c")},
+		"broken/sub/d/e.txt": {Data: []byte("e")},
+		"ok/f.txt":           {Data: []byte("f")},
+	}
+	for _, dirsOnly := range []bool{false, true} {
+		dst := newMemFS()
+		rep := SyncFS(src, failMkdirFS{memFS: dst, fail: "broken"}, Options{DirsOnly: dirsOnly})
+		if len(rep.Errors) != 1 {
+			t.Fatalf("dirsOnly=%v: expected one error for the subtree, got %v", dirsOnly, rep.Errors)
+		}
+		if !strings.Contains(rep.Errors[0].Error(), "broken") {
+			t.Fatalf("dirsOnly=%v: unexpected error: %v", dirsOnly, rep.Errors[0])
+		}
+		if _, ok := dst.MapFS["ok"]; !ok {
+			t.Fatalf("dirsOnly=%v: sibling directory not synced", dirsOnly)
+		}
+		if !dirsOnly && (rep.Copied != 1 || string(dst.MapFS["ok/f.txt"].Data) != "f") {
+			t.Fatalf("sibling file not synced: %+v", *rep)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package sync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+)
+
+// gitChanges lists the files changed between rev and HEAD in the git work tree dir, relative to
+// dir: changed files were added or modified (or are the new name of a renamed or copied file),
+// removed files were deleted (or are the old name of a renamed file).
+func gitChanges(dir, rev string) (changed, removed []string, err error) {
+	commit, err := resolveCommit(dir, rev)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err := runGit(dir, "diff", "--name-status", "-z", "--relative", commit, "HEAD", "--")
+	if err != nil {
+		return nil, nil, fmt.Errorf("git diff %s HEAD in %s: %w", rev, dir, err)
+	}
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for i := 0; i < len(fields) && fields[i] != ""; i++ {
+		status := fields[i]
+		switch status[0] {
+		case 'R', 'C':
+			// Renames and copies are followed by the old and the new name
+			if i+2 >= len(fields) {
+				return nil, nil, fmt.Errorf("git diff %s HEAD in %s: truncated output", rev, dir)
+			}
+			if status[0] == 'R' {
+				removed = append(removed, fields[i+1])
+			}
+			changed = append(changed, fields[i+2])
+			i += 2
+		case 'D':
+			if i+1 >= len(fields) {
+				return nil, nil, fmt.Errorf("git diff %s HEAD in %s: truncated output", rev, dir)
+			}
+			removed = append(removed, fields[i+1])
+			i++
+		default:
+			if i+1 >= len(fields) {
+				return nil, nil, fmt.Errorf("git diff %s HEAD in %s: truncated output", rev, dir)
+			}
+			changed = append(changed, fields[i+1])
+			i++
+		}
+	}
+	return changed, removed, nil
+}
+
+// resolveCommit returns the hash of the commit rev names in dir. A rev starting with "-" is
+// rejected, so that it cannot pass an option to git.
+func resolveCommit(dir, rev string) (string, error) {
+	if rev == "" || strings.HasPrefix(rev, "-") {
+		return "", fmt.Errorf("git revision %q: not a revision", rev)
+	}
+	out, err := runGit(dir, "rev-parse", "--verify", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("git revision %q in %s: %w", rev, dir, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// runGit runs git in dir and returns its output, with git's message in the error.
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// listGitChanges replaces Options.FileList with the files changed since Options.GitSince and,
+// with DeleteMissing, remembers the removed ones for deleteGitRemoved.
+// It returns false if the run must stop; the error has been recorded.
+func (r *runner) listGitChanges() bool {
+	changed, removed, err := r.gitSourceChanges()
+	if err != nil {
+		r.opt.Logger.Printf("ERR: %v", err)
+		r.rep.addErr(err)
+		return false
+	}
+	r.opt.Logger.Printf("GIT: %d files changed, %d removed since %s", len(changed), len(removed), r.opt.GitSince)
+	r.opt.FileList = changed
+	if r.opt.DeleteMissing {
+		r.gitRemoved = removed
+	}
+	return true
+}
+
+// gitSourceChanges runs gitChanges on the single source directory.
+func (r *runner) gitSourceChanges() (changed, removed []string, err error) {
+	root := r.sources[0].root
+	if len(r.sources) > 1 {
+		return nil, nil, errors.New("GitSince is not supported with multiple sources")
+	}
+	if root == "" || DetectArchive(root) != NotArchive {
+		return nil, nil, errors.New("GitSince needs a source directory")
+	}
+	return gitChanges(root, r.opt.GitSince)
+}
+
+// deleteGitRemoved removes the target files of the source files removed since Options.GitSince,
+// unless the source has them again.
+func (r *runner) deleteGitRemoved() {
+	for _, rel := range r.gitRemoved {
+		info, err := r.dst.Stat(rel)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			r.opt.Logger.Printf("ERR: stat %s: %v", r.dstPath(rel), err)
+			r.rep.addErr(err)
+			continue
+		}
+		if info.IsDir() || r.statSources(rel) == nil {
+			continue
+		}
+		if r.est != nil {
+			r.est.addDelete(fs.FileInfoToDirEntry(info))
+		}
+		if r.ver != nil {
+			r.ver.Orphans = append(r.ver.Orphans, r.dstPath(rel))
+		}
+		if r.plan != nil {
+			r.addAction(ActionDelete, rel, rel, nil)
+		}
+		r.removeEntry(rel)
+	}
+}
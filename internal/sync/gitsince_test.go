@@ -0,0 +1,109 @@
+package sync
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// git runs a git command in dir, failing the test on error.
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestGitSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	src, dst := t.TempDir(), t.TempDir()
+	git(t, src, "init", "-q")
+	mustWrite(t, filepath.Join(src, "keep.txt"), "keep")
+	mustWrite(t, filepath.Join(src, "edit.txt"), "old")
+	mustWrite(t, filepath.Join(src, "gone.txt"), "gone")
+	mustWrite(t, filepath.Join(src, "dir", "moved.txt"), "moved content that git detects as a rename")
+	git(t, src, "add", "-A")
+	git(t, src, "commit", "-q", "-m", "base")
+	base := git(t, src, "rev-parse", "HEAD")
+
+	// The target holds the base revision
+	if rep := Sync(Options{Source: src, Target: dst, UseDefaultExcludes: true}); len(rep.Errors) != 0 || rep.Copied != 4 {
+		t.Fatalf("initial sync: %+v", *rep)
+	}
+	mustWrite(t, filepath.Join(dst, "keep.txt"), "changed in target only")
+
+	mustWrite(t, filepath.Join(src, "edit.txt"), "new content")
+	mustWrite(t, filepath.Join(src, "new", "added.txt"), "added")
+	git(t, src, "rm", "-q", "gone.txt")
+	git(t, src, "mv", "dir/moved.txt", "renamed.txt")
+	git(t, src, "add", "-A")
+	git(t, src, "commit", "-q", "-m", "change")
+
+	rep := Sync(Options{Source: src, Target: dst, GitSince: base, DeleteMissing: true})
+	if len(rep.Errors) != 0 || rep.Copied != 2 || rep.Overwritten != 1 || rep.Deleted != 2 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	want := map[string]string{
+		"dir":           "/",
+		"edit.txt":      "new content",
+		"keep.txt":      "changed in target only",
+		"new":           "/",
+		"new/added.txt": "added",
+		"renamed.txt":   "moved content that git detects as a rename",
+	}
+	if got := treeContents(t, dst); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+
+	// Nothing changed since HEAD: nothing is walked
+	rep = Sync(Options{Source: src, Target: dst, GitSince: "HEAD", DeleteMissing: true})
+	if len(rep.Errors) != 0 || rep.Copied+rep.Overwritten+rep.Deleted+rep.Skipped != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+
+	rep = Sync(Options{Source: src, Target: dst, GitSince: "no-such-rev"})
+	if len(rep.Errors) != 1 || !strings.Contains(rep.Errors[0].Error(), "no-such-rev") {
+		t.Fatalf("expected a git error, got %+v", *rep)
+	}
+
+	// A revision cannot smuggle in an option
+	out := filepath.Join(t.TempDir(), "written")
+	rep = Sync(Options{Source: src, Target: dst, GitSince: "--output=" + out})
+	if len(rep.Errors) != 1 {
+		t.Fatalf("expected the revision rejected, got %+v", *rep)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("git wrote %s: %v", out, err)
+	}
+}
+
+func TestGitSinceSubdir(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo, dst := t.TempDir(), t.TempDir()
+	git(t, repo, "init", "-q")
+	mustWrite(t, filepath.Join(repo, "site", "index.html"), "v1")
+	git(t, repo, "add", "-A")
+	git(t, repo, "commit", "-q", "-m", "base")
+	mustWrite(t, filepath.Join(repo, "site", "index.html"), "v2")
+	mustWrite(t, filepath.Join(repo, "README"), "outside the source")
+	git(t, repo, "add", "-A")
+	git(t, repo, "commit", "-q", "-m", "change")
+
+	rep := Sync(Options{Source: filepath.Join(repo, "site"), Target: dst, GitSince: "HEAD~1"})
+	if len(rep.Errors) != 0 || rep.Copied != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "index.html")); err != nil {
+		t.Fatal(err)
+	}
+}
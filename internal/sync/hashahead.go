@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"io/fs"
+	"path"
+	"sync"
+)
+
+// hashAhead runs the content comparisons of Options.IgnoreModTime for the files of a source
+// directory in a pool of Options.HashConcurrency workers as soon as the walk enters the
+// directory. The walk still decides, counts and copies every file itself, in walk order,
+// using the comparison result instead of hashing the file again.
+type hashAhead struct {
+	jobs chan *hashJob
+	// quit stops the feeders at the end of the pass.
+	quit chan struct{}
+	// pending holds the started comparisons by source name, until the walk reaches them.
+	pending map[string]*hashJob
+	// feeders counts the goroutines handing jobs to the workers.
+	feeders sync.WaitGroup
+	workers sync.WaitGroup
+}
+
+// hashJob compares one source file with its target counterpart.
+type hashJob struct {
+	rel, dstRel string
+	info        fs.FileInfo
+	done        chan struct{}
+	// ok is false when there was nothing to compare: no target file or a different size.
+	ok   bool
+	same bool
+	err  error
+}
+
+// startHashAhead starts the workers for the current copy pass.
+func (r *runner) startHashAhead() {
+	h := &hashAhead{jobs: make(chan *hashJob), quit: make(chan struct{}), pending: map[string]*hashJob{}}
+	for i := 0; i < r.opt.HashConcurrency; i++ {
+		h.workers.Add(1)
+		go func() {
+			defer h.workers.Done()
+			for j := range h.jobs {
+				r.compareAhead(j)
+			}
+		}()
+	}
+	r.ha = h
+}
+
+// stopHashAhead drops the comparisons the walk did not use and waits for the running ones.
+func (r *runner) stopHashAhead() {
+	close(r.ha.quit)
+	r.ha.feeders.Wait()
+	close(r.ha.jobs)
+	r.ha.workers.Wait()
+	r.ha = nil
+}
+
+// hashDir queues the comparison of the regular files directly in the source directory rel,
+// whose target counterpart is dstRel. Listing errors are left for the walk to report.
+func (r *runner) hashDir(rel, dstRel string) {
+	entries, _ := fs.ReadDir(r.src, rel)
+	var jobs []*hashJob
+	for _, e := range entries {
+		name := path.Join(rel, e.Name())
+		if !e.Type().IsRegular() || !r.hashed(name) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		j := &hashJob{rel: name, dstRel: path.Join(dstRel, e.Name()), info: info, done: make(chan struct{})}
+		r.ha.pending[name] = j
+		jobs = append(jobs, j)
+	}
+	if len(jobs) == 0 {
+		return
+	}
+	h := r.ha
+	h.feeders.Add(1)
+	go func() {
+		defer h.feeders.Done()
+		for _, j := range jobs {
+			select {
+			case h.jobs <- j:
+			case <-h.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (r *runner) compareAhead(j *hashJob) {
+	defer close(j.done)
+	dst, err := r.dst.Stat(j.dstRel)
+	if err != nil || !dst.Mode().IsRegular() || dst.Size() != j.info.Size() {
+		return
+	}
+	j.ok = true
+	j.same, j.err = r.sameAsTarget(j.rel, j.dstRel, dst)
+}
+
+// aheadResult returns the result of the comparison started for rel, if any and if it applies
+// to the target file dstRel described by dst.
+func (r *runner) aheadResult(rel, dstRel string, src, dst fs.FileInfo) (same bool, err error, ok bool) {
+	j := r.ha.pending[rel]
+	if j == nil {
+		return false, nil, false
+	}
+	delete(r.ha.pending, rel)
+	<-j.done
+	if !j.ok || j.dstRel != dstRel || j.info.Size() != src.Size() || dst.Size() != src.Size() {
+		return false, nil, false
+	}
+	return j.same, j.err, true
+}
@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// seedSameSize writes files of size bytes into src and dst, identical but with differing
+// mod-times, except that every fourth target file has one byte changed.
+func seedSameSize(t testing.TB, src, dst string, files, size int) {
+	t.Helper()
+	for i := 0; i < files; i++ {
+		rel := filepath.Join(fmt.Sprintf("d%d", i%3), fmt.Sprintf("f%03d.bin", i))
+		data := bytes.Repeat([]byte{byte(i)}, size)
+		write := func(root string) {
+			p := filepath.Join(root, rel)
+			if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+				t.Fatalf("mkdir: %v", err)
+			}
+			if err := os.WriteFile(p, data, 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+		}
+		write(src)
+		if i%4 == 0 {
+			data[size/2]++
+		}
+		write(dst)
+	}
+}
+
+func TestHashConcurrency(t *testing.T) {
+	src := t.TempDir()
+	serial, parallel := t.TempDir(), t.TempDir()
+	seedSameSize(t, src, serial, 40, 4096)
+	seedSameSize(t, src, parallel, 40, 4096)
+	mustWrite(t, filepath.Join(src, "d0", "new.bin"), "new")
+	mustWrite(t, filepath.Join(parallel, "d1", "f001.bin"), "resized")
+	mustWrite(t, filepath.Join(serial, "d1", "f001.bin"), "resized")
+
+	want := Sync(Options{Source: src, Target: serial, IgnoreModTime: true})
+	got := Sync(Options{Source: src, Target: parallel, IgnoreModTime: true, HashConcurrency: 8})
+	if len(got.Errors) != 0 || got.Copied != 1 || got.Overwritten != 11 || got.Skipped != 29 {
+		t.Fatalf("unexpected rep: %+v", *got)
+	}
+	if !got.Equal(want) {
+		t.Fatalf("reports differ: %s", got.Diff(want))
+	}
+	if a, b := treeContents(t, serial), treeContents(t, parallel); !reflect.DeepEqual(a, b) {
+		t.Fatalf("targets differ")
+	}
+	if a, b := treeContents(t, src), treeContents(t, parallel); !reflect.DeepEqual(a, b) {
+		t.Fatalf("target differs from source")
+	}
+}
+
+func BenchmarkHashConcurrency(b *testing.B) {
+	src, dst := b.TempDir(), b.TempDir()
+	seedSameSize(b, src, dst, 200, 256<<10)
+	// Make every target identical so that each run hashes all files and copies none
+	if rep := Sync(Options{Source: src, Target: dst, IgnoreModTime: true}); len(rep.Errors) != 0 {
+		b.Fatal(rep.Errors)
+	}
+	quiet := log.New(io.Discard, "", 0)
+
+	for _, n := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Sync(Options{Source: src, Target: dst, IgnoreModTime: true, HashConcurrency: n, Logger: quiet})
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package sync
+
+import (
+	"path"
+	"strings"
+)
+
+// hashed reports whether checksum mode (Options.IgnoreModTime) compares rel by content,
+// according to Options.HashExtensions and Options.NoHashExtensions.
+func (r *runner) hashed(rel string) bool {
+	ext := path.Ext(rel)
+	if len(r.opt.HashExtensions) > 0 && !hasExtension(r.opt.HashExtensions, ext) {
+		return false
+	}
+	return !hasExtension(r.opt.NoHashExtensions, ext)
+}
+
+// hasExtension reports whether ext (".txt") is in list, ignoring case and an optional leading dot.
+func hasExtension(list []string, ext string) bool {
+	if ext == "" {
+		return false
+	}
+	for _, e := range list {
+		if strings.EqualFold(strings.TrimPrefix(e, "."), ext[1:]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashExtensions(t *testing.T) {
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, opt := range []Options{
+		{IgnoreModTime: true, HashExtensions: []string{"txt"}},
+		{IgnoreModTime: true, NoHashExtensions: []string{".MP4"}},
+	} {
+		src, dst := t.TempDir(), t.TempDir()
+		// Same size and mod-time, different content: only hashing notices
+		writeWithModTime(t, filepath.Join(src, "notes.txt"), "new", 0o644, old)
+		writeWithModTime(t, filepath.Join(dst, "notes.txt"), "old", 0o644, old)
+		writeWithModTime(t, filepath.Join(src, "clip.mp4"), "new", 0o644, old)
+		writeWithModTime(t, filepath.Join(dst, "clip.mp4"), "old", 0o644, old)
+		// Same content, different mod-time: only size+mtime notices
+		writeWithModTime(t, filepath.Join(src, "touched.txt"), "same", 0o644, time.Now())
+		writeWithModTime(t, filepath.Join(dst, "touched.txt"), "same", 0o644, old)
+		writeWithModTime(t, filepath.Join(src, "touched.mp4"), "same", 0o644, time.Now())
+		writeWithModTime(t, filepath.Join(dst, "touched.mp4"), "same", 0o644, old)
+
+		opt.Source, opt.Target = src, dst
+		rep := Sync(opt)
+		if len(rep.Errors) != 0 || rep.Overwritten != 2 || rep.Skipped != 2 {
+			t.Fatalf("%v/%v: unexpected rep: %+v", opt.HashExtensions, opt.NoHashExtensions, *rep)
+		}
+		for name, want := range map[string]string{"notes.txt": "new", "clip.mp4": "old"} {
+			if b, _ := os.ReadFile(filepath.Join(dst, name)); string(b) != want {
+				t.Fatalf("%s: got %q want %q", name, b, want)
+			}
+		}
+		info, err := os.Stat(filepath.Join(dst, "touched.txt"))
+		if err != nil || !info.ModTime().Equal(old) {
+			t.Fatalf("touched.txt was rewritten: %v, %v", info, err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// defaultHeartbeatInterval is used when Options.HeartbeatInterval is 0.
+const defaultHeartbeatInterval = 10 * time.Second
+
+// beat rewrites Options.HeartbeatFile if the interval has passed since the last write.
+// It is called by the walks for every entry, so the file only advances while the run makes progress.
+func (r *runner) beat() {
+	if r.opt.HeartbeatFile == "" {
+		return
+	}
+	interval := r.opt.HeartbeatInterval
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	if now := time.Now(); now.Sub(r.lastBeat) >= interval {
+		r.writeHeartbeat(now)
+	}
+}
+
+// writeHeartbeat replaces the heartbeat file with the current time and counters.
+// Failures are only logged: the heartbeat must not fail the sync it reports on.
+func (r *runner) writeHeartbeat(now time.Time) {
+	r.lastBeat = now
+	p := r.opt.HeartbeatFile
+	line := fmt.Sprintf("%s %s\n", now.UTC().Format(time.RFC3339), r.rep)
+	err := os.WriteFile(p+tempSuffix, []byte(line), 0o644)
+	if err == nil {
+		err = os.Rename(p+tempSuffix, p)
+	}
+	if err != nil {
+		r.opt.Logger.Printf("WARN: heartbeat %s: %v", p, err)
+	}
+}
@@ -0,0 +1,63 @@
+package sync
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// slowFS delays every Open and calls hook with the opened name.
+type slowFS struct {
+	fstest.MapFS
+	delay time.Duration
+	hook  func(name string)
+}
+
+func (s slowFS) Open(name string) (fs.File, error) {
+	time.Sleep(s.delay)
+	if s.hook != nil {
+		s.hook(name)
+	}
+	return s.MapFS.Open(name)
+}
+
+func TestHeartbeatFile(t *testing.T) {
+	hb := filepath.Join(t.TempDir(), "heartbeat")
+	src := fstest.MapFS{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		src[name+".txt"] = &fstest.MapFile{Data: []byte(name), ModTime: time.Now()}
+	}
+
+	var seen []time.Time
+	hook := func(name string) {
+		if name != "b.txt" && name != "h.txt" {
+			return
+		}
+		info, err := os.Stat(hb)
+		if err != nil {
+			t.Errorf("heartbeat missing while running: %v", err)
+			return
+		}
+		seen = append(seen, info.ModTime())
+	}
+	rep := SyncFS(slowFS{MapFS: src, delay: 20 * time.Millisecond, hook: hook}, newMemFS(),
+		Options{HeartbeatFile: hb, HeartbeatInterval: 30 * time.Millisecond})
+	if len(rep.Errors) != 0 || rep.Copied != len(src) {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if len(seen) != 2 || !seen[1].After(seen[0]) {
+		t.Fatalf("heartbeat did not advance during the run: %v", seen)
+	}
+
+	b, err := os.ReadFile(hb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "copied=8 ") {
+		t.Fatalf("final heartbeat lacks the counters: %q", b)
+	}
+}
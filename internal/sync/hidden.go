@@ -0,0 +1,16 @@
+package sync
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// isHidden reports whether a walked entry should be treated as hidden.
+// Names starting with a dot are hidden on every platform; on Windows the
+// FILE_ATTRIBUTE_HIDDEN bit is consulted as well (see hasHiddenAttr).
+func isHidden(d fs.DirEntry) bool {
+	if strings.HasPrefix(d.Name(), ".") {
+		return true
+	}
+	return hasHiddenAttr(d)
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package sync
+
+import "io/fs"
+
+// hasHiddenAttr is a no-op outside Windows; only dot-prefixed names are hidden.
+func hasHiddenAttr(fs.DirEntry) bool {
+	return false
+}
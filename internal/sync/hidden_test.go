@@ -0,0 +1,58 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSkipHidden(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	mustWrite(t, filepath.Join(src, "visible.txt"), "v")
+	mustWrite(t, filepath.Join(src, "sub", "nested.txt"), "n")
+	mustWrite(t, filepath.Join(src, ".env"), "SECRET=1")
+	mustWrite(t, filepath.Join(src, ".git", "HEAD"), "ref: refs/heads/main")
+	mustWrite(t, filepath.Join(src, ".git", "objects", "ab"), "blob")
+
+	rep := Sync(Options{Source: src, Target: dst, SkipHidden: true})
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+	if rep.Copied != 2 {
+		t.Fatalf("expected copied=2, got %+v", *rep)
+	}
+	// .env is counted as skipped; .git is pruned without visiting its files
+	if rep.Skipped != 1 {
+		t.Fatalf("expected skipped=1, got %+v", *rep)
+	}
+
+	for _, p := range []string{"visible.txt", filepath.Join("sub", "nested.txt")} {
+		if _, err := os.Stat(filepath.Join(dst, p)); err != nil {
+			t.Fatalf("expected %s copied, err=%v", p, err)
+		}
+	}
+	for _, p := range []string{".env", ".git"} {
+		if _, err := os.Stat(filepath.Join(dst, p)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s not copied, err=%v", p, err)
+		}
+	}
+}
+
+func TestSkipHiddenKeepsHiddenTargetEntriesOnDelete(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+	mustWrite(t, filepath.Join(dst, ".git", "HEAD"), "ref")
+	mustWrite(t, filepath.Join(dst, "stale.txt"), "s")
+
+	rep := Sync(Options{Source: src, Target: dst, DeleteMissing: true, SkipHidden: true})
+	if rep.Deleted != 1 {
+		t.Fatalf("expected deleted=1, got %+v", *rep)
+	}
+	if _, err := os.Stat(filepath.Join(dst, ".git", "HEAD")); err != nil {
+		t.Fatalf("expected hidden target entry to remain, err=%v", err)
+	}
+}
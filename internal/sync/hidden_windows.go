@@ -0,0 +1,21 @@
+//go:build windows
+
+package sync
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// hasHiddenAttr reports whether the entry carries the Windows hidden attribute.
+func hasHiddenAttr(d fs.DirEntry) bool {
+	info, err := d.Info()
+	if err != nil {
+		return false
+	}
+	attrs, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return false
+	}
+	return attrs.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+}
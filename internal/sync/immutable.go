@@ -0,0 +1,15 @@
+package sync
+
+// immutable reports whether the target file rel carries the immutable or append-only attribute.
+// Only OS directory targets are checked.
+func (r *runner) immutable(rel string) bool {
+	dst, ok := r.dst.(dirFS)
+	if !ok {
+		return false
+	}
+	p, err := dst.path("open", rel)
+	if err != nil {
+		return false
+	}
+	return isImmutable(p)
+}
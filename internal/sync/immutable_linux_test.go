@@ -0,0 +1,61 @@
+//go:build linux
+
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+const fsIocSetFlags = 0x40006602 | uintptr(unsafe.Sizeof(uintptr(0)))<<16
+
+func setInodeFlags(p string, flags int32) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), fsIocSetFlags, uintptr(unsafe.Pointer(&flags))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func TestRespectImmutable(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("setting the immutable attribute requires root")
+	}
+	src, dst := t.TempDir(), t.TempDir()
+	writeWithModTime(t, filepath.Join(src, "locked.txt"), "new", 0o644, time.Now())
+	writeWithModTime(t, filepath.Join(src, "free.txt"), "new", 0o644, time.Now())
+	old := time.Now().Add(-time.Hour)
+	writeWithModTime(t, filepath.Join(dst, "locked.txt"), "old", 0o644, old)
+	writeWithModTime(t, filepath.Join(dst, "free.txt"), "old", 0o644, old)
+
+	locked := filepath.Join(dst, "locked.txt")
+	if err := setInodeFlags(locked, fsImmutableFl); err != nil {
+		t.Skipf("immutable attribute unsupported here: %v", err)
+	}
+	t.Cleanup(func() { _ = setInodeFlags(locked, 0) })
+	if !isImmutable(locked) {
+		t.Fatal("isImmutable missed the attribute")
+	}
+
+	rep := Sync(Options{Source: src, Target: dst, RespectImmutable: true})
+	if len(rep.Errors) != 0 || rep.Overwritten != 1 || rep.SkippedImmutable != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if got := treeContents(t, dst); got["locked.txt"] != "old" || got["free.txt"] != "new" {
+		t.Fatalf("unexpected target: %v", got)
+	}
+
+	// Without the option the overwrite fails
+	rep = Sync(Options{Source: src, Target: dst})
+	if len(rep.Errors) != 1 {
+		t.Fatalf("expected the immutable target to fail, got %+v", *rep)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package sync
+
+// isImmutable cannot read inode attributes on this platform.
+func isImmutable(string) bool {
+	return false
+}
@@ -0,0 +1,97 @@
+package sync
+
+import (
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Buffers of the io_uring copy (Options.IOUring): ringBuffers reads or writes of
+// ringBufferSize bytes are in flight at a time.
+const (
+	ringBuffers    = 8
+	ringBufferSize = 256 << 10
+)
+
+// startRing sets up the io_uring used for copying with Options.IOUring, or logs why
+// files are copied normally.
+func (r *runner) startRing() {
+	ring, err := newRing(ringBuffers, ringBufferSize)
+	if err != nil {
+		r.opt.Logger.Printf("WARN: io_uring unavailable: %v; copying normally", err)
+		return
+	}
+	r.ring = ring
+}
+
+func (r *runner) stopRing() {
+	if r.ring != nil {
+		r.ring.close()
+	}
+}
+
+// ringFS wraps a WritableFS so that files it creates as *os.File copy OS source files
+// through the io_uring.
+type ringFS struct {
+	WritableFS
+	ring *ring
+}
+
+func (f ringFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	w, err := f.WritableFS.Create(name, perm)
+	if err != nil {
+		return nil, err
+	}
+	if of, ok := w.(*os.File); ok {
+		return &ringFile{File: of, ring: f.ring}, nil
+	}
+	return w, nil
+}
+
+// fileCopier is a target file that copies a whole source file by itself.
+type fileCopier interface {
+	// copyFrom copies src into the file, or reports false, having written nothing,
+	// when it cannot and src is to be copied normally.
+	copyFrom(src fs.File) (bool, error)
+}
+
+type ringFile struct {
+	*os.File
+	ring *ring
+}
+
+func (f *ringFile) copyFrom(src fs.File) (bool, error) {
+	sf, ok := src.(*os.File)
+	if !ok {
+		return false, nil
+	}
+	info, err := sf.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return false, nil
+	}
+	size := info.Size()
+	shrunk, err := f.ring.copy(int(f.Fd()), int(sf.Fd()), size)
+	if err == errRingUnsupported {
+		// Nothing usable was written; start over with a plain copy
+		if err := f.Truncate(0); err != nil {
+			return true, err
+		}
+		return false, nil
+	}
+	if err != nil {
+		return true, &fs.PathError{Op: "copy", Path: f.Name(), Err: err}
+	}
+	if shrunk {
+		return true, nil
+	}
+	// The ring reads and writes at explicit offsets; what the source grew by meanwhile
+	// is copied like io.Copy would
+	if _, err := sf.Seek(size, io.SeekStart); err != nil {
+		return true, err
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		return true, err
+	}
+	_, err = io.Copy(f.File, sf)
+	return true, err
+}
@@ -0,0 +1,299 @@
+//go:build linux
+
+package sync
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// io_uring ABI, see linux/io_uring.h.
+const (
+	sysIOUringSetup = 425
+	sysIOUringEnter = 426
+
+	ioringOffSQRing = 0
+	ioringOffCQRing = 0x8000000
+	ioringOffSQEs   = 0x10000000
+
+	ioringFeatSingleMmap = 1 << 0
+	// ioringFeatRWCurPos came with Linux 5.6, as did the READ and WRITE opcodes.
+	ioringFeatRWCurPos = 1 << 3
+
+	ioringEnterGetEvents = 1 << 0
+
+	ioringOpRead  = 22
+	ioringOpWrite = 23
+)
+
+type ioSQRingOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type ioCQRingOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type ioUringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFd uint32
+	resv                                                                   [3]uint32
+	sqOff                                                                  ioSQRingOffsets
+	cqOff                                                                  ioCQRingOffsets
+}
+
+type ioUringSQE struct {
+	opcode   uint8
+	flags    uint8
+	ioprio   uint16
+	fd       int32
+	off      uint64
+	addr     uint64
+	len      uint32
+	rwFlags  uint32
+	userData uint64
+	_        [3]uint64
+}
+
+type ioUringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// errRingUnsupported is returned by ring.copy when the files cannot be copied through
+// the ring (the kernel or filesystem rejects the operations) before anything was written.
+var errRingUnsupported = errors.New("io_uring read/write not supported")
+
+// ring is an io_uring with a fixed set of buffers, copying one file at a time with up to
+// one read or write per buffer in flight.
+type ring struct {
+	mu sync.Mutex
+	fd int
+
+	sqMem, cqMem, sqeMem []byte
+	sqTail               *uint32
+	sqMask               uint32
+	sqArray              []uint32
+	sqes                 []ioUringSQE
+	cqHead, cqTail       *uint32
+	cqMask               uint32
+	cqes                 []ioUringCQE
+
+	bufs    [][]byte
+	pending int
+	// broken is set when operations may still be in flight on the buffers.
+	broken bool
+}
+
+// newRing sets up an io_uring with the given number of buffers of size bytes.
+func newRing(buffers, size int) (*ring, error) {
+	var p ioUringParams
+	fd, _, errno := syscall.Syscall(sysIOUringSetup, uintptr(buffers), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("io_uring_setup", errno)
+	}
+	r := &ring{fd: int(fd)}
+	if p.features&ioringFeatRWCurPos == 0 {
+		r.close()
+		return nil, errors.New("kernel too old (io_uring read/write need Linux 5.6)")
+	}
+	if err := r.mmap(&p); err != nil {
+		r.close()
+		return nil, err
+	}
+	r.bufs = make([][]byte, buffers)
+	for i := range r.bufs {
+		r.bufs[i] = make([]byte, size)
+	}
+	return r, nil
+}
+
+func (r *ring) mmap(p *ioUringParams) error {
+	sqSize := int(p.sqOff.array + p.sqEntries*4)
+	cqSize := int(p.cqOff.cqes + p.cqEntries*uint32(unsafe.Sizeof(ioUringCQE{})))
+	single := p.features&ioringFeatSingleMmap != 0
+	if single && cqSize > sqSize {
+		sqSize = cqSize
+	}
+	var err error
+	if r.sqMem, err = ringMmap(r.fd, ioringOffSQRing, sqSize); err != nil {
+		return err
+	}
+	r.cqMem = r.sqMem
+	if !single {
+		if r.cqMem, err = ringMmap(r.fd, ioringOffCQRing, cqSize); err != nil {
+			return err
+		}
+	}
+	if r.sqeMem, err = ringMmap(r.fd, ioringOffSQEs, int(p.sqEntries)*int(unsafe.Sizeof(ioUringSQE{}))); err != nil {
+		return err
+	}
+
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sqMem[p.sqOff.tail]))
+	r.sqMask = *(*uint32)(unsafe.Pointer(&r.sqMem[p.sqOff.ringMask]))
+	r.sqArray = unsafe.Slice((*uint32)(unsafe.Pointer(&r.sqMem[p.sqOff.array])), p.sqEntries)
+	r.sqes = unsafe.Slice((*ioUringSQE)(unsafe.Pointer(&r.sqeMem[0])), p.sqEntries)
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cqMem[p.cqOff.head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cqMem[p.cqOff.tail]))
+	r.cqMask = *(*uint32)(unsafe.Pointer(&r.cqMem[p.cqOff.ringMask]))
+	r.cqes = unsafe.Slice((*ioUringCQE)(unsafe.Pointer(&r.cqMem[p.cqOff.cqes])), p.cqEntries)
+	return nil
+}
+
+func ringMmap(fd int, off int64, size int) ([]byte, error) {
+	b, err := syscall.Mmap(fd, off, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		return nil, os.NewSyscallError("mmap", err)
+	}
+	return b, nil
+}
+
+func (r *ring) close() {
+	if r.sqeMem != nil {
+		_ = syscall.Munmap(r.sqeMem)
+	}
+	if r.cqMem != nil && &r.cqMem[0] != &r.sqMem[0] {
+		_ = syscall.Munmap(r.cqMem)
+	}
+	if r.sqMem != nil {
+		_ = syscall.Munmap(r.sqMem)
+	}
+	_ = syscall.Close(r.fd)
+}
+
+// submit queues a read or write of buf at off of fd, tagged with the buffer index.
+func (r *ring) submit(op uint8, fd int, buf []byte, off int64, idx int) {
+	tail := atomic.LoadUint32(r.sqTail)
+	i := tail & r.sqMask
+	r.sqes[i] = ioUringSQE{
+		opcode:   op,
+		fd:       int32(fd),
+		off:      uint64(off),
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		len:      uint32(len(buf)),
+		userData: uint64(idx),
+	}
+	r.sqArray[i] = i
+	atomic.StoreUint32(r.sqTail, tail+1)
+	r.pending++
+}
+
+// wait submits the queued operations and waits for at least one completion.
+func (r *ring) wait() error {
+	for {
+		_, _, errno := syscall.Syscall6(sysIOUringEnter, uintptr(r.fd), uintptr(r.pending), 1, ioringEnterGetEvents, 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return os.NewSyscallError("io_uring_enter", errno)
+		}
+		r.pending = 0
+		return nil
+	}
+}
+
+// copy copies the first size bytes of the file src into dst, reading and writing at the
+// same offsets with all buffers in flight. Short reads and writes are resubmitted for the
+// rest; it reports whether src ended early, i.e. a read returned end of file.
+func (r *ring) copy(dst, src int, size int64) (shrunk bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.broken {
+		return false, errRingUnsupported
+	}
+
+	// done counts the bytes of the slot read, then written
+	type slot struct {
+		off     int64
+		n, done int
+		writing bool
+	}
+	slots := make([]slot, len(r.bufs))
+	var next int64
+	inflight, written := 0, false
+	read := func(i int) {
+		n := int64(len(r.bufs[i]))
+		if size-next < n {
+			n = size - next
+		}
+		slots[i] = slot{off: next, n: int(n)}
+		next += n
+		r.submit(ioringOpRead, src, r.bufs[i][:n], slots[i].off, i)
+		inflight++
+	}
+	write := func(i int) {
+		s := &slots[i]
+		s.writing, s.done = true, 0
+		r.submit(ioringOpWrite, dst, r.bufs[i][:s.n], s.off, i)
+		inflight++
+	}
+	for i := range r.bufs {
+		if next >= size {
+			break
+		}
+		read(i)
+	}
+
+	for inflight > 0 {
+		if err := r.wait(); err != nil {
+			// Operations still in flight may use the buffers; the ring is unusable
+			r.broken = true
+			return false, err
+		}
+		head := *r.cqHead
+		for tail := atomic.LoadUint32(r.cqTail); head != tail; head++ {
+			cqe := r.cqes[head&r.cqMask]
+			inflight--
+			i, s := int(cqe.userData), &slots[int(cqe.userData)]
+			switch {
+			case cqe.res < 0:
+				errno := syscall.Errno(-cqe.res)
+				if err == nil {
+					err = errno
+					if !written && (errno == syscall.EINVAL || errno == syscall.EOPNOTSUPP || errno == syscall.EBADF) {
+						err = errRingUnsupported
+					}
+				}
+			case !s.writing && cqe.res == 0:
+				// The source was truncated meanwhile: keep what was read, read no further
+				shrunk = true
+				s.n = s.done
+				if s.n > 0 && err == nil {
+					write(i)
+				}
+			case !s.writing:
+				s.done += int(cqe.res)
+				if s.done < s.n && err == nil {
+					// Short read: read the rest
+					r.submit(ioringOpRead, src, r.bufs[i][s.done:s.n], s.off+int64(s.done), i)
+					inflight++
+				} else if err == nil {
+					write(i)
+				}
+			case cqe.res == 0:
+				if err == nil {
+					err = syscall.EIO
+				}
+			default:
+				written = true
+				s.done += int(cqe.res)
+				if s.done < s.n && err == nil {
+					// Short write: write the rest
+					r.submit(ioringOpWrite, dst, r.bufs[i][s.done:s.n], s.off+int64(s.done), i)
+					inflight++
+				} else if err == nil && !shrunk && next < size {
+					read(i)
+				}
+			}
+		}
+		atomic.StoreUint32(r.cqHead, head)
+	}
+	return shrunk, err
+}
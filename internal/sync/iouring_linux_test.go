@@ -0,0 +1,148 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ringOrSkip returns an io_uring or skips the test where none is available.
+func ringOrSkip(t testing.TB) *ring {
+	t.Helper()
+	ring, err := newRing(ringBuffers, ringBufferSize)
+	if err != nil {
+		t.Skipf("io_uring unavailable: %v", err)
+	}
+	return ring
+}
+
+// ringSizes exercise empty files, partial and exact buffers and buffer reuse.
+var ringSizes = []int{0, 1, ringBufferSize - 1, ringBufferSize, 3*ringBufferSize + 17, (ringBuffers+3)*ringBufferSize + 5}
+
+func randomBytes(seed int64, n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+func TestIOUringCopy(t *testing.T) {
+	ringOrSkip(t).close()
+	src, dst := t.TempDir(), t.TempDir()
+	for i, n := range ringSizes {
+		if err := os.WriteFile(filepath.Join(src, fmt.Sprintf("f%d", i)), randomBytes(int64(i), n), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := newDirRunner(Options{Source: src, Target: dst, IOUring: true})
+	rep := r.run()
+	if len(rep.Errors) != 0 || rep.Copied != len(ringSizes) {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if r.ring == nil {
+		t.Fatal("files were not copied through io_uring")
+	}
+	for i, n := range ringSizes {
+		got, err := os.ReadFile(filepath.Join(dst, fmt.Sprintf("f%d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, randomBytes(int64(i), n)) {
+			t.Fatalf("f%d (%d bytes): content differs (got %d bytes)", i, n, len(got))
+		}
+	}
+}
+
+func TestRingCopy(t *testing.T) {
+	ring := ringOrSkip(t)
+	defer ring.close()
+	dir := t.TempDir()
+	for i, n := range ringSizes {
+		want := randomBytes(int64(i), n)
+		sp, dp := filepath.Join(dir, fmt.Sprintf("src%d", i)), filepath.Join(dir, fmt.Sprintf("dst%d", i))
+		if err := os.WriteFile(sp, want, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		sf, err := os.Open(sp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		df, err := os.Create(dp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copied, err := (&ringFile{File: df, ring: ring}).copyFrom(sf)
+		sf.Close()
+		df.Close()
+		if !copied || err != nil {
+			t.Fatalf("%d bytes: copied=%v err=%v", n, copied, err)
+		}
+		if got, err := os.ReadFile(dp); err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("%d bytes: content differs (got %d bytes, %v)", n, len(got), err)
+		}
+	}
+}
+
+func BenchmarkIOUring(b *testing.B) {
+	ringOrSkip(b).close()
+	src := b.TempDir()
+	for i := 0; i < 32; i++ {
+		if err := os.WriteFile(filepath.Join(src, fmt.Sprintf("f%02d", i)), randomBytes(int64(i), 4<<20), 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	quiet := log.New(io.Discard, "", 0)
+	for _, uring := range []bool{false, true} {
+		b.Run(fmt.Sprintf("io_uring=%v", uring), func(b *testing.B) {
+			b.SetBytes(32 << 22)
+			for i := 0; i < b.N; i++ {
+				// A fresh target each time, so that every file is copied
+				b.StopTimer()
+				dst := b.TempDir()
+				b.StartTimer()
+				if rep := Sync(Options{Source: src, Target: dst, IOUring: uring, Logger: quiet}); len(rep.Errors) != 0 {
+					b.Fatal(rep.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestRingCopyShortReads(t *testing.T) {
+	ring := ringOrSkip(t)
+	defer ring.close()
+	// A pipe returns what was written so far: a short read, not the end of the file
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	df, err := os.Create(filepath.Join(t.TempDir(), "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer df.Close()
+	if _, err := pw.WriteString("first half"); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		pw.WriteString("other half")
+		time.Sleep(20 * time.Millisecond)
+		pw.Close()
+	}()
+
+	shrunk, err := ring.copy(int(df.Fd()), int(pr.Fd()), 30)
+	if err != nil || !shrunk {
+		t.Fatalf("shrunk=%v err=%v, want the end of the pipe reported", shrunk, err)
+	}
+	if got, err := os.ReadFile(df.Name()); err != nil || string(got) != "first halfother half" {
+		t.Fatalf("got %q (%v)", got, err)
+	}
+}
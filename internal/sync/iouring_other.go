@@ -0,0 +1,19 @@
+//go:build !linux
+
+package sync
+
+import "errors"
+
+var errRingUnsupported = errors.New("io_uring is only available on Linux")
+
+type ring struct{}
+
+func newRing(buffers, size int) (*ring, error) {
+	return nil, errRingUnsupported
+}
+
+func (*ring) copy(dst, src int, size int64) (bool, error) {
+	return false, errRingUnsupported
+}
+
+func (*ring) close() {}
@@ -0,0 +1,81 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func seedTree(t testing.TB, dir string, files int) {
+	t.Helper()
+	for i := 0; i < files; i++ {
+		p := filepath.Join(dir, fmt.Sprintf("d%02d", i%10), fmt.Sprintf("f%04d.txt", i))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(p), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+}
+
+func TestTargetKnownEmpty(t *testing.T) {
+	src := t.TempDir()
+	seedTree(t, src, 50)
+
+	normal := t.TempDir()
+	fast := t.TempDir()
+	want := Sync(Options{Source: src, Target: normal})
+	got := Sync(Options{Source: src, Target: fast, TargetKnownEmpty: true, DeleteMissing: true})
+	if len(got.Errors) != 0 || got.Copied != want.Copied || got.Skipped != want.Skipped {
+		t.Fatalf("fast path %+v differs from normal path %+v", *got, *want)
+	}
+	err := filepath.WalkDir(normal, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(normal, p)
+		a, _ := os.ReadFile(p)
+		b, err := os.ReadFile(filepath.Join(fast, rel))
+		if err != nil || string(a) != string(b) {
+			t.Fatalf("%s differs: %v", rel, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+}
+
+func TestTargetKnownEmptyFallsBackWhenNotEmpty(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+	mustWrite(t, filepath.Join(dst, "orphan.txt"), "o")
+	Sync(Options{Source: src, Target: dst})
+
+	rep := Sync(Options{Source: src, Target: dst, TargetKnownEmpty: true, DeleteMissing: true})
+	if rep.Copied != 0 || rep.Skipped != 1 || rep.Deleted != 1 {
+		t.Fatalf("expected normal path on a non-empty target, got %+v", *rep)
+	}
+}
+
+func BenchmarkTargetKnownEmpty(b *testing.B) {
+	src := b.TempDir()
+	seedTree(b, src, 1000)
+	quiet := log.New(io.Discard, "", 0)
+
+	for _, known := range []bool{false, true} {
+		b.Run(fmt.Sprintf("known=%v", known), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				dst := b.TempDir()
+				b.StartTimer()
+				Sync(Options{Source: src, Target: dst, TargetKnownEmpty: known, Logger: quiet})
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// LineEndings selects the line-ending convention copied text files are converted to.
+type LineEndings int
+
+const (
+	// LineEndingsPreserve copies files byte for byte (the default).
+	LineEndingsPreserve LineEndings = iota
+	// LineEndingsLF converts CRLF to LF.
+	LineEndingsLF
+	// LineEndingsCRLF converts lone LF to CRLF.
+	LineEndingsCRLF
+)
+
+var lineEndingsNames = map[LineEndings]string{
+	LineEndingsPreserve: "preserve",
+	LineEndingsLF:       "lf",
+	LineEndingsCRLF:     "crlf",
+}
+
+func (l LineEndings) String() string {
+	if s, ok := lineEndingsNames[l]; ok {
+		return s
+	}
+	return fmt.Sprintf("LineEndings(%d)", int(l))
+}
+
+// ParseLineEndings parses the CLI spelling of a LineEndings ("preserve", "lf", "crlf").
+func ParseLineEndings(s string) (LineEndings, error) {
+	for l, name := range lineEndingsNames {
+		if name == s {
+			return l, nil
+		}
+	}
+	return LineEndingsPreserve, fmt.Errorf("unknown line endings %q", s)
+}
+
+// textSniffLen is how much of a file is checked for NUL bytes to tell text from binary.
+const textSniffLen = 8000
+
+// LineEndingsTransform returns the Transform behind Options.LineEndings: text files
+// (no NUL byte in the first 8000 bytes) get their line endings converted to mode.
+func LineEndingsTransform(mode LineEndings) Transform {
+	return func(_ string, r io.Reader) (io.Reader, error) {
+		return newEOLReader(r, mode), nil
+	}
+}
+
+// newEOLReader returns a reader converting the line endings of r to mode,
+// or passing r through unchanged if its first textSniffLen bytes hold a NUL byte (binary).
+func newEOLReader(r io.Reader, mode LineEndings) io.Reader {
+	br := bufio.NewReaderSize(r, 2*textSniffLen)
+	sample, _ := br.Peek(textSniffLen)
+	if mode == LineEndingsPreserve || bytes.IndexByte(sample, 0) >= 0 {
+		return br
+	}
+	return &eolReader{r: br, crlf: mode == LineEndingsCRLF}
+}
+
+type eolReader struct {
+	r    *bufio.Reader
+	crlf bool
+	// lf is a pending '\n' of a CRLF pair that did not fit into the last Read.
+	lf bool
+}
+
+func (e *eolReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if e.lf {
+			p[n] = '\n'
+			n++
+			e.lf = false
+			continue
+		}
+		b, err := e.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		switch {
+		case b == '\r':
+			next, err := e.r.Peek(1)
+			if err != nil || next[0] != '\n' {
+				// A lone CR is no line ending
+				break
+			}
+			_, _ = e.r.ReadByte()
+			if e.crlf {
+				p[n] = '\r'
+				n++
+				e.lf = true
+				continue
+			}
+			b = '\n'
+		case b == '\n' && e.crlf:
+			p[n] = '\r'
+			n++
+			e.lf = true
+			continue
+		}
+		p[n] = b
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,99 @@
+package sync
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestEOLReader(t *testing.T) {
+	for _, tc := range []struct {
+		mode     LineEndings
+		in, want string
+	}{
+		{LineEndingsLF, "a\r\nb\r\n", "a\nb\n"},
+		{LineEndingsLF, "a\nb\rc\r", "a\nb\rc\r"},
+		{LineEndingsCRLF, "a\nb\r\nc", "a\r\nb\r\nc"},
+		{LineEndingsCRLF, "\n\n", "\r\n\r\n"},
+		{LineEndingsPreserve, "a\r\nb\n", "a\r\nb\n"},
+		// Binary content is passed through
+		{LineEndingsLF, "\x00a\r\n", "\x00a\r\n"},
+		{LineEndingsCRLF, "a\n\x00\n", "a\n\x00\n"},
+	} {
+		// One-byte reads exercise the CRLF pair split across reads
+		got, err := io.ReadAll(iotest.OneByteReader(newEOLReader(strings.NewReader(tc.in), tc.mode)))
+		if err != nil || string(got) != tc.want {
+			t.Fatalf("%v %q: got %q, %v want %q", tc.mode, tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+	writeWithModTime(t, filepath.Join(src, "existing.txt"), "one\r\ntwo\r\n", 0o644, mtime)
+	writeWithModTime(t, filepath.Join(dst, "existing.txt"), "one\ntwo\n", 0o644, mtime)
+	writeWithModTime(t, filepath.Join(src, "new.txt"), "a\r\nb\r\n", 0o644, mtime)
+	writeWithModTime(t, filepath.Join(src, "blob.bin"), "\x00\r\n", 0o644, mtime)
+
+	opt := Options{Source: src, Target: dst, LineEndings: LineEndingsLF}
+	rep := Sync(opt)
+	if len(rep.Errors) != 0 || rep.Copied != 2 || rep.Overwritten != 0 || rep.Skipped != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	want := map[string]string{"existing.txt": "one\ntwo\n", "new.txt": "a\nb\n", "blob.bin": "\x00\r\n"}
+	if got := treeContents(t, dst); !reflect.DeepEqual(got, want) {
+		t.Fatalf("target: got %q want %q", got, want)
+	}
+
+	// Converted files must not look changed on the next run
+	for _, o := range []Options{opt, {Source: src, Target: dst, LineEndings: LineEndingsLF, IgnoreModTime: true}} {
+		rep = Sync(o)
+		if len(rep.Errors) != 0 || rep.Copied+rep.Overwritten != 0 || rep.Skipped != 3 {
+			t.Fatalf("re-sync (IgnoreModTime=%v) not stable: %+v", o.IgnoreModTime, *rep)
+		}
+	}
+
+	// A real change is still copied
+	writeWithModTime(t, filepath.Join(src, "existing.txt"), "one\r\nthree\r\n", 0o644, time.Now())
+	rep = Sync(opt)
+	if rep.Overwritten != 1 {
+		t.Fatalf("expected the change to be copied: %+v", *rep)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dst, "existing.txt")); string(b) != "one\nthree\n" {
+		t.Fatalf("existing.txt: %q", b)
+	}
+}
+
+func TestLineEndingsCRLF(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "unix.txt"), "a\nb\n")
+
+	opt := Options{Source: src, Target: dst, LineEndings: LineEndingsCRLF}
+	if rep := Sync(opt); len(rep.Errors) != 0 || rep.Copied != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dst, "unix.txt")); string(b) != "a\r\nb\r\n" {
+		t.Fatalf("unix.txt: %q", b)
+	}
+	if rep := Sync(opt); rep.Copied+rep.Overwritten != 0 || rep.Skipped != 1 {
+		t.Fatalf("re-sync not stable: %+v", *rep)
+	}
+}
+
+func TestParseLineEndings(t *testing.T) {
+	for _, l := range []LineEndings{LineEndingsPreserve, LineEndingsLF, LineEndingsCRLF} {
+		got, err := ParseLineEndings(l.String())
+		if err != nil || got != l {
+			t.Fatalf("round trip %v: got %v, %v", l, got, err)
+		}
+	}
+	if _, err := ParseLineEndings("cr"); err == nil {
+		t.Fatalf("expected error for unknown line endings")
+	}
+}
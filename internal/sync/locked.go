@@ -0,0 +1,15 @@
+package sync
+
+// locked reports whether another process holds the source file rel locked.
+// Only OS-backed sources can be probed.
+func (r *runner) locked(rel string) bool {
+	src, ok := r.src.(dirFS)
+	if !ok {
+		return false
+	}
+	p, err := src.path("open", rel)
+	if err != nil {
+		return false
+	}
+	return isLocked(p)
+}
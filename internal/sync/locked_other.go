@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package sync
+
+// isLocked cannot probe locks on this platform.
+func isLocked(string) bool {
+	return false
+}
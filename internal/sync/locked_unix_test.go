@@ -0,0 +1,50 @@
+//go:build unix
+
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestSkipLockedFiles(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "free.txt"), "free")
+	lockedPath := filepath.Join(src, "busy.db")
+	mustWrite(t, lockedPath, "busy")
+
+	// flock binds the open file, so a second open in this process conflicts like another process would.
+	f, err := os.Open(lockedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		t.Fatalf("flock: %v", err)
+	}
+
+	rep := Sync(Options{Source: src, Target: dst, SkipLockedFiles: true})
+	if len(rep.Errors) != 0 || rep.Copied != 1 || rep.SkippedLocked != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "busy.db")); !os.IsNotExist(err) {
+		t.Fatalf("locked file must not be copied, got %v", err)
+	}
+
+	// Without the option the lock is ignored.
+	rep = Sync(Options{Source: src, Target: dst})
+	if rep.Copied != 1 || rep.SkippedLocked != 0 {
+		t.Fatalf("unexpected rep without SkipLockedFiles: %+v", *rep)
+	}
+
+	// Once released, the file is picked up.
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
+		t.Fatal(err)
+	}
+	rep = Sync(Options{Source: src, Target: t.TempDir(), SkipLockedFiles: true})
+	if rep.Copied != 2 || rep.SkippedLocked != 0 {
+		t.Fatalf("unexpected rep after unlock: %+v", *rep)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build windows
+
+package sync
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// isLocked reports whether p cannot be opened for reading while denying writers,
+// i.e. another process has it open for writing or locked.
+func isLocked(p string) bool {
+	name, err := syscall.UTF16PtrFromString(p)
+	if err != nil {
+		return false
+	}
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ, syscall.FILE_SHARE_READ, nil,
+		syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation)
+	}
+	syscall.CloseHandle(h)
+	return false
+}
@@ -0,0 +1,37 @@
+//go:build windows
+
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestSkipLockedFiles(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "free.txt"), "free")
+	lockedPath := filepath.Join(src, "busy.db")
+	mustWrite(t, lockedPath, "busy")
+
+	// Hold the file open for writing without sharing, like a database would.
+	name, err := syscall.UTF16PtrFromString(lockedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil,
+		syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.CloseHandle(h)
+
+	rep := Sync(Options{Source: src, Target: dst, SkipLockedFiles: true})
+	if len(rep.Errors) != 0 || rep.Copied != 1 || rep.SkippedLocked != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "busy.db")); !os.IsNotExist(err) {
+		t.Fatalf("locked file must not be copied, got %v", err)
+	}
+}
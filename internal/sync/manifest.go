@@ -0,0 +1,139 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// Changes lists how the target changed since the run that wrote the previous Options.Manifest.
+// Entries are target-relative, slash-separated file names, sorted.
+type Changes struct {
+	Added    []string
+	Modified []string
+	Removed  []string
+}
+
+// manifest is the on-disk snapshot of the target written after each run with Options.Manifest.
+// Files maps a target file name to its size and mod-time.
+type manifest struct {
+	Files map[string]manifestEntry `json:"files"`
+}
+
+type manifestEntry struct {
+	Size    int64 `json:"size"`
+	ModTime int64 `json:"mtime"`
+}
+
+// loadManifest reads the manifest at p. A missing file yields an empty manifest.
+func loadManifest(p string) (*manifest, error) {
+	m := &manifest{Files: map[string]manifestEntry{}}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return m, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", p, err)
+	}
+	if m.Files == nil {
+		m.Files = map[string]manifestEntry{}
+	}
+	return m, nil
+}
+
+// save writes the manifest to p through a temp file so a crash never leaves a truncated manifest.
+func (m *manifest) save(p string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := p + tempSuffix
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// changesSince compares the current snapshot m with prev.
+func (m *manifest) changesSince(prev *manifest) *Changes {
+	c := &Changes{}
+	for name, e := range m.Files {
+		old, ok := prev.Files[name]
+		switch {
+		case !ok:
+			c.Added = append(c.Added, name)
+		case old != e:
+			c.Modified = append(c.Modified, name)
+		}
+	}
+	for name := range prev.Files {
+		if _, ok := m.Files[name]; !ok {
+			c.Removed = append(c.Removed, name)
+		}
+	}
+	sort.Strings(c.Added)
+	sort.Strings(c.Modified)
+	sort.Strings(c.Removed)
+	return c
+}
+
+// snapshotTarget records every file in the target, leaving out the trash,
+// the completion marker and temp files.
+func (r *runner) snapshotTarget() (*manifest, error) {
+	m := &manifest{Files: map[string]manifestEntry{}}
+	err := fs.WalkDir(r.dst, ".", func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if r.isTrash(rel) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if rel == r.opt.CompletionMarker || isTempName(rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		// Whole seconds, like differ
+		m.Files[rel] = manifestEntry{Size: info.Size(), ModTime: info.ModTime().Unix()}
+		return nil
+	})
+	return m, err
+}
+
+// updateManifest compares the target with the previous manifest into Report.Changes
+// and stores the new state. Failures are recorded as errors; the sync itself is not undone.
+func (r *runner) updateManifest() {
+	p := r.opt.Manifest
+	prev, err := loadManifest(p)
+	if err != nil {
+		r.opt.Logger.Printf("WARN: load manifest %s: %v; not reporting changes", p, err)
+	}
+	cur, err := r.snapshotTarget()
+	if err != nil {
+		r.opt.Logger.Printf("ERR: manifest: walk target %s: %v", r.dstPath("."), err)
+		r.rep.addErr(err)
+		return
+	}
+	if prev != nil {
+		r.rep.Changes = cur.changesSince(prev)
+	}
+	if err := cur.save(p); err != nil {
+		r.opt.Logger.Printf("ERR: save manifest %s: %v", p, err)
+		r.rep.addErr(err)
+	}
+}
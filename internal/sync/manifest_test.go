@@ -0,0 +1,64 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestManifestChanges(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+	opt := Options{Source: src, Target: dst, DeleteMissing: true, Manifest: manifest, CompletionMarker: ".done"}
+
+	mustWrite(t, filepath.Join(src, "keep.txt"), "k")
+	mustWrite(t, filepath.Join(src, "edit.txt"), "v1")
+	mustWrite(t, filepath.Join(src, "dir", "drop.txt"), "d")
+
+	rep := Sync(opt)
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+	// No previous manifest: everything is new
+	want := &Changes{Added: []string{"dir/drop.txt", "edit.txt", "keep.txt"}}
+	if !reflect.DeepEqual(rep.Changes, want) {
+		t.Fatalf("first run changes: got %+v want %+v", rep.Changes, want)
+	}
+
+	writeWithModTime(t, filepath.Join(src, "edit.txt"), "v2", 0o644, time.Now().Add(time.Hour))
+	if err := os.Remove(filepath.Join(src, "dir", "drop.txt")); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, filepath.Join(src, "dir", "new.txt"), "n")
+
+	rep = Sync(opt)
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+	want = &Changes{Added: []string{"dir/new.txt"}, Modified: []string{"edit.txt"}, Removed: []string{"dir/drop.txt"}}
+	if !reflect.DeepEqual(rep.Changes, want) {
+		t.Fatalf("second run changes: got %+v want %+v", rep.Changes, want)
+	}
+
+	rep = Sync(opt)
+	if !reflect.DeepEqual(rep.Changes, &Changes{}) {
+		t.Fatalf("expected no changes, got %+v", rep.Changes)
+	}
+}
+
+func TestManifestDryRun(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+
+	rep := Sync(Options{Source: src, Target: t.TempDir(), Manifest: manifest, DryRun: true})
+	if rep.Changes != nil {
+		t.Fatalf("dry run must not report changes: %+v", rep.Changes)
+	}
+	if _, err := os.Stat(manifest); !os.IsNotExist(err) {
+		t.Fatalf("dry run must not write the manifest, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// Marker is the content of the Options.CompletionMarker file.
+type Marker struct {
+	Time        time.Time `json:"time"`
+	Copied      int       `json:"copied"`
+	Overwritten int       `json:"overwritten"`
+	Deleted     int       `json:"deleted"`
+	Skipped     int       `json:"skipped"`
+	// Files is the number of files known to be in sync after the run.
+	Files int `json:"files"`
+	// Checksum is a SHA-256 over the name, size and mod-time of every file in sync, in walk order.
+	Checksum string `json:"sha256"`
+}
+
+// markSynced feeds a file that is in sync after this run into the marker checksum.
+// It also offers the file to the post-sync sample (Options.PostSyncSample).
+func (r *runner) markSynced(rel, dstRel string, info fs.FileInfo) {
+	r.sampleSynced(rel, dstRel)
+	if r.opt.CompletionMarker == "" {
+		return
+	}
+	if r.synced == nil {
+		r.synced = sha256.New()
+	}
+	fmt.Fprintf(r.synced, "%q %d %d\n", rel, info.Size(), info.ModTime().Unix())
+	r.syncedFiles++
+}
+
+// removeStaleMarker deletes the marker left by a previous run before anything is changed.
+func (r *runner) removeStaleMarker() {
+	name := r.opt.CompletionMarker
+	if err := r.dst.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		r.opt.Logger.Printf("ERR: remove stale marker %s: %v", r.dstPath(name), err)
+		r.rep.addErr(err)
+	}
+}
+
+// writeMarker writes the completion marker if the run finished without errors.
+func (r *runner) writeMarker() {
+	if r.rep.ErrorCount() > 0 {
+		r.opt.Logger.Printf("SKIP: completion marker not written (%d errors)", r.rep.ErrorCount())
+		return
+	}
+	m := Marker{
+		Time:        time.Now().UTC(),
+		Copied:      r.rep.Copied,
+		Overwritten: r.rep.Overwritten,
+		Deleted:     r.rep.Deleted,
+		Skipped:     r.rep.Skipped,
+		Files:       r.syncedFiles,
+	}
+	if r.synced == nil {
+		r.synced = sha256.New()
+	}
+	m.Checksum = hex.EncodeToString(r.synced.Sum(nil))
+
+	name := r.opt.CompletionMarker
+	if err := writeJSON(r.dst, name, m); err != nil {
+		r.opt.Logger.Printf("ERR: write marker %s: %v", r.dstPath(name), err)
+		r.rep.addErr(err)
+		return
+	}
+	r.opt.Logger.Printf("MARKER: %s", r.dstPath(name))
+}
+
+// writeJSON atomically writes v as indented JSON to name in dst.
+func writeJSON(dst WritableFS, name string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := name + tempSuffix
+	w, err := dst.Create(tmp, 0o644)
+	if err != nil {
+		return err
+	}
+	_, wErr := w.Write(append(b, '\n'))
+	cErr := w.Close()
+	if err := errors.Join(wErr, cErr); err != nil {
+		_ = dst.Remove(tmp)
+		return err
+	}
+	if err := dst.Rename(tmp, name); err != nil {
+		_ = dst.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCompletionMarkerWrittenOnSuccess(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+	mustWrite(t, filepath.Join(src, "sub", "b.txt"), "b")
+
+	rep := Sync(Options{Source: src, Target: dst, CompletionMarker: ".synced"})
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dst, ".synced"))
+	if err != nil {
+		t.Fatalf("read marker: %v", err)
+	}
+	var m Marker
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("parse marker: %v", err)
+	}
+	if m.Copied != 2 || m.Files != 2 || len(m.Checksum) != 64 || m.Time.IsZero() {
+		t.Fatalf("unexpected marker: %+v", m)
+	}
+
+	// An unchanged tree yields the same checksum on the next run.
+	Sync(Options{Source: src, Target: dst, CompletionMarker: ".synced"})
+	b, _ = os.ReadFile(filepath.Join(dst, ".synced"))
+	var m2 Marker
+	if err := json.Unmarshal(b, &m2); err != nil {
+		t.Fatalf("parse marker: %v", err)
+	}
+	if m2.Checksum != m.Checksum || m2.Skipped != 2 {
+		t.Fatalf("expected stable checksum, got %+v vs %+v", m2, m)
+	}
+}
+
+func TestCompletionMarkerAbsentOnFailure(t *testing.T) {
+	src := fstest.MapFS{
+		"a.txt":   {Data: []byte("a")},
+		"bad.txt": {Data: []byte("b")},
+	}
+	dst := newMemFS()
+	dst.MapFS[".synced"] = &fstest.MapFile{Data: []byte("{}")}
+
+	rep := SyncFS(failOpenFS{FS: src, fail: "bad.txt"}, dst, Options{CompletionMarker: ".synced"})
+	if len(rep.Errors) != 1 {
+		t.Fatalf("expected one error, got %v", rep.Errors)
+	}
+	if _, ok := dst.MapFS[".synced"]; ok {
+		t.Fatalf("expected stale marker removed and not rewritten")
+	}
+}
@@ -0,0 +1,68 @@
+package sync
+
+import (
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// pendingTimes is a mod-time to apply to a written target file in the metadata pass (Options.DeferMetadata).
+// atime is the source access time with Options.PreserveAllTimes, otherwise zero.
+type pendingTimes struct {
+	dstRel  string
+	modTime time.Time
+	atime   time.Time
+}
+
+// noTimesFS discards Chtimes while files are written, leaving mod-times to the metadata pass.
+type noTimesFS struct {
+	WritableFS
+}
+
+func (noTimesFS) Chtimes(string, time.Time, time.Time) error {
+	return nil
+}
+
+// writeTarget returns the target to write the copy of the source file described by info through.
+func (r *runner) writeTarget(info fs.FileInfo) WritableFS {
+	dst := r.dst
+	if r.ring != nil {
+		dst = ringFS{WritableFS: dst, ring: r.ring}
+	}
+	if r.opt.DeferMetadata {
+		return noTimesFS{dst}
+	}
+	if atime, ok := r.sourceAtime(info); ok {
+		return atimeFS{WritableFS: dst, atime: atime}
+	}
+	return dst
+}
+
+// deferTimes schedules the source times of a copied file for the metadata pass.
+func (r *runner) deferTimes(dstRel string, info fs.FileInfo) {
+	if r.opt.DeferMetadata {
+		atime, _ := r.sourceAtime(info)
+		r.pendingTimes = append(r.pendingTimes, pendingTimes{dstRel: dstRel, modTime: info.ModTime(), atime: atime})
+	}
+}
+
+// applyMetadata sets the mod-times of all files copied during the run in one sweep,
+// sorted by name so that entries of the same directory are updated together.
+// A file whose mod-time cannot be set keeps its content; the error is recorded
+// and the next run copies it again.
+func (r *runner) applyMetadata() {
+	pending := r.pendingTimes
+	r.pendingTimes = nil
+	sort.Slice(pending, func(i, j int) bool { return pending[i].dstRel < pending[j].dstRel })
+	now := time.Now()
+	for _, p := range pending {
+		atime := p.atime
+		if atime.IsZero() {
+			atime = now
+		}
+		if err := r.dst.Chtimes(p.dstRel, atime, p.modTime); err != nil {
+			r.opt.Logger.Printf("ERR: chtimes %s: %v", r.dstPath(p.dstRel), err)
+			r.rep.addErr(err)
+		}
+	}
+}
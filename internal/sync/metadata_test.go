@@ -0,0 +1,103 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestDeferMetadata(t *testing.T) {
+	for _, transactional := range []bool{false, true} {
+		t.Run(fmt.Sprintf("transactional=%v", transactional), func(t *testing.T) {
+			src, dst := t.TempDir(), t.TempDir()
+			base := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+			names := []string{"b.txt", "a.txt", "dir/c.txt", "dir/sub/d.txt"}
+			for i, name := range names {
+				p := filepath.Join(src, filepath.FromSlash(name))
+				mustWrite(t, p, name)
+				mtime := base.Add(time.Duration(i) * time.Hour)
+				if err := os.Chtimes(p, mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opt := Options{Source: src, Target: dst, DeferMetadata: true, Transactional: transactional}
+			rep := Sync(opt)
+			if len(rep.Errors) != 0 || rep.Copied != len(names) {
+				t.Fatalf("unexpected rep: %+v", *rep)
+			}
+			for i, name := range names {
+				info, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := base.Add(time.Duration(i) * time.Hour); !info.ModTime().Equal(want) {
+					t.Fatalf("%s: mtime %v, want %v", name, info.ModTime(), want)
+				}
+			}
+
+			rep = Sync(opt)
+			if rep.Copied+rep.Overwritten != 0 || rep.Skipped != len(names) {
+				t.Fatalf("second run should find everything in sync: %+v", *rep)
+			}
+		})
+	}
+}
+
+// chtimesFailFS fails Chtimes for one name.
+type chtimesFailFS struct {
+	*memFS
+	fail string
+}
+
+func (c chtimesFailFS) Chtimes(name string, atime, mtime time.Time) error {
+	if name == c.fail {
+		return errors.New("injected chtimes failure")
+	}
+	return c.memFS.Chtimes(name, atime, mtime)
+}
+
+func TestDeferMetadataFailure(t *testing.T) {
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	src := fstest.MapFS{
+		"a.txt": {Data: []byte("a"), ModTime: mtime},
+		"b.txt": {Data: []byte("b"), ModTime: mtime},
+	}
+	dst := chtimesFailFS{memFS: newMemFS(), fail: "b.txt"}
+
+	rep := SyncFS(src, dst, Options{DeferMetadata: true})
+	if len(rep.Errors) != 1 || rep.Copied != 2 {
+		t.Fatalf("expected both copied and one error: %+v", *rep)
+	}
+	if got := dst.MapFS["a.txt"].ModTime; !got.Equal(mtime) {
+		t.Fatalf("a.txt: mtime %v, want %v", got, mtime)
+	}
+	if dst.MapFS["b.txt"].ModTime.Equal(mtime) {
+		t.Fatalf("b.txt must not have the source mtime")
+	}
+}
+
+// BenchmarkDeferMetadata is meant to be run with TMPDIR on the filesystem of interest, e.g. a network
+// mount; on local disks metadata updates are cheap and deferring them rarely pays off.
+func BenchmarkDeferMetadata(b *testing.B) {
+	src := b.TempDir()
+	seedTree(b, src, 10000)
+	quiet := log.New(io.Discard, "", 0)
+
+	for _, deferred := range []bool{false, true} {
+		b.Run(fmt.Sprintf("deferred=%v", deferred), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				dst := b.TempDir()
+				b.StartTimer()
+				Sync(Options{Source: src, Target: dst, DeferMetadata: deferred, Logger: quiet})
+			}
+		})
+	}
+}
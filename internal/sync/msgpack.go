@@ -0,0 +1,199 @@
+package sync
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// msgpackWriter appends the MessagePack encodings of the few types ReportMessage needs.
+type msgpackWriter struct {
+	b []byte
+}
+
+func (w *msgpackWriter) header(n int, fix, fixMax byte, c16, c32 byte) {
+	switch {
+	case n <= int(fixMax):
+		w.b = append(w.b, fix|byte(n))
+	case n <= math.MaxUint16:
+		w.b = binary.BigEndian.AppendUint16(append(w.b, c16), uint16(n))
+	default:
+		w.b = binary.BigEndian.AppendUint32(append(w.b, c32), uint32(n))
+	}
+}
+
+func (w *msgpackWriter) mapHeader(n int) {
+	w.header(n, 0x80, 15, 0xde, 0xdf)
+}
+
+func (w *msgpackWriter) arrayHeader(n int) {
+	w.header(n, 0x90, 15, 0xdc, 0xdd)
+}
+
+func (w *msgpackWriter) str(s string) {
+	if n := len(s); n > 31 && n <= math.MaxUint8 {
+		w.b = append(w.b, 0xd9, byte(n))
+	} else {
+		w.header(n, 0xa0, 31, 0xda, 0xdb)
+	}
+	w.b = append(w.b, s...)
+}
+
+// int writes v in the shortest form: fixint, or int64 for anything larger.
+func (w *msgpackWriter) int(v int64) {
+	if v >= -32 && v <= 127 {
+		w.b = append(w.b, byte(v))
+		return
+	}
+	w.b = binary.BigEndian.AppendUint64(append(w.b, 0xd3), uint64(v))
+}
+
+// msgpackReader decodes MessagePack values from b. The first error sticks and
+// makes every further read return a zero value.
+type msgpackReader struct {
+	b   []byte
+	err error
+}
+
+func (r *msgpackReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < n {
+		r.err = errors.New("unexpected end of data")
+		return nil
+	}
+	p := r.b[:n]
+	r.b = r.b[n:]
+	return p
+}
+
+func (r *msgpackReader) byte() byte {
+	if p := r.next(1); p != nil {
+		return p[0]
+	}
+	return 0
+}
+
+// uint reads an n-byte big-endian unsigned integer.
+func (r *msgpackReader) uint(n int) uint64 {
+	var v uint64
+	for _, c := range r.next(n) {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func (r *msgpackReader) fail(what string, c byte) {
+	if r.err == nil {
+		r.err = fmt.Errorf("expected %s, got type byte 0x%02x", what, c)
+	}
+}
+
+// length reads a map, array or string header whose fix form is fix (masked by fixMask).
+func (r *msgpackReader) length(what string, fix, fixMask byte, c8, c16, c32 byte) int {
+	c := r.byte()
+	switch {
+	case r.err != nil:
+		return 0
+	case c&^fixMask == fix:
+		return int(c & fixMask)
+	case c8 != 0 && c == c8:
+		return int(r.uint(1))
+	case c == c16:
+		return int(r.uint(2))
+	case c == c32:
+		return int(r.uint(4))
+	}
+	r.fail(what, c)
+	return 0
+}
+
+func (r *msgpackReader) mapHeader() int {
+	return r.length("map", 0x80, 0x0f, 0, 0xde, 0xdf)
+}
+
+func (r *msgpackReader) arrayHeader() int {
+	return r.length("array", 0x90, 0x0f, 0, 0xdc, 0xdd)
+}
+
+func (r *msgpackReader) str() string {
+	n := r.length("string", 0xa0, 0x1f, 0xd9, 0xda, 0xdb)
+	return string(r.next(n))
+}
+
+func (r *msgpackReader) int() int64 {
+	c := r.byte()
+	switch {
+	case r.err != nil:
+		return 0
+	case c <= 0x7f:
+		return int64(c)
+	case c >= 0xe0:
+		return int64(int8(c))
+	case c >= 0xcc && c <= 0xcf:
+		// uint8 to uint64
+		v := r.uint(1 << (c - 0xcc))
+		if v > math.MaxInt64 {
+			r.err = errors.New("integer overflows int64")
+		}
+		return int64(v)
+	case c >= 0xd0 && c <= 0xd3:
+		// int8 to int64
+		n := 1 << (c - 0xd0)
+		v := r.uint(n)
+		shift := 64 - 8*n
+		return int64(v<<shift) >> shift
+	}
+	r.fail("integer", c)
+	return 0
+}
+
+// skip reads over one value of any type.
+func (r *msgpackReader) skip() {
+	c := r.byte()
+	if r.err != nil {
+		return
+	}
+	skipN := func(n int) {
+		for ; n > 0 && r.err == nil; n-- {
+			r.skip()
+		}
+	}
+	switch {
+	case c <= 0x7f, c >= 0xe0, c == 0xc0, c == 0xc2, c == 0xc3:
+		// fixint, nil, bool
+	case c&0xf0 == 0x80:
+		skipN(2 * int(c&0x0f))
+	case c&0xf0 == 0x90:
+		skipN(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		r.next(int(c & 0x1f))
+	case c == 0xc4 || c == 0xd9:
+		r.next(int(r.uint(1)))
+	case c == 0xc5 || c == 0xda:
+		r.next(int(r.uint(2)))
+	case c == 0xc6 || c == 0xdb:
+		r.next(int(r.uint(4)))
+	case c == 0xcc || c == 0xd0:
+		r.next(1)
+	case c == 0xcd || c == 0xd1:
+		r.next(2)
+	case c == 0xce || c == 0xd2 || c == 0xca:
+		r.next(4)
+	case c == 0xcf || c == 0xd3 || c == 0xcb:
+		r.next(8)
+	case c == 0xdc:
+		skipN(int(r.uint(2)))
+	case c == 0xdd:
+		skipN(int(r.uint(4)))
+	case c == 0xde:
+		skipN(2 * int(r.uint(2)))
+	case c == 0xdf:
+		skipN(2 * int(r.uint(4)))
+	default:
+		// Extension types are never written by ReportMessage
+		r.fail("a supported type", c)
+	}
+}
@@ -0,0 +1,43 @@
+package sync
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestModTimeTolerance(t *testing.T) {
+	// 900ms apart across a second boundary: whole-second truncation sees 10s vs 11s.
+	srcTime := time.Date(2024, 1, 1, 10, 0, 10, 600e6, time.UTC)
+	dstTime := srcTime.Add(900 * time.Millisecond)
+
+	tests := []struct {
+		tolerance     time.Duration
+		wantOverwrite bool
+	}{
+		{0, true},
+		{500 * time.Millisecond, true},
+		{time.Second, false},
+	}
+	for _, tt := range tests {
+		src := fstest.MapFS{"a.txt": {Data: []byte("same"), ModTime: srcTime}}
+		dst := newMemFS()
+		dst.MapFS["a.txt"] = &fstest.MapFile{Data: []byte("same"), ModTime: dstTime}
+
+		rep := SyncFS(src, dst, Options{ModTimeTolerance: tt.tolerance})
+		if len(rep.Errors) != 0 {
+			t.Fatalf("unexpected errors: %v", rep.Errors)
+		}
+		if got := rep.Overwritten == 1; got != tt.wantOverwrite {
+			t.Fatalf("tolerance %v: overwritten=%v want %v (%+v)", tt.tolerance, got, tt.wantOverwrite, *rep)
+		}
+	}
+
+	// A size change is never tolerated
+	src := fstest.MapFS{"a.txt": {Data: []byte("longer"), ModTime: srcTime}}
+	dst := newMemFS()
+	dst.MapFS["a.txt"] = &fstest.MapFile{Data: []byte("same"), ModTime: srcTime}
+	if rep := SyncFS(src, dst, Options{ModTimeTolerance: time.Hour}); rep.Overwritten != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+}
@@ -0,0 +1,130 @@
+package sync
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// SanitizeMode selects how names that are illegal on the target filesystem are handled.
+type SanitizeMode int
+
+const (
+	// SanitizeOff copies names as they are.
+	SanitizeOff SanitizeMode = iota
+	// SanitizeError records an error for every illegal name.
+	SanitizeError
+	// SanitizeSkip skips entries with illegal names.
+	SanitizeSkip
+	// SanitizeReplace replaces the offending parts of the name on the target.
+	SanitizeReplace
+)
+
+var sanitizeModeNames = map[SanitizeMode]string{
+	SanitizeOff:     "off",
+	SanitizeError:   "error",
+	SanitizeSkip:    "skip",
+	SanitizeReplace: "replace",
+}
+
+func (m SanitizeMode) String() string {
+	if s, ok := sanitizeModeNames[m]; ok {
+		return s
+	}
+	return fmt.Sprintf("SanitizeMode(%d)", int(m))
+}
+
+// ParseSanitizeMode parses the CLI spelling of a SanitizeMode ("off", "error", "skip", "replace").
+func ParseSanitizeMode(s string) (SanitizeMode, error) {
+	for m, name := range sanitizeModeNames {
+		if name == s {
+			return m, nil
+		}
+	}
+	return SanitizeOff, fmt.Errorf("unknown sanitize mode %q", s)
+}
+
+// illegalNameChars are rejected by Windows and SMB shares.
+const illegalNameChars = `<>:"\|?*`
+
+// reservedNames are Windows device names, reserved regardless of case or extension.
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// invalidNameReason describes why a single path element is illegal on the target, or returns "".
+func invalidNameReason(name string) string {
+	for _, c := range name {
+		if c < 0x20 || strings.ContainsRune(illegalNameChars, c) {
+			return fmt.Sprintf("illegal character %q", c)
+		}
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return "trailing dot or space"
+	}
+	stem, _, _ := strings.Cut(name, ".")
+	if reservedNames[strings.ToUpper(stem)] {
+		return "reserved name"
+	}
+	return ""
+}
+
+// sanitizeName rewrites a path element so that it is legal on the target.
+func sanitizeName(name string) string {
+	name = strings.Map(func(c rune) rune {
+		if c < 0x20 || strings.ContainsRune(illegalNameChars, c) {
+			return '_'
+		}
+		return c
+	}, name)
+	trimmed := strings.TrimRight(name, ". ")
+	name = trimmed + strings.Repeat("_", len(name)-len(trimmed))
+	stem, ext, hasExt := strings.Cut(name, ".")
+	if reservedNames[strings.ToUpper(stem)] {
+		name = stem + "_"
+		if hasExt {
+			name += "." + ext
+		}
+	}
+	return name
+}
+
+// targetName maps a source name onto its target name according to SanitizeNames.
+// It returns false when the entry must not be synced.
+func (r *runner) targetName(rel string, isDir bool) (string, bool) {
+	if r.opt.SanitizeNames == SanitizeOff {
+		return rel, true
+	}
+	// Parents were checked when they were visited, so only the last element can be new trouble.
+	reason := invalidNameReason(path.Base(rel))
+	switch {
+	case r.opt.SanitizeNames == SanitizeReplace:
+		var parts []string
+		for _, p := range strings.Split(rel, "/") {
+			parts = append(parts, sanitizeName(p))
+		}
+		dstRel := strings.Join(parts, "/")
+		if dstRel != rel {
+			if reason != "" {
+				r.opt.Logger.Printf("RENAME: %s -> %s (%s)", rel, dstRel, reason)
+			}
+			r.sanitized[dstRel] = true
+		}
+		return dstRel, true
+	case reason == "":
+		return rel, true
+	case r.opt.SanitizeNames == SanitizeSkip:
+		r.opt.Logger.Printf("SKIP: %s (invalid name on target: %s)", rel, reason)
+		if !isDir {
+			r.skip(rel, SkipInvalidName)
+		}
+		return "", false
+	default:
+		err := fmt.Errorf("invalid name on target %s: %s", rel, reason)
+		r.opt.Logger.Printf("ERR: %v", err)
+		r.rep.addErr(err)
+		return "", false
+	}
+}
@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func namesFixture() fstest.MapFS {
+	return fstest.MapFS{
+		"con.txt":    {Data: []byte("reserved")},
+		"a<b.txt":    {Data: []byte("illegal")},
+		"normal.txt": {Data: []byte("ok")},
+	}
+}
+
+func TestSanitizeNames(t *testing.T) {
+	t.Run("off", func(t *testing.T) {
+		dst := newMemFS()
+		rep := SyncFS(namesFixture(), dst, Options{})
+		if rep.Copied != 3 || len(rep.Errors) != 0 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		dst := newMemFS()
+		rep := SyncFS(namesFixture(), dst, Options{SanitizeNames: SanitizeError})
+		if rep.Copied != 1 || len(rep.Errors) != 2 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if _, ok := dst.MapFS["con.txt"]; ok {
+			t.Fatalf("con.txt must not be copied")
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		dst := newMemFS()
+		rep := SyncFS(namesFixture(), dst, Options{SanitizeNames: SanitizeSkip})
+		if rep.Copied != 1 || rep.Skipped != 2 || len(rep.Errors) != 0 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if _, ok := dst.MapFS["a<b.txt"]; ok {
+			t.Fatalf("a<b.txt must not be copied")
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		dst := newMemFS()
+		opt := Options{SanitizeNames: SanitizeReplace, DeleteMissing: true}
+		rep := SyncFS(namesFixture(), dst, opt)
+		if rep.Copied != 3 || len(rep.Errors) != 0 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		for name, want := range map[string]string{"con_.txt": "reserved", "a_b.txt": "illegal", "normal.txt": "ok"} {
+			if f := dst.MapFS[name]; f == nil || string(f.Data) != want {
+				t.Fatalf("expected %s with %q", name, want)
+			}
+		}
+
+		// Renamed files are neither re-copied nor deleted as orphans.
+		rep2 := SyncFS(namesFixture(), dst, opt)
+		if rep2.Skipped != 3 || rep2.Deleted != 0 {
+			t.Fatalf("expected stable re-sync, got %+v", *rep2)
+		}
+	})
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := map[string]string{
+		"con":         "con_",
+		"Aux.tar.gz":  "Aux_.tar.gz",
+		"what?.txt":   "what_.txt",
+		"trailing. ":  "trailing__",
+		"console.txt": "console.txt",
+	}
+	for in, want := range tests {
+		if got := sanitizeName(in); got != want {
+			t.Fatalf("sanitizeName(%q) = %q, want %q", in, got, want)
+		}
+		if reason := invalidNameReason(sanitizeName(in)); reason != "" {
+			t.Fatalf("sanitized %q still invalid: %s", in, reason)
+		}
+	}
+}
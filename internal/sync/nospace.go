@@ -0,0 +1,30 @@
+package sync
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+// defaultENOSPCRetries is used when PauseOnENOSPC is set without ENOSPCRetries.
+const defaultENOSPCRetries = 3
+
+// retryNoSpace runs write, the copy of rel, and with Options.PauseOnENOSPC runs it again after
+// a pause while it fails because the target is full, until the retries are used up.
+func (r *runner) retryNoSpace(rel string, write func() error) error {
+	err := write()
+	if r.opt.PauseOnENOSPC <= 0 {
+		return err
+	}
+	retries := r.opt.ENOSPCRetries
+	if retries <= 0 {
+		retries = defaultENOSPCRetries
+	}
+	for i := 1; i <= retries && errors.Is(err, syscall.ENOSPC); i++ {
+		r.opt.Logger.Printf("WARN: target full writing %s; retrying in %v (%d/%d)", r.srcPath(rel), r.opt.PauseOnENOSPC, i, retries)
+		time.Sleep(r.opt.PauseOnENOSPC)
+		r.beat()
+		err = write()
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// fullFS is a memFS whose first full creates fail as if the disk were full.
+type fullFS struct {
+	*memFS
+	full    int
+	creates int
+}
+
+func (f *fullFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	f.creates++
+	if f.full > 0 {
+		f.full--
+		return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.ENOSPC}
+	}
+	return f.memFS.Create(name, perm)
+}
+
+func TestPauseOnENOSPC(t *testing.T) {
+	src := fstest.MapFS{"a.txt": {Data: []byte("alpha"), ModTime: time.Now()}}
+
+	tests := []struct {
+		name        string
+		full        int
+		opt         Options
+		wantCopied  int
+		wantCreates int
+	}{
+		{name: "disabled", full: 1, wantCreates: 1},
+		{name: "space freed", full: 2, opt: Options{PauseOnENOSPC: time.Millisecond}, wantCopied: 1, wantCreates: 3},
+		{name: "still full", full: 5, opt: Options{PauseOnENOSPC: time.Millisecond, ENOSPCRetries: 2}, wantCreates: 3},
+		{name: "transactional", full: 1, opt: Options{PauseOnENOSPC: time.Millisecond, Transactional: true}, wantCopied: 1, wantCreates: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := &fullFS{memFS: newMemFS(), full: tt.full}
+			rep := SyncFS(src, dst, tt.opt)
+			if rep.Copied != tt.wantCopied || dst.creates != tt.wantCreates {
+				t.Fatalf("copied=%d creates=%d, want %d and %d: %+v", rep.Copied, dst.creates, tt.wantCopied, tt.wantCreates, *rep)
+			}
+			if tt.wantCopied == 0 {
+				if len(rep.Errors) != 1 || !errors.Is(rep.Errors[0], syscall.ENOSPC) {
+					t.Fatalf("expected an ENOSPC error, got %v", rep.Errors)
+				}
+				return
+			}
+			if len(rep.Errors) != 0 || string(dst.MapFS["a.txt"].Data) != "alpha" {
+				t.Fatalf("unexpected result: %v", rep.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package sync
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// WalkOrder selects the order in which the entries of each source directory are processed.
+type WalkOrder int
+
+const (
+	// WalkDefault keeps the order returned by the filesystem walk.
+	WalkDefault WalkOrder = iota
+	// WalkNameAsc processes entries alphabetically by name.
+	WalkNameAsc
+	// WalkSizeDesc processes the largest files first, followed by directories.
+	WalkSizeDesc
+	// WalkMTimeDesc processes the most recently modified entries first.
+	WalkMTimeDesc
+)
+
+var walkOrderNames = map[WalkOrder]string{
+	WalkDefault:   "default",
+	WalkNameAsc:   "name",
+	WalkSizeDesc:  "size",
+	WalkMTimeDesc: "mtime",
+}
+
+func (o WalkOrder) String() string {
+	if s, ok := walkOrderNames[o]; ok {
+		return s
+	}
+	return fmt.Sprintf("WalkOrder(%d)", int(o))
+}
+
+// ParseWalkOrder parses the CLI spelling of a WalkOrder ("default", "name", "size", "mtime").
+func ParseWalkOrder(s string) (WalkOrder, error) {
+	for o, name := range walkOrderNames {
+		if name == s {
+			return o, nil
+		}
+	}
+	return WalkDefault, fmt.Errorf("unknown walk order %q", s)
+}
+
+// walkDirSorted behaves like fs.WalkDir but sorts the entries of every directory per order before visiting them.
+func walkDirSorted(fsys fs.FS, root string, order WalkOrder, fn fs.WalkDirFunc) error {
+	info, err := fs.Stat(fsys, root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkSorted(fsys, root, fs.FileInfoToDirEntry(info), order, fn)
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walkSorted(fsys fs.FS, name string, d fs.DirEntry, order WalkOrder, fn fs.WalkDirFunc) error {
+	if err := fn(name, d, nil); err != nil || !d.IsDir() {
+		if err == fs.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		// Second call, to report the ReadDir error.
+		if err := fn(name, d, err); err != nil {
+			if err == fs.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+	sortEntries(entries, order)
+
+	for _, e := range entries {
+		if err := walkSorted(fsys, path.Join(name, e.Name()), e, order, fn); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// sortEntries orders directory entries in place. Entries whose info cannot be read sort last.
+func sortEntries(entries []fs.DirEntry, order WalkOrder) {
+	type keyed struct {
+		e    fs.DirEntry
+		info fs.FileInfo
+	}
+	ks := make([]keyed, len(entries))
+	for i, e := range entries {
+		info, _ := e.Info()
+		ks[i] = keyed{e: e, info: info}
+	}
+	less := func(a, b keyed) bool { return a.e.Name() < b.e.Name() }
+	switch order {
+	case WalkSizeDesc:
+		less = func(a, b keyed) bool {
+			// Files before directories, largest first; ties by name for determinism.
+			ad, bd := a.e.IsDir(), b.e.IsDir()
+			if ad != bd {
+				return !ad
+			}
+			if a.info == nil || b.info == nil {
+				return a.info != nil
+			}
+			if a.info.Size() != b.info.Size() {
+				return a.info.Size() > b.info.Size()
+			}
+			return a.e.Name() < b.e.Name()
+		}
+	case WalkMTimeDesc:
+		less = func(a, b keyed) bool {
+			if a.info == nil || b.info == nil {
+				return a.info != nil
+			}
+			if !a.info.ModTime().Equal(b.info.ModTime()) {
+				return a.info.ModTime().After(b.info.ModTime())
+			}
+			return a.e.Name() < b.e.Name()
+		}
+	}
+	sort.SliceStable(ks, func(i, j int) bool { return less(ks[i], ks[j]) })
+	for i := range ks {
+		entries[i] = ks[i].e
+	}
+}
@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"bytes"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// copiedOrder extracts the base names of COPY log lines in the order they were logged.
+func copiedOrder(logs string) []string {
+	var out []string
+	for _, line := range strings.Split(logs, "\n") {
+		if i := strings.Index(line, "COPY: "); i >= 0 {
+			src := strings.SplitN(line[i+len("COPY: "):], " -> ", 2)[0]
+			out = append(out, filepath.Base(src))
+		}
+	}
+	return out
+}
+
+func TestWalkOrder(t *testing.T) {
+	src := t.TempDir()
+	now := time.Now()
+	writeWithModTime(t, filepath.Join(src, "a.txt"), "a", 0o644, now.Add(-3*time.Hour))
+	writeWithModTime(t, filepath.Join(src, "b.txt"), strings.Repeat("b", 100), 0o644, now.Add(-2*time.Hour))
+	writeWithModTime(t, filepath.Join(src, "c.txt"), strings.Repeat("c", 10), 0o644, now.Add(-1*time.Hour))
+
+	tests := []struct {
+		order WalkOrder
+		want  []string
+	}{
+		{WalkNameAsc, []string{"a.txt", "b.txt", "c.txt"}},
+		{WalkSizeDesc, []string{"b.txt", "c.txt", "a.txt"}},
+		{WalkMTimeDesc, []string{"c.txt", "b.txt", "a.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			rep := Sync(Options{Source: src, Target: t.TempDir(), WalkOrder: tt.order, Logger: log.New(&buf, "", 0)})
+			if len(rep.Errors) != 0 {
+				t.Fatalf("unexpected errors: %v", rep.Errors)
+			}
+			if got := copiedOrder(buf.String()); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("order mismatch: got %v want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWalkOrderSizeDescFilesBeforeDirs(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a", "nested.txt"), strings.Repeat("n", 1000))
+	mustWrite(t, filepath.Join(src, "small.txt"), "s")
+	mustWrite(t, filepath.Join(src, "big.txt"), strings.Repeat("b", 50))
+
+	var buf bytes.Buffer
+	Sync(Options{Source: src, Target: t.TempDir(), WalkOrder: WalkSizeDesc, Logger: log.New(&buf, "", 0)})
+	want := []string{"big.txt", "small.txt", "nested.txt"}
+	if got := copiedOrder(buf.String()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("order mismatch: got %v want %v", got, want)
+	}
+}
+
+func TestParseWalkOrder(t *testing.T) {
+	for _, o := range []WalkOrder{WalkDefault, WalkNameAsc, WalkSizeDesc, WalkMTimeDesc} {
+		got, err := ParseWalkOrder(o.String())
+		if err != nil || got != o {
+			t.Fatalf("round trip %v: got %v, %v", o, got, err)
+		}
+	}
+	if _, err := ParseWalkOrder("random"); err == nil {
+		t.Fatalf("expected error for unknown order")
+	}
+}
@@ -0,0 +1,38 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestReportOrphans(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "keep.txt"), "keep")
+	mustWrite(t, filepath.Join(src, "new.txt"), "new")
+	mustWrite(t, filepath.Join(dst, "keep.txt"), "keep")
+	mustWrite(t, filepath.Join(dst, "stale.txt"), "stale")
+	mustWrite(t, filepath.Join(dst, "old", "gone.txt"), "gone")
+
+	rep := Sync(Options{Source: src, Target: dst, ReportOrphans: true})
+	if len(rep.Errors) != 0 || rep.Copied != 1 || rep.Deleted != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	sort.Strings(rep.Orphans)
+	if want := []string{"old/gone.txt", "stale.txt"}; !reflect.DeepEqual(rep.Orphans, want) {
+		t.Fatalf("orphans: got %q want %q", rep.Orphans, want)
+	}
+	for _, name := range []string{"stale.txt", "old/gone.txt"} {
+		if _, err := os.Stat(filepath.Join(dst, name)); err != nil {
+			t.Fatalf("orphan %s removed: %v", name, err)
+		}
+	}
+
+	// With DeleteMissing they are listed and deleted
+	rep = Sync(Options{Source: src, Target: dst, ReportOrphans: true, DeleteMissing: true})
+	if len(rep.Errors) != 0 || rep.Deleted != 2 || len(rep.Orphans) != 2 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+}
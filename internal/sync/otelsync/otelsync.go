@@ -0,0 +1,49 @@
+// Package otelsync adapts an OpenTelemetry tracer to sync.Tracer.
+//
+//	opt.Tracer = otelsync.New(otel.Tracer("sync-service"))
+package otelsync
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/e-wrobel/sync-service/internal/sync"
+)
+
+// New returns a sync.Tracer that starts its spans on t.
+func New(t trace.Tracer) sync.Tracer {
+	return tracer{t: t}
+}
+
+type tracer struct {
+	t trace.Tracer
+}
+
+func (t tracer) Start(ctx context.Context, name string) (context.Context, sync.Span) {
+	ctx, s := t.t.Start(ctx, name)
+	return ctx, span{s: s}
+}
+
+type span struct {
+	s trace.Span
+}
+
+func (s span) SetInt(key string, value int64) {
+	s.s.SetAttributes(attribute.Int64(key, value))
+}
+
+func (s span) SetString(key, value string) {
+	s.s.SetAttributes(attribute.String(key, value))
+}
+
+func (s span) RecordError(err error) {
+	s.s.RecordError(err)
+	s.s.SetStatus(codes.Error, err.Error())
+}
+
+func (s span) End() {
+	s.s.End()
+}
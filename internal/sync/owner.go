@@ -0,0 +1,95 @@
+package sync
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// IDRange maps the user or group ids Low..High onto To..To+(High-Low), like a user-namespace mapping.
+type IDRange struct {
+	Low, High, To uint32
+}
+
+// IDMap translates source uids or gids to target ones for Options.PreserveOwner.
+// The first range containing an id applies; ids outside every range are kept.
+type IDMap []IDRange
+
+// Map returns the target id for the source id.
+func (m IDMap) Map(id uint32) uint32 {
+	for _, r := range m {
+		if id >= r.Low && id <= r.High {
+			return r.To + (id - r.Low)
+		}
+	}
+	return id
+}
+
+// ParseIDMap parses a comma-separated list of "FROM:TO" and "LOW-HIGH:TO" entries,
+// e.g. "0:1000,1000-1999:100000". An empty string yields an empty map.
+func ParseIDMap(s string) (IDMap, error) {
+	var m IDMap
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid id map entry %q: want FROM:TO or LOW-HIGH:TO", entry)
+		}
+		low, high, isRange := strings.Cut(from, "-")
+		if !isRange {
+			high = low
+		}
+		var r IDRange
+		var errs [3]error
+		r.Low, errs[0] = parseID(low)
+		r.High, errs[1] = parseID(high)
+		r.To, errs[2] = parseID(to)
+		for _, err := range errs {
+			if err != nil {
+				return nil, fmt.Errorf("invalid id map entry %q: %w", entry, err)
+			}
+		}
+		if r.High < r.Low || uint64(r.To)+uint64(r.High-r.Low) > 1<<32-1 {
+			return nil, fmt.Errorf("invalid id map entry %q: range out of bounds", entry)
+		}
+		m = append(m, r)
+	}
+	return m, nil
+}
+
+func parseID(s string) (uint32, error) {
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+	return uint32(n), err
+}
+
+// copyOwner gives dstName the owner and group of the copied source file described by info,
+// translated by Options.UIDMap and Options.GIDMap, when PreserveOwner is set. Only OS-backed
+// targets on Unix are supported. Failures (typically missing privileges) are logged and counted
+// in Report.OwnerFailures but do not fail the run.
+func (r *runner) copyOwner(info fs.FileInfo, dstName string) {
+	if !r.opt.PreserveOwner {
+		return
+	}
+	dst, ok := r.dst.(dirFS)
+	if !ok {
+		return
+	}
+	uid, gid, ok := fileOwner(info)
+	if !ok {
+		return
+	}
+	dp, err := dst.path("chown", dstName)
+	if err != nil {
+		return
+	}
+	uid, gid = r.opt.UIDMap.Map(uid), r.opt.GIDMap.Map(gid)
+	if err := os.Lchown(dp, int(uid), int(gid)); err != nil {
+		r.opt.Logger.Printf("WARN: chown %s: %v", r.dstPath(dstName), err)
+		r.rep.OwnerFailures++
+	}
+}
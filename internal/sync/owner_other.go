@@ -0,0 +1,12 @@
+//go:build !unix
+
+package sync
+
+import "io/fs"
+
+const ownersSupported = false
+
+// fileOwner is not available on this platform, which makes PreserveOwner a no-op.
+func fileOwner(fs.FileInfo) (uid, gid uint32, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,31 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIDMap(t *testing.T) {
+	m, err := ParseIDMap("0:1000, 1000-1999:100000,5:5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := IDMap{{0, 0, 1000}, {1000, 1999, 100000}, {5, 5, 5}}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("got %+v want %+v", m, want)
+	}
+	for id, want := range map[uint32]uint32{0: 1000, 1000: 100000, 1500: 100500, 1999: 100999, 2000: 2000, 5: 5, 7: 7} {
+		if got := m.Map(id); got != want {
+			t.Fatalf("Map(%d) = %d, want %d", id, got, want)
+		}
+	}
+
+	if m, err := ParseIDMap(""); err != nil || len(m) != 0 {
+		t.Fatalf("empty map: %v, %v", m, err)
+	}
+	for _, bad := range []string{"1000", "a:1", "1:b", "9-1:0", "-1:0", "0-10:4294967290"} {
+		if _, err := ParseIDMap(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
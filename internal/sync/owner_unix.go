@@ -0,0 +1,20 @@
+//go:build unix
+
+package sync
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// ownersSupported reports whether file owners can be read and changed.
+const ownersSupported = true
+
+// fileOwner returns the uid and gid of the file described by info.
+func fileOwner(info fs.FileInfo) (uid, gid uint32, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return st.Uid, st.Gid, true
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestPreserveOwnerMapped(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing file owners requires root")
+	}
+	src, dst := t.TempDir(), t.TempDir()
+	p := filepath.Join(src, "a.txt")
+	mustWrite(t, p, "a")
+	if err := os.Chown(p, 1000, 1500); err != nil {
+		t.Fatal(err)
+	}
+	uidMap, _ := ParseIDMap("1000:2000")
+	gidMap, _ := ParseIDMap("1000-1999:3000")
+
+	rep := Sync(Options{Source: src, Target: dst, PreserveOwner: true, UIDMap: uidMap, GIDMap: gidMap})
+	if len(rep.Errors) != 0 || rep.Copied != 1 || rep.OwnerFailures != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	info, err := os.Lstat(filepath.Join(dst, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := info.Sys().(*syscall.Stat_t)
+	if st.Uid != 2000 || st.Gid != 3500 {
+		t.Fatalf("owner %d:%d, want 2000:3500", st.Uid, st.Gid)
+	}
+}
@@ -0,0 +1,267 @@
+package sync
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ArchiveTargetKind reports the archive format a target path asks for, by extension
+// (.tar, .tar.gz/.tgz or .zip). Sync then packs the selected source files into that archive
+// instead of mirroring them into a directory.
+func ArchiveTargetKind(p string) ArchiveKind {
+	return archiveKindByExt(p)
+}
+
+// checkArchiveTarget rejects options that need an existing target tree.
+func checkArchiveTarget(opt Options) error {
+	var unsupported []string
+	if opt.DeleteMissing {
+		unsupported = append(unsupported, "DeleteMissing")
+	}
+	if opt.Transactional {
+		unsupported = append(unsupported, "Transactional")
+	}
+	if opt.SubtreeCheck {
+		unsupported = append(unsupported, "SubtreeCheck")
+	}
+	if opt.TrashDir != "" {
+		unsupported = append(unsupported, "TrashDir")
+	}
+	if opt.CompletionMarker != "" {
+		unsupported = append(unsupported, "CompletionMarker")
+	}
+	if opt.Manifest != "" {
+		unsupported = append(unsupported, "Manifest")
+	}
+	if opt.ContentValidator != nil {
+		unsupported = append(unsupported, "ContentValidator")
+	}
+	if opt.LineEndings != LineEndingsPreserve {
+		unsupported = append(unsupported, "LineEndings")
+	}
+	if len(opt.Transforms) > 0 {
+		unsupported = append(unsupported, "Transforms")
+	}
+	if opt.StoreChecksumXattr {
+		unsupported = append(unsupported, "StoreChecksumXattr")
+	}
+	if opt.RepairPermsOnly {
+		unsupported = append(unsupported, "RepairPermsOnly")
+	}
+	if opt.EncryptPassphrase != "" {
+		unsupported = append(unsupported, "EncryptPassphrase")
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("%v not supported with archive target %s", unsupported, opt.Target)
+	}
+	return nil
+}
+
+// packer streams entries into an archive written under a temp name,
+// which is renamed into place once the archive is complete.
+type packer struct {
+	path string
+	// abs and absTemp are the absolute archive and temp paths, which are never packed.
+	abs, absTemp string
+	f            *os.File
+	gz           *gzip.Writer
+	tw           *tar.Writer
+	zw           *zip.Writer
+	dirs         map[string]bool
+}
+
+func newPacker(p string, kind ArchiveKind) (*packer, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return nil, err
+	}
+	absTemp := tempName(abs)
+	f, err := os.OpenFile(absTemp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	pk := &packer{path: p, abs: abs, absTemp: absTemp, f: f, dirs: map[string]bool{}}
+	switch kind {
+	case ArchiveZip:
+		pk.zw = zip.NewWriter(f)
+	case ArchiveTarGz:
+		pk.gz = gzip.NewWriter(f)
+		pk.tw = tar.NewWriter(pk.gz)
+	default:
+		pk.tw = tar.NewWriter(f)
+	}
+	return pk, nil
+}
+
+// writes reports whether the file at p is the archive or its temp file, which would be
+// packed into itself when the target lies inside the source.
+func (pk *packer) writes(p string) bool {
+	abs, err := filepath.Abs(p)
+	return err == nil && (abs == pk.abs || abs == pk.absTemp)
+}
+
+// dir adds a directory entry, once per name.
+func (pk *packer) dir(name string, info fs.FileInfo) error {
+	if pk.dirs[name] {
+		return nil
+	}
+	pk.dirs[name] = true
+	if pk.zw != nil {
+		fh, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		fh.Name = name + "/"
+		_, err = pk.zw.CreateHeader(fh)
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name + "/"
+	return pk.tw.WriteHeader(hdr)
+}
+
+// file streams the source file rel into the archive as name, keeping its mode and mod-time.
+func (pk *packer) file(src fs.FS, rel, name string, info fs.FileInfo) error {
+	in, err := src.Open(rel)
+	if err != nil {
+		return fmt.Errorf("open src: %w", err)
+	}
+	defer in.Close()
+
+	if pk.zw != nil {
+		fh, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		fh.Name = name
+		fh.Method = zip.Deflate
+		w, err := pk.zw.CreateHeader(fh)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, in)
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := pk.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	// The header fixes the size; a file changing under us must not corrupt the stream
+	n, err := io.Copy(pk.tw, io.LimitReader(in, info.Size()))
+	if n != info.Size() {
+		// Pad the entry so the rest of the archive stays readable
+		if _, perr := io.CopyN(pk.tw, zeros{}, info.Size()-n); perr != nil {
+			return errors.Join(err, perr)
+		}
+		if err == nil {
+			err = fmt.Errorf("file shrank while packing: %d of %d bytes", n, info.Size())
+		}
+	}
+	return err
+}
+
+// close finishes the archive and renames it into place.
+func (pk *packer) close() error {
+	var err error
+	if pk.zw != nil {
+		err = pk.zw.Close()
+	} else {
+		err = pk.tw.Close()
+		if pk.gz != nil {
+			err = errors.Join(err, pk.gz.Close())
+		}
+	}
+	err = errors.Join(err, pk.f.Close())
+	if err == nil {
+		err = os.Rename(pk.f.Name(), pk.path)
+	}
+	if err != nil {
+		_ = os.Remove(pk.f.Name())
+	}
+	return err
+}
+
+// abort closes and removes the temp file without touching the archive.
+func (pk *packer) abort() {
+	_ = pk.f.Close()
+	_ = os.Remove(pk.f.Name())
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// initPack prepares packing into the archive named by Target; startPack creates it.
+// The archive starts out empty, so every selected file is added without comparing.
+func (r *runner) initPack(kind ArchiveKind) {
+	if err := checkArchiveTarget(r.opt); err != nil {
+		r.fatal = err
+		return
+	}
+	r.emptyTarget = true
+	// There is no target tree to clean or inspect
+	r.opt.CleanStaleTemps = false
+	r.opt.TargetKnownEmpty = false
+	r.packKind = kind
+}
+
+// startPack creates the temp file of the archive once the run goes ahead, reporting whether
+// it could.
+func (r *runner) startPack() bool {
+	pk, err := newPacker(r.opt.Target, r.packKind)
+	if err != nil {
+		err = fmt.Errorf("create archive %s: %w", r.opt.Target, err)
+		r.opt.Logger.Printf("ERR: %v", err)
+		r.rep.addErr(err)
+		return false
+	}
+	r.pack = pk
+	return true
+}
+
+// packDir adds a source directory to the archive so that empty directories survive.
+func (r *runner) packDir(dstRel string, d fs.DirEntry) {
+	info, err := d.Info()
+	if err == nil {
+		err = r.pack.dir(dstRel, info)
+	}
+	if err != nil {
+		r.opt.Logger.Printf("ERR: pack dir %s: %v", dstRel, err)
+		r.rep.addErr(err)
+	}
+}
+
+// closePack finishes the archive. An aborted or cancelled run leaves any earlier archive in
+// place and drops the partial one.
+func (r *runner) closePack() {
+	if r.aborted || r.stopped() {
+		r.opt.Logger.Printf("ABORT: run stopped; not writing archive %s", r.opt.Target)
+		r.pack.abort()
+		r.pack = nil
+		return
+	}
+	if err := r.pack.close(); err != nil {
+		r.opt.Logger.Printf("ERR: write archive %s: %v", r.opt.Target, err)
+		r.rep.addErr(err)
+	}
+	r.pack = nil
+}
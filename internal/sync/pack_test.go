@@ -0,0 +1,252 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// archiveFiles reads every regular file of an archive into a name -> content map.
+func archiveFiles(t *testing.T, p string) map[string]string {
+	t.Helper()
+	fsys, c, err := OpenArchive(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	out := map[string]string{}
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(fsys, name)
+		out[name] = string(b)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestSyncToArchive(t *testing.T) {
+	src := t.TempDir()
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	mustWrite(t, filepath.Join(src, "a.txt"), "alpha")
+	mustWrite(t, filepath.Join(src, "dir", "b.txt"), "beta")
+	writeWithModTime(t, filepath.Join(src, "dir", "b.txt"), "beta", 0o644, mtime)
+	mustWrite(t, filepath.Join(src, ".env"), "secret")
+	mustWrite(t, filepath.Join(src, ".git", "HEAD"), "ref")
+	if err := os.MkdirAll(filepath.Join(src, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"a.txt": "alpha", "dir/b.txt": "beta"}
+	for _, name := range []string{"out.tar", "out.tar.gz", "out.zip"} {
+		t.Run(name, func(t *testing.T) {
+			target := filepath.Join(t.TempDir(), name)
+			rep := Sync(Options{Source: src, Target: target, SkipHidden: true})
+			if len(rep.Errors) != 0 {
+				t.Fatalf("unexpected errors: %v", rep.Errors)
+			}
+			if rep.Copied != 2 {
+				t.Fatalf("unexpected rep: %+v", *rep)
+			}
+			if got := archiveFiles(t, target); !reflect.DeepEqual(got, want) {
+				t.Fatalf("archive entries: got %v want %v", got, want)
+			}
+			fsys, c, err := OpenArchive(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			info, err := fs.Stat(fsys, "dir/b.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !info.ModTime().Equal(mtime) {
+				t.Fatalf("mtime mismatch: got %v want %v", info.ModTime(), mtime)
+			}
+			if info, err := fs.Stat(fsys, "empty"); err != nil || !info.IsDir() {
+				t.Fatalf("expected empty directory entry, got %v, %v", info, err)
+			}
+
+			matches, _ := filepath.Glob(target + "*")
+			sort.Strings(matches)
+			if !reflect.DeepEqual(matches, []string{target}) {
+				t.Fatalf("unexpected files next to the archive: %v", matches)
+			}
+		})
+	}
+}
+
+func TestSyncToArchiveRejectsDeleteMissing(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "alpha")
+	target := filepath.Join(t.TempDir(), "out.zip")
+
+	rep := Sync(Options{Source: src, Target: target, DeleteMissing: true})
+	if len(rep.Errors) != 1 {
+		t.Fatalf("expected one error, got %v", rep.Errors)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("expected no archive, got %v", err)
+	}
+}
+
+func TestSyncToArchiveDryRun(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "alpha")
+	target := filepath.Join(t.TempDir(), "out.tar.gz")
+
+	rep := Sync(Options{Source: src, Target: target, DryRun: true})
+	if len(rep.Errors) != 0 || rep.Copied != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("dry run must not create the archive, got %v", err)
+	}
+}
+
+func TestSyncToArchiveInsideSource(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "alpha")
+	target := filepath.Join(src, "out.tar")
+
+	// Neither the archive being written nor an earlier one is packed into itself
+	for i := 0; i < 2; i++ {
+		rep := Sync(Options{Source: src, Target: target})
+		if len(rep.Errors) != 0 || rep.Copied != 1 {
+			t.Fatalf("run %d: unexpected rep: %+v", i, *rep)
+		}
+		if got, want := archiveFiles(t, target), map[string]string{"a.txt": "alpha"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: archive entries: got %v want %v", i, got, want)
+		}
+	}
+}
+
+func TestSyncToArchiveEarlyFailure(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "alpha")
+	dir := t.TempDir()
+
+	// A run stopped before the walk leaves no temp file behind
+	rep := Sync(Options{Source: src, Target: filepath.Join(dir, "out.zip"), CSVReport: filepath.Join(dir, "missing", "report.csv")})
+	if len(rep.Errors) != 1 {
+		t.Fatalf("expected one error, got %v", rep.Errors)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("target dir holds %v (%v)", entries, err)
+	}
+}
+
+// cancelOnWrite cancels a run on the first log line containing match.
+type cancelOnWrite struct {
+	match  string
+	cancel context.CancelFunc
+}
+
+func (w cancelOnWrite) Write(p []byte) (int, error) {
+	if strings.Contains(string(p), w.match) {
+		w.cancel()
+	}
+	return len(p), nil
+}
+
+func TestSyncToArchiveCancelled(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "alpha")
+	mustWrite(t, filepath.Join(src, "b.txt"), "beta")
+	dir := t.TempDir()
+	target := filepath.Join(dir, "out.tar")
+	if rep := Sync(Options{Source: src, Target: target}); len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+	mustWrite(t, filepath.Join(src, "c.txt"), "gamma")
+
+	// A cancelled run keeps the earlier archive and leaves no temp file behind
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := log.New(cancelOnWrite{match: "COPY", cancel: cancel}, "", 0)
+	rep, err := NewService().Sync(ctx, Options{Source: src, Target: target, Logger: logger})
+	if !errors.Is(err, context.Canceled) || rep.Copied != 1 {
+		t.Fatalf("unexpected result: %+v, %v", rep, err)
+	}
+	if got, want := archiveFiles(t, target), map[string]string{"a.txt": "alpha", "b.txt": "beta"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("archive entries: got %v want %v", got, want)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Fatalf("target dir holds %v (%v)", entries, err)
+	}
+}
+
+// failReadFS fails reads of the file name after its first two bytes.
+type failReadFS struct {
+	fstest.MapFS
+	name string
+}
+
+func (f failReadFS) Open(name string) (fs.File, error) {
+	file, err := f.MapFS.Open(name)
+	if err != nil || name != f.name {
+		return file, err
+	}
+	return &failReadFile{File: file}, nil
+}
+
+type failReadFile struct {
+	fs.File
+	read bool
+}
+
+func (f *failReadFile) Read(p []byte) (int, error) {
+	if f.read {
+		return 0, errors.New("read failed")
+	}
+	f.read = true
+	if len(p) > 2 {
+		p = p[:2]
+	}
+	return f.File.Read(p)
+}
+
+func TestPackReadErrorPadsEntry(t *testing.T) {
+	src := failReadFS{MapFS: fstest.MapFS{
+		"bad.txt":  {Data: []byte("broken file")},
+		"good.txt": {Data: []byte("good")},
+	}, name: "bad.txt"}
+	target := filepath.Join(t.TempDir(), "out.tar")
+	pk, err := newPacker(target, ArchiveTar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"bad.txt", "good.txt"} {
+		info, err := fs.Stat(src, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = pk.file(src, name, name, info)
+		if (name == "bad.txt") != (err != nil) {
+			t.Fatalf("pack %s: %v", name, err)
+		}
+	}
+	if err := pk.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The failed entry keeps its size, so the entry after it stays readable
+	got := archiveFiles(t, target)
+	if got["good.txt"] != "good" || got["bad.txt"] != "br"+string(bytes.Repeat([]byte{0}, 9)) {
+		t.Fatalf("archive entries: %q", got)
+	}
+}
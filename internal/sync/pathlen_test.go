@@ -0,0 +1,39 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMaxPathLen(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	short := filepath.Join("a", "short.txt")
+	long := filepath.Join(strings.Repeat("d", 40), strings.Repeat("e", 40), "long.txt")
+	mustWrite(t, filepath.Join(src, short), "s")
+	mustWrite(t, filepath.Join(src, long), "l")
+
+	// The limit sits between the two target paths.
+	limit := len(filepath.Join(dst, short)) + 10
+	if len(filepath.Join(dst, long)) <= limit {
+		t.Fatalf("test paths do not straddle the limit")
+	}
+
+	rep := Sync(Options{Source: src, Target: dst, MaxPathLen: limit})
+	if len(rep.Errors) != 0 || rep.Copied != 1 || rep.SkippedTooLong != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if _, err := os.Stat(filepath.Join(dst, short)); err != nil {
+		t.Fatalf("short path not copied: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, strings.Repeat("d", 40))); !os.IsNotExist(err) {
+		t.Fatalf("no directory should be created for the skipped file, got %v", err)
+	}
+
+	// At exactly the path length the file fits.
+	rep = Sync(Options{Source: src, Target: dst, MaxPathLen: len(filepath.Join(dst, long))})
+	if rep.Copied != 1 || rep.SkippedTooLong != 0 {
+		t.Fatalf("unexpected rep at the exact limit: %+v", *rep)
+	}
+}
@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// ChmodFS is implemented by targets whose permission bits can be changed,
+// which Options.RepairPermsOnly needs. DirFS implements it.
+type ChmodFS interface {
+	WritableFS
+	// Chmod sets the permission bits of name.
+	Chmod(name string, mode fs.FileMode) error
+}
+
+func (d dirFS) Chmod(name string, mode fs.FileMode) error {
+	p, err := d.path("chmod", name)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(p, mode)
+}
+
+// repairPerms handles a source file in a RepairPermsOnly run: when the target file exists and
+// its permission bits differ from the source's, they are set to the source's.
+func (r *runner) repairPerms(rel, dstRel string, d fs.DirEntry) {
+	opt, rep := r.opt, r.rep
+	info, err := d.Info()
+	if err != nil {
+		opt.Logger.Printf("ERR: info %s: %v", r.srcPath(rel), err)
+		rep.addErr(err)
+		return
+	}
+	if !info.Mode().IsRegular() {
+		opt.Logger.Printf("SKIP: not regular file %s (mode=%v)", r.srcPath(rel), info.Mode())
+		r.skip(dstRel, SkipNotRegular)
+		return
+	}
+	tst, err := r.dst.Stat(dstRel)
+	if errors.Is(err, fs.ErrNotExist) {
+		opt.Logger.Printf("SKIP: %s (missing in target)", rel)
+		r.skip(dstRel, SkipMissingTarget)
+		return
+	}
+	if err != nil {
+		opt.Logger.Printf("ERR: stat %s: %v", r.dstPath(dstRel), err)
+		rep.addErr(err)
+		return
+	}
+	want, got := info.Mode().Perm(), tst.Mode().Perm()
+	if want == got {
+		opt.Logger.Printf("SKIP: %s (identical)", rel)
+		r.skip(dstRel, SkipIdentical)
+		return
+	}
+	if !opt.DryRun {
+		if err := r.dst.(ChmodFS).Chmod(dstRel, want); err != nil {
+			opt.Logger.Printf("ERR: chmod %s: %v", r.dstPath(dstRel), err)
+			rep.addErr(err)
+			return
+		}
+	}
+	opt.Logger.Printf("CHMOD: %s (%v -> %v)", r.dstPath(dstRel), got, want)
+	rep.PermsFixed++
+}
@@ -0,0 +1,78 @@
+//go:build unix
+
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRepairPermsOnly(t *testing.T) {
+	setup := func(t *testing.T) (src, dst string) {
+		src, dst = t.TempDir(), t.TempDir()
+		files := []struct {
+			name, srcData, dstData string
+			srcMode, dstMode       os.FileMode
+		}{
+			{"same.txt", "new", "old", 0o644, 0o644},
+			{"sub/drift.txt", "new", "old", 0o600, 0o644},
+			{"tool.sh", "new", "old", 0o755, 0o700},
+		}
+		for _, f := range files {
+			for _, side := range []struct {
+				root, data string
+				mode       os.FileMode
+			}{{src, f.srcData, f.srcMode}, {dst, f.dstData, f.dstMode}} {
+				p := filepath.Join(side.root, f.name)
+				mustWrite(t, p, side.data)
+				if err := os.Chmod(p, side.mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		mustWrite(t, filepath.Join(src, "src-only.txt"), "s")
+		mustWrite(t, filepath.Join(dst, "dst-only.txt"), "d")
+		return src, dst
+	}
+	perms := func(t *testing.T, dir string) map[string]os.FileMode {
+		out := map[string]os.FileMode{}
+		for _, name := range []string{"same.txt", "sub/drift.txt", "tool.sh"} {
+			info, err := os.Stat(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out[name] = info.Mode().Perm()
+		}
+		return out
+	}
+	wantTree := map[string]string{"same.txt": "old", "sub": "/", "sub/drift.txt": "old", "tool.sh": "old", "dst-only.txt": "d"}
+
+	t.Run("repair", func(t *testing.T) {
+		src, dst := setup(t)
+		rep := Sync(Options{Source: src, Target: dst, RepairPermsOnly: true, DeleteMissing: true})
+		if len(rep.Errors) != 0 || rep.PermsFixed != 2 || rep.Copied+rep.Overwritten+rep.Deleted != 0 || rep.Skipped != 2 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if got, want := perms(t, dst), perms(t, src); !reflect.DeepEqual(got, want) {
+			t.Fatalf("perms %v, want %v", got, want)
+		}
+		// Content is untouched, nothing is created or deleted
+		if got := treeContents(t, dst); !reflect.DeepEqual(got, wantTree) {
+			t.Fatalf("target changed: %v", got)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		src, dst := setup(t)
+		before := perms(t, dst)
+		rep := Sync(Options{Source: src, Target: dst, RepairPermsOnly: true, DryRun: true})
+		if len(rep.Errors) != 0 || rep.PermsFixed != 2 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if got := perms(t, dst); !reflect.DeepEqual(got, before) {
+			t.Fatalf("dry run changed perms: %v", got)
+		}
+	})
+}
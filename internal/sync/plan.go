@@ -0,0 +1,163 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+)
+
+// ActionType is the kind of an Action.
+type ActionType int
+
+const (
+	// ActionCopy copies a source file that is missing in the target.
+	ActionCopy ActionType = iota
+	// ActionOverwrite replaces a target file that differs from its source.
+	ActionOverwrite
+	// ActionDelete removes a target file missing in every source (DeleteMissing).
+	ActionDelete
+	// ActionSkip leaves a target file that is already in sync.
+	ActionSkip
+	// ActionMkdir creates a target directory.
+	ActionMkdir
+)
+
+var actionTypeNames = map[ActionType]string{
+	ActionCopy:      "copy",
+	ActionOverwrite: "overwrite",
+	ActionDelete:    "delete",
+	ActionSkip:      "skip",
+	ActionMkdir:     "mkdir",
+}
+
+func (t ActionType) String() string {
+	if s, ok := actionTypeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("ActionType(%d)", int(t))
+}
+
+// Action is one step of a sync as returned by Plan.
+type Action struct {
+	Type ActionType
+	// Rel is the slash-separated source name; for ActionDelete and ActionMkdir it is the target name.
+	Rel string
+	// SrcInfo describes the source file (nil for ActionDelete and ActionMkdir).
+	SrcInfo fs.FileInfo
+
+	// dstRel is the target name when it differs from Rel (SanitizeReplace).
+	dstRel string
+	// source indexes Options.Sources.
+	source int
+}
+
+// target returns the target name the action applies to.
+func (a Action) target() string {
+	if a.dstRel != "" {
+		return a.dstRel
+	}
+	return a.Rel
+}
+
+// Plan walks source and target like Sync and returns, in order, the actions Sync would perform,
+// without performing any of them or logging anything. Files skipped for other reasons than being
+// in sync (hidden, excluded, locked, ...) are left out. The returned error joins the errors hit
+// during the walk; the plan is then incomplete. Options.Targets is not supported.
+func Plan(opt Options) ([]Action, error) {
+	if len(opt.Targets) > 0 {
+		return nil, errors.New("plan: Targets not supported; plan each target separately")
+	}
+	opt.DryRun = true
+	opt.Syslog = false
+	opt.Logger = log.New(io.Discard, "", 0)
+	opt.Tracer = nil
+	opt.CSVReport = ""
+	opt.HeartbeatFile = ""
+	opt.TextfileMetrics = ""
+
+	r := newDirRunner(opt)
+	r.plan = []Action{}
+	rep := r.run()
+	return r.plan, errors.Join(rep.Errors...)
+}
+
+// Apply performs actions, typically a filtered or reordered result of Plan, with the same
+// options. Transactional, TrashDir, CompletionMarker and the other run-level options apply
+// as in Sync; source files are not compared again, but a warning is logged for every source
+// that changed since it was planned. Options.Targets is not supported.
+func Apply(actions []Action, opt Options) *Report {
+	if len(opt.Targets) > 0 {
+		err := errors.New("apply: Targets not supported; apply each target separately")
+		return &Report{Errors: []error{err}}
+	}
+	if actions == nil {
+		// A nil list would make the run walk as usual
+		actions = []Action{}
+	}
+	r := newDirRunner(opt)
+	r.actions = actions
+	return r.run()
+}
+
+// addAction records an action while planning.
+func (r *runner) addAction(t ActionType, rel, dstRel string, info fs.FileInfo) {
+	a := Action{Type: t, Rel: rel, SrcInfo: info, source: r.srcIdx}
+	if dstRel != rel {
+		a.dstRel = dstRel
+	}
+	r.plan = append(r.plan, a)
+}
+
+// applyActions replaces the copy and delete passes when running Apply.
+func (r *runner) applyActions() {
+	for _, a := range r.actions {
+		r.beat()
+		if r.overBudget() || r.stopped() {
+			break
+		}
+		if a.source < 0 || a.source >= len(r.sources) {
+			err := fmt.Errorf("apply %s %s: no source %d", a.Type, a.Rel, a.source)
+			r.opt.Logger.Printf("ERR: %v", err)
+			r.rep.addErr(err)
+			continue
+		}
+		src := r.sources[a.source]
+		r.src, r.srcRoot, r.srcIdx = src.fsys, src.root, a.source
+		switch a.Type {
+		case ActionCopy, ActionOverwrite:
+			info, err := r.checkPlanned(a)
+			if err != nil {
+				r.opt.Logger.Printf("ERR: %v", err)
+				r.rep.addErr(err)
+				continue
+			}
+			r.copyEntry(a.Rel, a.target(), info, a.Type == ActionOverwrite)
+		case ActionDelete:
+			if r.statSources(a.target()) == nil {
+				r.opt.Logger.Printf("WARN: %s reappeared in source since it was planned", r.dstPath(a.target()))
+			}
+			r.removeEntry(a.target())
+		case ActionSkip:
+			info, err := r.plannedInfo(a)
+			if err != nil {
+				r.opt.Logger.Printf("ERR: %v", err)
+				r.rep.addErr(err)
+				continue
+			}
+			r.opt.Logger.Printf("SKIP: %s (identical)", a.Rel)
+			r.skip(a.target(), SkipIdentical)
+			r.markSynced(a.Rel, a.target(), info)
+		case ActionMkdir:
+			r.mkdir(a.target())
+		default:
+			err := fmt.Errorf("apply %s: unknown action %v", a.Rel, a.Type)
+			r.opt.Logger.Printf("ERR: %v", err)
+			r.rep.addErr(err)
+		}
+	}
+	if r.ra != nil {
+		r.flushCopies()
+	}
+}
@@ -0,0 +1,261 @@
+package sync
+
+import (
+	"bytes"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// treeContents maps every entry below dir to its content ("/" for directories).
+func treeContents(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	out := map[string]string{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if d.IsDir() {
+			out[filepath.ToSlash(rel)] = "/"
+			return nil
+		}
+		b, err := os.ReadFile(p)
+		out[filepath.ToSlash(rel)] = string(b)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// planFixture returns a source and two identical targets needing every kind of action.
+func planFixture(t *testing.T) (src, a, b string) {
+	src = t.TempDir()
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	mustWrite(t, filepath.Join(src, "new.txt"), "new")
+	mustWrite(t, filepath.Join(src, "dir", "sub", "deep.txt"), "deep")
+	writeWithModTime(t, filepath.Join(src, "same.txt"), "same", 0o644, old)
+	mustWrite(t, filepath.Join(src, "changed.txt"), "changed")
+	if err := os.MkdirAll(filepath.Join(src, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	a, b = t.TempDir(), t.TempDir()
+	for _, dst := range []string{a, b} {
+		writeWithModTime(t, filepath.Join(dst, "same.txt"), "same", 0o644, old)
+		writeWithModTime(t, filepath.Join(dst, "changed.txt"), "old", 0o644, old)
+		mustWrite(t, filepath.Join(dst, "orphan.txt"), "orphan")
+	}
+	return src, a, b
+}
+
+func TestPlanThenApplyMatchesSync(t *testing.T) {
+	src, a, b := planFixture(t)
+
+	planned := Options{Source: src, Target: a, DeleteMissing: true, CompletionMarker: ".done"}
+	actions, err := Plan(planned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]ActionType{}
+	for _, act := range actions {
+		got[act.Rel] = act.Type
+	}
+	want := map[string]ActionType{
+		"new.txt": ActionCopy, "dir": ActionMkdir, "dir/sub": ActionMkdir, "dir/sub/deep.txt": ActionCopy,
+		"same.txt": ActionSkip, "changed.txt": ActionOverwrite, "empty": ActionMkdir, "orphan.txt": ActionDelete,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("plan: got %v want %v", got, want)
+	}
+	if tree := treeContents(t, a); len(tree) != 3 {
+		t.Fatalf("Plan modified the target: %v", tree)
+	}
+
+	applied := Apply(actions, planned)
+	direct := Sync(Options{Source: src, Target: b, DeleteMissing: true, CompletionMarker: ".done"})
+	if len(direct.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", direct.Errors)
+	}
+	if !applied.Equal(direct) {
+		t.Fatalf("Apply and Sync reports differ:\n%s", applied.Diff(direct))
+	}
+
+	// The markers carry a timestamp; compare everything else
+	ta, tb := treeContents(t, a), treeContents(t, b)
+	delete(ta, ".done")
+	delete(tb, ".done")
+	if !reflect.DeepEqual(ta, tb) {
+		t.Fatalf("targets differ:\napply: %v\nsync:  %v", ta, tb)
+	}
+}
+
+func TestApplyFilteredPlan(t *testing.T) {
+	src, a, _ := planFixture(t)
+	opt := Options{Source: src, Target: a, DeleteMissing: true}
+	actions, err := Plan(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keep []Action
+	for _, act := range actions {
+		if act.Type != ActionDelete {
+			keep = append(keep, act)
+		}
+	}
+
+	rep := Apply(keep, opt)
+	if rep.Deleted != 0 || rep.Copied != 2 || rep.Overwritten != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if _, err := os.Stat(filepath.Join(a, "orphan.txt")); err != nil {
+		t.Fatalf("filtered delete was applied: %v", err)
+	}
+
+	if rep := Apply(nil, opt); rep.Copied+rep.Overwritten+rep.Deleted != 0 {
+		t.Fatalf("empty plan must do nothing: %+v", *rep)
+	}
+}
+
+func TestApplyHandBuiltActions(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+	mustWrite(t, filepath.Join(src, "b.txt"), "b")
+	mustWrite(t, filepath.Join(dst, "b.txt"), "b")
+
+	// Actions built without SrcInfo: the source is stat'ed, a missing one is an error
+	actions := []Action{
+		{Type: ActionCopy, Rel: "a.txt"},
+		{Type: ActionSkip, Rel: "b.txt"},
+		{Type: ActionCopy, Rel: "missing.txt"},
+		{Type: ActionSkip, Rel: "missing.txt"},
+	}
+	rep := Apply(actions, Options{Source: src, Target: dst, CompletionMarker: ".synced"})
+	if rep.Copied != 1 || rep.Skipped != 1 || len(rep.Errors) != 2 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if b, err := os.ReadFile(filepath.Join(dst, "a.txt")); err != nil || string(b) != "a" {
+		t.Fatalf("a.txt: %q, %v", b, err)
+	}
+}
+
+func TestPlanFileRoundTrip(t *testing.T) {
+	src, a, b := planFixture(t)
+	opt := Options{Source: src, Target: a, DeleteMissing: true}
+	actions, err := Plan(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WritePlan(&buf, actions); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadPlan(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(actions) {
+		t.Fatalf("read %d actions, wrote %d", len(read), len(actions))
+	}
+
+	var logs bytes.Buffer
+	opt.Logger = log.New(&logs, "", 0)
+	applied := Apply(read, opt)
+	direct := Sync(Options{Source: src, Target: b, DeleteMissing: true})
+	if !applied.Equal(direct) {
+		t.Fatalf("Apply and Sync reports differ:\n%s", applied.Diff(direct))
+	}
+	if ta, tb := treeContents(t, a), treeContents(t, b); !reflect.DeepEqual(ta, tb) {
+		t.Fatalf("targets differ:\napply: %v\nsync:  %v", ta, tb)
+	}
+	if strings.Contains(logs.String(), "WARN") {
+		t.Fatalf("unexpected warnings:\n%s", logs.String())
+	}
+}
+
+func TestPlanFileZeroModTime(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	writeWithModTime(t, filepath.Join(src, "epoch.txt"), "epoch", 0o644, time.Unix(0, 0))
+	opt := Options{Source: src, Target: dst}
+	actions, err := Plan(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WritePlan(&buf, actions); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadPlan(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 1 || read[0].SrcInfo == nil {
+		t.Fatalf("source info of a 1970 file was lost: %+v", read)
+	}
+	if got := read[0].SrcInfo.ModTime(); !got.Equal(time.Unix(0, 0)) || read[0].SrcInfo.Size() != 5 {
+		t.Fatalf("read back mtime %v size %d", got, read[0].SrcInfo.Size())
+	}
+
+	var logs bytes.Buffer
+	opt.Logger = log.New(&logs, "", 0)
+	if rep := Apply(read, opt); rep.Copied != 1 || len(rep.Errors) != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if strings.Contains(logs.String(), "WARN") {
+		t.Fatalf("unexpected warnings:\n%s", logs.String())
+	}
+}
+
+func TestApplyPlanFileDrift(t *testing.T) {
+	src, a, _ := planFixture(t)
+	opt := Options{Source: src, Target: a, DeleteMissing: true}
+	actions, err := Plan(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WritePlan(&buf, actions); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadPlan(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeWithModTime(t, filepath.Join(src, "new.txt"), "newer", 0o644, time.Now().Add(time.Minute))
+	mustWrite(t, filepath.Join(src, "orphan.txt"), "back")
+	var logs bytes.Buffer
+	opt.Logger = log.New(&logs, "", 0)
+	rep := Apply(read, opt)
+	if len(rep.Errors) != 0 || rep.Copied != 2 || rep.Deleted != 1 {
+		t.Fatalf("the plan must be applied as written: %+v", *rep)
+	}
+	for _, want := range []string{"new.txt changed since it was planned", "orphan.txt reappeared in source"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Fatalf("missing warning %q:\n%s", want, logs.String())
+		}
+	}
+	if got := treeContents(t, a)["new.txt"]; got != "newer" {
+		t.Fatalf("new.txt holds %q", got)
+	}
+}
+
+func TestReadPlanErrors(t *testing.T) {
+	for _, in := range []string{
+		`not json`,
+		`{"version": 2, "actions": []}`,
+		`{"version": 1, "actions": [{"type": "explode", "rel": "a"}]}`,
+		`{"version": 1, "actions": [{"type": "delete", "rel": "../a"}]}`,
+	} {
+		if _, err := ReadPlan(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadPlan(%s) succeeded", in)
+		}
+	}
+}
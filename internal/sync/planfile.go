@@ -0,0 +1,138 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// planFileVersion is the version of the plan file format written by WritePlan.
+const planFileVersion = 1
+
+// planFile is the JSON form of a plan. Source file infos are kept as size, mode and
+// mod-time (nanoseconds), enough to copy the file and to tell whether it changed since.
+type planFile struct {
+	Version int          `json:"version"`
+	Actions []planAction `json:"actions"`
+}
+
+type planAction struct {
+	Type   string `json:"type"`
+	Rel    string `json:"rel"`
+	Target string `json:"target,omitempty"`
+	Source int    `json:"source,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	Mode   uint32 `json:"mode,omitempty"`
+	// ModTime is set only for actions with a source file, so it also marks them; it is a
+	// pointer so that a zero mtime (1970) still does
+	ModTime *int64 `json:"mtime,omitempty"`
+}
+
+// WritePlan writes actions, as returned by Plan, to w as JSON, for ReadPlan and Apply in a
+// later invocation, e.g. after the plan was reviewed.
+func WritePlan(w io.Writer, actions []Action) error {
+	pf := planFile{Version: planFileVersion, Actions: make([]planAction, 0, len(actions))}
+	for _, a := range actions {
+		pa := planAction{Type: a.Type.String(), Rel: a.Rel, Target: a.dstRel, Source: a.source}
+		if a.SrcInfo != nil {
+			pa.Size = a.SrcInfo.Size()
+			pa.Mode = uint32(a.SrcInfo.Mode())
+			mtime := a.SrcInfo.ModTime().UnixNano()
+			pa.ModTime = &mtime
+		}
+		pf.Actions = append(pf.Actions, pa)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(pf)
+}
+
+// ReadPlan reads a plan written by WritePlan. The source infos of the returned actions
+// describe the source files as they were when planned.
+func ReadPlan(r io.Reader) ([]Action, error) {
+	var pf planFile
+	if err := json.NewDecoder(r).Decode(&pf); err != nil {
+		return nil, fmt.Errorf("read plan: %w", err)
+	}
+	if pf.Version != planFileVersion {
+		return nil, fmt.Errorf("read plan: unsupported version %d", pf.Version)
+	}
+	actions := make([]Action, 0, len(pf.Actions))
+	for i, pa := range pf.Actions {
+		t, err := parseActionType(pa.Type)
+		if err != nil {
+			return nil, fmt.Errorf("read plan: action %d: %w", i, err)
+		}
+		if !fs.ValidPath(pa.Rel) || pa.Target != "" && !fs.ValidPath(pa.Target) {
+			return nil, fmt.Errorf("read plan: action %d: invalid path %q", i, pa.Rel)
+		}
+		a := Action{Type: t, Rel: pa.Rel, dstRel: pa.Target, source: pa.Source}
+		if pa.ModTime != nil {
+			a.SrcInfo = plannedInfo{
+				name:    path.Base(pa.Rel),
+				size:    pa.Size,
+				mode:    fs.FileMode(pa.Mode),
+				modTime: time.Unix(0, *pa.ModTime),
+			}
+		}
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
+
+func parseActionType(s string) (ActionType, error) {
+	for t, name := range actionTypeNames {
+		if name == s {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown action type %q", s)
+}
+
+// plannedInfo is a source file info read back from a plan file.
+type plannedInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i plannedInfo) Name() string       { return i.name }
+func (i plannedInfo) Size() int64        { return i.size }
+func (i plannedInfo) Mode() fs.FileMode  { return i.mode }
+func (i plannedInfo) ModTime() time.Time { return i.modTime }
+func (i plannedInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i plannedInfo) Sys() any           { return nil }
+
+// plannedInfo returns the SrcInfo of a, or stats the source for actions built without one.
+func (r *runner) plannedInfo(a Action) (fs.FileInfo, error) {
+	if a.SrcInfo != nil {
+		return a.SrcInfo, nil
+	}
+	info, err := fs.Stat(r.src, a.Rel)
+	if err != nil {
+		return nil, fmt.Errorf("apply %s %s: %w", a.Type, a.Rel, err)
+	}
+	return info, nil
+}
+
+// checkPlanned returns the source info for a copy or overwrite action, warning when the source
+// changed since it was planned.
+func (r *runner) checkPlanned(a Action) (fs.FileInfo, error) {
+	if a.SrcInfo == nil {
+		return r.plannedInfo(a)
+	}
+	now, err := fs.Stat(r.src, a.Rel)
+	if err != nil {
+		// The copy reports the error
+		return a.SrcInfo, nil
+	}
+	if now.Size() != a.SrcInfo.Size() || !now.ModTime().Equal(a.SrcInfo.ModTime()) {
+		r.opt.Logger.Printf("WARN: source %s changed since it was planned", r.srcPath(a.Rel))
+		return now, nil
+	}
+	return a.SrcInfo, nil
+}
@@ -0,0 +1,193 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// TargetPolicy is the Options.TargetPolicy: the permissions and ownership every target file
+// and directory must end up with, whatever those of its source.
+type TargetPolicy struct {
+	// FileMode and DirMode are the required permission bits of regular files and of directories;
+	// 0 leaves them alone.
+	FileMode fs.FileMode
+	DirMode  fs.FileMode
+	// Owner and Group are the required user and group, by name or numeric ID; empty leaves them
+	// alone. Unix only.
+	Owner string
+	Group string
+}
+
+// ParseTargetPolicy parses the CLI spelling of a TargetPolicy, comma-separated "key=value" pairs
+// with the keys mode, dirmode (octal), owner and group, e.g. "mode=0640,dirmode=0750,owner=www-data".
+func ParseTargetPolicy(s string) (*TargetPolicy, error) {
+	p := &TargetPolicy{}
+	for _, part := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("target policy: %q is not key=value", part)
+		}
+		switch key {
+		case "mode", "dirmode":
+			m, err := strconv.ParseUint(value, 8, 32)
+			if err != nil || m == 0 || m&^uint64(fs.ModePerm) != 0 {
+				return nil, fmt.Errorf("target policy: bad %s %q", key, value)
+			}
+			if key == "mode" {
+				p.FileMode = fs.FileMode(m)
+			} else {
+				p.DirMode = fs.FileMode(m)
+			}
+		case "owner":
+			p.Owner = value
+		case "group":
+			p.Group = value
+		default:
+			return nil, fmt.Errorf("target policy: unknown key %q", key)
+		}
+	}
+	return p, nil
+}
+
+// targetPolicy is a TargetPolicy with the owner and group resolved to IDs (-1 if not set).
+type targetPolicy struct {
+	TargetPolicy
+	uid, gid int
+}
+
+// resolvePolicy checks p and resolves its owner and group.
+func resolvePolicy(p *TargetPolicy) (*targetPolicy, error) {
+	if p.FileMode&^fs.ModePerm != 0 || p.DirMode&^fs.ModePerm != 0 {
+		return nil, errors.New("modes may only hold permission bits")
+	}
+	tp := &targetPolicy{TargetPolicy: *p, uid: -1, gid: -1}
+	if (p.Owner != "" || p.Group != "") && !ownersSupported {
+		return nil, errors.New("owner and group are not supported on this platform")
+	}
+	var err error
+	if p.Owner != "" {
+		if tp.uid, err = lookupID(p.Owner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if p.Group != "" {
+		if tp.gid, err = lookupID(p.Group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return tp, nil
+}
+
+// lookupID returns the numeric ID s, or that of the name s found by lookup.
+func lookupID(s string, lookup func(string) (string, error)) (int, error) {
+	if id, err := parseID(s); err == nil {
+		return int(id), nil
+	}
+	id, err := lookup(s)
+	if err != nil {
+		return -1, err
+	}
+	n, err := parseID(id)
+	return int(n), err
+}
+
+// enforcePolicy walks the target after the run and gives every file and directory below the
+// root that does not conform to Options.TargetPolicy the required mode and owner, counting
+// them in Report.PolicyFixed. Symlinks are left alone.
+func (r *runner) enforcePolicy() {
+	opt, rep := r.opt, r.rep
+	err := fs.WalkDir(r.dst, ".", func(rel string, d fs.DirEntry, err error) error {
+		r.beat()
+		if err != nil {
+			opt.Logger.Printf("ERR: read %s: %v", r.dstPath(rel), err)
+			rep.addErr(err)
+			return nil
+		}
+		if rel == "." || d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			opt.Logger.Printf("ERR: info %s: %v", r.dstPath(rel), err)
+			rep.addErr(err)
+			return nil
+		}
+		r.conformEntry(rel, info)
+		return nil
+	})
+	if err != nil {
+		opt.Logger.Printf("ERR: walk target %s: %v", r.dstPath("."), err)
+		rep.addErr(err)
+	}
+}
+
+// conformEntry applies the target policy to the target entry rel described by info.
+func (r *runner) conformEntry(rel string, info fs.FileInfo) {
+	opt, rep, p := r.opt, r.rep, r.policy
+	want := p.FileMode
+	if info.IsDir() {
+		want = p.DirMode
+	} else if !info.Mode().IsRegular() {
+		want = 0
+	}
+	var changes []string
+	chmod := want != 0 && info.Mode().Perm() != want
+	if chmod {
+		changes = append(changes, fmt.Sprintf("mode %v -> %v", info.Mode().Perm(), want))
+	}
+	uid, gid, ok := fileOwner(info)
+	chown := ok && (p.uid >= 0 && int(uid) != p.uid || p.gid >= 0 && int(gid) != p.gid)
+	if chown {
+		wantUID, wantGID := int(uid), int(gid)
+		if p.uid >= 0 {
+			wantUID = p.uid
+		}
+		if p.gid >= 0 {
+			wantGID = p.gid
+		}
+		changes = append(changes, fmt.Sprintf("owner %d:%d -> %d:%d", uid, gid, wantUID, wantGID))
+	}
+	if len(changes) == 0 {
+		return
+	}
+	if !opt.DryRun {
+		// Owner first: chown may clear the setuid and setgid bits
+		if chown {
+			dp, err := r.dst.(dirFS).path("chown", rel)
+			if err == nil {
+				err = os.Lchown(dp, p.uid, p.gid)
+			}
+			if err != nil {
+				opt.Logger.Printf("ERR: chown %s: %v", r.dstPath(rel), err)
+				rep.addErr(err)
+				return
+			}
+		}
+		if chmod {
+			if err := r.dst.(ChmodFS).Chmod(rel, want); err != nil {
+				opt.Logger.Printf("ERR: chmod %s: %v", r.dstPath(rel), err)
+				rep.addErr(err)
+				return
+			}
+		}
+	}
+	opt.Logger.Printf("POLICY: %s (%s)", r.dstPath(rel), strings.Join(changes, ", "))
+	rep.PolicyFixed++
+}
@@ -0,0 +1,18 @@
+package sync
+
+import "testing"
+
+func TestParseTargetPolicy(t *testing.T) {
+	p, err := ParseTargetPolicy("mode=0640, dirmode=750,owner=www-data,group=33")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *p != (TargetPolicy{FileMode: 0o640, DirMode: 0o750, Owner: "www-data", Group: "33"}) {
+		t.Fatalf("got %+v", *p)
+	}
+	for _, s := range []string{"", "mode", "mode=0999", "mode=01777", "umask=022"} {
+		if _, err := ParseTargetPolicy(s); err == nil {
+			t.Fatalf("%q: expected an error", s)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+//go:build unix
+
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestTargetPolicy(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "public.txt"), "public")
+	mustWrite(t, filepath.Join(src, "dir", "script.sh"), "#!/bin/sh")
+	if err := os.Chmod(filepath.Join(src, "dir", "script.sh"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Already in the target and not synced: the policy covers it too
+	mustWrite(t, filepath.Join(dst, "extra.txt"), "extra")
+
+	policy := &TargetPolicy{FileMode: 0o640, DirMode: 0o750}
+	root := os.Geteuid() == 0
+	if root {
+		policy.Owner, policy.Group = "1234", "2345"
+	}
+	rep := Sync(Options{Source: src, Target: dst, TargetPolicy: policy})
+	// public.txt, dir, dir/script.sh and extra.txt
+	if len(rep.Errors) != 0 || rep.Copied != 2 || rep.PolicyFixed != 4 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	for name, want := range map[string]os.FileMode{"public.txt": 0o640, "dir": 0o750, "dir/script.sh": 0o640, "extra.txt": 0o640} {
+		info, err := os.Lstat(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != want {
+			t.Fatalf("%s: mode %v, want %v", name, info.Mode().Perm(), want)
+		}
+		if st := info.Sys().(*syscall.Stat_t); root && (st.Uid != 1234 || st.Gid != 2345) {
+			t.Fatalf("%s: owner %d:%d, want 1234:2345", name, st.Uid, st.Gid)
+		}
+	}
+
+	// Everything conforms now
+	if rep := Sync(Options{Source: src, Target: dst, TargetPolicy: policy}); len(rep.Errors) != 0 || rep.PolicyFixed != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+
+	rep = Sync(Options{Source: src, Target: dst, TargetPolicy: &TargetPolicy{Owner: "no-such-user-for-sure"}})
+	if len(rep.Errors) != 1 || !strings.Contains(rep.Errors[0].Error(), "TargetPolicy") {
+		t.Fatalf("expected a startup error, got %+v", *rep)
+	}
+}
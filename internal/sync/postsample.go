@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"fmt"
+	"io/fs"
+	"math/rand"
+)
+
+// sampledFile is a target file picked for the post-sync check, with the source it was synced from.
+type sampledFile struct {
+	src         fs.FS
+	srcRoot     string
+	rel, dstRel string
+}
+
+// sampleSynced picks a file that is in sync with probability Options.PostSyncSample.
+func (r *runner) sampleSynced(rel, dstRel string) {
+	ratio := r.opt.PostSyncSample
+	if ratio <= 0 || r.opt.DryRun || r.pack != nil {
+		return
+	}
+	if ratio < 1 && rand.Float64() >= ratio {
+		return
+	}
+	r.samples = append(r.samples, sampledFile{src: r.src, srcRoot: r.srcRoot, rel: rel, dstRel: dstRel})
+}
+
+// checkSamples reads every sampled target file back and compares it with its source, recording a
+// mismatch as an error. It catches corruption that happened after writing, e.g. by faulty RAM
+// or disks; recently written files may be served from the page cache, though.
+func (r *runner) checkSamples() {
+	src, srcRoot := r.src, r.srcRoot
+	defer func() { r.src, r.srcRoot = src, srcRoot }()
+	for _, s := range r.samples {
+		r.beat()
+		r.src, r.srcRoot = s.src, s.srcRoot
+		same, err := r.sameContent(s.rel, s.dstRel)
+		if err != nil {
+			err = fmt.Errorf("post-sync check %s: %w", r.dstPath(s.dstRel), err)
+		} else if !same {
+			err = fmt.Errorf("post-sync check: %s differs from source %s", r.dstPath(s.dstRel), r.srcPath(s.rel))
+		}
+		if err != nil {
+			r.opt.Logger.Printf("ERR: %v", err)
+			r.rep.addErr(err)
+		}
+	}
+	r.opt.Logger.Printf("CHECK: %d sampled files verified after sync", len(r.samples))
+}
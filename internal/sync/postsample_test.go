@@ -0,0 +1,46 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// rotFS corrupts the file named rot right after it was renamed into place.
+type rotFS struct {
+	*memFS
+	rot string
+}
+
+func (f rotFS) Rename(oldname, newname string) error {
+	if err := f.memFS.Rename(oldname, newname); err != nil {
+		return err
+	}
+	if newname == f.rot {
+		f.MapFS[newname].Data[0] ^= 0xff
+	}
+	return nil
+}
+
+func TestPostSyncSample(t *testing.T) {
+	mtime := time.Now().Add(-time.Hour)
+	src := fstest.MapFS{
+		"good.txt": {Data: []byte("good"), ModTime: mtime},
+		"bad.txt":  {Data: []byte("bad"), ModTime: mtime},
+	}
+
+	rep := SyncFS(src, rotFS{memFS: newMemFS(), rot: "bad.txt"}, Options{PostSyncSample: 1})
+	if rep.Copied != 2 || len(rep.Errors) != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if msg := rep.Errors[0].Error(); !strings.Contains(msg, "bad.txt differs from source") {
+		t.Fatalf("unexpected error: %v", msg)
+	}
+
+	// Without sampling the corruption goes unnoticed
+	rep = SyncFS(src, rotFS{memFS: newMemFS(), rot: "bad.txt"}, Options{})
+	if rep.Copied != 2 || len(rep.Errors) != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+}
@@ -0,0 +1,53 @@
+package sync
+
+import "fmt"
+
+// IOClass is the Options.IONice I/O scheduling class.
+type IOClass int
+
+const (
+	// IOClassDefault leaves the I/O priority unchanged.
+	IOClassDefault IOClass = iota
+	// IOClassBestEffort uses the best-effort class at its lowest priority level.
+	IOClassBestEffort
+	// IOClassIdle only gets disk time when no other process needs it.
+	IOClassIdle
+)
+
+var ioClassNames = map[IOClass]string{
+	IOClassDefault:    "default",
+	IOClassBestEffort: "best-effort",
+	IOClassIdle:       "idle",
+}
+
+func (c IOClass) String() string {
+	if s, ok := ioClassNames[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("IOClass(%d)", int(c))
+}
+
+// ParseIOClass parses the CLI spelling of an IOClass ("default", "best-effort", "idle").
+func ParseIOClass(s string) (IOClass, error) {
+	for c, name := range ioClassNames {
+		if name == s {
+			return c, nil
+		}
+	}
+	return IOClassDefault, fmt.Errorf("unknown I/O class %q", s)
+}
+
+// lowerPriority applies Options.Nice and Options.IONice to the whole process.
+// Failures are logged as warnings; the run continues at the old priority.
+func (r *runner) lowerPriority() {
+	if r.opt.Nice != 0 {
+		if err := setNice(r.opt.Nice); err != nil {
+			r.opt.Logger.Printf("WARN: nice %d: %v", r.opt.Nice, err)
+		}
+	}
+	if r.opt.IONice != IOClassDefault {
+		if err := setIOClass(r.opt.IONice); err != nil {
+			r.opt.Logger.Printf("WARN: ionice %s: %v", r.opt.IONice, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+//go:build linux
+
+package sync
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// ioprio_set(2) encoding, see linux/ioprio.h.
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+	ioprioLowestBE   = 7
+)
+
+// ioprio returns the ioprio_set(2) value for c.
+func ioprio(c IOClass) uintptr {
+	if c == IOClassIdle {
+		return ioprioClassIdle << ioprioClassShift
+	}
+	return ioprioClassBE<<ioprioClassShift | ioprioLowestBE
+}
+
+// setNice sets the nice value of every thread of the process.
+func setNice(n int) error {
+	return forEachThread(func(tid int) error {
+		return syscall.Setpriority(syscall.PRIO_PROCESS, tid, n)
+	})
+}
+
+// setIOClass sets the I/O priority of every thread of the process.
+func setIOClass(c IOClass) error {
+	prio := ioprio(c)
+	return forEachThread(func(tid int) error {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), prio); errno != 0 {
+			return errno
+		}
+		return nil
+	})
+}
+
+// forEachThread calls fn with the id of every thread of the process.
+// Linux keeps both priorities per thread, and the Go runtime has several threads;
+// threads started later inherit the priority of the thread creating them.
+func forEachThread(fn func(tid int) error) error {
+	entries, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		// No /proc: at least the calling thread
+		return fn(0)
+	}
+	for _, e := range entries {
+		tid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		if err := fn(tid); err != nil {
+			if err == syscall.ESRCH {
+				// The thread exited meanwhile
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
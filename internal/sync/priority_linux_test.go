@@ -0,0 +1,47 @@
+//go:build linux
+
+package sync
+
+import (
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+)
+
+func TestLowerPriority(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// The raw getpriority(2) result is 20 - nice.
+	raw, err := syscall.Getpriority(syscall.PRIO_PROCESS, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 20 - raw + 1
+	if want > 19 {
+		want = 19
+	}
+
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+	rep := Sync(Options{Source: src, Target: t.TempDir(), Nice: want, IONice: IOClassBestEffort})
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+
+	raw, err = syscall.Getpriority(syscall.PRIO_PROCESS, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := 20 - raw; got != want {
+		t.Fatalf("nice: got %d want %d", got, want)
+	}
+	prio, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_GET, ioprioWhoProcess, 0, 0)
+	if errno != 0 {
+		t.Fatal(errno)
+	}
+	if prio != ioprio(IOClassBestEffort) {
+		t.Fatalf("ioprio: got %#x want %#x", prio, ioprio(IOClassBestEffort))
+	}
+}
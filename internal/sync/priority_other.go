@@ -0,0 +1,13 @@
+//go:build !linux
+
+package sync
+
+// setNice is only implemented on Linux; elsewhere Options.Nice is ignored.
+func setNice(int) error {
+	return nil
+}
+
+// setIOClass is only implemented on Linux; elsewhere Options.IONice is ignored.
+func setIOClass(IOClass) error {
+	return nil
+}
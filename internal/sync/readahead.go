@@ -0,0 +1,95 @@
+package sync
+
+import (
+	"bytes"
+	"io/fs"
+)
+
+// readaheadMaxSize is the largest file whose content is prefetched into memory.
+// Larger files pass through the queue in order but are streamed as usual.
+const readaheadMaxSize = 1 << 20
+
+// readahead is the prefetch queue of Options.Readahead. Reads run in background goroutines,
+// while writes and all report updates stay on the walking goroutine, in walk order.
+type readahead struct {
+	depth int
+	queue []*prefetch
+	// queued is the size of the queued files, counted against Options.MaxTotalBytes.
+	queued int64
+}
+
+// prefetch is a queued copy and the source content read for it.
+type prefetch struct {
+	src         fs.FS
+	rel, dstRel string
+	info        fs.FileInfo
+	overwrite   bool
+	done        chan struct{}
+	data        []byte
+	err         error
+}
+
+// queueCopy starts reading rel in the background and writes the oldest queued file
+// once the queue is full, so at most depth reads are in flight.
+func (r *runner) queueCopy(rel, dstRel string, info fs.FileInfo, overwrite bool) {
+	p := &prefetch{src: r.src, rel: rel, dstRel: dstRel, info: info, overwrite: overwrite, done: make(chan struct{})}
+	if info.Size() <= readaheadMaxSize {
+		go func() {
+			defer close(p.done)
+			p.data, p.err = fs.ReadFile(p.src, p.rel)
+		}()
+	} else {
+		close(p.done)
+	}
+	r.ra.queue = append(r.ra.queue, p)
+	r.ra.queued += info.Size()
+	if len(r.ra.queue) >= r.ra.depth {
+		head := r.ra.queue[0]
+		r.ra.queue = r.ra.queue[1:]
+		r.finishCopy(head)
+	}
+}
+
+// flushCopies writes every queued file.
+func (r *runner) flushCopies() {
+	for _, p := range r.ra.queue {
+		r.finishCopy(p)
+	}
+	r.ra.queue = nil
+}
+
+func (r *runner) finishCopy(p *prefetch) {
+	<-p.done
+	r.ra.queued -= p.info.Size()
+	src := p.src
+	if p.err == nil && p.data != nil {
+		src = prefetchedFS{FS: p.src, name: p.rel, data: p.data, info: p.info}
+	}
+	// A failed prefetch falls back to reading the source again, which reports the error as usual
+	r.traceFile(p.dstRel, p.info, p.overwrite, func() error {
+		return r.writeEntry(src, p.rel, p.dstRel, p.info, p.overwrite)
+	})
+}
+
+// prefetchedFS serves one already read file from memory and everything else from the wrapped FS.
+type prefetchedFS struct {
+	fs.FS
+	name string
+	data []byte
+	info fs.FileInfo
+}
+
+func (f prefetchedFS) Open(name string) (fs.File, error) {
+	if name != f.name {
+		return f.FS.Open(name)
+	}
+	return &memReadFile{Reader: bytes.NewReader(f.data), info: f.info}, nil
+}
+
+type memReadFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *memReadFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memReadFile) Close() error               { return nil }
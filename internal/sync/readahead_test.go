@@ -0,0 +1,96 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestReadahead(t *testing.T) {
+	src := t.TempDir()
+	seedTree(t, src, 200)
+	// Larger than readaheadMaxSize, so it is streamed in its turn instead of prefetched
+	mustWrite(t, filepath.Join(src, "d05", "big.bin"), strings.Repeat("x", readaheadMaxSize+1))
+
+	var plainLog, raLog bytes.Buffer
+	plain, ra := t.TempDir(), t.TempDir()
+	want := Sync(Options{Source: src, Target: plain, WalkOrder: WalkNameAsc, Logger: log.New(&plainLog, "", 0)})
+	got := Sync(Options{Source: src, Target: ra, WalkOrder: WalkNameAsc, Readahead: 8, Logger: log.New(&raLog, "", 0)})
+	if len(got.Errors) != 0 || got.Copied != want.Copied || got.BytesCopied != want.BytesCopied {
+		t.Fatalf("readahead %+v differs from plain %+v", *got, *want)
+	}
+	if !reflect.DeepEqual(copiedOrder(raLog.String()), copiedOrder(plainLog.String())) {
+		t.Fatalf("readahead changed the copy order")
+	}
+	err := filepath.WalkDir(src, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		a, _ := os.ReadFile(p)
+		b, err := os.ReadFile(filepath.Join(ra, rel))
+		if err != nil || !bytes.Equal(a, b) {
+			t.Fatalf("%s: content mismatch (%v)", rel, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Everything is in sync now: nothing is queued.
+	rep := Sync(Options{Source: src, Target: ra, Readahead: 8})
+	if rep.Copied != 0 || rep.Overwritten != 0 {
+		t.Fatalf("expected no copies on second run, got %+v", *rep)
+	}
+}
+
+func TestReadaheadReadFailure(t *testing.T) {
+	mtime := time.Now().Add(-time.Hour)
+	src := fstest.MapFS{
+		"a.txt":   {Data: []byte("a"), ModTime: mtime},
+		"bad.txt": {Data: []byte("b"), ModTime: mtime},
+		"c.txt":   {Data: []byte("c"), ModTime: mtime},
+	}
+	for _, transactional := range []bool{false, true} {
+		dst := newMemFS()
+		rep := SyncFS(failOpenFS{FS: src, fail: "bad.txt"}, dst, Options{Readahead: 2, Transactional: transactional})
+		if len(rep.Errors) != 1 || !strings.Contains(rep.Errors[0].Error(), "injected failure") {
+			t.Fatalf("transactional=%v: expected the injected error only, got %v", transactional, rep.Errors)
+		}
+		if transactional {
+			if len(dst.MapFS) != 0 {
+				t.Fatalf("expected nothing applied, got %v", snapshot(dst))
+			}
+			continue
+		}
+		if got := snapshot(dst); !reflect.DeepEqual(got, map[string]string{"a.txt": "a", "c.txt": "c"}) {
+			t.Fatalf("unexpected target: %v", got)
+		}
+	}
+}
+
+func BenchmarkReadahead(b *testing.B) {
+	src := b.TempDir()
+	seedTree(b, src, 10000)
+	quiet := log.New(io.Discard, "", 0)
+
+	for _, depth := range []int{0, 16, 64} {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				dst := b.TempDir()
+				b.StartTimer()
+				Sync(Options{Source: src, Target: dst, Readahead: depth, Logger: quiet})
+			}
+		})
+	}
+}
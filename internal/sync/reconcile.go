@@ -0,0 +1,51 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"log"
+)
+
+// reconcile re-compares source and target after the run (Options.ReconcileAfter) and records
+// every remaining difference as an error, so that partial failures cannot go unnoticed.
+// It compares like the run did, except that IgnoreModTime falls back to sizes only, never hashing.
+func (r *runner) reconcile() {
+	o := r.opt
+	o.DryRun = true
+	o.Logger = log.New(io.Discard, "", 0)
+	o.Tracer = nil
+	o.Syslog = false
+	o.Transactional = false
+	o.SubtreeCheck = false
+	o.CleanStaleTemps = false
+	o.TargetKnownEmpty = false
+	o.CompletionMarker = ""
+	o.Manifest = ""
+	o.CSVReport = ""
+	o.HeartbeatFile = ""
+	o.TextfileMetrics = ""
+	o.MaxDeletes = 0
+	o.FixCase = false
+	o.Nice, o.IONice = 0, IOClassDefault
+	if o.IgnoreModTime {
+		o.IgnoreModTime = false
+		o.CompareSizeOnly = true
+	}
+
+	v := newRunner(r.src, r.dst, o)
+	v.sources = r.sources
+	v.srcRoot, v.dstRoot = r.srcRoot, r.dstRoot
+	v.ver = &Verification{}
+	v.run()
+
+	report := func(kind string, paths []string) {
+		for _, p := range paths {
+			err := fmt.Errorf("reconcile: %s %s", p, kind)
+			r.opt.Logger.Printf("ERR: %v", err)
+			r.rep.addErr(err)
+		}
+	}
+	report("missing in target", v.ver.Missing)
+	report("differs from source", v.ver.Changed)
+	report("still present though missing in source", v.ver.Orphans)
+}
@@ -0,0 +1,51 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestReconcileAfter(t *testing.T) {
+	mtime := time.Now().Add(-time.Hour)
+	newSrc := func() fstest.MapFS {
+		return fstest.MapFS{
+			"a.txt":   {Data: []byte("a"), ModTime: mtime},
+			"bad.txt": {Data: []byte("unreadable"), ModTime: mtime},
+		}
+	}
+
+	for _, reconcile := range []bool{false, true} {
+		src := failOpenFS{FS: newSrc(), fail: "bad.txt"}
+		dst := newMemFS()
+		rep := SyncFS(src, dst, Options{ReconcileAfter: reconcile})
+		if rep.Copied != 1 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		var msgs []string
+		for _, err := range rep.Errors {
+			msgs = append(msgs, err.Error())
+		}
+		all := strings.Join(msgs, "\n")
+		if got := strings.Contains(all, "reconcile: bad.txt missing in target"); got != reconcile {
+			t.Fatalf("reconcile=%v: errors:\n%s", reconcile, all)
+		}
+		want := 1
+		if reconcile {
+			want = 2
+		}
+		if len(rep.Errors) != want {
+			t.Fatalf("reconcile=%v: expected %d errors, got:\n%s", reconcile, want, all)
+		}
+	}
+
+	t.Run("clean run", func(t *testing.T) {
+		dst := newMemFS()
+		dst.MapFS["orphan.txt"] = &fstest.MapFile{Data: []byte("o")}
+		rep := SyncFS(newSrc(), dst, Options{DeleteMissing: true, ReconcileAfter: true})
+		if len(rep.Errors) != 0 || rep.Copied != 2 || rep.Deleted != 1 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+)
+
+// RenameStrategy is the Options.RenameStrategy.
+type RenameStrategy int
+
+const (
+	// RenameAtomic writes a temp file and renames it over the target, which is never seen half-written.
+	RenameAtomic RenameStrategy = iota
+	// RenameRemoveThenRename writes a temp file, removes the target and renames the temp file into
+	// place, for filesystems that cannot rename over an existing file. The target is briefly missing.
+	RenameRemoveThenRename
+	// RenameCopyInPlace truncates the target and writes it directly, for filesystems without
+	// usable renames. An interrupted copy leaves a partial (then removed) target.
+	RenameCopyInPlace
+)
+
+var renameStrategyNames = map[RenameStrategy]string{
+	RenameAtomic:           "atomic",
+	RenameRemoveThenRename: "remove-then-rename",
+	RenameCopyInPlace:      "copy-in-place",
+}
+
+func (s RenameStrategy) String() string {
+	if name, ok := renameStrategyNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("RenameStrategy(%d)", int(s))
+}
+
+// ParseRenameStrategy parses the CLI spelling of a RenameStrategy ("atomic", "remove-then-rename", "copy-in-place").
+func ParseRenameStrategy(s string) (RenameStrategy, error) {
+	for st, name := range renameStrategyNames {
+		if name == s {
+			return st, nil
+		}
+	}
+	return RenameAtomic, fmt.Errorf("unknown rename strategy %q", s)
+}
+
+// place copies rel from src onto the target file dstRel using the configured RenameStrategy,
+// or directly with StreamVisible.
+func (r *runner) place(src fs.FS, rel, dstRel string, info fs.FileInfo) error {
+	if r.streamVisible(info) {
+		return writeFS(src, rel, syncingFS{r.writeTarget(info)}, dstRel, "dst", info)
+	}
+	if r.opt.RenameStrategy == RenameCopyInPlace {
+		return writeFS(src, rel, r.writeTarget(info), dstRel, "dst", info)
+	}
+	tmp, err := stageFS(src, rel, r.writeTarget(info), dstRel, info)
+	if err != nil {
+		return err
+	}
+	if err := r.replace(tmp, dstRel); err != nil {
+		// Best-effort cleanup of leftover temp file on error.
+		_ = r.dst.Remove(tmp)
+		return fmt.Errorf("rename: %w", err)
+	}
+	return nil
+}
+
+// replace moves the temp file tmp onto name: by a plain rename with RenameAtomic,
+// otherwise by removing name first. Options.TempDevice may check the devices first.
+func (r *runner) replace(tmp, name string) error {
+	if r.opt.TempDevice != TempDeviceAssume {
+		if err := r.checkTempDevice(tmp, name); err != nil {
+			if r.opt.TempDevice == TempDeviceError {
+				return err
+			}
+			r.opt.Logger.Printf("WARN: %v; copying in place", err)
+			return r.copyOver(tmp, name)
+		}
+	}
+	if r.opt.RenameStrategy == RenameAtomic {
+		err := r.dst.Rename(tmp, name)
+		if err != nil && errors.Is(err, syscall.EXDEV) && r.opt.TempDevice == TempDeviceCopy {
+			r.opt.Logger.Printf("WARN: rename %s: %v; copying in place", r.dstPath(name), err)
+			return r.copyOver(tmp, name)
+		}
+		if err != nil && targetBusy(err) {
+			return r.replaceBusy(tmp, name, err)
+		}
+		if err != nil && renameUnsupported(err) {
+			return fmt.Errorf("%w (if the target cannot rename over existing files, use RenameStrategy %s or %s)",
+				err, RenameRemoveThenRename, RenameCopyInPlace)
+		}
+		return err
+	}
+	if err := r.dst.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return r.dst.Rename(tmp, name)
+}
+
+// renameUnsupported reports whether a rename failed because the target filesystem refuses to
+// replace an existing file or does not support the operation.
+func renameUnsupported(err error) bool {
+	return errors.Is(err, fs.ErrExist) || errors.Is(err, syscall.ENOTSUP)
+}
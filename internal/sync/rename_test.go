@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// noReplaceFS is a memFS that cannot rename over an existing file, like some SMB/NFS mounts.
+type noReplaceFS struct {
+	*memFS
+	renames int
+}
+
+func (n *noReplaceFS) Rename(oldname, newname string) error {
+	n.renames++
+	if _, ok := n.MapFS[newname]; ok {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+	}
+	return n.memFS.Rename(oldname, newname)
+}
+
+func TestRenameStrategy(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	src := fstest.MapFS{
+		"changed.txt": {Data: []byte("new content"), ModTime: time.Now()},
+		"new.txt":     {Data: []byte("new"), ModTime: time.Now()},
+	}
+
+	tests := []struct {
+		strategy      RenameStrategy
+		transactional bool
+		wantErr       string
+		wantRenames   bool
+	}{
+		{strategy: RenameAtomic, wantErr: "use RenameStrategy remove-then-rename or copy-in-place", wantRenames: true},
+		{strategy: RenameRemoveThenRename, wantRenames: true},
+		{strategy: RenameRemoveThenRename, transactional: true, wantRenames: true},
+		{strategy: RenameCopyInPlace},
+	}
+	for _, tt := range tests {
+		name := tt.strategy.String()
+		if tt.transactional {
+			name += "/transactional"
+		}
+		t.Run(name, func(t *testing.T) {
+			dst := &noReplaceFS{memFS: newMemFS()}
+			dst.MapFS["changed.txt"] = &fstest.MapFile{Data: []byte("old"), ModTime: old}
+
+			rep := SyncFS(src, dst, Options{RenameStrategy: tt.strategy, Transactional: tt.transactional})
+			if got := string(dst.MapFS["new.txt"].Data); got != "new" {
+				t.Fatalf("new.txt: %q", got)
+			}
+			for name := range dst.MapFS {
+				if strings.HasSuffix(name, tempSuffix) {
+					t.Fatalf("temp file left: %s", name)
+				}
+			}
+			if (dst.renames > 0) != tt.wantRenames {
+				t.Fatalf("renames=%d, want renames %v", dst.renames, tt.wantRenames)
+			}
+
+			if tt.wantErr != "" {
+				if len(rep.Errors) != 1 || !strings.Contains(rep.Errors[0].Error(), tt.wantErr) {
+					t.Fatalf("expected error with %q, got %v", tt.wantErr, rep.Errors)
+				}
+				if got := string(dst.MapFS["changed.txt"].Data); got != "old" {
+					t.Fatalf("changed.txt: %q", got)
+				}
+				return
+			}
+			if len(rep.Errors) != 0 || rep.Copied != 1 || rep.Overwritten != 1 {
+				t.Fatalf("unexpected rep: %+v", *rep)
+			}
+			if got := string(dst.MapFS["changed.txt"].Data); got != "new content" {
+				t.Fatalf("changed.txt: %q", got)
+			}
+		})
+	}
+}
+
+func TestParseRenameStrategy(t *testing.T) {
+	for _, s := range []RenameStrategy{RenameAtomic, RenameRemoveThenRename, RenameCopyInPlace} {
+		got, err := ParseRenameStrategy(s.String())
+		if err != nil || got != s {
+			t.Fatalf("round trip %v: got %v, %v", s, got, err)
+		}
+	}
+	if _, err := ParseRenameStrategy("hardlink"); err == nil {
+		t.Fatalf("expected error for unknown strategy")
+	}
+}
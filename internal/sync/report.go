@@ -1,15 +1,415 @@
 package sync
 
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
 type Report struct {
 	Copied      int
 	Overwritten int
 	Deleted     int
 	Skipped     int
-	Errors      []error
+	// BytesCopied is the total size of copied and overwritten files.
+	BytesCopied int64
+	// BytesAdded and BytesRemoved sum how much overwritten files grew and shrank (Options.ComputeChurn).
+	BytesAdded   int64
+	BytesRemoved int64
+	// SkippedSubtrees counts directories skipped wholesale by Options.SubtreeCheck.
+	SkippedSubtrees int
+	// CleanedTemps counts stale temp files removed by Options.CleanStaleTemps.
+	CleanedTemps int
+	// ACLFailures counts copied files whose ACL could not be replicated (Options.PreserveACLs).
+	ACLFailures int
+	// OwnerFailures counts copied files whose owner could not be set (Options.PreserveOwner).
+	OwnerFailures int
+	// SkippedLocked counts files skipped because another process held them locked (Options.SkipLockedFiles).
+	SkippedLocked int
+	// SkippedTooLong counts files skipped because their target path exceeds Options.MaxPathLen.
+	SkippedTooLong int
+	// SkippedImmutable counts changed files not overwritten because their target is immutable
+	// or append-only (Options.RespectImmutable).
+	SkippedImmutable int
+	// ModifiedDuringCopy counts files whose source kept changing while being copied, so that
+	// their target may be inconsistent (Options.DetectConcurrentModification).
+	ModifiedDuringCopy int
+	// DirsCreated counts target directories created by a run with Options.DirsOnly.
+	DirsCreated int
+	// Appended counts target files extended with only the new tail of their source (Options.AppendOnly).
+	Appended int
+	// Recased counts target files renamed to the case of their source (Options.FixCase).
+	Recased int
+	// SpotCheckCaught counts files overwritten because Options.SpotCheckRatio found their content changed.
+	SpotCheckCaught int
+	// Conflicts counts target files overwritten although they were newer than their source
+	// (Options.WarnOnNewerTarget).
+	Conflicts int
+	// PermsFixed counts target files whose permission bits were set to the source's
+	// (Options.RepairPermsOnly).
+	PermsFixed int
+	// Pruned counts target files deleted to meet Options.MaxTargetFiles (TargetLimitPrune).
+	Pruned int
+	// PolicyFixed counts target files and directories given the mode or owner required by
+	// Options.TargetPolicy.
+	PolicyFixed int
+	// Passes is how many times the whole sync ran with Options.RetryWholeSync, counting the
+	// first pass; it is 0 without the option.
+	Passes int
+	// SkipReasons counts skipped files by reason (SkipIdentical, SkipHiddenFile, ...) with
+	// Options.CollectSkipReasons, including those counted in SkippedLocked, SkippedTooLong and SkippedImmutable.
+	SkipReasons map[string]int
+	// DirStats breaks the changes down by top-level target directory (Options.PerDirStats);
+	// files directly in the target root are counted under ".".
+	DirStats map[string]DirStat
+	// Changes lists the target files changed since the previous run (Options.Manifest).
+	Changes *Changes
+	// Orphans lists the target files missing in every source, by slash-separated target name
+	// (Options.ReportOrphans).
+	Orphans []string
+	// PerTarget holds the individual reports of a run with Options.Targets, keyed by target.
+	PerTarget map[string]*Report
+	Errors    []error
+	// ErrorsByCategory groups the errors in Errors by ErrorCategory (Options.CategorizeErrors).
+	// Errors dropped because of Options.MaxStoredErrors are not included.
+	ErrorsByCategory map[string][]error
+	// ErrorSummary groups all errors by message, in order of first occurrence (Options.CoalesceErrors).
+	ErrorSummary []ErrorGroup
+	// DroppedErrors counts errors that were not stored because Errors reached Options.MaxStoredErrors
+	// or, with Options.CoalesceErrors, because an error with the same message was already stored.
+	DroppedErrors int
+
+	// maxErrors caps len(Errors); 0 means unlimited.
+	maxErrors int
+	// categorize fills ErrorsByCategory.
+	categorize bool
+	// coalesce fills ErrorSummary and, unless trackAll is set, stores one error per message.
+	coalesce bool
+	trackAll bool
+	// groups indexes ErrorSummary by message.
+	groups map[string]int
+}
+
+// newReport returns an empty report that stores errors as configured in opt.
+func newReport(opt Options) *Report {
+	return &Report{
+		maxErrors:  opt.MaxStoredErrors,
+		categorize: opt.CategorizeErrors,
+		coalesce:   opt.CoalesceErrors,
+		trackAll:   opt.TrackAllErrors,
+	}
+}
+
+// ErrorGroup counts the errors with the same message.
+type ErrorGroup struct {
+	Message string
+	Count   int
 }
 
 func (r *Report) addErr(err error) {
-	if err != nil {
-		r.Errors = append(r.Errors, err)
+	if err == nil {
+		return
+	}
+	if r.coalesce && r.group(err.Error(), 1) && !r.trackAll {
+		// A duplicate, counted in ErrorSummary
+		r.DroppedErrors++
+		return
+	}
+	r.storeErr(err)
+}
+
+// storeErr appends err to Errors unless they reached maxErrors.
+func (r *Report) storeErr(err error) {
+	if r.maxErrors > 0 && len(r.Errors) >= r.maxErrors {
+		r.DroppedErrors++
+		return
+	}
+	r.Errors = append(r.Errors, err)
+	if r.categorize {
+		if r.ErrorsByCategory == nil {
+			r.ErrorsByCategory = map[string][]error{}
+		}
+		c := ErrorCategory(err)
+		r.ErrorsByCategory[c] = append(r.ErrorsByCategory[c], err)
+	}
+}
+
+// group adds n errors with message msg to ErrorSummary and reports whether the message was known.
+func (r *Report) group(msg string, n int) bool {
+	if i, ok := r.groups[msg]; ok {
+		r.ErrorSummary[i].Count += n
+		return true
+	}
+	if r.groups == nil {
+		r.groups = map[string]int{}
+	}
+	r.groups[msg] = len(r.ErrorSummary)
+	r.ErrorSummary = append(r.ErrorSummary, ErrorGroup{Message: msg, Count: n})
+	return false
+}
+
+// ErrorCount returns the total number of errors encountered, including dropped ones.
+func (r *Report) ErrorCount() int {
+	return len(r.Errors) + r.DroppedErrors
+}
+
+// Err returns nil if the run recorded no errors, and otherwise an error joining the stored
+// errors (errors.Is and errors.As match any of them) and noting those that were not stored.
+func (r *Report) Err() error {
+	if r.ErrorCount() == 0 {
+		return nil
+	}
+	errs := r.Errors[:len(r.Errors):len(r.Errors)]
+	if r.DroppedErrors > 0 {
+		errs = append(errs, fmt.Errorf("%d more errors not stored", r.DroppedErrors))
+	}
+	return errors.Join(errs...)
+}
+
+// ErrorCategoryCounts returns a summary of ErrorsByCategory such as "permission=12 space=3",
+// sorted by category, or "" when the errors were not categorized.
+func (r *Report) ErrorCategoryCounts() string {
+	keys := unionKeys(r.ErrorsByCategory, nil)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", k, len(r.ErrorsByCategory[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// merge adds the counters and errors of o to r.
+func (r *Report) merge(o *Report) {
+	r.Copied += o.Copied
+	r.Overwritten += o.Overwritten
+	r.Deleted += o.Deleted
+	r.Skipped += o.Skipped
+	r.BytesCopied += o.BytesCopied
+	r.BytesAdded += o.BytesAdded
+	r.BytesRemoved += o.BytesRemoved
+	r.SkippedSubtrees += o.SkippedSubtrees
+	r.CleanedTemps += o.CleanedTemps
+	r.ACLFailures += o.ACLFailures
+	r.OwnerFailures += o.OwnerFailures
+	r.SkippedLocked += o.SkippedLocked
+	r.SkippedTooLong += o.SkippedTooLong
+	r.SkippedImmutable += o.SkippedImmutable
+	r.ModifiedDuringCopy += o.ModifiedDuringCopy
+	r.DirsCreated += o.DirsCreated
+	r.Appended += o.Appended
+	r.Recased += o.Recased
+	r.SpotCheckCaught += o.SpotCheckCaught
+	r.Conflicts += o.Conflicts
+	r.PermsFixed += o.PermsFixed
+	r.Pruned += o.Pruned
+	r.PolicyFixed += o.PolicyFixed
+	r.Orphans = append(r.Orphans, o.Orphans...)
+	for reason, n := range o.SkipReasons {
+		if r.SkipReasons == nil {
+			r.SkipReasons = map[string]int{}
+		}
+		r.SkipReasons[reason] += n
+	}
+	for dir, st := range o.DirStats {
+		if r.DirStats == nil {
+			r.DirStats = map[string]DirStat{}
+		}
+		sum := r.DirStats[dir]
+		sum.Copied += st.Copied
+		sum.Overwritten += st.Overwritten
+		sum.Deleted += st.Deleted
+		sum.BytesCopied += st.BytesCopied
+		r.DirStats[dir] = sum
+	}
+	if !r.coalesce {
+		for _, err := range o.Errors {
+			r.addErr(err)
+		}
+		r.DroppedErrors += o.DroppedErrors
+		return
+	}
+	for _, err := range o.Errors {
+		if r.group(err.Error(), 0) && !r.trackAll {
+			r.DroppedErrors++
+			continue
+		}
+		r.storeErr(err)
+	}
+	for _, g := range o.ErrorSummary {
+		r.group(g.Message, g.Count)
+	}
+	r.DroppedErrors += o.DroppedErrors
+}
+
+// String returns a one-line summary of the counters,
+// with the skip reasons in parentheses when they were collected.
+func (r *Report) String() string {
+	reasons := ""
+	if len(r.SkipReasons) > 0 {
+		keys := unionKeys(r.SkipReasons, nil)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = fmt.Sprintf("%s=%d", k, r.SkipReasons[k])
+		}
+		reasons = " (" + strings.Join(parts, " ") + ")"
+	}
+	churn := ""
+	if r.BytesAdded != 0 || r.BytesRemoved != 0 {
+		churn = fmt.Sprintf(" bytes_added=%d bytes_removed=%d", r.BytesAdded, r.BytesRemoved)
+	}
+	passes := ""
+	if r.Passes > 1 {
+		passes = fmt.Sprintf(" passes=%d", r.Passes)
+	}
+	return fmt.Sprintf("copied=%d overwritten=%d deleted=%d skipped=%d%s%s errors=%d%s",
+		r.Copied, r.Overwritten, r.Deleted, r.Skipped, reasons, churn, r.ErrorCount(), passes)
+}
+
+// Equal reports whether r and o describe the same run outcome; see Diff.
+func (r *Report) Equal(o *Report) bool {
+	return r.Diff(o) == ""
+}
+
+// Diff describes how o differs from r, one "field: r-value != o-value" line per difference,
+// or returns "" when they match. Counters, per-directory and per-target reports are compared,
+// errors by count and message (in any order), not by identity, and the orphans and changes
+// by name (in any order).
+func (r *Report) Diff(o *Report) string {
+	var b strings.Builder
+	r.diff(o, "", &b)
+	return b.String()
+}
+
+func (r *Report) diff(o *Report, prefix string, b *strings.Builder) {
+	counter := func(name string, a, c int64) {
+		if a != c {
+			fmt.Fprintf(b, "%s%s: %d != %d\n", prefix, name, a, c)
+		}
+	}
+	counter("copied", int64(r.Copied), int64(o.Copied))
+	counter("overwritten", int64(r.Overwritten), int64(o.Overwritten))
+	counter("deleted", int64(r.Deleted), int64(o.Deleted))
+	counter("skipped", int64(r.Skipped), int64(o.Skipped))
+	counter("bytes_copied", r.BytesCopied, o.BytesCopied)
+	counter("bytes_added", r.BytesAdded, o.BytesAdded)
+	counter("bytes_removed", r.BytesRemoved, o.BytesRemoved)
+	counter("skipped_subtrees", int64(r.SkippedSubtrees), int64(o.SkippedSubtrees))
+	counter("cleaned_temps", int64(r.CleanedTemps), int64(o.CleanedTemps))
+	counter("acl_failures", int64(r.ACLFailures), int64(o.ACLFailures))
+	counter("owner_failures", int64(r.OwnerFailures), int64(o.OwnerFailures))
+	counter("skipped_locked", int64(r.SkippedLocked), int64(o.SkippedLocked))
+	counter("skipped_too_long", int64(r.SkippedTooLong), int64(o.SkippedTooLong))
+	counter("skipped_immutable", int64(r.SkippedImmutable), int64(o.SkippedImmutable))
+	counter("modified_during_copy", int64(r.ModifiedDuringCopy), int64(o.ModifiedDuringCopy))
+	counter("dirs_created", int64(r.DirsCreated), int64(o.DirsCreated))
+	counter("appended", int64(r.Appended), int64(o.Appended))
+	counter("recased", int64(r.Recased), int64(o.Recased))
+	counter("spot_check_caught", int64(r.SpotCheckCaught), int64(o.SpotCheckCaught))
+	counter("conflicts", int64(r.Conflicts), int64(o.Conflicts))
+	counter("perms_fixed", int64(r.PermsFixed), int64(o.PermsFixed))
+	counter("pruned", int64(r.Pruned), int64(o.Pruned))
+	counter("policy_fixed", int64(r.PolicyFixed), int64(o.PolicyFixed))
+	counter("passes", int64(r.Passes), int64(o.Passes))
+	counter("errors", int64(r.ErrorCount()), int64(o.ErrorCount()))
+
+	for _, msg := range diffMessages(r.Errors, o.Errors) {
+		fmt.Fprintf(b, "%serror: %s\n", prefix, msg)
+	}
+
+	for _, name := range diffStrings(r.Orphans, o.Orphans) {
+		fmt.Fprintf(b, "%sorphan: %s\n", prefix, name)
+	}
+	var rc, oc Changes
+	if r.Changes != nil {
+		rc = *r.Changes
+	}
+	if o.Changes != nil {
+		oc = *o.Changes
+	}
+	for _, name := range diffStrings(rc.Added, oc.Added) {
+		fmt.Fprintf(b, "%sadded: %s\n", prefix, name)
+	}
+	for _, name := range diffStrings(rc.Modified, oc.Modified) {
+		fmt.Fprintf(b, "%smodified: %s\n", prefix, name)
+	}
+	for _, name := range diffStrings(rc.Removed, oc.Removed) {
+		fmt.Fprintf(b, "%sremoved: %s\n", prefix, name)
+	}
+
+	for _, reason := range unionKeys(r.SkipReasons, o.SkipReasons) {
+		counter("skip_reason "+reason, int64(r.SkipReasons[reason]), int64(o.SkipReasons[reason]))
+	}
+
+	for _, dir := range unionKeys(r.DirStats, o.DirStats) {
+		if a, c := r.DirStats[dir], o.DirStats[dir]; a != c {
+			fmt.Fprintf(b, "%sdir %s: %+v != %+v\n", prefix, dir, a, c)
+		}
+	}
+
+	for _, target := range unionKeys(r.PerTarget, o.PerTarget) {
+		a, c := r.PerTarget[target], o.PerTarget[target]
+		switch {
+		case a == nil:
+			fmt.Fprintf(b, "%starget %s: missing != present\n", prefix, target)
+		case c == nil:
+			fmt.Fprintf(b, "%starget %s: present != missing\n", prefix, target)
+		default:
+			a.diff(c, prefix+"target "+target+": ", b)
+		}
+	}
+}
+
+// diffMessages lists error messages present only on one side, as "-msg" (only in a)
+// and "+msg" (only in b), counting duplicates.
+func diffMessages(a, b []error) []string {
+	msgs := func(errs []error) []string {
+		out := make([]string, len(errs))
+		for i, err := range errs {
+			out[i] = err.Error()
+		}
+		return out
+	}
+	return diffStrings(msgs(a), msgs(b))
+}
+
+// diffStrings lists the strings present only in a, as "-s", or only in b, as "+s",
+// counting duplicates.
+func diffStrings(a, b []string) []string {
+	count := map[string]int{}
+	for _, s := range a {
+		count[s]++
+	}
+	for _, s := range b {
+		count[s]--
+	}
+	keys := make([]string, 0, len(count))
+	for s := range count {
+		keys = append(keys, s)
+	}
+	sort.Strings(keys)
+	var out []string
+	for _, s := range keys {
+		for n := count[s]; n > 0; n-- {
+			out = append(out, "-"+s)
+		}
+		for n := count[s]; n < 0; n++ {
+			out = append(out, "+"+s)
+		}
+	}
+	return out
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range []map[string]V{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
 	}
+	sort.Strings(keys)
+	return keys
 }
@@ -2,6 +2,12 @@ package sync
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"reflect"
+	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -44,3 +50,220 @@ func TestReportAddErr(t *testing.T) {
 		}
 	})
 }
+
+func TestReportAddErrBounded(t *testing.T) {
+	r := Report{maxErrors: 3}
+	for i := 0; i < 10; i++ {
+		r.addErr(errors.New("boom"))
+	}
+	r.addErr(nil)
+
+	if len(r.Errors) != 3 {
+		t.Fatalf("expected 3 stored errors, got %d", len(r.Errors))
+	}
+	if r.DroppedErrors != 7 {
+		t.Fatalf("expected 7 dropped errors, got %d", r.DroppedErrors)
+	}
+	if r.ErrorCount() != 10 {
+		t.Fatalf("expected total 10 errors, got %d", r.ErrorCount())
+	}
+}
+
+func TestReportErrorsByCategory(t *testing.T) {
+	r := Report{categorize: true}
+	errs := map[string][]error{
+		ErrCategoryPermission: {
+			&fs.PathError{Op: "open", Path: "a", Err: syscall.EACCES},
+			fmt.Errorf("chmod: %w", syscall.EPERM),
+		},
+		ErrCategorySpace: {
+			&fs.PathError{Op: "write", Path: "b", Err: syscall.ENOSPC},
+			fmt.Errorf("copy: %w", syscall.EDQUOT),
+		},
+		ErrCategoryIO: {
+			&fs.PathError{Op: "read", Path: "c", Err: syscall.EIO},
+			fmt.Errorf("copy: %w", io.ErrUnexpectedEOF),
+		},
+		ErrCategoryNotFound: {&fs.PathError{Op: "stat", Path: "d", Err: fs.ErrNotExist}},
+		ErrCategoryOther:    {errors.New("boom")},
+	}
+	for _, c := range []string{ErrCategoryPermission, ErrCategorySpace, ErrCategoryIO, ErrCategoryNotFound, ErrCategoryOther} {
+		for _, err := range errs[c] {
+			r.addErr(err)
+		}
+	}
+	if !reflect.DeepEqual(r.ErrorsByCategory, errs) {
+		t.Fatalf("got %v want %v", r.ErrorsByCategory, errs)
+	}
+	if got, want := r.ErrorCategoryCounts(), "io=2 not-found=1 other=1 permission=2 space=2"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+
+	var plain Report
+	plain.addErr(errors.New("boom"))
+	if plain.ErrorsByCategory != nil || plain.ErrorCategoryCounts() != "" {
+		t.Fatalf("uncategorized report has categories: %v", plain.ErrorsByCategory)
+	}
+}
+
+func TestReportCoalesceErrors(t *testing.T) {
+	feed := func(r *Report) {
+		for i := 0; i < 1000; i++ {
+			r.addErr(fmt.Errorf("copy f%d: %w", i%2, syscall.EROFS))
+		}
+		r.addErr(errors.New("other"))
+	}
+	want := []ErrorGroup{
+		{Message: "copy f0: read-only file system", Count: 500},
+		{Message: "copy f1: read-only file system", Count: 500},
+		{Message: "other", Count: 1},
+	}
+
+	r := newReport(Options{CoalesceErrors: true})
+	feed(r)
+	if !reflect.DeepEqual(r.ErrorSummary, want) {
+		t.Fatalf("got %+v want %+v", r.ErrorSummary, want)
+	}
+	if len(r.Errors) != 3 || r.ErrorCount() != 1001 {
+		t.Fatalf("expected 3 stored of 1001 errors, got %d of %d", len(r.Errors), r.ErrorCount())
+	}
+
+	all := newReport(Options{CoalesceErrors: true, TrackAllErrors: true})
+	feed(all)
+	if !reflect.DeepEqual(all.ErrorSummary, want) || len(all.Errors) != 1001 || all.DroppedErrors != 0 {
+		t.Fatalf("unexpected report: %d errors, %+v", len(all.Errors), all.ErrorSummary)
+	}
+
+	total := newReport(Options{CoalesceErrors: true})
+	total.merge(r)
+	total.merge(r)
+	if len(total.Errors) != 3 || total.ErrorCount() != 2002 || total.ErrorSummary[0].Count != 1000 {
+		t.Fatalf("unexpected merge: %d of %d errors, %+v", len(total.Errors), total.ErrorCount(), total.ErrorSummary)
+	}
+}
+
+func TestReportMergeDirStats(t *testing.T) {
+	total := &Report{}
+	total.merge(&Report{Copied: 1, DirStats: map[string]DirStat{"a": {Copied: 1, BytesCopied: 10}}})
+	total.merge(&Report{})
+	total.merge(&Report{Copied: 1, Deleted: 1, DirStats: map[string]DirStat{
+		"a": {Copied: 1, BytesCopied: 5},
+		"b": {Deleted: 1},
+	}})
+	if got := total.DirStats["a"]; got != (DirStat{Copied: 2, BytesCopied: 15}) {
+		t.Fatalf("a: %+v", got)
+	}
+	if got := total.DirStats["b"]; got != (DirStat{Deleted: 1}) {
+		t.Fatalf("b: %+v", got)
+	}
+}
+
+func TestReportMergeOrphans(t *testing.T) {
+	total := &Report{}
+	total.merge(&Report{Orphans: []string{"a"}})
+	total.merge(&Report{})
+	total.merge(&Report{Orphans: []string{"b", "c"}})
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(total.Orphans, want) {
+		t.Fatalf("orphans: got %q want %q", total.Orphans, want)
+	}
+}
+
+func TestReportEqualAndDiff(t *testing.T) {
+	base := func() *Report {
+		return &Report{
+			Copied: 2, Deleted: 1, BytesCopied: 10,
+			Errors:   []error{errors.New("open a: denied"), errors.New("open b: denied")},
+			DirStats: map[string]DirStat{"a": {Copied: 2, BytesCopied: 10}},
+		}
+	}
+
+	t.Run("equal", func(t *testing.T) {
+		a, b := base(), base()
+		// Error identity and order do not matter
+		b.Errors = []error{errors.New("open b: denied"), errors.New("open a: denied")}
+		if !a.Equal(b) || a.Diff(b) != "" {
+			t.Fatalf("expected equal, diff:\n%s", a.Diff(b))
+		}
+		if !(&Report{}).Equal(&Report{}) {
+			t.Fatalf("empty reports must be equal")
+		}
+	})
+
+	t.Run("count mismatch", func(t *testing.T) {
+		a, b := base(), base()
+		b.Copied = 3
+		b.BytesCopied = 12
+		b.DirStats["a"] = DirStat{Copied: 3, BytesCopied: 12}
+		if a.Equal(b) {
+			t.Fatalf("expected different")
+		}
+		diff := a.Diff(b)
+		for _, want := range []string{"copied: 2 != 3", "bytes_copied: 10 != 12", "dir a: "} {
+			if !strings.Contains(diff, want) {
+				t.Fatalf("expected %q in diff:\n%s", want, diff)
+			}
+		}
+	})
+
+	t.Run("error messages", func(t *testing.T) {
+		a, b := base(), base()
+		b.Errors[1] = errors.New("open c: denied")
+		diff := a.Diff(b)
+		if a.Equal(b) || strings.Contains(diff, "errors:") {
+			t.Fatalf("expected message-only difference, got:\n%s", diff)
+		}
+		for _, want := range []string{"error: -open b: denied", "error: +open c: denied"} {
+			if !strings.Contains(diff, want) {
+				t.Fatalf("expected %q in diff:\n%s", want, diff)
+			}
+		}
+
+		b = base()
+		b.Errors = b.Errors[:1]
+		if diff := a.Diff(b); !strings.Contains(diff, "errors: 2 != 1") {
+			t.Fatalf("expected error count difference, got:\n%s", diff)
+		}
+	})
+
+	t.Run("orphans and changes", func(t *testing.T) {
+		a, b := base(), base()
+		a.Orphans, b.Orphans = []string{"x", "y"}, []string{"y", "x"}
+		a.Changes = &Changes{Added: []string{"n"}}
+		b.Changes = &Changes{Added: []string{"n"}}
+		if !a.Equal(b) {
+			t.Fatalf("expected equal, diff:\n%s", a.Diff(b))
+		}
+		b.Orphans = []string{"y", "z"}
+		b.Changes = &Changes{Removed: []string{"n"}}
+		diff := a.Diff(b)
+		for _, want := range []string{"orphan: -x", "orphan: +z", "added: -n", "removed: +n"} {
+			if !strings.Contains(diff, want) {
+				t.Fatalf("expected %q in diff:\n%s", want, diff)
+			}
+		}
+		if diff := base().Diff(a); !strings.Contains(diff, "added: +n") {
+			t.Fatalf("nil Changes must differ from added files, got:\n%s", diff)
+		}
+	})
+
+	t.Run("per target", func(t *testing.T) {
+		a := &Report{PerTarget: map[string]*Report{"x": {Copied: 1}, "y": {}}}
+		b := &Report{PerTarget: map[string]*Report{"x": {Copied: 2}}}
+		diff := a.Diff(b)
+		for _, want := range []string{"target x: copied: 1 != 2", "target y: present != missing"} {
+			if !strings.Contains(diff, want) {
+				t.Fatalf("expected %q in diff:\n%s", want, diff)
+			}
+		}
+	})
+}
+
+func TestReportEqualAcrossRuns(t *testing.T) {
+	src := t.TempDir()
+	seedTree(t, src, 20)
+	baseline := Sync(Options{Source: src, Target: t.TempDir()})
+	again := Sync(Options{Source: src, Target: t.TempDir()})
+	if !baseline.Equal(again) {
+		t.Fatalf("identical runs differ:\n%s", baseline.Diff(again))
+	}
+}
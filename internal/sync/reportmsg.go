@@ -0,0 +1,242 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ReportMessage is the stable serialization schema of a Report, for consumers of JSON or
+// MessagePack reports. Field names (the json tags, also used as MessagePack map keys) are never
+// renamed or reused; new fields may be added, so decoders should ignore unknown keys.
+// Errors are carried as their messages.
+type ReportMessage struct {
+	Copied             int64                    `json:"copied"`
+	Overwritten        int64                    `json:"overwritten"`
+	Deleted            int64                    `json:"deleted"`
+	Skipped            int64                    `json:"skipped"`
+	BytesCopied        int64                    `json:"bytes_copied"`
+	BytesAdded         int64                    `json:"bytes_added"`
+	BytesRemoved       int64                    `json:"bytes_removed"`
+	SkippedSubtrees    int64                    `json:"skipped_subtrees"`
+	CleanedTemps       int64                    `json:"cleaned_temps"`
+	ACLFailures        int64                    `json:"acl_failures"`
+	OwnerFailures      int64                    `json:"owner_failures"`
+	SkippedLocked      int64                    `json:"skipped_locked"`
+	SkippedTooLong     int64                    `json:"skipped_too_long"`
+	SkippedImmutable   int64                    `json:"skipped_immutable"`
+	ModifiedDuringCopy int64                    `json:"modified_during_copy"`
+	DirsCreated        int64                    `json:"dirs_created"`
+	Appended           int64                    `json:"appended"`
+	Recased            int64                    `json:"recased"`
+	SpotCheckCaught    int64                    `json:"spot_check_caught"`
+	Conflicts          int64                    `json:"conflicts"`
+	PermsFixed         int64                    `json:"perms_fixed"`
+	Pruned             int64                    `json:"pruned"`
+	PolicyFixed        int64                    `json:"policy_fixed"`
+	Passes             int64                    `json:"passes"`
+	DroppedErrors      int64                    `json:"dropped_errors"`
+	Errors             []string                 `json:"errors"`
+	Orphans            []string                 `json:"orphans,omitempty"`
+	SkipReasons        map[string]int64         `json:"skip_reasons,omitempty"`
+	PerTarget          map[string]ReportMessage `json:"per_target,omitempty"`
+}
+
+// Message converts r into its serialization schema.
+func (r *Report) Message() ReportMessage {
+	m := ReportMessage{
+		Copied:             int64(r.Copied),
+		Overwritten:        int64(r.Overwritten),
+		Deleted:            int64(r.Deleted),
+		Skipped:            int64(r.Skipped),
+		BytesCopied:        r.BytesCopied,
+		BytesAdded:         r.BytesAdded,
+		BytesRemoved:       r.BytesRemoved,
+		SkippedSubtrees:    int64(r.SkippedSubtrees),
+		CleanedTemps:       int64(r.CleanedTemps),
+		ACLFailures:        int64(r.ACLFailures),
+		OwnerFailures:      int64(r.OwnerFailures),
+		SkippedLocked:      int64(r.SkippedLocked),
+		SkippedTooLong:     int64(r.SkippedTooLong),
+		SkippedImmutable:   int64(r.SkippedImmutable),
+		ModifiedDuringCopy: int64(r.ModifiedDuringCopy),
+		DirsCreated:        int64(r.DirsCreated),
+		Appended:           int64(r.Appended),
+		Recased:            int64(r.Recased),
+		SpotCheckCaught:    int64(r.SpotCheckCaught),
+		Conflicts:          int64(r.Conflicts),
+		PermsFixed:         int64(r.PermsFixed),
+		Pruned:             int64(r.Pruned),
+		PolicyFixed:        int64(r.PolicyFixed),
+		Passes:             int64(r.Passes),
+		DroppedErrors:      int64(r.DroppedErrors),
+		Errors:             make([]string, len(r.Errors)),
+	}
+	for i, err := range r.Errors {
+		m.Errors[i] = err.Error()
+	}
+	if r.Orphans != nil {
+		m.Orphans = append([]string{}, r.Orphans...)
+	}
+	for reason, n := range r.SkipReasons {
+		if m.SkipReasons == nil {
+			m.SkipReasons = map[string]int64{}
+		}
+		m.SkipReasons[reason] = int64(n)
+	}
+	for target, rep := range r.PerTarget {
+		if m.PerTarget == nil {
+			m.PerTarget = map[string]ReportMessage{}
+		}
+		m.PerTarget[target] = rep.Message()
+	}
+	return m
+}
+
+// counters lists the integer fields of m under their schema names, in schema order.
+func (m *ReportMessage) counters() []struct {
+	name string
+	v    *int64
+} {
+	return []struct {
+		name string
+		v    *int64
+	}{
+		{"copied", &m.Copied},
+		{"overwritten", &m.Overwritten},
+		{"deleted", &m.Deleted},
+		{"skipped", &m.Skipped},
+		{"bytes_copied", &m.BytesCopied},
+		{"bytes_added", &m.BytesAdded},
+		{"bytes_removed", &m.BytesRemoved},
+		{"skipped_subtrees", &m.SkippedSubtrees},
+		{"cleaned_temps", &m.CleanedTemps},
+		{"acl_failures", &m.ACLFailures},
+		{"owner_failures", &m.OwnerFailures},
+		{"skipped_locked", &m.SkippedLocked},
+		{"skipped_too_long", &m.SkippedTooLong},
+		{"skipped_immutable", &m.SkippedImmutable},
+		{"modified_during_copy", &m.ModifiedDuringCopy},
+		{"dirs_created", &m.DirsCreated},
+		{"appended", &m.Appended},
+		{"recased", &m.Recased},
+		{"spot_check_caught", &m.SpotCheckCaught},
+		{"conflicts", &m.Conflicts},
+		{"perms_fixed", &m.PermsFixed},
+		{"pruned", &m.Pruned},
+		{"policy_fixed", &m.PolicyFixed},
+		{"passes", &m.Passes},
+		{"dropped_errors", &m.DroppedErrors},
+	}
+}
+
+// MarshalMsgpack encodes m as a MessagePack map keyed by the schema names.
+// Map entries (skip reasons, per-target reports) are written sorted by key.
+func (m ReportMessage) MarshalMsgpack() ([]byte, error) {
+	var w msgpackWriter
+	m.encode(&w)
+	return w.b, nil
+}
+
+func (m *ReportMessage) encode(w *msgpackWriter) {
+	counters := m.counters()
+	n := len(counters) + 1
+	if m.Orphans != nil {
+		n++
+	}
+	if m.SkipReasons != nil {
+		n++
+	}
+	if m.PerTarget != nil {
+		n++
+	}
+	w.mapHeader(n)
+	for _, c := range counters {
+		w.str(c.name)
+		w.int(*c.v)
+	}
+	w.str("errors")
+	w.arrayHeader(len(m.Errors))
+	for _, msg := range m.Errors {
+		w.str(msg)
+	}
+	if m.Orphans != nil {
+		w.str("orphans")
+		w.arrayHeader(len(m.Orphans))
+		for _, name := range m.Orphans {
+			w.str(name)
+		}
+	}
+	if m.SkipReasons != nil {
+		w.str("skip_reasons")
+		w.mapHeader(len(m.SkipReasons))
+		for _, k := range unionKeys(m.SkipReasons, nil) {
+			w.str(k)
+			w.int(m.SkipReasons[k])
+		}
+	}
+	if m.PerTarget != nil {
+		w.str("per_target")
+		w.mapHeader(len(m.PerTarget))
+		for _, k := range unionKeys(m.PerTarget, nil) {
+			w.str(k)
+			t := m.PerTarget[k]
+			t.encode(w)
+		}
+	}
+}
+
+// UnmarshalMsgpack decodes a MessagePack map written by MarshalMsgpack into m.
+// Unknown keys are skipped; missing ones leave their field unchanged.
+func (m *ReportMessage) UnmarshalMsgpack(b []byte) error {
+	r := msgpackReader{b: b}
+	m.decode(&r)
+	if r.err == nil && len(r.b) > 0 {
+		r.err = errors.New("trailing data")
+	}
+	if r.err != nil {
+		return fmt.Errorf("decode report: %w", r.err)
+	}
+	return nil
+}
+
+func (m *ReportMessage) decode(r *msgpackReader) {
+	counters := map[string]*int64{}
+	for _, c := range m.counters() {
+		counters[c.name] = c.v
+	}
+	for n := r.mapHeader(); n > 0 && r.err == nil; n-- {
+		key := r.str()
+		if v, ok := counters[key]; ok {
+			*v = r.int()
+			continue
+		}
+		switch key {
+		case "errors":
+			m.Errors = []string{}
+			for n := r.arrayHeader(); n > 0 && r.err == nil; n-- {
+				m.Errors = append(m.Errors, r.str())
+			}
+		case "orphans":
+			m.Orphans = []string{}
+			for n := r.arrayHeader(); n > 0 && r.err == nil; n-- {
+				m.Orphans = append(m.Orphans, r.str())
+			}
+		case "skip_reasons":
+			m.SkipReasons = map[string]int64{}
+			for n := r.mapHeader(); n > 0 && r.err == nil; n-- {
+				k := r.str()
+				m.SkipReasons[k] = r.int()
+			}
+		case "per_target":
+			m.PerTarget = map[string]ReportMessage{}
+			for n := r.mapHeader(); n > 0 && r.err == nil; n-- {
+				k := r.str()
+				var t ReportMessage
+				t.decode(r)
+				m.PerTarget[k] = t
+			}
+		default:
+			r.skip()
+		}
+	}
+}
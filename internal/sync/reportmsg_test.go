@@ -0,0 +1,88 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReportMessageMsgpackRoundTrip(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	target := &Report{Copied: 2, BytesCopied: 1 << 40, Errors: []error{errors.New("copy a: boom")}}
+	rep := &Report{
+		Copied:        3,
+		Overwritten:   200,
+		Deleted:       70000,
+		Skipped:       -1,
+		BytesCopied:   5_000_000_000,
+		PermsFixed:    31,
+		DroppedErrors: 4,
+		SkipReasons:   map[string]int{SkipIdentical: 5, SkipHiddenFile: 1},
+		Orphans:       []string{"old/a.txt", "b.txt"},
+		Errors: []error{
+			fmt.Errorf("open %s: %w", long, errors.New("permission denied")),
+			errors.New(""),
+			errors.New("ünïcode ✓"),
+		},
+		PerTarget: map[string]*Report{"/dst/a": target, "/dst/b": {}},
+	}
+	want := rep.Message()
+
+	b, err := want.MarshalMsgpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got ReportMessage
+	if err := got.UnmarshalMsgpack(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip changed the report:\ngot  %+v\nwant %+v", got, want)
+	}
+	if got.Errors[0] != rep.Errors[0].Error() || got.PerTarget["/dst/a"].Errors[0] != "copy a: boom" {
+		t.Fatalf("error messages lost: %q", got.Errors)
+	}
+
+	if err := got.UnmarshalMsgpack(b[:len(b)-1]); err == nil {
+		t.Fatal("expected an error for truncated data")
+	}
+}
+
+func TestReportMessageMsgpackSkipsUnknownKeys(t *testing.T) {
+	var w msgpackWriter
+	w.mapHeader(4)
+	w.str("future_list")
+	w.arrayHeader(2)
+	w.str("a")
+	w.mapHeader(1)
+	w.str("k")
+	w.int(-200)
+	w.str("copied")
+	// uint16, as other encoders may write it
+	w.b = append(w.b, 0xcd, 0x01, 0x00)
+	w.str("future_flag")
+	w.b = append(w.b, 0xc3)
+	w.str("errors")
+	w.arrayHeader(0)
+
+	var m ReportMessage
+	if err := m.UnmarshalMsgpack(w.b); err != nil {
+		t.Fatal(err)
+	}
+	if m.Copied != 256 || m.Errors == nil || len(m.Errors) != 0 {
+		t.Fatalf("unexpected message: %+v", m)
+	}
+}
+
+func TestReportMessageJSON(t *testing.T) {
+	b, err := json.Marshal((&Report{Copied: 1, Errors: []error{errors.New("boom")}}).Message())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); !strings.Contains(s, `"copied":1,`) || !strings.Contains(s, `"errors":["boom"]`) || strings.Contains(s, "per_target") {
+		t.Fatalf("unexpected JSON: %s", s)
+	}
+}
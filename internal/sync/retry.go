@@ -0,0 +1,70 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// defaultRetryWholeSyncDelay is the wait before each extra pass when Options.RetryWholeSyncDelay is unset.
+const defaultRetryWholeSyncDelay = 10 * time.Second
+
+// retryWholeSync re-runs a sync that ended with errors up to Options.RetryWholeSync times
+// and returns the report of the last pass, with Report.Passes set.
+func retryWholeSync(opt Options, pass func(Options) *Report) *Report {
+	rep := pass(opt)
+	if opt.RetryWholeSync <= 0 {
+		return rep
+	}
+	logger := opt.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	delay := opt.RetryWholeSyncDelay
+	if delay <= 0 {
+		delay = defaultRetryWholeSyncDelay
+	}
+	n := 1
+	for ; n <= opt.RetryWholeSync && rep.ErrorCount() > 0 && wholeSyncRetryable(rep) && (opt.ctx == nil || opt.ctx.Err() == nil); n++ {
+		logger.Printf("RETRY: pass %d ended with %d errors; running pass %d of %d in %s",
+			n, rep.ErrorCount(), n+1, opt.RetryWholeSync+1, delay)
+		if !sleepCtx(opt, delay) {
+			// Cancelled while waiting: the last pass stands
+			err := fmt.Errorf("run cancelled: %w", opt.ctx.Err())
+			logger.Printf("ERR: %v", err)
+			rep.addErr(err)
+			break
+		}
+		rep = pass(opt)
+	}
+	rep.Passes = n
+	return rep
+}
+
+// sleepCtx waits for d, reporting false if the context of Service.Sync is done first.
+func sleepCtx(opt Options, d time.Duration) bool {
+	if opt.ctx == nil {
+		time.Sleep(d)
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-opt.ctx.Done():
+		return false
+	}
+}
+
+// wholeSyncRetryable reports whether another pass may resolve the errors of rep:
+// one after an exhausted MaxTotalBytes budget would only copy more.
+func wholeSyncRetryable(rep *Report) bool {
+	for _, err := range rep.Errors {
+		if errors.Is(err, ErrByteBudgetExhausted) {
+			return false
+		}
+	}
+	return true
+}
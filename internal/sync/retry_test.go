@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryWholeSync(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+	mustWrite(t, filepath.Join(src, "b.txt"), "b")
+
+	// b.txt fails once, as on a flaky mount
+	failed := false
+	flaky := func(rel string, r io.Reader) error {
+		if rel == "b.txt" && !failed {
+			failed = true
+			return errors.New("transient read error")
+		}
+		return nil
+	}
+	opt := Options{Source: src, Target: dst, ContentValidator: flaky, RetryWholeSync: 2, RetryWholeSyncDelay: time.Millisecond}
+	rep := Sync(opt)
+	if len(rep.Errors) != 0 || rep.Passes != 2 || rep.Copied != 1 || rep.Skipped != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if got, want := treeContents(t, dst), map[string]string{"a.txt": "a", "b.txt": "b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+
+	// A clean first pass is not repeated
+	if rep := Sync(opt); len(rep.Errors) != 0 || rep.Passes != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+
+	// Persistent failures give up after RetryWholeSync extra passes
+	opt.ContentValidator = func(string, io.Reader) error { return errors.New("permanent") }
+	mustWrite(t, filepath.Join(src, "c.txt"), "c")
+	if rep := Sync(opt); len(rep.Errors) != 1 || rep.Passes != 3 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+}
+
+func TestRetryWholeSyncTargets(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+
+	// Each target is retried by the outer passes only, not again inside each pass
+	var calls atomic.Int32
+	failing := func(string, io.Reader) error {
+		calls.Add(1)
+		return errors.New("permanent")
+	}
+	opt := Options{Source: src, Targets: []string{t.TempDir(), t.TempDir()}, ContentValidator: failing,
+		RetryWholeSync: 1, RetryWholeSyncDelay: time.Millisecond}
+	rep := Sync(opt)
+	if rep.Passes != 2 || len(rep.Errors) != 2 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if n := calls.Load(); n != 4 {
+		t.Fatalf("a.txt was validated %d times, want 2 targets x 2 passes", n)
+	}
+}
+
+func TestRetryWholeSyncCancelledWhileWaiting(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+
+	// Cancelling during the delay ends the run without another pass
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	failing := func(string, io.Reader) error { return errors.New("permanent") }
+	start := time.Now()
+	rep, err := NewService().Sync(ctx, Options{Source: src, Target: dst, ContentValidator: failing, RetryWholeSync: 3, RetryWholeSyncDelay: time.Hour})
+	if !errors.Is(err, context.DeadlineExceeded) || rep.Passes != 1 || len(rep.Errors) != 2 {
+		t.Fatalf("unexpected result: %+v, %v", rep, err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("run waited %s after cancellation", elapsed)
+	}
+}
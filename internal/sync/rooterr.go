@@ -0,0 +1,50 @@
+package sync
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// RootError is recorded when the source or target root cannot be read any more during a walk,
+// e.g. because it was unmounted. The run is aborted: no further files are copied and nothing is
+// deleted, as an unreadable source would look like one without files.
+type RootError struct {
+	// Kind is "source" or "target".
+	Kind string
+	Root string
+	Err  error
+}
+
+func (e *RootError) Error() string {
+	return fmt.Sprintf("%s directory %s became inaccessible during sync; aborting: %v", e.Kind, e.Root, e.Err)
+}
+
+func (e *RootError) Unwrap() error {
+	return e.Err
+}
+
+// checkRoot is called for a walk error at rel in fsys. It returns a *RootError when the error
+// is on the root itself or the root cannot be stat-ed any more, and nil for a local failure.
+func checkRoot(fsys fs.FS, kind, root, rel string, err error) *RootError {
+	if rel != "." {
+		_, statErr := fs.Stat(fsys, ".")
+		if statErr == nil {
+			return nil
+		}
+		err = statErr
+	}
+	return &RootError{Kind: kind, Root: root, Err: err}
+}
+
+// abortOnRoot records a *RootError for the walk error at rel, if it is one, and marks the run
+// aborted. It reports whether the walk must stop.
+func (r *runner) abortOnRoot(fsys fs.FS, kind, root, rel string, err error) bool {
+	rootErr := checkRoot(fsys, kind, root, rel, err)
+	if rootErr == nil {
+		return false
+	}
+	r.opt.Logger.Printf("ERR: %v", rootErr)
+	r.rep.addErr(rootErr)
+	r.aborted = true
+	return true
+}
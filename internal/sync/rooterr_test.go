@@ -0,0 +1,101 @@
+package sync
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// goneSrcFS loses its root, as on an unmount, when the directory vanish is read.
+type goneSrcFS struct {
+	fstest.MapFS
+	vanish string
+	gone   *bool
+}
+
+func (f goneSrcFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == f.vanish {
+		*f.gone = true
+	}
+	if *f.gone {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("transport endpoint is not connected")}
+	}
+	return f.MapFS.ReadDir(name)
+}
+
+func (f goneSrcFS) Stat(name string) (fs.FileInfo, error) {
+	if *f.gone {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: errors.New("transport endpoint is not connected")}
+	}
+	return f.MapFS.Stat(name)
+}
+
+// goneDstFS is a memFS that loses its root when the directory vanish is read.
+type goneDstFS struct {
+	*memFS
+	vanish string
+	gone   bool
+}
+
+func (f *goneDstFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == f.vanish {
+		f.gone = true
+	}
+	if f.gone {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("stale file handle")}
+	}
+	return f.memFS.ReadDir(name)
+}
+
+func (f *goneDstFS) Stat(name string) (fs.FileInfo, error) {
+	if f.gone {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: errors.New("stale file handle")}
+	}
+	return f.memFS.Stat(name)
+}
+
+func TestSourceRootGoneAborts(t *testing.T) {
+	gone := false
+	src := goneSrcFS{MapFS: fstest.MapFS{
+		"a/1.txt": {Data: []byte("1")},
+		"b/2.txt": {Data: []byte("2")},
+		"z.txt":   {Data: []byte("z")},
+	}, vanish: "b", gone: &gone}
+	dst := newMemFS()
+	dst.MapFS["orphan.txt"] = &fstest.MapFile{Data: []byte("keep")}
+
+	rep := SyncFS(src, dst, Options{DeleteMissing: true})
+	var rootErr *RootError
+	if len(rep.Errors) != 1 || !errors.As(rep.Errors[0], &rootErr) || rootErr.Kind != "source" {
+		t.Fatalf("expected a single source RootError, got %v", rep.Errors)
+	}
+	if rep.Copied != 1 || rep.Deleted != 0 {
+		t.Fatalf("walk not stopped cleanly: %+v", *rep)
+	}
+	if _, ok := dst.MapFS["z.txt"]; ok {
+		t.Fatal("z.txt copied after the source root was lost")
+	}
+	if _, ok := dst.MapFS["orphan.txt"]; !ok {
+		t.Fatal("orphan.txt deleted although the source root was lost")
+	}
+}
+
+func TestTargetRootGoneAborts(t *testing.T) {
+	src := fstest.MapFS{"keep.txt": {Data: []byte("k")}}
+	mem := newMemFS()
+	mem.MapFS["keep.txt"] = &fstest.MapFile{Data: []byte("k")}
+	for _, name := range []string{"a/orphan.txt", "sub/orphan.txt", "z.txt"} {
+		mem.MapFS[name] = &fstest.MapFile{Data: []byte("x")}
+	}
+	dst := &goneDstFS{memFS: mem, vanish: "sub"}
+
+	rep := SyncFS(src, dst, Options{DeleteMissing: true, MaxDeletes: 10})
+	var rootErr *RootError
+	if len(rep.Errors) != 1 || !errors.As(rep.Errors[0], &rootErr) || rootErr.Kind != "target" {
+		t.Fatalf("expected a single target RootError, got %v", rep.Errors)
+	}
+	if rep.Deleted != 0 || len(mem.MapFS) != 4 {
+		t.Fatalf("files deleted after the target root was lost: %+v", *rep)
+	}
+}
@@ -0,0 +1,44 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkSameRoots refuses to run when a source directory and the target are the same directory
+// under different paths (a bind mount, a symlink or a hard-linked alias), which comparing the
+// paths does not catch: every file would be compared with itself, and a delete pass or a
+// replacing write could destroy the source. Directories are identified by device and inode,
+// so the check is a no-op on platforms without them. It returns false if the run must stop;
+// the error has been recorded.
+func (r *runner) checkSameRoots() bool {
+	if r.dstRoot == "" || ArchiveTargetKind(r.dstRoot) != NotArchive {
+		return true
+	}
+	dst, err := os.Stat(r.dstRoot)
+	if err != nil {
+		// A missing target is created as usual
+		return true
+	}
+	dstDev, dstIno, ok := fileID(dst)
+	if !ok {
+		return true
+	}
+	for _, src := range r.sources {
+		if src.root == "" || DetectArchive(src.root) != NotArchive {
+			continue
+		}
+		info, err := os.Stat(src.root)
+		if err != nil {
+			continue
+		}
+		if dev, ino, ok := fileID(info); ok && dev == dstDev && ino == dstIno {
+			err := fmt.Errorf("source %s and target %s are the same directory (device %d, inode %d)",
+				src.root, r.dstRoot, dev, ino)
+			r.opt.Logger.Printf("ERR: %v", err)
+			r.rep.addErr(err)
+			return false
+		}
+	}
+	return true
+}
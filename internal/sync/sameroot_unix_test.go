@@ -0,0 +1,34 @@
+//go:build unix
+
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSameRootsRejected(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+	// A different path to the same directory, as a bind mount would be
+	alias := filepath.Join(t.TempDir(), "alias")
+	if err := os.Symlink(src, alias); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, opt := range map[string]Options{
+		"target":        {Source: src, Target: alias, DeleteMissing: true},
+		"second source": {Sources: []string{t.TempDir(), alias}, Target: src, DeleteMissing: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rep := Sync(opt)
+			if len(rep.Errors) != 1 || rep.Copied+rep.Overwritten+rep.Deleted+rep.Skipped != 0 {
+				t.Fatalf("expected the run to be refused: %+v", *rep)
+			}
+			if _, err := os.Stat(filepath.Join(src, "a.txt")); err != nil {
+				t.Fatalf("source file lost: %v", err)
+			}
+		})
+	}
+}
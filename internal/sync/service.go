@@ -0,0 +1,56 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+)
+
+// Service runs synchronizations for the commands of this module, returning the report and an
+// error. Options and Report are its contract: their fields keep their meaning across releases,
+// and new fields default to the previous behavior.
+type Service interface {
+	// Sync runs a synchronization like the package-level Sync (or SyncFS, see NewFSService) and
+	// returns its report along with Report.Err. Once ctx is done, the run copies and deletes
+	// nothing more (the file being copied is completed) and records the context's error.
+	Sync(ctx context.Context, opt Options) (*Report, error)
+}
+
+// NewService returns a Service syncing the OS directories named in Options.
+func NewService() Service {
+	return service{run: Sync}
+}
+
+// NewFSService returns a Service syncing src into dst, ignoring Options.Source and Options.Target.
+func NewFSService(src fs.FS, dst WritableFS) Service {
+	return service{run: func(opt Options) *Report { return SyncFS(src, dst, opt) }}
+}
+
+type service struct {
+	run func(Options) *Report
+}
+
+func (s service) Sync(ctx context.Context, opt Options) (*Report, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	opt.ctx = ctx
+	rep := s.run(opt)
+	return rep, rep.Err()
+}
+
+// stopped reports whether the context of Service.Sync is done, aborting the run and recording
+// the context's error the first time.
+func (r *runner) stopped() bool {
+	if r.cancelled {
+		return true
+	}
+	if r.opt.ctx == nil || r.opt.ctx.Err() == nil {
+		return false
+	}
+	r.aborted, r.cancelled = true, true
+	err := fmt.Errorf("run cancelled: %w", r.opt.ctx.Err())
+	r.opt.Logger.Printf("ERR: %v", err)
+	r.rep.addErr(err)
+	return true
+}
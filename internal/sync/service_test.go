@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestService(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+
+	svc := NewService()
+	rep, err := svc.Sync(context.Background(), Options{Source: src, Target: dst})
+	if err != nil || rep.Copied != 1 {
+		t.Fatalf("unexpected result: %+v, %v", rep, err)
+	}
+
+	rep, err = svc.Sync(context.Background(), Options{Source: filepath.Join(src, "missing"), Target: dst})
+	if err == nil || len(rep.Errors) == 0 || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected an error, got %+v, %v", rep, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if rep, err := svc.Sync(ctx, Options{Source: src, Target: dst}); rep != nil || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %+v, %v", rep, err)
+	}
+}
+
+func TestServiceCancelDuringRun(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		mustWrite(t, filepath.Join(src, name), name)
+	}
+	mustWrite(t, filepath.Join(dst, "orphan.txt"), "orphan")
+
+	// The first copied file cancels the run: it is completed, nothing else is copied or deleted
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	validate := func(string, io.Reader) error {
+		cancel()
+		return nil
+	}
+	rep, err := NewService().Sync(ctx, Options{Source: src, Target: dst, DeleteMissing: true, ContentValidator: validate})
+	if !errors.Is(err, context.Canceled) || rep.Copied != 1 || rep.Deleted != 0 || len(rep.Errors) != 1 {
+		t.Fatalf("unexpected result: %+v, %v", rep, err)
+	}
+	if got := treeContents(t, dst); len(got) != 2 || got["orphan.txt"] != "orphan" {
+		t.Fatalf("target: %v", got)
+	}
+}
+
+func TestFSService(t *testing.T) {
+	src := fstest.MapFS{"a.txt": {Data: []byte("a")}}
+	dst := newMemFS()
+	rep, err := NewFSService(src, dst).Sync(context.Background(), Options{})
+	if err != nil || rep.Copied != 1 {
+		t.Fatalf("unexpected result: %+v, %v", rep, err)
+	}
+}
+
+func TestReportErr(t *testing.T) {
+	rep := &Report{}
+	if err := rep.Err(); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	rep.addErr(fs.ErrPermission)
+	rep.DroppedErrors = 2
+	err := rep.Err()
+	if !errors.Is(err, fs.ErrPermission) || err.Error() != "permission denied\n2 more errors not stored" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package sync
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// sidecarSkip reports why the source entry rel is skipped under Options.SidecarIgnoreSuffix:
+// it is an ignore marker itself, or a sibling marker names it. It returns "" otherwise.
+func (r *runner) sidecarSkip(rel string) string {
+	suffix := r.opt.SidecarIgnoreSuffix
+	if suffix == "" {
+		return ""
+	}
+	if strings.HasSuffix(rel, suffix) {
+		return "ignore marker"
+	}
+	if _, err := fs.Stat(r.src, rel+suffix); err == nil {
+		return "ignored by " + rel + suffix
+	}
+	return ""
+}
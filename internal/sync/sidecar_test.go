@@ -0,0 +1,28 @@
+package sync
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSidecarIgnoreSuffix(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "keep.txt"), "keep")
+	mustWrite(t, filepath.Join(src, "secret.txt"), "secret")
+	mustWrite(t, filepath.Join(src, "secret.txt.sync-ignore"), "")
+	mustWrite(t, filepath.Join(src, "cache", "big.bin"), "big")
+	mustWrite(t, filepath.Join(src, "cache.sync-ignore"), "")
+
+	rep := Sync(Options{Source: src, Target: dst, SidecarIgnoreSuffix: ".sync-ignore", CollectSkipReasons: true})
+	if len(rep.Errors) != 0 || rep.Copied != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	// secret.txt and both markers
+	if rep.Skipped != 3 || rep.SkipReasons[SkipSidecarIgnore] != 3 {
+		t.Fatalf("unexpected skips: %+v", *rep)
+	}
+	if got, want := treeContents(t, dst), map[string]string{"keep.txt": "keep"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,51 @@
+package sync
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestCompareSizeOnly(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+	src := fstest.MapFS{
+		// Same size, different content and mod-time: left alone.
+		"same_size_newer.txt": {Data: []byte("AAAA"), ModTime: recent},
+		"same_size_older.txt": {Data: []byte("BBBB"), ModTime: old},
+		// Different size, identical mod-time: always overwritten.
+		"grown.log":  {Data: []byte("line1\nline2\n"), ModTime: old},
+		"shrunk.log": {Data: []byte("x"), ModTime: old},
+	}
+	dst := newMemFS()
+	dst.MapFS["same_size_newer.txt"] = &fstest.MapFile{Data: []byte("aaaa"), ModTime: old}
+	dst.MapFS["same_size_older.txt"] = &fstest.MapFile{Data: []byte("bbbb"), ModTime: recent}
+	dst.MapFS["grown.log"] = &fstest.MapFile{Data: []byte("line1\n"), ModTime: old}
+	dst.MapFS["shrunk.log"] = &fstest.MapFile{Data: []byte("xyz"), ModTime: old}
+
+	// Content is never read for same-size files: opening them would fail.
+	guarded := failOpenFS{FS: src, fail: "same_size_newer.txt"}
+	for _, ignoreModTime := range []bool{false, true} {
+		rep := SyncFS(guarded, dst, Options{CompareSizeOnly: true, IgnoreModTime: ignoreModTime})
+		if len(rep.Errors) != 0 {
+			t.Fatalf("ignoreModTime=%v: unexpected errors: %v", ignoreModTime, rep.Errors)
+		}
+		want := map[string]string{
+			"same_size_newer.txt": "aaaa",
+			"same_size_older.txt": "bbbb",
+			"grown.log":           "line1\nline2\n",
+			"shrunk.log":          "x",
+		}
+		for name, data := range want {
+			if got := string(dst.MapFS[name].Data); got != data {
+				t.Fatalf("ignoreModTime=%v: %s = %q, want %q", ignoreModTime, name, got, data)
+			}
+		}
+		if !ignoreModTime && (rep.Overwritten != 2 || rep.Skipped != 2) {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if ignoreModTime && (rep.Overwritten != 0 || rep.Skipped != 4) {
+			t.Fatalf("expected all skipped on second run, got %+v", *rep)
+		}
+	}
+}
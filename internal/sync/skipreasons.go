@@ -0,0 +1,36 @@
+package sync
+
+// Skip reasons, the keys of Report.SkipReasons.
+const (
+	SkipIdentical      = "identical"
+	SkipHiddenFile     = "hidden"
+	SkipExcluded       = "excluded"
+	SkipNotRegular     = "not-regular"
+	SkipInvalidName    = "invalid-name"
+	SkipSourceConflict = "source-conflict"
+	SkipLocked         = "locked"
+	SkipPathTooLong    = "path-too-long"
+	SkipTargetVersion  = "target-version"
+	SkipMissingTarget  = "missing-target"
+	SkipImmutable      = "immutable"
+	SkipSidecarIgnore  = "sidecar-ignore"
+)
+
+// skip counts the file rel skipped for reason in Report.Skipped.
+func (r *runner) skip(rel, reason string) {
+	r.rep.Skipped++
+	r.countSkipReason(rel, reason)
+}
+
+// countSkipReason tallies a skipped file in Report.SkipReasons when Options.CollectSkipReasons is set,
+// and adds its row to the CSV report.
+func (r *runner) countSkipReason(rel, reason string) {
+	r.record(CSVSkip, rel, 0, nil)
+	if !r.opt.CollectSkipReasons {
+		return
+	}
+	if r.rep.SkipReasons == nil {
+		r.rep.SkipReasons = map[string]int{}
+	}
+	r.rep.SkipReasons[reason]++
+}
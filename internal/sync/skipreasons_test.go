@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSkipReasons(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	writeWithModTime(t, filepath.Join(src, "same1.txt"), "s", 0o644, old)
+	writeWithModTime(t, filepath.Join(src, "same2.txt"), "s", 0o644, old)
+	writeWithModTime(t, filepath.Join(dst, "same1.txt"), "s", 0o644, old)
+	writeWithModTime(t, filepath.Join(dst, "same2.txt"), "s", 0o644, old)
+	mustWrite(t, filepath.Join(src, ".env"), "secret")
+	mustWrite(t, filepath.Join(src, "notes.swp"), "swap")
+	mustWrite(t, filepath.Join(src, strings.Repeat("n", 40)+".txt"), "long")
+	mustWrite(t, filepath.Join(src, "new.txt"), "n")
+	if err := os.Symlink("new.txt", filepath.Join(src, "link")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	opt := Options{
+		Source: src, Target: dst,
+		SkipHidden: true, UseDefaultExcludes: true, MaxPathLen: len(dst) + 30,
+		CollectSkipReasons: true,
+	}
+	rep := Sync(opt)
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+	want := map[string]int{
+		SkipIdentical:   2,
+		SkipHiddenFile:  1,
+		SkipExcluded:    1,
+		SkipPathTooLong: 1,
+		SkipNotRegular:  1,
+	}
+	if !reflect.DeepEqual(rep.SkipReasons, want) {
+		t.Fatalf("skip reasons: got %v want %v", rep.SkipReasons, want)
+	}
+	if rep.Skipped != 5 || rep.SkippedTooLong != 1 || rep.Copied != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	wantSummary := "skipped=5 (excluded=1 hidden=1 identical=2 not-regular=1 path-too-long=1) errors=0"
+	if s := rep.String(); !strings.HasSuffix(s, wantSummary) {
+		t.Fatalf("summary %q, want suffix %q", s, wantSummary)
+	}
+
+	opt.CollectSkipReasons = false
+	if rep := Sync(opt); rep.SkipReasons != nil || strings.Contains(rep.String(), "(") {
+		t.Fatalf("reasons collected without the option: %v", rep.String())
+	}
+}
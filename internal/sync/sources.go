@@ -0,0 +1,113 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// SourceConflict selects which source provides a path present in several Options.Sources.
+type SourceConflict int
+
+const (
+	// SourceFirstWins keeps the file from the earliest source listing it.
+	SourceFirstWins SourceConflict = iota
+	// SourceLastWins keeps the file from the latest source listing it.
+	SourceLastWins
+	// SourceConflictError records an error for every conflicting path; the earliest source's file is kept.
+	SourceConflictError
+)
+
+var sourceConflictNames = map[SourceConflict]string{
+	SourceFirstWins:     "first-wins",
+	SourceLastWins:      "last-wins",
+	SourceConflictError: "error",
+}
+
+func (c SourceConflict) String() string {
+	if s, ok := sourceConflictNames[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("SourceConflict(%d)", int(c))
+}
+
+// ParseSourceConflict parses the CLI spelling of a SourceConflict ("first-wins", "last-wins", "error").
+func ParseSourceConflict(s string) (SourceConflict, error) {
+	for c, name := range sourceConflictNames {
+		if name == s {
+			return c, nil
+		}
+	}
+	return SourceFirstWins, fmt.Errorf("unknown source conflict policy %q", s)
+}
+
+// claim reserves dstRel for the source currently being walked.
+// It returns false when an earlier walked source already provides that path.
+func (r *runner) claim(rel, dstRel string) bool {
+	if len(r.sources) < 2 {
+		return true
+	}
+	owner, ok := r.claimed[dstRel]
+	if !ok || owner == r.srcIdx {
+		r.claimed[dstRel] = r.srcIdx
+		return true
+	}
+	if r.opt.SourceConflict == SourceConflictError {
+		err := fmt.Errorf("conflict: %s exists in several sources", rel)
+		r.opt.Logger.Printf("ERR: %v", err)
+		r.rep.addErr(err)
+		return false
+	}
+	r.opt.Logger.Printf("SKIP: %s (provided by another source, %v)", r.srcPath(rel), r.opt.SourceConflict)
+	r.skip(rel, SkipSourceConflict)
+	return false
+}
+
+// statSources returns nil if rel exists in any source, fs.ErrNotExist if it exists in none,
+// or the first other error encountered.
+func (r *runner) statSources(rel string) error {
+	var firstErr error
+	for _, s := range r.sources {
+		_, err := fs.Stat(s.fsys, rel)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	return fs.ErrNotExist
+}
+
+// lstatFS is implemented by source trees that can stat a name without following symlinks.
+type lstatFS interface {
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// confirmMissing re-checks, right before deleting target file rel, that rel is absent from every
+// source (Options.VerifyBeforeDelete). Sources implementing lstatFS are asked with Lstat, so that
+// a dangling symlink counts as present. Anything but "not exist" keeps the target file.
+func (r *runner) confirmMissing(rel string) bool {
+	for _, s := range r.sources {
+		var err error
+		if l, ok := s.fsys.(lstatFS); ok {
+			_, err = l.Lstat(rel)
+		} else {
+			_, err = fs.Stat(s.fsys, rel)
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			r.opt.Logger.Printf("WARN: %s not deleted: re-checking source: %v", r.dstPath(rel), err)
+		} else {
+			r.opt.Logger.Printf("WARN: %s not deleted: re-check found %s", r.dstPath(rel), filepath.Join(s.root, filepath.FromSlash(rel)))
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMultipleSources(t *testing.T) {
+	tests := []struct {
+		policy     SourceConflict
+		wantShared string
+		wantErrs   int
+	}{
+		{SourceFirstWins, "from one", 0},
+		{SourceLastWins, "from two!", 0},
+		{SourceConflictError, "from one", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			one := t.TempDir()
+			two := t.TempDir()
+			dst := t.TempDir()
+			mustWrite(t, filepath.Join(one, "shared.txt"), "from one")
+			mustWrite(t, filepath.Join(one, "only-one.txt"), "1")
+			mustWrite(t, filepath.Join(two, "shared.txt"), "from two!")
+			mustWrite(t, filepath.Join(two, "sub", "only-two.txt"), "2")
+			mustWrite(t, filepath.Join(dst, "orphan.txt"), "o")
+
+			opt := Options{Sources: []string{one, two}, Target: dst, SourceConflict: tt.policy, DeleteMissing: true}
+			rep := Sync(opt)
+			if len(rep.Errors) != tt.wantErrs {
+				t.Fatalf("expected %d errors, got %v", tt.wantErrs, rep.Errors)
+			}
+			if rep.Copied != 3 || rep.Deleted != 1 {
+				t.Fatalf("unexpected rep: %+v", *rep)
+			}
+			b, err := os.ReadFile(filepath.Join(dst, "shared.txt"))
+			if err != nil || string(b) != tt.wantShared {
+				t.Fatalf("shared.txt = %q (err=%v), want %q", b, err, tt.wantShared)
+			}
+			for _, p := range []string{"only-one.txt", filepath.Join("sub", "only-two.txt")} {
+				if _, err := os.Stat(filepath.Join(dst, p)); err != nil {
+					t.Fatalf("expected %s in target: %v", p, err)
+				}
+			}
+
+			// The winner is stable: a second run changes nothing.
+			rep2 := Sync(opt)
+			if rep2.Copied != 0 || rep2.Overwritten != 0 || rep2.Deleted != 0 {
+				t.Fatalf("expected no changes on second run, got %+v", *rep2)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+)
+
+// SpaceFS is implemented by targets that can report their free space,
+// which Options.CheckFreeSpace needs. DirFS implements it on Linux.
+type SpaceFS interface {
+	WritableFS
+	// FreeSpace returns the bytes and inodes available to unprivileged users. Filesystems that
+	// allocate inodes dynamically report 0 inodes and limitInodes false.
+	FreeSpace() (bytes, inodes uint64, limitInodes bool, err error)
+}
+
+// errSpaceUnsupported is returned by FreeSpace where the platform cannot report it.
+var errSpaceUnsupported = errors.New("free space not available on this platform")
+
+// checkFreeSpace is the Options.CheckFreeSpace pre-flight: it estimates the files and bytes the
+// run would write and reports whether the target has room for them. The error has been recorded.
+func (r *runner) checkFreeSpace() bool {
+	dst, ok := r.dst.(SpaceFS)
+	if !ok {
+		return true
+	}
+	bytes, inodes, limitInodes, err := dst.FreeSpace()
+	if errors.Is(err, errSpaceUnsupported) {
+		return true
+	}
+	if err != nil {
+		r.opt.Logger.Printf("WARN: free space of %s: %v; not checking", r.dstPath("."), err)
+		return true
+	}
+
+	o := r.opt
+	o.DryRun = true
+	o.Logger = log.New(io.Discard, "", 0)
+	o.Tracer = nil
+	o.Syslog = false
+	o.Transactional = false
+	o.CheckFreeSpace = false
+	o.CleanTarget = false
+	o.CompletionMarker = ""
+	o.Manifest = ""
+	o.CSVReport = ""
+	o.HeartbeatFile = ""
+	o.TextfileMetrics = ""
+	o.ReconcileAfter = false
+	o.FixCase = false
+	o.IgnoreModTime = false
+	e := newRunner(r.src, r.dst, o)
+	e.sources = r.sources
+	e.srcRoot, e.dstRoot = r.srcRoot, r.dstRoot
+	e.est = &Estimate{}
+	e.run()
+
+	// Overwrites are staged next to the old file, which needs the room of both for a moment
+	need := uint64(e.est.CopyBytes + e.est.OverwriteBytes)
+	var short error
+	switch {
+	case limitInodes && uint64(e.est.CopyFiles) > inodes:
+		short = fmt.Errorf("insufficient inodes on %s: %d files to create, %d inodes free", r.dstPath("."), e.est.CopyFiles, inodes)
+	case need > bytes:
+		short = fmt.Errorf("insufficient space on %s: %d bytes to write, %d bytes free", r.dstPath("."), need, bytes)
+	default:
+		return true
+	}
+	r.opt.Logger.Printf("ERR: %v", short)
+	r.rep.addErr(short)
+	return false
+}
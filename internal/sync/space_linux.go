@@ -0,0 +1,13 @@
+//go:build linux
+
+package sync
+
+import "syscall"
+
+func (d dirFS) FreeSpace() (bytes, inodes uint64, limitInodes bool, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(string(d), &st); err != nil {
+		return 0, 0, false, err
+	}
+	return st.Bavail * uint64(st.Bsize), st.Ffree, st.Files > 0, nil
+}
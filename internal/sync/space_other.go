@@ -0,0 +1,7 @@
+//go:build !linux
+
+package sync
+
+func (d dirFS) FreeSpace() (bytes, inodes uint64, limitInodes bool, err error) {
+	return 0, 0, false, errSpaceUnsupported
+}
@@ -0,0 +1,54 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// spaceFS is a memFS reporting a fixed amount of free space.
+type spaceFS struct {
+	*memFS
+	bytes, inodes uint64
+}
+
+func (s *spaceFS) FreeSpace() (uint64, uint64, bool, error) {
+	return s.bytes, s.inodes, true, nil
+}
+
+func TestCheckFreeSpace(t *testing.T) {
+	src := fstest.MapFS{
+		"a.txt":     {Data: []byte("aaaa"), ModTime: time.Now()},
+		"b.txt":     {Data: []byte("bbbb"), ModTime: time.Now()},
+		"dir/c.txt": {Data: []byte("cccc"), ModTime: time.Now()},
+	}
+
+	tests := []struct {
+		name          string
+		bytes, inodes uint64
+		wantErr       string
+	}{
+		{name: "enough", bytes: 1 << 20, inodes: 100},
+		{name: "few inodes", bytes: 1 << 20, inodes: 2, wantErr: "insufficient inodes"},
+		{name: "little space", bytes: 10, inodes: 100, wantErr: "insufficient space"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := &spaceFS{memFS: newMemFS(), bytes: tt.bytes, inodes: tt.inodes}
+			rep := SyncFS(src, dst, Options{CheckFreeSpace: true})
+			if tt.wantErr == "" {
+				if len(rep.Errors) != 0 || rep.Copied != 3 {
+					t.Fatalf("unexpected rep: %+v", *rep)
+				}
+				return
+			}
+			if len(rep.Errors) != 1 || !strings.Contains(rep.Errors[0].Error(), tt.wantErr) {
+				t.Fatalf("expected %q, got %v", tt.wantErr, rep.Errors)
+			}
+			if rep.Copied != 0 || len(dst.MapFS) != 0 {
+				t.Fatalf("aborted run changed the target: %+v", *rep)
+			}
+		})
+	}
+}
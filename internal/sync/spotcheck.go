@@ -0,0 +1,28 @@
+package sync
+
+import "math/rand"
+
+// spotCheck hashes a file found identical by size and mod-time with probability
+// Options.SpotCheckRatio and reports whether its content nevertheless differs.
+// Read errors are recorded and leave the file alone.
+func (r *runner) spotCheck(rel, dstRel string) bool {
+	if r.opt.SpotCheckRatio <= 0 || (r.opt.IgnoreModTime && r.hashed(rel)) || r.est != nil {
+		// Content comparison already hashes everything; Estimate never reads content
+		return false
+	}
+	if r.opt.SpotCheckRatio < 1 && rand.Float64() >= r.opt.SpotCheckRatio {
+		return false
+	}
+	same, err := r.sameContent(rel, dstRel)
+	if err != nil {
+		r.opt.Logger.Printf("ERR: spot-check %s: %v", r.srcPath(rel), err)
+		r.rep.addErr(err)
+		return false
+	}
+	if same {
+		return false
+	}
+	r.opt.Logger.Printf("WARN: spot-check caught change in %s (same size and mod-time, different content)", r.srcPath(rel))
+	r.rep.SpotCheckCaught++
+	return true
+}
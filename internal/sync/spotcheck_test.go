@@ -0,0 +1,46 @@
+package sync
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestSpotCheck(t *testing.T) {
+	mtime := time.Now().Add(-time.Hour)
+	newTrees := func() (fstest.MapFS, *memFS) {
+		src := fstest.MapFS{
+			"sneaky.txt": {Data: []byte("AAAA"), ModTime: mtime},
+			"same.txt":   {Data: []byte("same"), ModTime: mtime},
+		}
+		dst := newMemFS()
+		// Same size and mod-time, different content
+		dst.MapFS["sneaky.txt"] = &fstest.MapFile{Data: []byte("BBBB"), ModTime: mtime}
+		dst.MapFS["same.txt"] = &fstest.MapFile{Data: []byte("same"), ModTime: mtime}
+		return src, dst
+	}
+
+	t.Run("caught", func(t *testing.T) {
+		src, dst := newTrees()
+		// A ratio of 1 puts every file into the sample
+		rep := SyncFS(src, dst, Options{SpotCheckRatio: 1})
+		if len(rep.Errors) != 0 {
+			t.Fatalf("unexpected errors: %v", rep.Errors)
+		}
+		if rep.Overwritten != 1 || rep.SpotCheckCaught != 1 || rep.Skipped != 1 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if got := string(dst.MapFS["sneaky.txt"].Data); got != "AAAA" {
+			t.Fatalf("sneaky.txt not overwritten: %q", got)
+		}
+	})
+
+	t.Run("off", func(t *testing.T) {
+		src, dst := newTrees()
+		// Content is never read without spot-checks
+		rep := SyncFS(failOpenFS{FS: src, fail: "sneaky.txt"}, dst, Options{})
+		if len(rep.Errors) != 0 || rep.Overwritten != 0 || rep.Skipped != 2 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+	})
+}
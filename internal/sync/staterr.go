@@ -0,0 +1,38 @@
+package sync
+
+import "fmt"
+
+// StatErrorPolicy is the Options.DeleteOnStatError policy.
+type StatErrorPolicy int
+
+const (
+	// StatErrorKeep records the error and keeps the target file.
+	StatErrorKeep StatErrorPolicy = iota
+	// StatErrorError records the error and stops the delete pass, keeping all remaining target files.
+	StatErrorError
+	// StatErrorDelete deletes the target file as if it were missing in the source. Dangerous.
+	StatErrorDelete
+)
+
+var statErrorPolicyNames = map[StatErrorPolicy]string{
+	StatErrorKeep:   "keep",
+	StatErrorError:  "error",
+	StatErrorDelete: "delete",
+}
+
+func (p StatErrorPolicy) String() string {
+	if s, ok := statErrorPolicyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("StatErrorPolicy(%d)", int(p))
+}
+
+// ParseStatErrorPolicy parses the CLI spelling of a StatErrorPolicy ("keep", "error", "delete").
+func ParseStatErrorPolicy(s string) (StatErrorPolicy, error) {
+	for p, name := range statErrorPolicyNames {
+		if name == s {
+			return p, nil
+		}
+	}
+	return StatErrorKeep, fmt.Errorf("unknown stat error policy %q", s)
+}
@@ -0,0 +1,93 @@
+package sync
+
+import (
+	"errors"
+	"io/fs"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+// flakyStatFS fails to stat (and open) the named files with a transient error.
+type flakyStatFS struct {
+	fstest.MapFS
+	fail map[string]bool
+}
+
+var errTransient = errors.New("transient I/O error")
+
+func (f flakyStatFS) Stat(name string) (fs.FileInfo, error) {
+	if f.fail[name] {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: errTransient}
+	}
+	return f.MapFS.Stat(name)
+}
+
+func (f flakyStatFS) Open(name string) (fs.File, error) {
+	if f.fail[name] {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errTransient}
+	}
+	return f.MapFS.Open(name)
+}
+
+func TestDeleteOnStatError(t *testing.T) {
+	tests := []struct {
+		policy      StatErrorPolicy
+		wantLeft    []string
+		wantDeleted int
+		wantErrors  int
+	}{
+		// The flaky file is kept, the real orphan after it is still deleted.
+		{StatErrorKeep, []string{"a.txt", "b_flaky.txt"}, 1, 1},
+		// The delete pass stops at the flaky file; nothing after it is deleted.
+		{StatErrorError, []string{"a.txt", "b_flaky.txt", "c_orphan.txt"}, 0, 1},
+		// The flaky file is deleted like the orphan.
+		{StatErrorDelete, []string{"a.txt"}, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			src := flakyStatFS{
+				MapFS: fstest.MapFS{"a.txt": {Data: []byte("a")}},
+				fail:  map[string]bool{"b_flaky.txt": true},
+			}
+			dst := newMemFS()
+			dst.MapFS["b_flaky.txt"] = &fstest.MapFile{Data: []byte("b")}
+			dst.MapFS["c_orphan.txt"] = &fstest.MapFile{Data: []byte("c")}
+
+			rep := SyncFS(src, dst, Options{DeleteMissing: true, DeleteOnStatError: tt.policy})
+			if rep.Deleted != tt.wantDeleted || rep.ErrorCount() != tt.wantErrors {
+				t.Fatalf("unexpected rep: %+v", *rep)
+			}
+			for _, err := range rep.Errors {
+				if !errors.Is(err, errTransient) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			var left []string
+			for name := range dst.MapFS {
+				left = append(left, name)
+			}
+			sort.Strings(left)
+			if len(left) != len(tt.wantLeft) {
+				t.Fatalf("target holds %v, want %v", left, tt.wantLeft)
+			}
+			for i := range left {
+				if left[i] != tt.wantLeft[i] {
+					t.Fatalf("target holds %v, want %v", left, tt.wantLeft)
+				}
+			}
+		})
+	}
+}
+
+func TestParseStatErrorPolicy(t *testing.T) {
+	for _, p := range []StatErrorPolicy{StatErrorKeep, StatErrorError, StatErrorDelete} {
+		got, err := ParseStatErrorPolicy(p.String())
+		if err != nil || got != p {
+			t.Fatalf("round trip %v: got %v, %v", p, got, err)
+		}
+	}
+	if _, err := ParseStatErrorPolicy("maybe"); err == nil {
+		t.Fatalf("expected error for unknown policy")
+	}
+}
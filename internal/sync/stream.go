@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"io"
+	"io/fs"
+	"time"
+)
+
+// streamSyncInterval is the minimum time between two syncs of a StreamVisible copy.
+const streamSyncInterval = time.Second
+
+// streamVisible reports whether the copy of a source file described by info is written
+// directly to its target (Options.StreamVisible).
+func (r *runner) streamVisible(info fs.FileInfo) bool {
+	return r.opt.StreamVisible && info.Size() >= r.opt.StreamVisibleMinSize
+}
+
+// syncingFS wraps a WritableFS so that created files are synced to disk periodically while
+// written, for files (like *os.File) that implement Sync.
+type syncingFS struct {
+	WritableFS
+}
+
+func (f syncingFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	w, err := f.WritableFS.Create(name, perm)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := w.(interface{ Sync() error })
+	if !ok {
+		return w, nil
+	}
+	return &syncingWriter{WriteCloser: w, sync: s.Sync, last: time.Now()}, nil
+}
+
+type syncingWriter struct {
+	io.WriteCloser
+	sync func() error
+	last time.Time
+}
+
+func (w *syncingWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	if err == nil && time.Since(w.last) >= streamSyncInterval {
+		err = w.sync()
+		w.last = time.Now()
+	}
+	return n, err
+}
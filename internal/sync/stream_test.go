@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// chunkedFS serves name from a MapFS, handing out its content one chunk per receive on next.
+type chunkedFS struct {
+	fstest.MapFS
+	name string
+	next chan int
+}
+
+func (f chunkedFS) Open(name string) (fs.File, error) {
+	file, err := f.MapFS.Open(name)
+	if err != nil || name != f.name {
+		return file, err
+	}
+	return chunkedFile{File: file, next: f.next}, nil
+}
+
+type chunkedFile struct {
+	fs.File
+	next chan int
+}
+
+func (f chunkedFile) Read(p []byte) (int, error) {
+	n, ok := <-f.next
+	if !ok {
+		return 0, io.EOF
+	}
+	return f.File.Read(p[:n])
+}
+
+func TestStreamVisible(t *testing.T) {
+	const chunk = 1000
+	data := bytes.Repeat([]byte("0123456789"), 3*chunk/10)
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	src := chunkedFS{
+		MapFS: fstest.MapFS{
+			"big.log":   {Data: data, ModTime: mtime},
+			"small.txt": {Data: []byte("small"), ModTime: mtime},
+		},
+		name: "big.log",
+		next: make(chan int),
+	}
+	dst := t.TempDir()
+
+	done := make(chan *Report)
+	go func() {
+		done <- SyncFS(src, DirFS(dst), Options{StreamVisible: true, StreamVisibleMinSize: 100})
+	}()
+
+	// A reader of the target sees every chunk as soon as it is written
+	for i := 1; i <= 3; i++ {
+		src.next <- chunk
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			got, _ := os.ReadFile(filepath.Join(dst, "big.log"))
+			if bytes.Equal(got, data[:i*chunk]) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("after chunk %d the target holds %d bytes", i, len(got))
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	close(src.next)
+
+	rep := <-done
+	if len(rep.Errors) != 0 || rep.Copied != 2 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	info, err := os.Stat(filepath.Join(dst, "big.log"))
+	if err != nil || !info.ModTime().Equal(mtime) {
+		t.Fatalf("unexpected target: %v %v", info, err)
+	}
+	if entries, _ := os.ReadDir(dst); len(entries) != 2 {
+		t.Fatalf("unexpected target entries: %v", entries)
+	}
+}
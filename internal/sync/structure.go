@@ -0,0 +1,23 @@
+package sync
+
+import (
+	"path"
+	"strings"
+)
+
+// structureOnly reports whether the directory rel matches one of Options.StructureOnlyDirs:
+// a pattern with a slash is matched against the whole slash-separated name, others against
+// the base name. Malformed patterns never match.
+func (r *runner) structureOnly(rel string) bool {
+	for _, pattern := range r.opt.StructureOnlyDirs {
+		pattern = strings.TrimSuffix(pattern, "/")
+		name := path.Base(rel)
+		if strings.Contains(pattern, "/") {
+			name = rel
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
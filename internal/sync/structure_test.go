@@ -0,0 +1,50 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStructureOnlyDirs(t *testing.T) {
+	src := fstest.MapFS{
+		"app/main.go":          {Data: []byte("package main")},
+		"app/cache/blob":       {Data: []byte("blob")},
+		"app/cache/sub/blob":   {Data: []byte("blob")},
+		"build/tmp/object.o":   {Data: []byte("obj")},
+		"other/tmp/keep.txt":   {Data: []byte("keep")},
+		"other/cache.txt/file": {Data: []byte("not a match")},
+	}
+	dst := newMemFS()
+	dst.MapFS["app/cache/local"] = &fstest.MapFile{Data: []byte("target only")}
+	dst.MapFS["gone.txt"] = &fstest.MapFile{Data: []byte("orphan")}
+
+	rep := SyncFS(src, dst, Options{StructureOnlyDirs: []string{"cache/", "build/tmp"}, DeleteMissing: true})
+	if len(rep.Errors) != 0 || rep.Copied != 3 || rep.Deleted != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	got := map[string]string{}
+	for name, f := range dst.MapFS {
+		if f.Mode.IsDir() {
+			got[name] = "/"
+		} else {
+			got[name] = string(f.Data)
+		}
+	}
+	want := map[string]string{
+		"app":                  "/",
+		"app/main.go":          "package main",
+		"app/cache":            "/",
+		"app/cache/local":      "target only",
+		"build":                "/",
+		"build/tmp":            "/",
+		"other":                "/",
+		"other/tmp":            "/",
+		"other/tmp/keep.txt":   "keep",
+		"other/cache.txt":      "/",
+		"other/cache.txt/file": "not a match",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
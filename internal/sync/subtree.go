@@ -0,0 +1,151 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// checksumDB is the on-disk store backing SubtreeCheck.
+// Dirs maps a directory name (relative to the sync root, "." for the root) to its aggregate fingerprint.
+type checksumDB struct {
+	Dirs map[string]string `json:"dirs"`
+}
+
+// loadChecksumDB reads the store at p. A missing file yields an empty store.
+func loadChecksumDB(p string) (*checksumDB, error) {
+	db := &checksumDB{Dirs: map[string]string{}}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return db, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, db); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", p, err)
+	}
+	if db.Dirs == nil {
+		db.Dirs = map[string]string{}
+	}
+	return db, nil
+}
+
+// save writes the store to p through a temp file so a crash never leaves a truncated DB.
+func (db *checksumDB) save(p string) error {
+	b, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := p + tempSuffix
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// fingerprintTree computes an aggregate fingerprint for every directory in fsys.
+// A directory's fingerprint covers the names, sizes and mod-times (whole seconds, like differ)
+// of its files and the fingerprints of its subdirectories, so any change below it changes it.
+func fingerprintTree(fsys fs.FS, skipHidden bool) (map[string]string, error) {
+	out := map[string]string{}
+	if _, err := fingerprintDir(fsys, ".", skipHidden, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func fingerprintDir(fsys fs.FS, dir string, skipHidden bool, out map[string]string) (string, error) {
+	// fs.ReadDir returns entries sorted by name, which keeps the fingerprint stable.
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, e := range entries {
+		if skipHidden && isHidden(e) {
+			continue
+		}
+		name := path.Join(dir, e.Name())
+		if e.IsDir() {
+			sub, err := fingerprintDir(fsys, name, skipHidden, out)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "d %q %s\n", e.Name(), sub)
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "f %q %d %d %v\n", e.Name(), info.Size(), info.ModTime().Unix(), info.Mode().Type())
+	}
+	fp := hex.EncodeToString(h.Sum(nil))
+	out[dir] = fp
+	return fp, nil
+}
+
+// subtreeState holds the fingerprints used to skip unchanged subtrees during one run.
+type subtreeState struct {
+	db  *checksumDB
+	src map[string]string
+	dst map[string]string
+}
+
+// initSubtreeCheck loads the checksum DB and fingerprints both trees.
+// On failure the run falls back to a full walk.
+func (r *runner) initSubtreeCheck() {
+	if r.opt.ChecksumDB == "" {
+		r.opt.Logger.Printf("WARN: SubtreeCheck requires ChecksumDB; walking every file")
+		return
+	}
+	db, err := loadChecksumDB(r.opt.ChecksumDB)
+	if err != nil {
+		r.opt.Logger.Printf("WARN: load checksum DB %s: %v; walking every file", r.opt.ChecksumDB, err)
+		return
+	}
+	src, err := fingerprintTree(r.src, r.opt.SkipHidden)
+	if err != nil {
+		r.opt.Logger.Printf("WARN: fingerprint source: %v; walking every file", err)
+		return
+	}
+	// The target may be missing directories (or be unreadable); those simply never match.
+	dst, err := fingerprintTree(r.dst, r.opt.SkipHidden)
+	if err != nil {
+		r.opt.Logger.Printf("WARN: fingerprint target: %v", err)
+		dst = map[string]string{}
+	}
+	r.subtree = &subtreeState{db: db, src: src, dst: dst}
+}
+
+// subtreeUnchanged reports whether dir matches its stored fingerprint in both source and target.
+func (r *runner) subtreeUnchanged(dir string) bool {
+	if r.subtree == nil {
+		return false
+	}
+	stored, ok := r.subtree.db.Dirs[dir]
+	return ok && r.subtree.src[dir] == stored && r.subtree.dst[dir] == stored
+}
+
+// saveSubtreeCheck records the source fingerprints after a clean run,
+// when the target is known to mirror them.
+func (r *runner) saveSubtreeCheck() {
+	if r.subtree == nil || r.rep.ErrorCount() > 0 {
+		return
+	}
+	r.subtree.db.Dirs = r.subtree.src
+	if err := r.subtree.db.save(r.opt.ChecksumDB); err != nil {
+		r.opt.Logger.Printf("ERR: save checksum DB %s: %v", r.opt.ChecksumDB, err)
+		r.rep.addErr(err)
+	}
+}
@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSubtreeCheck(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	db := filepath.Join(t.TempDir(), "checksums.json")
+
+	mustWrite(t, filepath.Join(src, "same", "a.txt"), "a")
+	mustWrite(t, filepath.Join(src, "same", "deep", "b.txt"), "b")
+	mustWrite(t, filepath.Join(src, "changed", "c.txt"), "c")
+	mustWrite(t, filepath.Join(src, "changed", "d.txt"), "d")
+
+	opt := Options{Source: src, Target: dst, SubtreeCheck: true, ChecksumDB: db}
+	rep := Sync(opt)
+	if rep.Copied != 4 || rep.SkippedSubtrees != 0 || len(rep.Errors) != 0 {
+		t.Fatalf("unexpected rep after first sync: %+v", *rep)
+	}
+
+	mustWrite(t, filepath.Join(src, "changed", "c.txt"), "c changed")
+
+	rep2 := Sync(opt)
+	if len(rep2.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep2.Errors)
+	}
+	// "same" is skipped wholesale; "changed" is walked file by file
+	if rep2.SkippedSubtrees != 1 {
+		t.Fatalf("expected 1 skipped subtree, got %+v", *rep2)
+	}
+	if rep2.Overwritten != 1 || rep2.Skipped != 1 {
+		t.Fatalf("expected changed subtree fully walked, got %+v", *rep2)
+	}
+
+	// With nothing changed the whole tree is skipped at the root.
+	rep3 := Sync(opt)
+	if rep3.SkippedSubtrees != 1 || rep3.Skipped != 0 || rep3.Overwritten != 0 {
+		t.Fatalf("expected root skipped, got %+v", *rep3)
+	}
+}
+
+func TestSubtreeCheckTargetChanged(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	db := filepath.Join(t.TempDir(), "checksums.json")
+
+	mustWrite(t, filepath.Join(src, "sub", "a.txt"), "a")
+
+	opt := Options{Source: src, Target: dst, SubtreeCheck: true, ChecksumDB: db}
+	Sync(opt)
+
+	// Tampering with the target must defeat the skip even though the source is unchanged.
+	mustWrite(t, filepath.Join(dst, "sub", "a.txt"), "tampered")
+
+	rep := Sync(opt)
+	if rep.SkippedSubtrees != 0 || rep.Overwritten != 1 {
+		t.Fatalf("expected tampered subtree re-synced, got %+v", *rep)
+	}
+}
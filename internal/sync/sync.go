@@ -1,157 +1,1492 @@
 package sync
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"time"
 )
 
 type Options struct {
-	Source        string
-	Target        string
+	// Source is the source directory. It may also be a .tar, .tar.gz or .zip archive,
+	// whose entries are synced as if it were a directory tree (as do archives in Sources).
+	Source string
+	// Sources lists several source directories synced into the one Target, in order.
+	// When set, Source is ignored. See SourceConflict for paths present in more than one source.
+	Sources []string
+	// SourceConflict decides which source provides a path that exists in several Sources.
+	SourceConflict SourceConflict
+	// Target is the target directory. A name ending in .tar, .tar.gz/.tgz or .zip
+	// packs the selected source files into a new archive instead; DeleteMissing and
+	// the other options that work on an existing target tree are rejected then.
+	Target string
+	// Targets mirrors the source(s) into several target directories concurrently.
+	// When set, Target is ignored and Report.PerTarget holds one report per target.
+	Targets       []string
 	DeleteMissing bool
-	Logger        *log.Logger
+	// CleanTarget empties the target before the run (into TrashDir when set), so that it ends up
+	// an exact copy of the source without relying on the delete pass. The run is refused when a
+	// source is the target or lies inside it. Cleaning is not part of a Transactional commit.
+	// Not supported with FileList.
+	CleanTarget bool
+	// StructureOnlyDirs lists patterns (path.Match syntax; a pattern with a slash matches the
+	// whole slash-separated name, others the base name, e.g. "cache") of source directories that
+	// are created on the target without their contents: nothing below them is copied and, with
+	// DeleteMissing, nothing below them on the target is deleted while the source directory
+	// exists. Files named in FileList are synced regardless.
+	StructureOnlyDirs []string
+	// DirsOnly replicates the source directory tree without copying any file, counting new
+	// target directories in Report.DirsCreated. With DeleteMissing only target directories
+	// missing in the source are removed (and counted in Report.Deleted), and only once empty;
+	// no file is deleted.
+	DirsOnly bool
+	// RepairPermsOnly compares only the permission bits of files present in both source and
+	// target and sets those of drifted target files to the source's, counting them in
+	// Report.PermsFixed. Content is never read or written, nothing is created or deleted
+	// (DeleteMissing is ignored), and DryRun only reports the drift. The target must
+	// implement ChmodFS, as DirFS does.
+	RepairPermsOnly bool
+	// SkipHidden skips dot-prefixed files and prunes dot-prefixed directories
+	// (e.g. .git, .env). On Windows entries with the hidden attribute are skipped too.
+	SkipHidden bool
+	// UseDefaultExcludes skips files and prunes directories matching DefaultExcludes
+	// (.git, node_modules, .DS_Store, *.swp, ...). Excluded target entries are never deleted.
+	UseDefaultExcludes bool
+	// MaxStoredErrors caps how many errors are kept in Report.Errors (0 = unlimited).
+	// Further errors are only counted in Report.DroppedErrors.
+	MaxStoredErrors int
+	// CategorizeErrors groups the stored errors by cause (permission, space, io, not-found, other)
+	// in Report.ErrorsByCategory; see ErrorCategory.
+	CategorizeErrors bool
+	// CoalesceErrors groups errors with the same message in Report.ErrorSummary, with their count,
+	// and stores only the first of each in Report.Errors (the others count as Report.DroppedErrors),
+	// so that a mass failure for one reason stays readable. TrackAllErrors keeps every error in
+	// Report.Errors as well.
+	CoalesceErrors bool
+	TrackAllErrors bool
+	// SubtreeCheck skips whole directories whose aggregate fingerprint (names, sizes, mod-times)
+	// matches the one stored in ChecksumDB on both source and target.
+	SubtreeCheck bool
+	// ChecksumDB is the path of the JSON file storing fingerprints between runs.
+	// Keep it outside the target when DeleteMissing is set.
+	ChecksumDB string
+	// Transactional stages every copy under a temp name and only renames (and deletes)
+	// once the whole run succeeded. On any error the staged files are discarded
+	// and the target is left untouched.
+	Transactional bool
+	// OneFileSystem prunes source directories that live on a different device than the source root
+	// (like rsync --one-file-system). It has no effect on platforms without device ids.
+	OneFileSystem bool
+	// WalkOrder controls the order in which source directory entries are processed.
+	WalkOrder WalkOrder
+	// CompletionMarker is a target-relative name of a marker file written after a run without errors.
+	// It holds the run timestamp, the counters and a checksum of the synced file list.
+	// A stale marker is removed when the run starts, so consumers can poll for it.
+	CompletionMarker string
+	// IgnoreModTime compares files by size and, when sizes match, by content hash,
+	// for filesystems with unreliable mod-times.
+	IgnoreModTime bool
+	// HashExtensions limits the content comparison of IgnoreModTime to files with one of these
+	// extensions ("txt" or ".txt", case-insensitive); NoHashExtensions excludes extensions from it
+	// (e.g. "mp4", whose size reliably tells a change). Other files are compared by size and mod-time.
+	HashExtensions   []string
+	NoHashExtensions []string
+	// HashConcurrency runs the content comparisons of IgnoreModTime for up to this many files at
+	// once, started for all files of a source directory as the walk enters it (0 or 1 = one at a
+	// time, as the walk reaches each file). Decisions, counts and copies still follow walk order.
+	HashConcurrency int
+	// CompareSizeOnly treats files as different only when their sizes differ, ignoring mod-times
+	// and never reading content. It is the cheapest check, for trees where every change
+	// also changes the size (e.g. append-only logs). It takes precedence over IgnoreModTime.
+	CompareSizeOnly bool
+	// StoreChecksumXattr records the SHA-256 of every copied file in the user.sync.sha256
+	// extended attribute of the target. Content comparisons (IgnoreModTime) then hash only the
+	// source while the target still has the size and mod-time it was written with. Linux only.
+	StoreChecksumXattr bool
+	// FileList restricts the run to these source paths (slash-separated, relative to the source
+	// root), e.g. a build's list of changed outputs, instead of walking the tree. Listed files
+	// are compared and copied as usual; listed directories are created but not descended into,
+	// and everything else is left alone. DeleteMissing and SubtreeCheck are not supported with
+	// it. A listed path missing in the source is an error (with one source).
+	FileList []string
+	// GitSince syncs only the files changed between this git revision and HEAD of the source, which
+	// must be a git work tree (a subdirectory syncs the changes below it), e.g. for CI deploys of a
+	// commit range. The changed files are synced as with FileList (which they replace); with
+	// DeleteMissing, the target copies of files deleted or renamed away since are removed.
+	// Requires the git command and a single source directory.
+	GitSince string
+	// Transforms rewrite the content of every copied file, in order (e.g. GzipTransform, or
+	// compress-then-encrypt), after LineEndings. With Transforms, files are compared by mod-time
+	// only, or with IgnoreModTime by the content of the transformed source; sizes are not
+	// compared. Not supported with archive targets; AppendOnly copies such files in full.
+	Transforms []Transform
+	// EncryptPassphrase, when set, encrypts every copied file at rest with AES-256-GCM under a
+	// key derived from the passphrase with scrypt; Decrypt restores the content. The target file
+	// header keeps a keyed hash of the content, so IgnoreModTime compares by content without
+	// decrypting. Without IgnoreModTime, files are compared by mod-time and plaintext size.
+	// Not supported with archive targets; AppendOnly copies such files in full.
+	EncryptPassphrase string
+	// LineEndings converts the line endings of text files (no NUL byte in the first 8000 bytes)
+	// while copying; binary files are copied unchanged. Files that differ from their target only
+	// in line endings count as identical. Not supported with archive targets; AppendOnly copies
+	// converted files in full.
+	LineEndings LineEndings
+	// ModTimeTolerance treats mod-times at most this far apart as equal, instead of comparing
+	// them truncated to whole seconds, for filesystems that round instead of truncating (or vice versa).
+	// 0 keeps the whole-second comparison.
+	ModTimeTolerance time.Duration
+	// SpotCheckRatio is the fraction (0 to 1) of files found identical by size and mod-time whose
+	// content is additionally hashed and compared; a file that differs anyway is overwritten,
+	// logged as caught by the spot-check and counted in Report.SpotCheckCaught. 0 disables it.
+	SpotCheckRatio float64
+	// VersionHeaderRegex finds a version token in the first 4 KiB of a file, e.g.
+	// `(?m)^# version: (\S+)`; the first capture group is the token, if there is one.
+	// A target file whose token is not older than its changed source's (compared as dotted
+	// versions) is kept instead of overwritten, so a manually bumped file is not clobbered.
+	// Files without a token in either copy are overwritten as usual.
+	VersionHeaderRegex string
+	// WarnOnNewerTarget logs a CONFLICT line and counts Report.Conflicts for every target file
+	// about to be replaced although its mod-time is newer than the source's, as a target-side
+	// edit would then be lost. The file is still overwritten.
+	WarnOnNewerTarget bool
+	// OnConflict is what happens to a target file about to be replaced although its mod-time
+	// is newer than the source's. ResolveKeepBoth keeps it under ConflictNameTemplate, logs
+	// a CONFLICT line and counts Report.Conflicts; DeleteMissing leaves such copies alone.
+	OnConflict ConflictResolution
+	// ConflictNameTemplate names the copies kept by ResolveKeepBoth, in the directory of the
+	// original, with placeholders {name} (the base name without extension, required), {ext}
+	// (the extension with its dot), {host} (this host's name) and {ts} (the run's start time,
+	// 20060102-150405). Empty means DefaultConflictNameTemplate. It is checked at startup.
+	ConflictNameTemplate string
+	// TrashDir is a target-relative directory that files removed by DeleteMissing are moved into
+	// instead of being deleted. It is never itself subject to deletion.
+	TrashDir string
+	// TrashTimestamped moves deletions under TrashDir/<timestamp>/<relpath>, one snapshot per run.
+	TrashTimestamped bool
+	// TrashRetention prunes timestamped trash snapshots older than this duration (0 = keep forever).
+	TrashRetention time.Duration
+	// SanitizeNames handles names that are illegal on Windows/SMB targets
+	// (reserved device names, illegal characters, trailing dots or spaces).
+	SanitizeNames SanitizeMode
+	// DryRun reports (and counts) what would be copied, overwritten and deleted without changing anything.
+	// Log lines are prefixed with "DRY-RUN ".
+	DryRun bool
+	// CleanStaleTemps removes leftover temp files of crashed runs from the target before syncing.
+	CleanStaleTemps bool
+	// StaleTempAge is the minimum age of a temp file to be considered stale (default 1h),
+	// which protects temp files of a sync running concurrently.
+	StaleTempAge time.Duration
+	// TargetKnownEmpty is a fast path for seeding an empty target: files are copied without
+	// checking for an existing target file and the delete pass is skipped.
+	// The target is verified to be empty at startup; otherwise the normal path is used.
+	TargetKnownEmpty bool
+	// Syslog sends the final report to the system logger (Unix only):
+	// errors at LOG_ERR and the summary at LOG_INFO.
+	Syslog bool
+	// SyslogFacility is the facility name, e.g. "daemon" or "local0" (default "user").
+	SyslogFacility string
+	// SyslogTag is the syslog tag (default "sync-service").
+	SyslogTag string
+	// PreserveACLs replicates the POSIX access ACL of every copied file onto the target (Linux only).
+	// Files whose ACL cannot be applied are counted in Report.ACLFailures but still synced.
+	PreserveACLs bool
+	// PreserveOwner gives every copied file the owner and group of its source file (Unix only,
+	// usually requires root), translated by UIDMap and GIDMap, e.g. for a target mapped into a
+	// container's user namespace. Files whose owner cannot be set are counted in
+	// Report.OwnerFailures but still synced.
+	PreserveOwner bool
+	UIDMap        IDMap
+	GIDMap        IDMap
+	// Readahead is the depth of the prefetch queue: up to this many upcoming small source files
+	// are read in the background while earlier ones are written, overlapping read and write I/O
+	// on trees with many tiny files (0 = off).
+	Readahead int
+	// SkipLockedFiles skips (and counts in Report.SkippedLocked) source files that another process
+	// holds locked instead of copying a torn or failing read. On Windows a file open for writing
+	// elsewhere counts as locked; on Unix it is a best-effort check for an exclusive flock.
+	SkipLockedFiles bool
+	// RespectImmutable leaves changed files alone whose target carries the immutable or
+	// append-only attribute (chattr +i / +a), counting them in Report.SkippedImmutable instead
+	// of failing with EPERM. Linux only; elsewhere it has no effect.
+	RespectImmutable bool
+	// DetectConcurrentModification stats each copied source file again after the copy. When its
+	// size or mod-time changed meanwhile, the copy may be a torn snapshot: it is logged and the
+	// file copied once more; if it changes again, the file is counted in
+	// Report.ModifiedDuringCopy. Readahead is disabled with it.
+	DetectConcurrentModification bool
+	// PerDirStats breaks the copy, overwrite and delete counts down by top-level target directory
+	// into Report.DirStats.
+	PerDirStats bool
+	// MaxPathLen skips files whose full target path would be longer than this many bytes
+	// (e.g. 260 for Windows targets without long path support), counting them in
+	// Report.SkippedTooLong instead of failing on them (0 = no limit).
+	MaxPathLen int
+	// DeleteOnStatError decides what DeleteMissing does with a target file when checking the source
+	// fails with an error other than "not exist" (e.g. a transient mount hiccup): keep it (the default),
+	// stop the delete pass, or delete it anyway. StatErrorDelete is dangerous: an unreachable
+	// source looks like a source without the file, and the target copy is lost.
+	DeleteOnStatError StatErrorPolicy
+	// VerifyBeforeDelete re-checks every source with a fresh Lstat right before a target file is
+	// deleted and keeps the file (with a warning) if the re-check finds anything, e.g. a dangling
+	// symlink or an entry that appeared since the first check.
+	VerifyBeforeDelete bool
+	// MaxDeletes caps how many files DeleteMissing may delete in one run (0 = no limit).
+	// Beyond it, DeleteLimit decides whether nothing is deleted or deletion stops at the cap;
+	// either way an error is recorded and the remaining files are kept.
+	MaxDeletes  int
+	DeleteLimit DeleteLimitPolicy
+	// MaxTotalBytes caps the bytes copied per run (per target with Targets; 0 = no limit), e.g.
+	// on metered links. Once Report.BytesCopied reaches it, the file being copied is completed,
+	// no further files are copied and an error wrapping ErrByteBudgetExhausted is recorded;
+	// the remaining files are left for the next run. The delete pass still runs. Not supported
+	// with Transactional, whose commit would roll back on that error.
+	MaxTotalBytes int64
+	// MaxTargetFiles caps the number of regular files in the target (outside TrashDir), checked
+	// once the run is done (0 = no limit), so that a target accumulating files (e.g. logs the
+	// source rotates away) does not grow without bound. Above it, TargetLimit records an error
+	// or deletes the files with the oldest mod-times, counted in Report.Pruned. Pruned files
+	// still in the source are copied again by the next run. Not supported with archive targets.
+	MaxTargetFiles int
+	TargetLimit    TargetLimitPolicy
+	// TargetSymlink decides what happens when Target itself is a symlink to a directory (as in a
+	// "current -> release-42" layout): sync through it (the default), replace the symlink with a
+	// real directory, or fail. Ignored by SyncFS.
+	TargetSymlink TargetSymlinkPolicy
+	// DereferenceRoots resolves Source (or Sources) and Target to their real paths with
+	// filepath.EvalSymlinks before the run, so a symlinked root, or one reached through symlinks
+	// and "..", is walked, compared and logged as the directory it points to. The target is
+	// resolved only with TargetSymlinkFollow and if it exists. Ignored by SyncFS.
+	DereferenceRoots bool
+	// GuardWalk resolves every source directory to its real path as the walk enters it and skips
+	// it, with a warning, if it is the target or lies inside it, or was already walked. This
+	// catches a directory replaced during the run by a symlink into the target, which the walk
+	// would follow, and a target nested in the source (if it exists when the walk starts).
+	// Ignored by SyncFS and for archives.
+	GuardWalk bool
+	// RenameStrategy selects how a copied file replaces its target, for network filesystems
+	// where renaming over an existing file fails or is not atomic. Transactional runs always
+	// stage temp files and use RenameRemoveThenRename for any strategy but RenameAtomic.
+	RenameStrategy RenameStrategy
+	// TempDevice checks, before a temp file replaces its target, that both are on the same
+	// device, as an atomic rename requires; they may not be when the target file is itself a
+	// mount point (a bind-mounted file). A mismatch fails the file (TempDeviceError) or has the
+	// temp file copied onto the target in place (TempDeviceCopy), which also handles a rename
+	// failing with EXDEV. Devices are known on Unix only; elsewhere nothing is checked.
+	TempDevice TempDevicePolicy
+	// BusyTargetRetries retries, up to this many times with a wait doubling from BusyTargetBackoff
+	// (default 100ms), a RenameAtomic replace that fails because another process has the target
+	// file open without sharing it, locked or memory-mapped (Windows). BusyTargetReplaceAside
+	// then renames the busy target aside under a temp name, puts the copy in place and has the
+	// old file deleted once its last user closes it; this works for mapped files and files opened
+	// with FILE_SHARE_DELETE. Elsewhere open files never block renames and these have no effect.
+	BusyTargetRetries      int
+	BusyTargetBackoff      time.Duration
+	BusyTargetReplaceAside bool
+	// StreamVisible writes copies of files of at least StreamVisibleMinSize bytes (every file if 0)
+	// directly to the target, syncing them to disk about once a second, instead of to a temp file
+	// renamed into place, so that a process tailing the target sees the data as it arrives. This
+	// trades atomicity for visibility: readers can see a partial file, an interrupted copy leaves
+	// (then removes) a truncated target, and the target's previous content is gone as soon as
+	// the copy starts. Ignored with Transactional, which always stages temp files.
+	StreamVisible        bool
+	StreamVisibleMinSize int64
+	// CheckFreeSpace estimates the run before it starts and aborts it, changing nothing, when the
+	// target has fewer free bytes than the new and changed files need, or fewer free inodes
+	// than new files (an inode-starved filesystem reports ENOSPC with space left). The target
+	// must implement SpaceFS; DirFS does on Linux, elsewhere nothing is checked.
+	CheckFreeSpace bool
+	// PauseOnENOSPC makes a copy that fails because the target is full (ENOSPC) wait this long
+	// and try the file again, up to ENOSPCRetries times (default 3), in case space is freed
+	// meanwhile (e.g. by a concurrent cleanup). 0 fails the file right away.
+	PauseOnENOSPC time.Duration
+	ENOSPCRetries int
+	// RetryWholeSync runs the whole sync again, up to this many times, while a pass ends with
+	// errors, waiting RetryWholeSyncDelay (default 10s) before each: a second pass often gets
+	// past transient failures such as a flaky mount. The report is that of the last pass, with
+	// Report.Passes set. A pass stopped by MaxTotalBytes is not retried.
+	RetryWholeSync      int
+	RetryWholeSyncDelay time.Duration
+	// ComputeChurn compares the size of every overwritten target file before and after the
+	// overwrite and sums the growth in Report.BytesAdded and the shrinkage in
+	// Report.BytesRemoved; AppendOnly tails count as added. New and deleted files are not
+	// counted. Sizes are those of the source files, before Transforms or encryption.
+	ComputeChurn bool
+	// SidecarIgnoreSuffix, e.g. ".sync-ignore", skips every source file or directory x next to
+	// which a file x<suffix> exists, counting skipped files under SkipSidecarIgnore. The marker
+	// files themselves are never synced either.
+	SidecarIgnoreSuffix string
+	// ReportOrphans walks the target like DeleteMissing and lists the files missing in every
+	// source in Report.Orphans, logging an ORPHAN line for each, without removing any (unless
+	// DeleteMissing is set as well). The directories of a DirsOnly run are not listed.
+	ReportOrphans bool
+	// IOUring (experimental, Linux 5.6+) copies file data between OS files through an io_uring,
+	// keeping several reads and writes in flight per file instead of alternating them. Where
+	// io_uring is unavailable (other systems, older kernels, disabled by seccomp or sysctl) or
+	// rejected by a filesystem, files are copied normally.
+	IOUring bool
+	// TargetPolicy, when set, is enforced by a final pass over the whole target: every file and
+	// directory whose permission bits or owner differ from the policy's gets them (POLICY lines,
+	// counted in Report.PolicyFixed), independently of the source's. Failures are errors. It is
+	// checked at startup and needs an OS target; in a dry run the drift is only reported.
+	TargetPolicy *TargetPolicy
+	// ReconcileAfter compares source and target once more after the run (by size and mod-time,
+	// or size only with IgnoreModTime) and records every file still missing, differing or,
+	// with DeleteMissing, left over as an error. The check also covers Transactional runs.
+	ReconcileAfter bool
+	// PostSyncSample is the fraction (0 to 1) of files in sync after the run (copied or found
+	// identical) that are read back from the target once the run is done and compared by content
+	// with their source, to catch silent corruption after writing. A mismatch is recorded as an
+	// error; nothing is rewritten. 0 disables it; it has no effect in dry runs and archive targets.
+	PostSyncSample float64
+	// CollectSkipReasons tallies skipped files by reason in Report.SkipReasons,
+	// which Report.String then includes.
+	CollectSkipReasons bool
+	// Manifest is the path of a JSON file recording the target files (size, mod-time) after each run.
+	// When set, Report.Changes lists the files added, modified and removed since the previous
+	// run's manifest (all files count as added on the first run). Keep it outside the target.
+	// It is ignored with Targets, as all targets would share the file; use one Syncer per target.
+	Manifest string
+	// DeferMetadata leaves the mod-times of copied files unset while copying and applies them
+	// all in a single pass, sorted by name, once the copies are done (after the commit of a
+	// Transactional run), which can be faster on filesystems with slow metadata updates.
+	// Permissions are set when a file is created either way. A failure is recorded per file.
+	DeferMetadata bool
+	// PreserveAllTimes gives copied files the access time of their source as well, instead of the
+	// time of the copy, for workflows keyed on access time. The atime is read before the copy
+	// (which may update it). Unix and Windows (where NTFS may update access times lazily or not
+	// at all); elsewhere copies get the current time as usual.
+	PreserveAllTimes bool
+	// CSVReport is the path of a CSV file receiving one "action,rel,bytes,error" row per processed
+	// file: copy, overwrite, append, delete or skip (including locked and too-long files), with the
+	// slash-separated target name (the source name for hidden, excluded and invalid names),
+	// the bytes written and, for a failed action, the error message.
+	// It is written in dry runs too. Not supported with Targets, which would share the file.
+	CSVReport string
+	// ContentValidator, when set, reads every source file while it is copied. If it returns an
+	// error the file is not written (the temp copy is removed) and a *ValidationError is recorded.
+	// It may stop reading early, e.g. after checking a header. Not supported with archive targets;
+	// AppendOnly copies validated files in full.
+	ContentValidator func(rel string, r io.Reader) error
+	// FixCase renames a target file whose name differs from its identical source file only in case
+	// (File.txt vs file.txt) to the source's spelling, without rewriting data, and counts it in
+	// Report.Recased. It takes effect only when the target is detected to be case-insensitive,
+	// and not with Transactional.
+	FixCase bool
+	// AppendOnly appends only the new tail to a target file that is a strict prefix of its
+	// (longer) source, as for growing logs; other changes (rotation, truncation) are copied
+	// in full. Appends are counted in Report.Appended. Ignored with Transactional and for
+	// targets that do not implement AppendFS.
+	AppendOnly bool
+	// Nice sets the nice value of the whole process when the run starts (Linux only; 0 = unchanged),
+	// e.g. 10 for background syncs. Unprivileged processes can only raise it.
+	Nice int
+	// IONice sets the I/O scheduling class of the whole process when the run starts (Linux only).
+	IONice IOClass
+	// TextfileMetrics is the path of a .prom file that receives the counters, error count and
+	// duration of every run (not of dry runs) in the Prometheus text format, for the node_exporter
+	// textfile collector. With Targets the file holds the totals over all targets.
+	TextfileMetrics string
+	// HeartbeatFile is rewritten with the current time and counters (as in Report.String) when the
+	// run starts and ends and, while it progresses, at most every HeartbeatInterval (default 10s),
+	// so that a supervisor can tell a hung run by the file's age. Copying a single large file
+	// does not advance it; allow for that when choosing a staleness threshold. Not supported with Targets.
+	HeartbeatFile     string
+	HeartbeatInterval time.Duration
+	// Tracer receives spans for the run, each copy and delete walk, the transactional commit
+	// and, with TraceFiles, every copied file. See the otelsync package for an OpenTelemetry adapter.
+	Tracer     Tracer
+	TraceFiles bool
+	Logger     *log.Logger
+
+	// ctx is the context of Service.Sync, checked as the run walks and copies (nil = never cancelled).
+	ctx context.Context
 }
 
 // Sync performs a one-way synchronization from the source directory to the target directory.
 // It copies new and modified files from source to target and optionally deletes files in the target
 // that are missing from the source.
 func Sync(opt Options) *Report {
+	return retryWholeSync(opt, func(opt Options) *Report {
+		if len(opt.Targets) > 0 {
+			return syncTargets(opt)
+		}
+		return newDirRunner(opt).run()
+	})
+}
+
+// SyncFS performs the same one-way synchronization as Sync, reading from an arbitrary fs.FS
+// (embedded files, archives, in-memory trees) and writing through a WritableFS.
+// Options.Source and Options.Target are ignored; src and dst are used instead.
+func SyncFS(src fs.FS, dst WritableFS, opt Options) *Report {
+	return retryWholeSync(opt, func(opt Options) *Report {
+		return newRunner(src, dst, opt).run()
+	})
+}
+
+// source is one tree synced into the target.
+type source struct {
+	fsys fs.FS
+	// root is the OS root used only to render paths in log lines (empty for SyncFS).
+	root string
+}
+
+// runner holds the state of a single synchronization run.
+type runner struct {
+	opt     Options
+	sources []source
+	// src and srcRoot describe the source currently being walked.
+	src fs.FS
+	dst WritableFS
+	// srcRoot and dstRoot are the OS roots, used to render paths in log lines (empty for SyncFS).
+	srcRoot string
+	dstRoot string
+	rep     *Report
+	// subtree is set when SubtreeCheck is enabled and fingerprints are available.
+	subtree *subtreeState
+	// txn collects staged changes when Transactional is enabled.
+	txn *txn
+	// rootDev is the device id of the source root when OneFileSystem is enabled.
+	rootDev    uint64
+	hasRootDev bool
+	// synced accumulates the checksum of files known to be in sync, for CompletionMarker.
+	synced      hash.Hash
+	syncedFiles int
+	// start is the time the run began.
+	start time.Time
+	// hostname and conflictRe serve ResolveKeepBoth: the {host} placeholder and a pattern
+	// matching the conflict copies.
+	hostname   string
+	conflictRe *regexp.Regexp
+	// churnBase holds the sizes of target files about to be overwritten (Options.ComputeChurn).
+	churnBase map[string]int64
+	// ring copies file data with Options.IOUring.
+	ring *ring
+	// policy is the resolved Options.TargetPolicy.
+	policy *targetPolicy
+	// sanitized holds target names produced by SanitizeReplace, which the delete pass must keep.
+	sanitized map[string]bool
+	// claimed maps target names to the index of the source providing them (multiple sources only).
+	claimed map[string]int
+	srcIdx  int
+	// emptyTarget is set when TargetKnownEmpty was requested and the target is indeed empty,
+	// and when packing into an archive target.
+	emptyTarget bool
+	// pack writes the archive when Target names an archive file of kind packKind.
+	pack     *packer
+	packKind ArchiveKind
+	// est accumulates byte counts for Syncer.Estimate.
+	est *Estimate
+	// ver collects differing paths for Syncer.Verify.
+	ver *Verification
+	// traceCtx carries the span of the current phase, the parent of per-file spans.
+	traceCtx context.Context
+	// ra queues copies whose source content is being prefetched (Options.Readahead).
+	ra *readahead
+	// ha runs content comparisons ahead of the walk during a copy pass (Options.HashConcurrency).
+	ha *hashAhead
+	// closers release source archives when the run ends.
+	closers []io.Closer
+	// fatal aborts the run before anything is changed.
+	fatal error
+	// caseNames caches, per target directory, the stored spelling of each lower-cased name.
+	// It is set only when FixCase is enabled and the target is case-insensitive.
+	caseNames map[string]map[string]string
+	// plan collects the actions of a Plan run; actions replaces the walk in an Apply run.
+	plan    []Action
+	actions []Action
+	// csv receives a row per processed file when Options.CSVReport is set.
+	csv *csvReport
+	// pendingTimes collects the mod-times left for the metadata pass (Options.DeferMetadata).
+	pendingTimes []pendingTimes
+	// lastBeat is when Options.HeartbeatFile was last written.
+	lastBeat time.Time
+	// versionRe is the compiled Options.VersionHeaderRegex.
+	versionRe *regexp.Regexp
+	// enc encrypts copied files when Options.EncryptPassphrase is set.
+	enc *encryptor
+	// gitRemoved lists the source files removed since Options.GitSince, deleted with DeleteMissing.
+	gitRemoved []string
+	// foldTarget is set during the delete pass when the target is case-insensitive.
+	foldTarget bool
+	// srcCaseNames caches, per source and directory, the spelling of each lower-cased name.
+	srcCaseNames []map[string]map[string]string
+	// aborted is set when a root became inaccessible; see RootError. It is also set when
+	// the run was cancelled.
+	aborted   bool
+	cancelled bool
+	// guard is the walkGuard of the current source walk (Options.GuardWalk).
+	guard *walkGuard
+	// budgetSpent is set once Options.MaxTotalBytes has been reached.
+	budgetSpent bool
+	// samples are the files picked for the post-sync check (Options.PostSyncSample).
+	samples []sampledFile
+}
+
+// newDirRunner prepares a run between the OS directories named in opt (Source or Sources, and Target).
+func newDirRunner(opt Options) *runner {
+	var derefErr error
+	if opt.DereferenceRoots {
+		opt, derefErr = dereferenceRoots(opt)
+	}
+	roots := opt.Sources
+	if len(roots) == 0 {
+		roots = []string{opt.Source}
+	}
+	r := newRunner(dirFS(roots[0]), dirFS(opt.Target), opt)
+	r.dstRoot = opt.Target
+	if derefErr != nil {
+		r.fatal = derefErr
+	}
+	r.sources = make([]source, len(roots))
+	for i, root := range roots {
+		r.sources[i] = source{fsys: dirFS(root), root: root}
+		if DetectArchive(root) == NotArchive {
+			continue
+		}
+		fsys, c, err := OpenArchive(root)
+		if err != nil {
+			// Syncing without this source could delete its files from the target
+			r.fatal = fmt.Errorf("open source archive %s: %w", root, err)
+			break
+		}
+		r.sources[i].fsys = fsys
+		r.closers = append(r.closers, c)
+	}
+	r.src = r.sources[0].fsys
+	if kind := ArchiveTargetKind(opt.Target); kind != NotArchive && r.fatal == nil {
+		r.initPack(kind)
+	}
+	return r
+}
+
+func newRunner(src fs.FS, dst WritableFS, opt Options) *runner {
 	// Initialize logger if not provided
 	if opt.Logger == nil {
 		opt.Logger = log.Default()
 	}
-	rep := &Report{}
+	if opt.Tracer == nil {
+		opt.Tracer = nopTracer{}
+	}
+	if opt.DryRun {
+		opt.Logger = log.New(opt.Logger.Writer(), opt.Logger.Prefix()+"DRY-RUN ", opt.Logger.Flags())
+		// Nothing is written, so there is nothing to stage
+		opt.Transactional = false
+	}
+	r := &runner{
+		opt:       opt,
+		sources:   []source{{fsys: src}},
+		src:       src,
+		dst:       dst,
+		rep:       newReport(opt),
+		start:     time.Now(),
+		sanitized: map[string]bool{},
+		claimed:   map[string]int{},
+	}
+	if opt.Readahead > 0 && !opt.DryRun && !opt.DetectConcurrentModification {
+		r.ra = &readahead{depth: opt.Readahead}
+	}
+	if opt.VersionHeaderRegex != "" {
+		re, err := regexp.Compile(opt.VersionHeaderRegex)
+		if err != nil {
+			r.fatal = fmt.Errorf("VersionHeaderRegex: %w", err)
+		}
+		r.versionRe = re
+	}
+	if opt.OnConflict == ResolveKeepBoth {
+		re, err := parseConflictTemplate(r.conflictTemplate())
+		if err != nil {
+			r.fatal = fmt.Errorf("ConflictNameTemplate %q: %w", r.conflictTemplate(), err)
+		}
+		r.conflictRe, r.hostname = re, hostname()
+	}
+	if opt.TargetPolicy != nil {
+		p, err := resolvePolicy(opt.TargetPolicy)
+		if _, ok := dst.(dirFS); err == nil && (!ok || ArchiveTargetKind(opt.Target) != NotArchive) {
+			err = errors.New("target is not an OS directory")
+		}
+		if err != nil {
+			r.fatal = fmt.Errorf("TargetPolicy: %w", err)
+		}
+		r.policy = p
+	}
+	if opt.MaxTotalBytes > 0 && opt.Transactional {
+		r.fatal = errors.New("MaxTotalBytes is not supported with Transactional")
+	}
+	if _, ok := dst.(ChmodFS); opt.RepairPermsOnly && !ok {
+		r.fatal = errors.New("RepairPermsOnly: target does not implement ChmodFS")
+	}
+	if opt.EncryptPassphrase != "" {
+		enc, err := newEncryptor(opt.EncryptPassphrase)
+		if err != nil {
+			r.fatal = fmt.Errorf("EncryptPassphrase: %w", err)
+		}
+		r.enc = enc
+	}
+	return r
+}
+
+// closeSources releases the archives opened as sources.
+func (r *runner) closeSources() {
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil {
+			r.opt.Logger.Printf("WARN: close source: %v", err)
+		}
+	}
+	r.closers = nil
+}
+
+// srcPath renders a source name for logging.
+func (r *runner) srcPath(name string) string {
+	return filepath.Join(r.srcRoot, filepath.FromSlash(name))
+}
+
+// dstPath renders a target name for logging.
+func (r *runner) dstPath(name string) string {
+	return filepath.Join(r.dstRoot, filepath.FromSlash(name))
+}
+
+func (r *runner) run() *Report {
+	opt, rep := r.opt, r.rep
+
+	ctx, span := opt.Tracer.Start(context.Background(), "sync.run")
+	r.traceCtx = ctx
+	defer endRunSpan(span, opt.Target, rep)
+	defer r.closeSources()
+	if opt.TextfileMetrics != "" && !opt.DryRun {
+		// Deferred so that runs stopped early are reported as well
+		defer r.writeMetrics()
+	}
+	if r.fatal != nil {
+		opt.Logger.Printf("ERR: %v", r.fatal)
+		rep.addErr(r.fatal)
+		return rep
+	}
+	if !r.checkSameRoots() {
+		return rep
+	}
+	if opt.HeartbeatFile != "" {
+		r.writeHeartbeat(time.Now())
+		defer func() { r.writeHeartbeat(time.Now()) }()
+	}
+	if opt.CSVReport != "" {
+		c, err := openCSVReport(opt.CSVReport)
+		if err != nil {
+			err = fmt.Errorf("create CSV report: %w", err)
+			opt.Logger.Printf("ERR: %v", err)
+			rep.addErr(err)
+			return rep
+		}
+		r.csv = c
+		defer r.closeCSVReport()
+	}
+	r.lowerPriority()
+	if opt.IOUring && !opt.DryRun {
+		r.startRing()
+		defer r.stopRing()
+	}
+	if opt.TargetSymlink != TargetSymlinkFollow && r.dstRoot != "" && ArchiveTargetKind(opt.Target) == NotArchive {
+		if !r.checkTargetSymlink() {
+			return rep
+		}
+	}
+
+	if opt.GitSince != "" && !r.listGitChanges() {
+		return rep
+	}
+	r.restrictToFileList()
+	opt = r.opt
+	if r.packKind != NotArchive && !opt.DryRun && !r.startPack() {
+		return rep
+	}
+	if opt.CheckFreeSpace && r.pack == nil && r.est == nil && r.ver == nil && r.plan == nil && !r.checkFreeSpace() {
+		return rep
+	}
+	if opt.CleanTarget && r.pack == nil && r.ver == nil && !r.cleanTarget() {
+		return rep
+	}
+
+	if opt.CompletionMarker != "" && !opt.DryRun {
+		r.removeStaleMarker()
+	}
+	if opt.CleanStaleTemps {
+		r.cleanStaleTemps()
+	}
+	if opt.TargetKnownEmpty {
+		r.emptyTarget = r.emptyTarget || r.targetIsEmpty()
+	}
+	if opt.SubtreeCheck {
+		if len(r.sources) > 1 {
+			opt.Logger.Printf("WARN: SubtreeCheck is not supported with multiple sources; walking every file")
+		} else {
+			r.initSubtreeCheck()
+		}
+	}
+	if opt.Transactional {
+		r.txn = newTxn()
+	}
+	if opt.FixCase && !opt.Transactional && !r.emptyTarget && r.plan == nil && r.detectCaseInsensitive() {
+		r.caseNames = map[string]map[string]string{}
+	}
+	sources := r.sources
+	if opt.SourceConflict == SourceLastWins {
+		// Walking in reverse with first-wins semantics lets the last source win
+		// without overwriting the same file once per source.
+		sources = make([]source, len(r.sources))
+		for i, src := range r.sources {
+			sources[len(sources)-1-i] = src
+		}
+	}
+	if r.actions != nil {
+		// Apply: the actions replace walking and comparing
+		r.phase("sync.apply", "", r.applyActions)
+	} else {
+		for i, src := range sources {
+			r.src, r.srcRoot, r.srcIdx = src.fsys, src.root, i
+			r.phase("sync.copy", src.root, r.copyPass)
+			if r.aborted || r.budgetSpent {
+				break
+			}
+		}
+
+		// If DeleteMissing flag is set, remove files in target that are missing from source
+		// (an initially empty target cannot hold such files)
+		if (opt.DeleteMissing || opt.ReportOrphans) && !r.emptyTarget && !opt.RepairPermsOnly && !r.aborted {
+			r.phase("sync.delete", "", r.deleteMissing)
+		}
+		if len(r.gitRemoved) > 0 && !opt.RepairPermsOnly && !r.aborted {
+			r.phase("sync.delete", "", r.deleteGitRemoved)
+		}
+	}
+
+	if r.txn != nil {
+		r.phase("sync.commit", "", r.commit)
+	}
+
+	if len(r.pendingTimes) > 0 {
+		r.phase("sync.metadata", "", r.applyMetadata)
+	}
+
+	if r.pack != nil {
+		r.closePack()
+	}
+
+	if opt.TrashDir != "" && opt.TrashTimestamped && opt.TrashRetention > 0 && !opt.DryRun {
+		r.pruneTrash()
+	}
+
+	if opt.MaxTargetFiles > 0 && ArchiveTargetKind(opt.Target) == NotArchive && !r.aborted {
+		r.phase("sync.limit", "", r.enforceTargetLimit)
+	}
+
+	if r.policy != nil && !r.aborted {
+		r.phase("sync.policy", "", r.enforcePolicy)
+	}
+
+	if opt.ReconcileAfter && !opt.DryRun && ArchiveTargetKind(opt.Target) == NotArchive && !r.aborted {
+		r.phase("sync.reconcile", "", r.reconcile)
+	}
+
+	if len(r.samples) > 0 && !r.aborted {
+		r.phase("sync.sample", "", r.checkSamples)
+	}
+
+	if opt.SubtreeCheck && !opt.DryRun {
+		r.saveSubtreeCheck()
+	}
+
+	if opt.Manifest != "" && !opt.DryRun {
+		r.updateManifest()
+	}
+
+	if opt.CompletionMarker != "" && !opt.DryRun {
+		r.writeMarker()
+	}
+
+	if opt.Syslog {
+		r.sendSyslog()
+	}
+
+	// Return report summarizing the synchronization process
+	return rep
+}
+
+// copyPass walks the current source and copies new and changed files into the target.
+func (r *runner) copyPass() {
+	opt, rep := r.opt, r.rep
+
+	if opt.OneFileSystem {
+		if info, err := fs.Stat(r.src, "."); err == nil {
+			r.rootDev, r.hasRootDev = deviceID(info)
+		}
+	}
+
+	r.guard = nil
+	if opt.GuardWalk {
+		r.guard = newWalkGuard(r.srcRoot, r.dstRoot)
+	}
+
+	if opt.HashConcurrency > 1 && opt.IgnoreModTime && !opt.CompareSizeOnly && r.enc == nil && len(opt.Transforms) == 0 && !r.listed() {
+		r.startHashAhead()
+		defer r.stopHashAhead()
+		r.hashDir(".", ".")
+	}
 
 	// Walk through the source directory tree
-	err := filepath.WalkDir(opt.Source, func(path string, d os.DirEntry, err error) error {
+	err := r.walkSource(func(rel string, d fs.DirEntry, err error) error {
+		r.beat()
+		if r.overBudget() || r.stopped() {
+			return fs.SkipAll
+		}
+		path := r.srcPath(rel)
 		if err != nil {
+			if r.abortOnRoot(r.src, "source", r.srcPath("."), rel, err) {
+				return fs.SkipAll
+			}
 			opt.Logger.Printf("ERR: read %s: %v", path, err)
 			rep.addErr(err)
 			return nil
 		}
-		if path == opt.Source {
+		if d.IsDir() && r.subtreeUnchanged(rel) {
+			// Nothing below this directory changed since the last clean run
+			opt.Logger.Printf("SKIP: %s (subtree unchanged)", path)
+			rep.SkippedSubtrees++
+			return fs.SkipDir
+		}
+		if rel == "." {
+			return nil
+		}
+		if r.pack != nil && r.srcRoot != "" && r.pack.writes(path) {
+			// The archive target lies inside the source
+			return nil
+		}
+		if opt.DirsOnly && !d.IsDir() {
+			return nil
+		}
+		if opt.RepairPermsOnly && d.IsDir() {
+			return nil
+		}
+
+		if d.IsDir() && r.prunedDir(rel, d) {
+			return fs.SkipDir
+		}
+
+		if opt.SkipHidden && isHidden(d) {
+			// Hidden directories are pruned above; hidden files are just skipped
+			opt.Logger.Printf("SKIP: hidden %s", rel)
+			r.skip(rel, SkipHiddenFile)
+			return nil
+		}
+
+		if opt.UseDefaultExcludes && isExcluded(d) {
+			opt.Logger.Printf("SKIP: excluded %s", rel)
+			r.skip(rel, SkipExcluded)
+			return nil
+		}
+
+		if why := r.sidecarSkip(rel); why != "" {
+			opt.Logger.Printf("SKIP: %s (%s)", path, why)
+			r.skip(rel, SkipSidecarIgnore)
+			return nil
+		}
+
+		dstRel, ok := r.targetName(rel, d.IsDir())
+		if !ok {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if n := len(r.dstPath(dstRel)); opt.MaxPathLen > 0 && n > opt.MaxPathLen {
+			if d.IsDir() {
+				// Everything below is too long as well and reported file by file
+				return nil
+			}
+			opt.Logger.Printf("SKIP: %s (target path %d bytes long, limit %d)", path, n, opt.MaxPathLen)
+			rep.SkippedTooLong++
+			r.countSkipReason(dstRel, SkipPathTooLong)
 			return nil
 		}
-		rel, _ := filepath.Rel(opt.Source, path)
-		targetPath := filepath.Join(opt.Target, rel)
 
 		if d.IsDir() {
+			if r.otherDevice(d) {
+				// Do not descend into mount points
+				opt.Logger.Printf("SKIP: %s (different filesystem)", path)
+				return fs.SkipDir
+			}
+			if r.guard != nil {
+				if why := r.guard.enter(rel); why != "" {
+					opt.Logger.Printf("WARN: %s %s; not descending", path, why)
+					return fs.SkipDir
+				}
+			}
+			var below error
+			if r.structureOnly(rel) {
+				// The directory is created, but nothing below it
+				opt.Logger.Printf("SKIP: %s (structure only)", path)
+				below = fs.SkipDir
+			}
 			// Create directories in target as needed
-			if err := os.MkdirAll(targetPath, 0o755); err != nil {
-				opt.Logger.Printf("ERR: mkdir %s: %v", targetPath, err)
-				rep.addErr(err)
+			if r.pack != nil {
+				r.packDir(dstRel, d)
+				return below
+			}
+			if r.plan != nil {
+				if _, err := r.dst.Stat(dstRel); err != nil {
+					r.addAction(ActionMkdir, dstRel, dstRel, nil)
+				}
+				return below
 			}
+			mkdir := r.mkdir
+			if opt.DirsOnly {
+				mkdir = r.mkdirOnly
+			}
+			if mkdir(dstRel) {
+				if r.ha != nil && below == nil {
+					r.hashDir(rel, dstRel)
+				}
+				return below
+			}
+			// Nothing below can be written: one error for the whole subtree instead of one per entry
+			opt.Logger.Printf("SKIP: %s (target directory could not be created)", path)
+			return fs.SkipDir
+		}
+
+		if !r.claim(rel, dstRel) {
 			return nil
 		}
+		if opt.RepairPermsOnly {
+			r.repairPerms(rel, dstRel, d)
+			return nil
+		}
+		r.syncFile(rel, dstRel, d)
+		return nil
+	})
+	if err != nil {
+		opt.Logger.Printf("ERR: walk %s: %v", r.srcPath("."), err)
+		rep.addErr(err)
+	}
+	if r.ra != nil {
+		r.flushCopies()
+	}
+}
+
+// syncFile brings the target file dstRel in line with the source file rel.
+func (r *runner) syncFile(rel, dstRel string, d fs.DirEntry) {
+	opt, rep := r.opt, r.rep
+	path := r.srcPath(rel)
 
-		info, err := d.Info()
+	info, err := d.Info()
+	if err != nil {
+		opt.Logger.Printf("ERR: info %s: %v", path, err)
+		rep.addErr(err)
+		return
+	}
+	if !info.Mode().IsRegular() {
+		opt.Logger.Printf("SKIP: not regular file %s (mode=%v)", path, info.Mode())
+		r.skip(dstRel, SkipNotRegular)
+		return
+	}
+
+	if r.emptyTarget {
+		// Nothing can exist yet; save the stat syscall
+		r.copyEntry(rel, dstRel, info, false)
+		return
+	}
+
+	tst, err := r.dst.Stat(dstRel)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Copy new files that do not exist in target
+			r.copyEntry(rel, dstRel, info, false)
+			return
+		}
+		opt.Logger.Printf("ERR: stat %s: %v", r.dstPath(dstRel), err)
+		rep.addErr(err)
+		return
+	}
+
+	diff, err := r.differ(rel, dstRel, info, tst)
+	if err != nil {
+		opt.Logger.Printf("ERR: compare %s: %v", path, err)
+		rep.addErr(err)
+		return
+	}
+	if !diff && r.spotCheck(rel, dstRel) {
+		diff = true
+	}
+	if diff {
+		if r.versionRe != nil && r.keepNewerVersion(rel, dstRel) {
+			return
+		}
+		if opt.WarnOnNewerTarget && opt.OnConflict != ResolveKeepBoth {
+			r.warnNewerTarget(rel, dstRel, info, tst)
+		}
+		if opt.RespectImmutable && r.immutable(dstRel) {
+			opt.Logger.Printf("SKIP: %s (target %s is immutable)", path, r.dstPath(dstRel))
+			rep.SkippedImmutable++
+			r.countSkipReason(dstRel, SkipImmutable)
+			return
+		}
+		if opt.AppendOnly && r.appendTail(rel, dstRel, info, tst) {
+			return
+		}
+		if opt.OnConflict == ResolveKeepBoth && !r.keepBoth(rel, dstRel, info, tst) {
+			return
+		}
+		// Overwrite files that differ between source and target
+		r.copyEntry(rel, dstRel, info, true)
+	} else {
+		if r.plan != nil {
+			r.addAction(ActionSkip, rel, dstRel, info)
+		}
+		if r.caseNames != nil && r.fixCase(rel, dstRel) {
+			r.markSynced(rel, dstRel, info)
+			return
+		}
+		// Skip files that are identical
+		opt.Logger.Printf("SKIP: %s (identical)", rel)
+		r.skip(dstRel, SkipIdentical)
+		r.markSynced(rel, dstRel, info)
+	}
+}
+
+// targetIsEmpty verifies the TargetKnownEmpty promise.
+func (r *runner) targetIsEmpty() bool {
+	entries, err := fs.ReadDir(r.dst, ".")
+	if err != nil {
+		r.opt.Logger.Printf("WARN: TargetKnownEmpty: read %s: %v; checking every file", r.dstPath("."), err)
+		return false
+	}
+	if len(entries) > 0 {
+		r.opt.Logger.Printf("WARN: TargetKnownEmpty: %s is not empty; checking every file", r.dstPath("."))
+		return false
+	}
+	return true
+}
+
+// walkSource walks the source tree in the configured WalkOrder, or only the entries of FileList.
+func (r *runner) walkSource(fn fs.WalkDirFunc) error {
+	if r.listed() {
+		return r.walkFileList(fn)
+	}
+	if r.opt.WalkOrder == WalkDefault {
+		return fs.WalkDir(r.src, ".", fn)
+	}
+	return walkDirSorted(r.src, ".", r.opt.WalkOrder, fn)
+}
+
+// deleteMissing walks the target and removes files that no longer exist in the source.
+func (r *runner) deleteMissing() {
+	opt, rep := r.opt, r.rep
+	if opt.DirsOnly {
+		if opt.DeleteMissing {
+			r.deleteExtraDirs()
+		}
+		return
+	}
+	// A target file may be spelled differently than the source file it was copied from
+	r.foldTarget = r.caseNames != nil || r.detectCaseInsensitive()
+	var orphans []string
+	err := fs.WalkDir(r.dst, ".", func(rel string, d fs.DirEntry, err error) error {
+		r.beat()
+		if r.stopped() {
+			orphans = nil
+			return fs.SkipAll
+		}
+		path := r.dstPath(rel)
 		if err != nil {
-			opt.Logger.Printf("ERR: info %s: %v", path, err)
+			if r.abortOnRoot(r.dst, "target", r.dstPath("."), rel, err) {
+				// Delete none of the orphans collected for MaxDeletes
+				orphans = nil
+				return fs.SkipAll
+			}
+			opt.Logger.Printf("ERR: read %s: %v", path, err)
 			rep.addErr(err)
 			return nil
 		}
-		if !info.Mode().IsRegular() {
-			opt.Logger.Printf("SKIP: not regular file %s (mode=%v)", path, info.Mode())
-			rep.Skipped++
+		if rel == "." {
 			return nil
 		}
+		srcPath := r.srcPath(rel)
 
-		tst, err := os.Stat(targetPath)
-		if err != nil {
-			if errors.Is(err, os.ErrNotExist) {
-				// Copy new files that do not exist in target
-				if err := copyFile(path, targetPath, info); err != nil {
-					opt.Logger.Printf("ERR: copy NEW %s -> %s: %v", path, targetPath, err)
-					rep.addErr(err)
-					return nil
-				}
-				opt.Logger.Printf("COPY: %s -> %s", path, targetPath)
-				rep.Copied++
-				return nil
+		if r.isTrash(rel) {
+			// Never delete what was already moved to the trash
+			return fs.SkipDir
+		}
+
+		if opt.SkipHidden && isHidden(d) {
+			// Hidden entries are never synced, so never delete them either
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if opt.UseDefaultExcludes && isExcluded(d) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			if r.structureOnly(rel) && r.statSources(rel) == nil {
+				// Its contents are not synced, so not deleted either
+				return fs.SkipDir
 			}
-			opt.Logger.Printf("ERR: stat %s: %v", targetPath, err)
+			// Skip directories during delete pass
+			return nil
+		}
+		// Entries come from ReadDir and describe symlinks themselves, as Lstat would: a dangling
+		// link or one to a directory is an orphan like any file, and Remove deletes only the link.
+		if r.sanitized[rel] {
+			// Renamed counterpart of a source file with an illegal name
+			return nil
+		}
+		if r.isConflictCopy(rel) {
+			// Kept by ResolveKeepBoth for the user to reconcile
+			return nil
+		}
+		if r.txn != nil && r.txn.isStaged(rel) {
+			// Staged temp files are renamed into place at commit
+			return nil
+		}
+
+		// Check if corresponding source file exists
+		err = r.statSources(rel)
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, fs.ErrNotExist):
+		case opt.DeleteOnStatError == StatErrorDelete:
+			opt.Logger.Printf("WARN: stat %s: %v; deleting target file anyway", srcPath, err)
+		case opt.DeleteOnStatError == StatErrorError:
+			// The source cannot be trusted to tell what is missing
+			opt.Logger.Printf("ERR: stat %s: %v; stopping the delete pass", srcPath, err)
+			rep.addErr(err)
+			return fs.SkipAll
+		default:
+			opt.Logger.Printf("ERR: stat %s: %v", srcPath, err)
 			rep.addErr(err)
 			return nil
 		}
 
-		if differ(info, tst) {
-			// Overwrite files that differ between source and target
-			if err := copyFile(path, targetPath, info); err != nil {
-				opt.Logger.Printf("ERR: overwrite %s -> %s: %v", path, targetPath, err)
-				rep.addErr(err)
+		if r.foldTarget {
+			if twin, ok := r.sourceCaseTwin(rel); ok {
+				opt.Logger.Printf("SKIP: %s (source %s differs only in case)", path, r.srcPath(twin))
 				return nil
 			}
-			opt.Logger.Printf("OVERWRITE: %s -> %s", path, targetPath)
-			rep.Overwritten++
-		} else {
-			// Skip files that are identical
-			opt.Logger.Printf("SKIP: %s (identical)", rel)
-			rep.Skipped++
 		}
+
+		if opt.ReportOrphans {
+			opt.Logger.Printf("ORPHAN: %s", path)
+			rep.Orphans = append(rep.Orphans, rel)
+		}
+		if !opt.DeleteMissing {
+			return nil
+		}
+
+		if opt.VerifyBeforeDelete && !r.confirmMissing(rel) {
+			return nil
+		}
+
+		// Remove file from target if missing in source
+		if r.est != nil {
+			r.est.addDelete(d)
+		}
+		if r.ver != nil {
+			r.ver.Orphans = append(r.ver.Orphans, path)
+		}
+		if r.plan != nil {
+			r.addAction(ActionDelete, rel, rel, nil)
+		}
+		if opt.MaxDeletes > 0 {
+			// Counted first, deleted below
+			orphans = append(orphans, rel)
+			return nil
+		}
+		r.removeEntry(rel)
 		return nil
 	})
 	if err != nil {
-		opt.Logger.Printf("ERR: walk %s: %v", opt.Source, err)
+		opt.Logger.Printf("ERR: walk target %s: %v", r.dstPath("."), err)
 		rep.addErr(err)
 	}
+	if opt.MaxDeletes > 0 {
+		r.removeLimited(orphans)
+	}
+}
 
-	// If DeleteMissing flag is set, remove files in target that are missing from source
-	if opt.DeleteMissing {
-		err = filepath.WalkDir(opt.Target, func(path string, d os.DirEntry, err error) error {
-			if err != nil {
-				opt.Logger.Printf("ERR: read %s: %v", path, err)
-				rep.addErr(err)
-				return nil
-			}
-			if path == opt.Target {
-				return nil
-			}
-			rel, _ := filepath.Rel(opt.Target, path)
-			srcPath := filepath.Join(opt.Source, rel)
+// mkdir creates a target directory, remembering it for rollback in transactional mode.
+// It returns false if that failed; the error has been recorded.
+func (r *runner) mkdir(rel string) bool {
+	if r.opt.DryRun {
+		return true
+	}
+	if r.txn != nil {
+		if _, err := r.dst.Stat(rel); err == nil {
+			return true
+		}
+		r.txn.dirs = append(r.txn.dirs, rel)
+	}
+	if err := r.dst.MkdirAll(rel, 0o755); err != nil {
+		r.opt.Logger.Printf("ERR: mkdir %s: %v", r.dstPath(rel), err)
+		r.rep.addErr(err)
+		return false
+	}
+	return true
+}
 
-			if d.IsDir() {
-				// Skip directories during delete pass
-				return nil
-			}
+// otherDevice reports whether a source directory is on a different device than the source root.
+func (r *runner) otherDevice(d fs.DirEntry) bool {
+	if !r.hasRootDev {
+		return false
+	}
+	info, err := d.Info()
+	if err != nil {
+		return false
+	}
+	dev, ok := deviceID(info)
+	return ok && dev != r.rootDev
+}
 
-			// Check if corresponding source file exists
-			if _, err := os.Stat(srcPath); err != nil {
-				if errors.Is(err, os.ErrNotExist) {
-					// Remove file from target if missing in source
-					if rmErr := os.Remove(path); rmErr != nil {
-						opt.Logger.Printf("ERR: delete %s: %v", path, rmErr)
-						rep.addErr(rmErr)
-						return nil
-					}
-					opt.Logger.Printf("DELETE: %s (missing in source)", path)
-					rep.Deleted++
-					return nil
-				}
-				opt.Logger.Printf("ERR: stat %s: %v", srcPath, err)
-				rep.addErr(err)
+// copyEntry copies (or, in transactional mode, stages) a new or changed file.
+func (r *runner) copyEntry(rel, dstRel string, info fs.FileInfo, overwrite bool) {
+	path, targetPath := r.srcPath(rel), r.dstPath(dstRel)
+	if r.opt.SkipLockedFiles && r.locked(rel) {
+		r.opt.Logger.Printf("SKIP: %s (locked by another process)", path)
+		r.rep.SkippedLocked++
+		r.countSkipReason(dstRel, SkipLocked)
+		return
+	}
+	if overwrite && r.opt.ComputeChurn {
+		r.noteChurnBase(dstRel)
+	}
+	if r.opt.DryRun {
+		r.logCopied(rel, dstRel, info, overwrite)
+		return
+	}
+	if r.pack != nil {
+		r.traceFile(dstRel, info, overwrite, func() error {
+			if err := r.pack.file(r.src, rel, dstRel, info); err != nil {
+				r.opt.Logger.Printf("ERR: pack %s -> %s: %v", path, targetPath, err)
+				r.rep.addErr(err)
+				r.recordCopy(dstRel, 0, overwrite, err)
+				return err
 			}
+			r.logCopied(rel, dstRel, info, overwrite)
 			return nil
 		})
+		return
+	}
+	if r.ra != nil {
+		r.queueCopy(rel, dstRel, info, overwrite)
+		return
+	}
+	r.traceFile(dstRel, info, overwrite, func() error {
+		return r.writeEntry(r.src, rel, dstRel, info, overwrite)
+	})
+}
+
+// writeEntry copies (or stages) rel read from src into the target.
+// A returned error has already been logged and recorded.
+func (r *runner) writeEntry(src fs.FS, rel, dstRel string, info fs.FileInfo, overwrite bool) error {
+	path, targetPath := r.srcPath(rel), r.dstPath(dstRel)
+	if ts := r.transforms(); len(ts) > 0 {
+		src = transformFS{FS: src, transforms: ts}
+	}
+	if r.opt.ContentValidator != nil {
+		src = validatingFS{FS: src, validate: r.opt.ContentValidator}
+	}
+	if r.enc != nil {
+		src = encryptFS{FS: src, enc: r.enc}
+	}
+	sum := r.checksumWriter(src)
+	if sum != nil {
+		src = sum
+	}
+	if r.txn != nil {
+		var tmp string
+		info, err := r.copyStable(rel, info, func(info fs.FileInfo) (err error) {
+			tmp, err = stageFS(src, rel, r.writeTarget(info), dstRel, info)
+			return err
+		})
 		if err != nil {
-			opt.Logger.Printf("ERR: walk target %s: %v", opt.Target, err)
-			rep.addErr(err)
+			r.opt.Logger.Printf("ERR: stage %s -> %s: %v", path, targetPath, err)
+			r.rep.addErr(err)
+			r.recordCopy(dstRel, 0, overwrite, err)
+			return err
 		}
+		r.storeChecksum(sum, tmp, info)
+		r.copyACL(rel, tmp)
+		r.copyOwner(info, tmp)
+		r.txn.stage(stagedFile{tmp: tmp, rel: rel, dstRel: dstRel, info: info, overwrite: overwrite})
+		return nil
 	}
+	info, err := r.copyStable(rel, info, func(info fs.FileInfo) error { return r.place(src, rel, dstRel, info) })
+	if err != nil {
+		if overwrite {
+			r.opt.Logger.Printf("ERR: overwrite %s -> %s: %v", path, targetPath, err)
+		} else {
+			r.opt.Logger.Printf("ERR: copy NEW %s -> %s: %v", path, targetPath, err)
+		}
+		r.rep.addErr(err)
+		r.recordCopy(dstRel, 0, overwrite, err)
+		return err
+	}
+	r.storeChecksum(sum, dstRel, info)
+	r.copyACL(rel, dstRel)
+	r.copyOwner(info, dstRel)
+	r.deferTimes(dstRel, info)
+	r.logCopied(rel, dstRel, info, overwrite)
+	return nil
+}
 
-	// Return report summarizing the synchronization process
-	return rep
+func (r *runner) logCopied(rel, dstRel string, info fs.FileInfo, overwrite bool) {
+	if r.plan != nil {
+		if overwrite {
+			r.addAction(ActionOverwrite, rel, dstRel, info)
+		} else {
+			r.addAction(ActionCopy, rel, dstRel, info)
+		}
+	}
+	r.markSynced(rel, dstRel, info)
+	r.recordCopy(dstRel, info.Size(), overwrite, nil)
+	r.rep.BytesCopied += info.Size()
+	if r.est != nil {
+		r.est.addCopy(info, overwrite)
+	}
+	if r.ver != nil {
+		r.ver.addCopy(r.dstPath(dstRel), overwrite)
+	}
+	if overwrite {
+		r.opt.Logger.Printf("OVERWRITE: %s -> %s", r.srcPath(rel), r.dstPath(dstRel))
+		r.rep.Overwritten++
+		r.addChurn(dstRel, info.Size())
+		r.addDirStat(dstRel, func(st *DirStat) { st.Overwritten++; st.BytesCopied += info.Size() })
+		return
+	}
+	r.opt.Logger.Printf("COPY: %s -> %s", r.srcPath(rel), r.dstPath(dstRel))
+	r.rep.Copied++
+	r.addDirStat(dstRel, func(st *DirStat) { st.Copied++; st.BytesCopied += info.Size() })
+}
+
+// countDeleted counts a removed (or, in a dry run, removable) target file.
+func (r *runner) countDeleted(rel string) {
+	r.record(CSVDelete, rel, 0, nil)
+	r.rep.Deleted++
+	r.addDirStat(rel, func(st *DirStat) { st.Deleted++ })
+}
+
+// removeEntry deletes (or, in transactional mode, schedules the deletion of) a target file.
+func (r *runner) removeEntry(rel string) {
+	if r.txn != nil {
+		r.txn.deletes = append(r.txn.deletes, rel)
+		return
+	}
+	path := r.dstPath(rel)
+	if r.opt.DryRun {
+		r.opt.Logger.Printf("DELETE: %s (missing in source)", path)
+		r.countDeleted(rel)
+		return
+	}
+	if r.opt.TrashDir != "" {
+		dest, err := r.moveToTrash(rel)
+		if err != nil {
+			r.opt.Logger.Printf("ERR: trash %s: %v", path, err)
+			r.rep.addErr(err)
+			r.record(CSVDelete, rel, 0, err)
+			return
+		}
+		r.opt.Logger.Printf("TRASH: %s -> %s (missing in source)", path, r.dstPath(dest))
+		r.countDeleted(rel)
+		return
+	}
+	if err := r.dst.Remove(rel); err != nil {
+		r.opt.Logger.Printf("ERR: delete %s: %v", path, err)
+		r.rep.addErr(err)
+		r.record(CSVDelete, rel, 0, err)
+		return
+	}
+	r.opt.Logger.Printf("DELETE: %s (missing in source)", path)
+	r.countDeleted(rel)
+}
+
+// differ applies the configured comparison to a source file and its existing target counterpart.
+// With LineEndings, files found different are compared once more by converted content.
+func (r *runner) differ(rel, dstRel string, src, dst fs.FileInfo) (bool, error) {
+	diff, err := r.differRaw(rel, dstRel, src, dst)
+	if err != nil || !diff || r.opt.LineEndings == LineEndingsPreserve || len(r.opt.Transforms) > 0 || r.enc != nil {
+		return diff, err
+	}
+	same, err := r.sameContent(rel, dstRel)
+	return !same, err
+}
+
+func (r *runner) differRaw(rel, dstRel string, src, dst fs.FileInfo) (bool, error) {
+	if r.enc != nil {
+		return r.differEncrypted(rel, dstRel, src, dst)
+	}
+	if len(r.opt.Transforms) > 0 {
+		// Transformed targets match their sources in neither size nor content
+		if r.opt.IgnoreModTime {
+			same, err := r.sameContent(rel, dstRel)
+			return !same, err
+		}
+		return modTimeDiffers(src, dst, r.opt.ModTimeTolerance), nil
+	}
+	if r.opt.CompareSizeOnly {
+		return src.Size() != dst.Size(), nil
+	}
+	if r.opt.IgnoreModTime && r.hashed(rel) {
+		if src.Size() != dst.Size() {
+			return true, nil
+		}
+		if r.ha != nil {
+			if same, err, ok := r.aheadResult(rel, dstRel, src, dst); ok {
+				return !same, err
+			}
+		}
+		same, err := r.sameAsTarget(rel, dstRel, dst)
+		return !same, err
+	}
+	if r.opt.ModTimeTolerance > 0 {
+		return differWithin(src, dst, r.opt.ModTimeTolerance), nil
+	}
+	return differ(src, dst), nil
+}
+
+// differWithin is like differ but treats mod-times at most tolerance apart as equal.
+func differWithin(src, dst fs.FileInfo, tolerance time.Duration) bool {
+	return src.Size() != dst.Size() || modTimeDiffers(src, dst, tolerance)
+}
+
+// modTimeDiffers compares mod-times only: more than tolerance apart or, without one,
+// in different whole seconds.
+func modTimeDiffers(src, dst fs.FileInfo, tolerance time.Duration) bool {
+	if tolerance <= 0 {
+		return !truncateToSeconds(src.ModTime()).Equal(truncateToSeconds(dst.ModTime()))
+	}
+	d := src.ModTime().Sub(dst.ModTime())
+	if d < 0 {
+		d = -d
+	}
+	return d > tolerance
 }
 
 // differ reports whether two files should be treated as different for synchronization.
 // It first compares sizes; if sizes are equal, it compares modification times truncated to seconds.
 // Truncation avoids false positives due to differing filesystem timestamp precision (e.g., FAT, some network mounts).
 // Returns true if files differ by size or (rounded) mod-time.
-func differ(src, dst os.FileInfo) bool {
+func differ(src, dst fs.FileInfo) bool {
 	// Fast path: any size mismatch means we must copy/overwrite.
 	if src.Size() != dst.Size() {
 		return true
@@ -168,54 +1503,91 @@ func truncateToSeconds(t time.Time) time.Time {
 	return t.Truncate(time.Second)
 }
 
+// copyFile copies a single file between two OS paths.
 func copyFile(srcPath, dstPath string, srcInfo os.FileInfo) error {
+	return copyFS(dirFS(filepath.Dir(srcPath)), filepath.Base(srcPath),
+		dirFS(filepath.Dir(dstPath)), filepath.Base(dstPath), srcInfo)
+}
+
+// copyFS copies srcName from src to dstName in dst, replacing the destination atomically.
+func copyFS(src fs.FS, srcName string, dst WritableFS, dstName string, srcInfo fs.FileInfo) error {
+	tmp, err := stageFS(src, srcName, dst, dstName, srcInfo)
+	if err != nil {
+		return err
+	}
+
+	// Atomically replace (or create) destination by renaming temp -> dst.
+	if err := dst.Rename(tmp, dstName); err != nil {
+		// Best-effort cleanup of leftover temp file on error.
+		_ = dst.Remove(tmp)
+		return fmt.Errorf("rename: %w", err)
+	}
+	// Success: temp replaced destination; nothing else to do.
+	return nil
+}
+
+// stageFS writes srcName into a temp file next to dstName, with the source mod-time applied,
+// and returns the temp name. The caller is responsible for renaming or removing it.
+func stageFS(src fs.FS, srcName string, dst WritableFS, dstName string, srcInfo fs.FileInfo) (string, error) {
+	// Write into a temporary file next to the destination to enable atomic replace.
+	tmp := tempName(dstName)
+	if err := writeFS(src, srcName, dst, tmp, "tmp", srcInfo); err != nil {
+		return "", err
+	}
+	return tmp, nil
+}
+
+// writeFS writes srcName into name, creating or truncating it, with the source mod-time applied.
+// kind names the written file in errors ("tmp" or "dst"). On failure name is removed.
+func writeFS(src fs.FS, srcName string, dst WritableFS, name, kind string, srcInfo fs.FileInfo) error {
 	// Ensure destination directory exists (idempotent).
-	if err := os.MkdirAll(filepath.Dir(dstPath), 0o755); err != nil {
-		return fmt.Errorf("mkdir %s: %w", filepath.Dir(dstPath), err)
+	if dir := path.Dir(name); dir != "." {
+		if err := dst.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("mkdir %s: %w", dir, err)
+		}
 	}
 
 	// Open source file for reading.
-	sf, err := os.Open(srcPath)
+	sf, err := src.Open(srcName)
 	if err != nil {
 		return fmt.Errorf("open src: %w", err)
 	}
 	defer sf.Close()
 
-	// Write into a temporary file next to the destination to enable atomic replace.
-	tmp := dstPath + ".tmp~"
-	df, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, srcInfo.Mode().Perm())
+	df, err := dst.Create(name, srcInfo.Mode().Perm())
 	if err != nil {
-		return fmt.Errorf("open tmp: %w", err)
+		return fmt.Errorf("open %s: %w", kind, err)
 	}
 
-	// Stream copy data from source to temp; avoid loading whole file into memory.
-	_, cErr := io.Copy(df, sf)
-	// Close temp file before further metadata operations and rename.
+	// Stream copy data from source to the file; avoid loading whole file into memory.
+	var cErr error
+	if fc, ok := df.(fileCopier); ok {
+		var copied bool
+		if copied, cErr = fc.copyFrom(sf); !copied && cErr == nil {
+			_, cErr = io.Copy(df, sf)
+		}
+	} else {
+		_, cErr = io.Copy(df, sf)
+	}
+	// Close the file before further metadata operations and rename.
 	cCloseErr := df.Close()
 	if cErr != nil {
-		// Best-effort cleanup of leftover temp file on error.
-		_ = os.Remove(tmp)
+		// Best-effort cleanup of leftover file on error.
+		_ = dst.Remove(name)
 		return fmt.Errorf("copy: %w", cErr)
 	}
 	if cCloseErr != nil {
-		// Best-effort cleanup of leftover temp file on error.
-		_ = os.Remove(tmp)
-		return fmt.Errorf("close tmp: %w", cCloseErr)
+		// Best-effort cleanup of leftover file on error.
+		_ = dst.Remove(name)
+		return fmt.Errorf("close %s: %w", kind, cCloseErr)
 	}
 
 	// Preserve source modification time on the newly written file (helps future differ()).
-	if err := os.Chtimes(tmp, time.Now(), srcInfo.ModTime()); err != nil {
-		// Best-effort cleanup of leftover temp file on error.
-		_ = os.Remove(tmp)
+	if err := dst.Chtimes(name, time.Now(), srcInfo.ModTime()); err != nil {
+		// Best-effort cleanup of leftover file on error.
+		_ = dst.Remove(name)
 		return fmt.Errorf("chtimes: %w", err)
 	}
 
-	// Atomically replace (or create) destination by renaming temp -> dst.
-	if err := os.Rename(tmp, dstPath); err != nil {
-		// Best-effort cleanup of leftover temp file on error.
-		_ = os.Remove(tmp)
-		return fmt.Errorf("rename: %w", err)
-	}
-	// Success: temp replaced destination; nothing else to do.
 	return nil
 }
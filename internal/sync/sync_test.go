@@ -183,8 +183,13 @@ func TestCopyFile_RenameFailureCleansTemp(t *testing.T) {
 		t.Fatalf("expected rename error, got: %v", err)
 	}
 
-	tmpPath := dst + ".tmp~"
-	if _, statErr := os.Stat(tmpPath); !os.IsNotExist(statErr) {
-		t.Fatalf("temp file not cleaned up: err=%v", statErr)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if isTempName(e.Name()) {
+			t.Fatalf("temp file not cleaned up: %s", e.Name())
+		}
 	}
 }
@@ -0,0 +1,16 @@
+package sync
+
+// Syncer runs synchronizations for a fixed set of Options.
+type Syncer struct {
+	opt Options
+}
+
+// New returns a Syncer for opt.
+func New(opt Options) *Syncer {
+	return &Syncer{opt: opt}
+}
+
+// Run performs the synchronization; it is equivalent to Sync(opt).
+func (s *Syncer) Run() *Report {
+	return Sync(s.opt)
+}
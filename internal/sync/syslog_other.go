@@ -0,0 +1,8 @@
+//go:build !unix
+
+package sync
+
+// sendSyslog is unavailable without log/syslog.
+func (r *runner) sendSyslog() {
+	r.opt.Logger.Printf("WARN: syslog is not supported on this platform")
+}
@@ -0,0 +1,64 @@
+//go:build unix
+
+package sync
+
+import (
+	"fmt"
+	"log/syslog"
+	"strings"
+)
+
+// defaultSyslogTag is the syslog tag used when Options.SyslogTag is empty.
+const defaultSyslogTag = "sync-service"
+
+// syslogWriter is the subset of *syslog.Writer used to deliver reports.
+type syslogWriter interface {
+	Info(m string) error
+	Err(m string) error
+	Close() error
+}
+
+// dialSyslog connects to the local system logger; tests replace it with a fake.
+var dialSyslog = func(facility syslog.Priority, tag string) (syslogWriter, error) {
+	return syslog.New(facility|syslog.LOG_INFO, tag)
+}
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern": syslog.LOG_KERN, "user": syslog.LOG_USER, "mail": syslog.LOG_MAIL, "daemon": syslog.LOG_DAEMON,
+	"auth": syslog.LOG_AUTH, "syslog": syslog.LOG_SYSLOG, "lpr": syslog.LOG_LPR, "news": syslog.LOG_NEWS,
+	"uucp": syslog.LOG_UUCP, "cron": syslog.LOG_CRON, "authpriv": syslog.LOG_AUTHPRIV, "ftp": syslog.LOG_FTP,
+	"local0": syslog.LOG_LOCAL0, "local1": syslog.LOG_LOCAL1, "local2": syslog.LOG_LOCAL2, "local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4, "local5": syslog.LOG_LOCAL5, "local6": syslog.LOG_LOCAL6, "local7": syslog.LOG_LOCAL7,
+}
+
+// sendSyslog delivers the run's errors at LOG_ERR and its summary at LOG_INFO.
+func (r *runner) sendSyslog() {
+	facility := syslog.LOG_USER
+	if name := r.opt.SyslogFacility; name != "" {
+		f, ok := syslogFacilities[strings.ToLower(name)]
+		if !ok {
+			r.opt.Logger.Printf("WARN: syslog: unknown facility %q, using user", name)
+		} else {
+			facility = f
+		}
+	}
+	tag := r.opt.SyslogTag
+	if tag == "" {
+		tag = defaultSyslogTag
+	}
+
+	w, err := dialSyslog(facility, tag)
+	if err != nil {
+		r.opt.Logger.Printf("WARN: syslog: %v", err)
+		return
+	}
+	defer w.Close()
+
+	for _, e := range r.rep.Errors {
+		_ = w.Err(e.Error())
+	}
+	if r.rep.DroppedErrors > 0 {
+		_ = w.Err(fmt.Sprintf("... and %d more errors", r.rep.DroppedErrors))
+	}
+	_ = w.Info(fmt.Sprintf("sync %s -> %s: %s", r.srcPath("."), r.dstPath("."), r.rep))
+}
@@ -0,0 +1,74 @@
+//go:build unix
+
+package sync
+
+import (
+	"log/syslog"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+type syslogLine struct {
+	prio string
+	msg  string
+}
+
+type fakeSyslog struct {
+	lines  []syslogLine
+	closed bool
+}
+
+func (f *fakeSyslog) Info(m string) error {
+	f.lines = append(f.lines, syslogLine{"info", m})
+	return nil
+}
+
+func (f *fakeSyslog) Err(m string) error {
+	f.lines = append(f.lines, syslogLine{"err", m})
+	return nil
+}
+
+func (f *fakeSyslog) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestSyslog(t *testing.T) {
+	fake := &fakeSyslog{}
+	var gotFacility syslog.Priority
+	var gotTag string
+	orig := dialSyslog
+	dialSyslog = func(facility syslog.Priority, tag string) (syslogWriter, error) {
+		gotFacility, gotTag = facility, tag
+		return fake, nil
+	}
+	defer func() { dialSyslog = orig }()
+
+	src := fstest.MapFS{
+		"a.txt":   {Data: []byte("a")},
+		"bad.txt": {Data: []byte("b")},
+	}
+	rep := SyncFS(failOpenFS{FS: src, fail: "bad.txt"}, newMemFS(), Options{
+		Syslog: true, SyslogFacility: "local3", SyslogTag: "backup",
+	})
+	if len(rep.Errors) != 1 {
+		t.Fatalf("expected one error, got %v", rep.Errors)
+	}
+
+	if gotFacility != syslog.LOG_LOCAL3 || gotTag != "backup" {
+		t.Fatalf("unexpected facility/tag: %v %q", gotFacility, gotTag)
+	}
+	if !fake.closed {
+		t.Fatalf("syslog writer not closed")
+	}
+	if len(fake.lines) != 2 {
+		t.Fatalf("expected 2 syslog lines, got %+v", fake.lines)
+	}
+	if l := fake.lines[0]; l.prio != "err" || !strings.Contains(l.msg, "injected failure") {
+		t.Fatalf("expected error at LOG_ERR, got %+v", l)
+	}
+	if l := fake.lines[1]; l.prio != "info" || !strings.Contains(l.msg, "copied=1") || !strings.Contains(l.msg, "errors=1") {
+		t.Fatalf("expected summary at LOG_INFO, got %+v", l)
+	}
+}
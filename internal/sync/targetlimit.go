@@ -0,0 +1,102 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// TargetLimitPolicy is what happens when the target holds more than Options.MaxTargetFiles files.
+type TargetLimitPolicy int
+
+const (
+	// TargetLimitReport records an error and changes nothing.
+	TargetLimitReport TargetLimitPolicy = iota
+	// TargetLimitPrune deletes the files with the oldest mod-times until the limit is met.
+	TargetLimitPrune
+)
+
+var targetLimitPolicyNames = map[TargetLimitPolicy]string{
+	TargetLimitReport: "report",
+	TargetLimitPrune:  "prune",
+}
+
+func (p TargetLimitPolicy) String() string {
+	if s, ok := targetLimitPolicyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("TargetLimitPolicy(%d)", int(p))
+}
+
+// ParseTargetLimitPolicy parses the CLI spelling of a TargetLimitPolicy ("report", "prune").
+func ParseTargetLimitPolicy(s string) (TargetLimitPolicy, error) {
+	for p, name := range targetLimitPolicyNames {
+		if name == s {
+			return p, nil
+		}
+	}
+	return TargetLimitReport, fmt.Errorf("unknown target limit policy %q", s)
+}
+
+// targetFile is a regular target file considered by enforceTargetLimit.
+type targetFile struct {
+	rel     string
+	modTime time.Time
+}
+
+// enforceTargetLimit counts the regular files in the target after the run and applies
+// Options.TargetLimit when there are more than Options.MaxTargetFiles.
+func (r *runner) enforceTargetLimit() {
+	var files []targetFile
+	err := fs.WalkDir(r.dst, ".", func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && rel == "." {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if r.isTrash(rel) {
+			return fs.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, targetFile{rel: rel, modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("count target files: %w", err)
+		r.opt.Logger.Printf("ERR: %v", err)
+		r.rep.addErr(err)
+		return
+	}
+	limit := r.opt.MaxTargetFiles
+	if len(files) <= limit {
+		return
+	}
+	if r.opt.TargetLimit != TargetLimitPrune {
+		err := fmt.Errorf("target %s holds %d files, more than the limit of %d", r.dstPath("."), len(files), limit)
+		r.opt.Logger.Printf("ERR: %v", err)
+		r.rep.addErr(err)
+		return
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files[:len(files)-limit] {
+		path := r.dstPath(f.rel)
+		if !r.opt.DryRun {
+			if err := r.dst.Remove(f.rel); err != nil {
+				r.opt.Logger.Printf("ERR: prune %s: %v", path, err)
+				r.rep.addErr(err)
+				continue
+			}
+		}
+		r.opt.Logger.Printf("PRUNE: %s (oldest beyond %d target files)", path, limit)
+		r.rep.Pruned++
+	}
+}
@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestMaxTargetFiles(t *testing.T) {
+	base := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	src := fstest.MapFS{}
+	for i := 0; i < 3; i++ {
+		// Today's logs, newer than everything already in the target
+		src[fmt.Sprintf("logs/new%d.log", i)] = &fstest.MapFile{Data: []byte("new"), ModTime: base.Add(time.Hour + time.Duration(i)*time.Minute)}
+	}
+	newTarget := func() *memFS {
+		dst := newMemFS()
+		for i := 0; i < 5; i++ {
+			// Rotated away from the source long ago, oldest first
+			dst.MapFS[fmt.Sprintf("logs/old%d.log", i)] = &fstest.MapFile{Data: []byte("old"), ModTime: base.Add(time.Duration(i) * time.Minute)}
+		}
+		return dst
+	}
+
+	t.Run("prune", func(t *testing.T) {
+		dst := newTarget()
+		rep := SyncFS(src, dst, Options{MaxTargetFiles: 4, TargetLimit: TargetLimitPrune})
+		if len(rep.Errors) != 0 || rep.Copied != 3 || rep.Pruned != 4 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		var got []string
+		for name, f := range dst.MapFS {
+			if !f.Mode.IsDir() {
+				got = append(got, name)
+			}
+		}
+		want := []string{"logs/new0.log", "logs/new1.log", "logs/new2.log", "logs/old4.log"}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v want %v", got, want)
+		}
+	})
+
+	t.Run("report", func(t *testing.T) {
+		dst := newTarget()
+		rep := SyncFS(src, dst, Options{MaxTargetFiles: 4})
+		if len(rep.Errors) != 1 || !strings.Contains(rep.Errors[0].Error(), "holds 8 files, more than the limit of 4") || rep.Pruned != 0 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if len(dst.MapFS) != 9 {
+			t.Fatalf("target changed: %v", dst.MapFS)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		dst := newTarget()
+		rep := SyncFS(src, dst, Options{MaxTargetFiles: 3, TargetLimit: TargetLimitPrune, DryRun: true})
+		if len(rep.Errors) != 0 || rep.Pruned != 2 || len(dst.MapFS) != 5 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// TargetSymlinkPolicy is the Options.TargetSymlink policy.
+type TargetSymlinkPolicy int
+
+const (
+	// TargetSymlinkFollow syncs into the directory the symlink points to.
+	TargetSymlinkFollow TargetSymlinkPolicy = iota
+	// TargetSymlinkReplace removes the symlink (not what it points to) and syncs into a new, real directory.
+	TargetSymlinkReplace
+	// TargetSymlinkError aborts the run without changing anything.
+	TargetSymlinkError
+)
+
+var targetSymlinkPolicyNames = map[TargetSymlinkPolicy]string{
+	TargetSymlinkFollow:  "follow",
+	TargetSymlinkReplace: "replace",
+	TargetSymlinkError:   "error",
+}
+
+func (p TargetSymlinkPolicy) String() string {
+	if s, ok := targetSymlinkPolicyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("TargetSymlinkPolicy(%d)", int(p))
+}
+
+// ParseTargetSymlinkPolicy parses the CLI spelling of a TargetSymlinkPolicy ("follow", "replace", "error").
+func ParseTargetSymlinkPolicy(s string) (TargetSymlinkPolicy, error) {
+	for p, name := range targetSymlinkPolicyNames {
+		if name == s {
+			return p, nil
+		}
+	}
+	return TargetSymlinkFollow, fmt.Errorf("unknown target symlink policy %q", s)
+}
+
+// checkTargetSymlink applies Options.TargetSymlink when the target directory is a symlink.
+// It returns false if the run must stop; the error has been recorded.
+func (r *runner) checkTargetSymlink() bool {
+	target := r.dstRoot
+	info, err := os.Lstat(target)
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		// Missing targets are created as usual
+		return true
+	}
+	if r.opt.TargetSymlink == TargetSymlinkError {
+		err := fmt.Errorf("target %s is a symlink", target)
+		r.opt.Logger.Printf("ERR: %v", err)
+		r.rep.addErr(err)
+		return false
+	}
+
+	r.opt.Logger.Printf("REPLACE: symlink %s with a directory", target)
+	// The new directory starts out empty
+	r.emptyTarget = true
+	if r.opt.DryRun {
+		return true
+	}
+	if err := os.Remove(target); err != nil {
+		r.opt.Logger.Printf("ERR: remove symlink %s: %v", target, err)
+		r.rep.addErr(err)
+		return false
+	}
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		r.opt.Logger.Printf("ERR: mkdir %s: %v", target, err)
+		r.rep.addErr(err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package sync
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTargetSymlink(t *testing.T) {
+	setup := func(t *testing.T) (src, link, release string) {
+		src = t.TempDir()
+		mustWrite(t, filepath.Join(src, "app.txt"), "v2")
+		base := t.TempDir()
+		release = filepath.Join(base, "release-1")
+		mustWrite(t, filepath.Join(release, "old.txt"), "v1")
+		link = filepath.Join(base, "current")
+		if err := os.Symlink(release, link); err != nil {
+			t.Skipf("symlinks unavailable: %v", err)
+		}
+		return src, link, release
+	}
+	exists := func(p string) bool {
+		_, err := os.Stat(p)
+		return err == nil
+	}
+
+	t.Run("follow", func(t *testing.T) {
+		src, link, release := setup(t)
+		rep := Sync(Options{Source: src, Target: link})
+		if len(rep.Errors) != 0 || rep.Copied != 1 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if !exists(filepath.Join(release, "app.txt")) {
+			t.Fatalf("expected the copy to land in the linked directory")
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		src, link, release := setup(t)
+		rep := Sync(Options{Source: src, Target: link, TargetSymlink: TargetSymlinkReplace, DeleteMissing: true})
+		if len(rep.Errors) != 0 || rep.Copied != 1 || rep.Deleted != 0 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		info, err := os.Lstat(link)
+		if err != nil || info.Mode()&fs.ModeSymlink != 0 || !info.IsDir() {
+			t.Fatalf("expected a real directory, got %v, %v", info, err)
+		}
+		if !exists(filepath.Join(link, "app.txt")) || exists(filepath.Join(link, "old.txt")) {
+			t.Fatalf("unexpected content in replaced target")
+		}
+		// The former link target is left alone
+		if !exists(filepath.Join(release, "old.txt")) || exists(filepath.Join(release, "app.txt")) {
+			t.Fatalf("linked directory was modified")
+		}
+	})
+
+	t.Run("replace_dry_run", func(t *testing.T) {
+		src, link, _ := setup(t)
+		rep := Sync(Options{Source: src, Target: link, TargetSymlink: TargetSymlinkReplace, DryRun: true})
+		if len(rep.Errors) != 0 || rep.Copied != 1 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if info, err := os.Lstat(link); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			t.Fatalf("dry run replaced the symlink: %v, %v", info, err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		src, link, release := setup(t)
+		rep := Sync(Options{Source: src, Target: link, TargetSymlink: TargetSymlinkError})
+		if len(rep.Errors) != 1 || rep.Copied != 0 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if exists(filepath.Join(release, "app.txt")) {
+			t.Fatalf("nothing may be written")
+		}
+	})
+
+	t.Run("real_directory", func(t *testing.T) {
+		src := t.TempDir()
+		mustWrite(t, filepath.Join(src, "app.txt"), "v2")
+		rep := Sync(Options{Source: src, Target: t.TempDir(), TargetSymlink: TargetSymlinkError})
+		if len(rep.Errors) != 0 || rep.Copied != 1 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+	})
+}
+
+func TestParseTargetSymlinkPolicy(t *testing.T) {
+	for _, p := range []TargetSymlinkPolicy{TargetSymlinkFollow, TargetSymlinkReplace, TargetSymlinkError} {
+		got, err := ParseTargetSymlinkPolicy(p.String())
+		if err != nil || got != p {
+			t.Fatalf("round trip %v: got %v, %v", p, got, err)
+		}
+	}
+	if _, err := ParseTargetSymlinkPolicy("ignore"); err == nil {
+		t.Fatalf("expected error for unknown policy")
+	}
+}
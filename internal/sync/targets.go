@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"sync"
+	"time"
+)
+
+// syncTargets mirrors the sources into every Options.Targets concurrently, one run per target.
+// A failing target does not affect the others. The returned report sums all targets
+// and holds the individual reports in PerTarget.
+func syncTargets(opt Options) *Report {
+	if opt.SubtreeCheck {
+		// All runs would race on the same checksum DB
+		if opt.Logger != nil {
+			opt.Logger.Printf("WARN: SubtreeCheck is not supported with multiple targets; walking every file")
+		}
+		opt.SubtreeCheck = false
+	}
+	if opt.CSVReport != "" {
+		// All runs would write the same file
+		if opt.Logger != nil {
+			opt.Logger.Printf("WARN: CSVReport is not supported with multiple targets; not writing %s", opt.CSVReport)
+		}
+		opt.CSVReport = ""
+	}
+	if opt.Manifest != "" {
+		// All runs would read and write the same manifest
+		if opt.Logger != nil {
+			opt.Logger.Printf("WARN: Manifest is not supported with multiple targets; not writing %s", opt.Manifest)
+		}
+		opt.Manifest = ""
+	}
+	if opt.HeartbeatFile != "" {
+		if opt.Logger != nil {
+			opt.Logger.Printf("WARN: HeartbeatFile is not supported with multiple targets; not writing %s", opt.HeartbeatFile)
+		}
+		opt.HeartbeatFile = ""
+	}
+
+	start := time.Now()
+	metrics := opt.TextfileMetrics
+	opt.TextfileMetrics = ""
+
+	reps := make([]*Report, len(opt.Targets))
+	var wg sync.WaitGroup
+	for i, target := range opt.Targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			o := opt
+			o.Targets = nil
+			o.Target = target
+			// Run once: Sync retries the whole multi-target run with RetryWholeSync
+			reps[i] = newDirRunner(o).run()
+		}(i, target)
+	}
+	wg.Wait()
+
+	total := newReport(opt)
+	total.PerTarget = map[string]*Report{}
+	for i, target := range opt.Targets {
+		total.merge(reps[i])
+		total.PerTarget[target] = reps[i]
+	}
+	if metrics != "" && !opt.DryRun {
+		end := time.Now()
+		if err := writeTextfileMetrics(metrics, total, end.Sub(start), end); err != nil {
+			if opt.Logger != nil {
+				opt.Logger.Printf("ERR: write metrics %s: %v", metrics, err)
+			}
+			total.addErr(err)
+		}
+	}
+	return total
+}
@@ -0,0 +1,82 @@
+package sync
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMultipleTargets(t *testing.T) {
+	src := t.TempDir()
+	one := t.TempDir()
+	two := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "alpha")
+	mustWrite(t, filepath.Join(src, "sub", "b.txt"), "beta")
+	mustWrite(t, filepath.Join(two, "orphan.txt"), "o")
+
+	rep := Sync(Options{Source: src, Targets: []string{one, two}, DeleteMissing: true})
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+	if len(rep.PerTarget) != 2 {
+		t.Fatalf("expected 2 per-target reports, got %v", rep.PerTarget)
+	}
+	if r := rep.PerTarget[one]; r.Copied != 2 || r.Deleted != 0 {
+		t.Fatalf("unexpected report for %s: %+v", one, *r)
+	}
+	if r := rep.PerTarget[two]; r.Copied != 2 || r.Deleted != 1 {
+		t.Fatalf("unexpected report for %s: %+v", two, *r)
+	}
+	if rep.Copied != 4 || rep.Deleted != 1 {
+		t.Fatalf("unexpected totals: %+v", *rep)
+	}
+
+	for _, name := range []string{"a.txt", filepath.Join("sub", "b.txt")} {
+		b1, err1 := os.ReadFile(filepath.Join(one, name))
+		b2, err2 := os.ReadFile(filepath.Join(two, name))
+		if err1 != nil || err2 != nil || string(b1) != string(b2) {
+			t.Fatalf("%s differs between targets: %q (%v) vs %q (%v)", name, b1, err1, b2, err2)
+		}
+	}
+}
+
+func TestMultipleTargetsFailureIsolated(t *testing.T) {
+	src := t.TempDir()
+	good := t.TempDir()
+	// A regular file cannot be used as a target directory.
+	bad := filepath.Join(t.TempDir(), "not-a-dir")
+	mustWrite(t, bad, "x")
+	mustWrite(t, filepath.Join(src, "a.txt"), "alpha")
+
+	rep := Sync(Options{Source: src, Targets: []string{bad, good}})
+	if len(rep.PerTarget[bad].Errors) == 0 {
+		t.Fatalf("expected errors for the bad target")
+	}
+	if r := rep.PerTarget[good]; r.Copied != 1 || len(r.Errors) != 0 {
+		t.Fatalf("good target affected by the bad one: %+v", *r)
+	}
+	if _, err := os.Stat(filepath.Join(good, "a.txt")); err != nil {
+		t.Fatalf("expected a.txt in good target: %v", err)
+	}
+}
+
+func TestMultipleTargetsIgnoreManifest(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "alpha")
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+
+	var logs bytes.Buffer
+	rep := Sync(Options{Source: src, Targets: []string{t.TempDir(), t.TempDir()}, Manifest: manifest, Logger: log.New(&logs, "", 0)})
+	if len(rep.Errors) != 0 || rep.Copied != 2 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if _, err := os.Stat(manifest); !os.IsNotExist(err) {
+		t.Fatalf("manifest written for multiple targets: %v", err)
+	}
+	if !strings.Contains(logs.String(), "WARN: Manifest is not supported with multiple targets") {
+		t.Fatalf("missing warning in logs:\n%s", logs.String())
+	}
+}
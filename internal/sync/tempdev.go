@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"fmt"
+	"path"
+	"syscall"
+)
+
+// TempDevicePolicy is the Options.TempDevice check of the device holding a temp file.
+type TempDevicePolicy int
+
+const (
+	// TempDeviceAssume renames temp files without checking: a temp file next to its target
+	// normally shares its filesystem.
+	TempDeviceAssume TempDevicePolicy = iota
+	// TempDeviceError fails the file when its temp file and target are on different devices.
+	TempDeviceError
+	// TempDeviceCopy copies the temp file onto the target instead (not atomic) when they are
+	// on different devices or the rename fails with EXDEV.
+	TempDeviceCopy
+)
+
+var tempDevicePolicyNames = map[TempDevicePolicy]string{
+	TempDeviceAssume: "assume",
+	TempDeviceError:  "error",
+	TempDeviceCopy:   "copy",
+}
+
+func (p TempDevicePolicy) String() string {
+	if s, ok := tempDevicePolicyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("TempDevicePolicy(%d)", int(p))
+}
+
+// ParseTempDevicePolicy parses the CLI spelling of a TempDevicePolicy ("assume", "error", "copy").
+func ParseTempDevicePolicy(s string) (TempDevicePolicy, error) {
+	for p, name := range tempDevicePolicyNames {
+		if name == s {
+			return p, nil
+		}
+	}
+	return TempDeviceAssume, fmt.Errorf("unknown temp device policy %q", s)
+}
+
+// checkTempDevice returns an error wrapping syscall.EXDEV if the temp file tmp is on another
+// device than the target file name (or, if it does not exist yet, its directory), e.g. because
+// name is a bind-mounted file. It returns nil when the devices cannot be told.
+func (r *runner) checkTempDevice(tmp, name string) error {
+	ti, err := r.dst.Stat(tmp)
+	if err != nil {
+		return nil
+	}
+	ni, err := r.dst.Stat(name)
+	if err != nil {
+		if ni, err = r.dst.Stat(path.Dir(name)); err != nil {
+			return nil
+		}
+	}
+	td, ok1 := deviceID(ti)
+	nd, ok2 := deviceID(ni)
+	if !ok1 || !ok2 || td == nd {
+		return nil
+	}
+	return fmt.Errorf("temp file %s is on device %d but %s on device %d, so replacing it would not be atomic: %w",
+		r.dstPath(tmp), td, r.dstPath(name), nd, syscall.EXDEV)
+}
+
+// copyOver copies the temp file tmp onto name in place, keeping its mod-time, and removes it.
+func (r *runner) copyOver(tmp, name string) error {
+	info, err := r.dst.Stat(tmp)
+	if err != nil {
+		return err
+	}
+	if err := writeFS(r.dst, tmp, r.dst, name, "dst", info); err != nil {
+		return err
+	}
+	return r.dst.Remove(tmp)
+}
@@ -0,0 +1,69 @@
+//go:build unix
+
+package sync
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// bindFS is a memFS whose entries report device 1, except for mounted, a file bind-mounted
+// from device 2.
+type bindFS struct {
+	*memFS
+	mounted string
+}
+
+func (f bindFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := f.memFS.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	dev := uint64(1)
+	if name == f.mounted {
+		dev = 2
+	}
+	return devInfo{FileInfo: info, dev: dev}, nil
+}
+
+func TestTempDevice(t *testing.T) {
+	src := fstest.MapFS{"etc/app.conf": {Data: []byte("new config")}}
+	newTarget := func() bindFS {
+		dst := bindFS{memFS: newMemFS(), mounted: "etc/app.conf"}
+		dst.MapFS["etc/app.conf"] = &fstest.MapFile{Data: []byte("old")}
+		return dst
+	}
+
+	t.Run("error", func(t *testing.T) {
+		dst := newTarget()
+		rep := SyncFS(src, dst, Options{TempDevice: TempDeviceError})
+		if len(rep.Errors) != 1 || !strings.Contains(rep.Errors[0].Error(), "would not be atomic") {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if string(dst.MapFS["etc/app.conf"].Data) != "old" || len(dst.MapFS) != 2 {
+			t.Fatalf("target changed: %v", dst.MapFS)
+		}
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		dst := newTarget()
+		rep := SyncFS(src, dst, Options{TempDevice: TempDeviceCopy})
+		if len(rep.Errors) != 0 || rep.Overwritten != 1 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		if string(dst.MapFS["etc/app.conf"].Data) != "new config" || len(dst.MapFS) != 2 {
+			t.Fatalf("unexpected target: %v", dst.MapFS)
+		}
+	})
+
+	t.Run("same device", func(t *testing.T) {
+		dst := newTarget()
+		dst.mounted = ""
+		rep := SyncFS(src, dst, Options{TempDevice: TempDeviceError})
+		if len(rep.Errors) != 0 || rep.Overwritten != 1 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tempSuffix marks the temp files written next to their destination before the final rename.
+const tempSuffix = ".tmp~"
+
+// tempPattern matches the base names given by tempName. The random tag keeps temps apart from
+// source files that merely end in tempSuffix, which are synced like any other file. The
+// submatch is the creation time, missing from the names of older versions.
+var tempPattern = regexp.MustCompile(`\.sync-[0-9a-f]{8}(?:-([0-9a-f]+))?` + regexp.QuoteMeta(tempSuffix) + `$`)
+
+// tempName returns a fresh temp name next to name, e.g. dir/a.txt.sync-1a2b3c4d-6712ab00.tmp~,
+// holding the creation time (Unix seconds, hex): writing the temp gives it the source mod-time,
+// so only the name tells its age.
+func tempName(name string) string {
+	return fmt.Sprintf("%s.sync-%08x-%x%s", name, rand.Uint32(), time.Now().Unix(), tempSuffix)
+}
+
+// tempCreated returns the creation time held in the name of the temp file rel, if any.
+func tempCreated(rel string) (time.Time, bool) {
+	m := tempPattern.FindStringSubmatch(path.Base(rel))
+	if m == nil || m[1] == "" {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(m[1], 16, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// isTempName reports whether rel was named by tempName.
+func isTempName(rel string) bool {
+	return tempPattern.MatchString(path.Base(rel))
+}
+
+// defaultStaleTempAge is used when CleanStaleTemps is set without StaleTempAge.
+const defaultStaleTempAge = time.Hour
+
+// cleanStaleTemps removes temp files left in the target by crashed runs.
+// Temps younger than StaleTempAge are kept, as they may belong to a sync that is still running.
+// Their age is that of the creation time in their name; temps named without one (by older
+// versions, or plain name.tmp~) are dated by their mod-time.
+func (r *runner) cleanStaleTemps() {
+	maxAge := r.opt.StaleTempAge
+	if maxAge <= 0 {
+		maxAge = defaultStaleTempAge
+	}
+	cutoff := r.start.Add(-maxAge)
+
+	err := fs.WalkDir(r.dst, ".", func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			r.opt.Logger.Printf("ERR: read %s: %v", r.dstPath(rel), err)
+			r.rep.addErr(err)
+			return nil
+		}
+		if d.IsDir() {
+			if r.isTrash(rel) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !r.isLeftoverTemp(rel) {
+			return nil
+		}
+		created, ok := tempCreated(rel)
+		if !ok {
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			created = info.ModTime()
+		}
+		if !created.Before(cutoff) {
+			return nil
+		}
+		if !r.opt.DryRun {
+			if err := r.dst.Remove(rel); err != nil {
+				r.opt.Logger.Printf("ERR: remove stale temp %s: %v", r.dstPath(rel), err)
+				r.rep.addErr(err)
+				return nil
+			}
+		}
+		r.opt.Logger.Printf("CLEAN: %s (stale temp file)", r.dstPath(rel))
+		r.rep.CleanedTemps++
+		return nil
+	})
+	if err != nil {
+		r.opt.Logger.Printf("ERR: walk target %s: %v", r.dstPath("."), err)
+		r.rep.addErr(err)
+	}
+}
+
+// isLeftoverTemp reports whether the target file rel is a temp file. Besides the names
+// given by tempName, a plain name.tmp~ counts as a temp of an older version only when no source
+// has a file of that name; otherwise it is a synced copy.
+func (r *runner) isLeftoverTemp(rel string) bool {
+	if isTempName(rel) {
+		return true
+	}
+	return strings.HasSuffix(rel, tempSuffix) && errors.Is(r.statSources(rel), fs.ErrNotExist)
+}
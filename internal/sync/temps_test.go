@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCleanStaleTemps(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+
+	stale := filepath.Join(dst, "sub", "crashed.txt.tmp~")
+	fresh := filepath.Join(dst, "in-flight.txt.tmp~")
+	mustWrite(t, stale, "partial")
+	mustWrite(t, fresh, "partial")
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	rep := Sync(Options{Source: src, Target: dst, CleanStaleTemps: true})
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+	if rep.CleanedTemps != 1 {
+		t.Fatalf("expected 1 cleaned temp, got %+v", *rep)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected stale temp removed, err=%v", err)
+	}
+	// A recent temp may belong to a concurrent run and is kept.
+	if _, err := os.Stat(fresh); err != nil {
+		t.Fatalf("expected fresh temp kept, err=%v", err)
+	}
+}
+
+func TestCleanStaleTempsByName(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+
+	// A temp being written by another run carries the source mod-time, but its name dates it
+	live := filepath.Join(dst, tempName("live.txt"))
+	writeWithModTime(t, live, "partial", 0o644, old.Add(-365*24*time.Hour))
+	crashed := filepath.Join(dst, fmt.Sprintf("crashed.txt.sync-0123abcd-%x.tmp~", old.Unix()))
+	writeWithModTime(t, crashed, "partial", 0o644, time.Now())
+
+	rep := Sync(Options{Source: src, Target: dst, CleanStaleTemps: true})
+	if len(rep.Errors) != 0 || rep.CleanedTemps != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if _, err := os.Stat(live); err != nil {
+		t.Fatalf("expected the live temp kept, err=%v", err)
+	}
+	if _, err := os.Stat(crashed); !os.IsNotExist(err) {
+		t.Fatalf("expected the crashed temp removed, err=%v", err)
+	}
+}
+
+func TestSourceNamedLikeTemp(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	old := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	writeWithModTime(t, filepath.Join(src, "data"), "data", 0o644, old)
+	writeWithModTime(t, filepath.Join(src, "data.tmp~"), "not a temp", 0o644, old)
+	leftover := filepath.Join(dst, "data.sync-0123abcd.tmp~")
+	writeWithModTime(t, leftover, "partial", 0o644, old)
+
+	opt := Options{Source: src, Target: dst, CleanStaleTemps: true, Manifest: filepath.Join(t.TempDir(), "manifest.json")}
+	for run := 1; run <= 2; run++ {
+		rep := Sync(opt)
+		if len(rep.Errors) != 0 {
+			t.Fatalf("run %d: unexpected errors: %v", run, rep.Errors)
+		}
+		if run == 1 && (rep.Copied != 2 || rep.CleanedTemps != 1 || len(rep.Changes.Added) != 2) {
+			t.Fatalf("run 1: unexpected rep: %+v %+v", *rep, *rep.Changes)
+		}
+		if run == 2 && (rep.Skipped != 2 || rep.CleanedTemps != 0) {
+			t.Fatalf("run 2: unexpected rep: %+v", *rep)
+		}
+	}
+	want := map[string]string{"data": "data", "data.tmp~": "not a temp"}
+	if got := treeContents(t, dst); !reflect.DeepEqual(got, want) {
+		t.Fatalf("target holds %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,45 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// writeTextfileMetrics writes the counters of rep to p in the Prometheus text exposition format,
+// for the node_exporter textfile collector. The file is written under a temp name and renamed
+// into place, so the collector never reads a partial file.
+func writeTextfileMetrics(p string, rep *Report, duration time.Duration, end time.Time) error {
+	var b strings.Builder
+	metric := func(name, help string, value any) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", name, help, name, name, value)
+	}
+	metric("sync_copied_total", "Files copied to the target in the last run.", rep.Copied)
+	metric("sync_overwritten_total", "Target files overwritten in the last run.", rep.Overwritten)
+	metric("sync_deleted_total", "Target files deleted in the last run.", rep.Deleted)
+	metric("sync_skipped_total", "Files skipped in the last run.", rep.Skipped)
+	metric("sync_errors_total", "Errors in the last run.", rep.ErrorCount())
+	metric("sync_bytes_copied_total", "Bytes copied or overwritten in the last run.", rep.BytesCopied)
+	metric("sync_duration_seconds", "Duration of the last run.", duration.Seconds())
+	metric("sync_last_run_timestamp_seconds", "Unix time the last run ended.", end.Unix())
+
+	tmp := p + tempSuffix
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// writeMetrics writes Options.TextfileMetrics when the run ends.
+func (r *runner) writeMetrics() {
+	end := time.Now()
+	if err := writeTextfileMetrics(r.opt.TextfileMetrics, r.rep, end.Sub(r.start), end); err != nil {
+		r.opt.Logger.Printf("ERR: write metrics %s: %v", r.opt.TextfileMetrics, err)
+		r.rep.addErr(err)
+	}
+}
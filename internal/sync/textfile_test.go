@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// readProm parses the samples of a Prometheus text file, checking every sample has HELP and TYPE lines.
+func readProm(t *testing.T, p string) map[string]float64 {
+	t.Helper()
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	samples := map[string]float64{}
+	described := map[string]int{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 3 && fields[0] == "#" {
+			described[fields[2]]++
+			continue
+		}
+		if len(fields) != 2 {
+			t.Fatalf("malformed line %q", sc.Text())
+		}
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		samples[fields[0]] = v
+	}
+	for name := range samples {
+		if described[name] != 2 {
+			t.Fatalf("%s lacks HELP or TYPE", name)
+		}
+	}
+	return samples
+}
+
+func TestTextfileMetrics(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	prom := filepath.Join(t.TempDir(), "sync.prom")
+	mustWrite(t, filepath.Join(src, "a.txt"), "aaa")
+	mustWrite(t, filepath.Join(src, "b.txt"), "bb")
+	mustWrite(t, filepath.Join(dst, "orphan.txt"), "o")
+
+	rep := Sync(Options{Source: src, Target: dst, DeleteMissing: true, TextfileMetrics: prom})
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+	got := readProm(t, prom)
+	for name, want := range map[string]float64{
+		"sync_copied_total":       2,
+		"sync_overwritten_total":  0,
+		"sync_deleted_total":      1,
+		"sync_errors_total":       0,
+		"sync_bytes_copied_total": 5,
+	} {
+		if v, ok := got[name]; !ok || v != want {
+			t.Fatalf("%s: got %v (present %v) want %v", name, v, ok, want)
+		}
+	}
+	if got["sync_duration_seconds"] < 0 || got["sync_last_run_timestamp_seconds"] <= 0 {
+		t.Fatalf("unexpected timing metrics: %v", got)
+	}
+	if _, err := os.Stat(prom + tempSuffix); err == nil {
+		t.Fatalf("temp file left behind")
+	}
+
+	// A run stopped early still reports its error
+	rep = Sync(Options{Source: filepath.Join(src, "missing.tar"), Target: dst, TextfileMetrics: prom})
+	if got := readProm(t, prom); got["sync_errors_total"] != float64(rep.ErrorCount()) || rep.ErrorCount() == 0 {
+		t.Fatalf("errors: metrics %v, report %d", got["sync_errors_total"], rep.ErrorCount())
+	}
+}
+
+func TestTextfileMetricsTargets(t *testing.T) {
+	src := t.TempDir()
+	prom := filepath.Join(t.TempDir(), "sync.prom")
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+
+	Sync(Options{Source: src, Targets: []string{t.TempDir(), t.TempDir()}, TextfileMetrics: prom})
+	if got := readProm(t, prom); got["sync_copied_total"] != 2 {
+		t.Fatalf("expected totals over both targets: %v", got)
+	}
+
+	dry := filepath.Join(t.TempDir(), "dry.prom")
+	Sync(Options{Source: src, Target: t.TempDir(), TextfileMetrics: dry, DryRun: true})
+	if _, err := os.Stat(dry); err == nil {
+		t.Fatalf("dry run must not write metrics")
+	}
+}
@@ -0,0 +1,88 @@
+package sync
+
+import (
+	"context"
+	"io/fs"
+)
+
+// Tracer starts spans (Options.Tracer). It mirrors the small part of a tracing API the run
+// needs, so the sync package does not depend on a tracing library.
+type Tracer interface {
+	// Start starts a span named name as a child of the span carried by ctx, if any,
+	// and returns a context carrying the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	SetInt(key string, value int64)
+	SetString(key, value string)
+	RecordError(err error)
+	End()
+}
+
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetInt(string, int64)     {}
+func (nopSpan) SetString(string, string) {}
+func (nopSpan) RecordError(error)        {}
+func (nopSpan) End()                     {}
+
+// setCounts records report counters on span; base is subtracted to get the share of one phase.
+func setCounts(span Span, rep, base *Report) {
+	span.SetInt("sync.copied", int64(rep.Copied-base.Copied))
+	span.SetInt("sync.overwritten", int64(rep.Overwritten-base.Overwritten))
+	span.SetInt("sync.deleted", int64(rep.Deleted-base.Deleted))
+	span.SetInt("sync.skipped", int64(rep.Skipped-base.Skipped))
+	span.SetInt("sync.bytes_copied", rep.BytesCopied-base.BytesCopied)
+	span.SetInt("sync.errors", int64(rep.ErrorCount()-base.ErrorCount()))
+}
+
+func endRunSpan(span Span, target string, rep *Report) {
+	if target != "" {
+		span.SetString("sync.target", target)
+	}
+	setCounts(span, rep, &Report{})
+	span.End()
+}
+
+// phase runs fn in a child span of the run, recording the counters it changed.
+func (r *runner) phase(name, root string, fn func()) {
+	parent := r.traceCtx
+	ctx, span := r.opt.Tracer.Start(parent, name)
+	if root != "" {
+		span.SetString("sync.source", root)
+	}
+	base := *r.rep
+	r.traceCtx = ctx
+	fn()
+	r.traceCtx = parent
+	setCounts(span, r.rep, &base)
+	span.End()
+}
+
+// traceFile runs the copy fn in a per-file span when TraceFiles is set.
+func (r *runner) traceFile(dstRel string, info fs.FileInfo, overwrite bool, fn func() error) {
+	if !r.opt.TraceFiles {
+		_ = fn()
+		return
+	}
+	_, span := r.opt.Tracer.Start(r.traceCtx, "sync.file")
+	span.SetString("sync.path", dstRel)
+	span.SetInt("sync.bytes", info.Size())
+	if overwrite {
+		span.SetString("sync.op", "overwrite")
+	} else {
+		span.SetString("sync.op", "copy")
+	}
+	if err := fn(); err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
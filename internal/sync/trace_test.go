@@ -0,0 +1,132 @@
+package sync
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// fakeTracer records finished spans with their parent.
+type fakeTracer struct {
+	spans []*fakeSpan
+}
+
+type fakeSpan struct {
+	name   string
+	parent *fakeSpan
+	ints   map[string]int64
+	strs   map[string]string
+	errs   []error
+	ended  bool
+}
+
+type fakeSpanKey struct{}
+
+func (t *fakeTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(fakeSpanKey{}).(*fakeSpan)
+	s := &fakeSpan{name: name, parent: parent, ints: map[string]int64{}, strs: map[string]string{}}
+	t.spans = append(t.spans, s)
+	return context.WithValue(ctx, fakeSpanKey{}, s), s
+}
+
+func (s *fakeSpan) SetInt(key string, value int64) { s.ints[key] = value }
+func (s *fakeSpan) SetString(key, value string)    { s.strs[key] = value }
+func (s *fakeSpan) RecordError(err error)          { s.errs = append(s.errs, err) }
+func (s *fakeSpan) End()                           { s.ended = true }
+
+// tree renders the span hierarchy as "parent/child" paths.
+func (t *fakeTracer) tree() []string {
+	var out []string
+	for _, s := range t.spans {
+		p := s.name
+		for q := s.parent; q != nil; q = q.parent {
+			p = q.name + "/" + p
+		}
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (t *fakeTracer) find(name string) []*fakeSpan {
+	var out []*fakeSpan
+	for _, s := range t.spans {
+		if s.name == name {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func TestTracer(t *testing.T) {
+	mtime := time.Now().Add(-time.Hour)
+	src := fstest.MapFS{
+		"a.txt":     {Data: []byte("alpha"), ModTime: mtime},
+		"dir/b.txt": {Data: []byte("be"), ModTime: mtime},
+		"bad.txt":   {Data: []byte("x"), ModTime: mtime},
+	}
+	dst := newMemFS()
+	dst.MapFS["orphan.txt"] = &fstest.MapFile{Data: []byte("o")}
+
+	tr := &fakeTracer{}
+	rep := SyncFS(failOpenFS{FS: src, fail: "bad.txt"}, dst, Options{DeleteMissing: true, Tracer: tr, TraceFiles: true})
+	if rep.ErrorCount() != 1 {
+		t.Fatalf("expected the injected error only, got %v", rep.Errors)
+	}
+
+	want := []string{
+		"sync.run",
+		"sync.run/sync.copy",
+		"sync.run/sync.copy/sync.file",
+		"sync.run/sync.copy/sync.file",
+		"sync.run/sync.copy/sync.file",
+		"sync.run/sync.delete",
+	}
+	if got := tr.tree(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("span tree:\n got %v\nwant %v", got, want)
+	}
+	for _, s := range tr.spans {
+		if !s.ended {
+			t.Fatalf("span %s not ended", s.name)
+		}
+	}
+
+	run := tr.find("sync.run")[0]
+	wantRun := map[string]int64{
+		"sync.copied": 2, "sync.overwritten": 0, "sync.deleted": 1, "sync.skipped": 0,
+		"sync.bytes_copied": 7, "sync.errors": 1,
+	}
+	if !reflect.DeepEqual(run.ints, wantRun) {
+		t.Fatalf("run attributes: got %v want %v", run.ints, wantRun)
+	}
+	if c := tr.find("sync.copy")[0]; c.ints["sync.copied"] != 2 || c.ints["sync.deleted"] != 0 || c.ints["sync.errors"] != 1 {
+		t.Fatalf("copy attributes: %v", c.ints)
+	}
+	if d := tr.find("sync.delete")[0]; d.ints["sync.deleted"] != 1 || d.ints["sync.copied"] != 0 {
+		t.Fatalf("delete attributes: %v", d.ints)
+	}
+
+	files := map[string]*fakeSpan{}
+	for _, s := range tr.find("sync.file") {
+		files[s.strs["sync.path"]] = s
+	}
+	if f := files["dir/b.txt"]; f == nil || f.ints["sync.bytes"] != 2 || f.strs["sync.op"] != "copy" || len(f.errs) != 0 {
+		t.Fatalf("unexpected file span for dir/b.txt: %+v", f)
+	}
+	if f := files["bad.txt"]; f == nil || len(f.errs) != 1 || !strings.Contains(f.errs[0].Error(), "injected failure") {
+		t.Fatalf("expected the error recorded on bad.txt's span, got %+v", f)
+	}
+}
+
+func TestTracerWithoutFileSpans(t *testing.T) {
+	src := fstest.MapFS{"a.txt": {Data: []byte("a")}}
+	tr := &fakeTracer{}
+	SyncFS(src, newMemFS(), Options{Tracer: tr})
+	if got, want := tr.tree(), []string{"sync.run", "sync.run/sync.copy"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("span tree: got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+package sync
+
+import (
+	"compress/gzip"
+	"io"
+	"io/fs"
+)
+
+// Transform rewrites the content of a file on its way to the target: it wraps r, which reads
+// the source file rel (or the output of the previous Transform), and returns the reader whose
+// content is written instead. A returned reader implementing io.Closer is closed after the copy.
+type Transform func(rel string, r io.Reader) (io.Reader, error)
+
+// GzipTransform returns a Transform compressing content with gzip at level
+// (gzip.DefaultCompression, gzip.BestSpeed, ...). Target names are not changed.
+func GzipTransform(level int) Transform {
+	return func(_ string, r io.Reader) (io.Reader, error) {
+		zw, err := gzip.NewWriterLevel(nil, level)
+		if err != nil {
+			return nil, err
+		}
+		pr, pw := io.Pipe()
+		zw.Reset(pw)
+		go func() {
+			_, err := io.Copy(zw, r)
+			if err == nil {
+				err = zw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		return pr, nil
+	}
+}
+
+// transformFS passes every file opened from the wrapped FS through transforms, in order.
+type transformFS struct {
+	fs.FS
+	transforms []Transform
+}
+
+func (t transformFS) Open(name string) (fs.File, error) {
+	f, err := t.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	tf := &transformFile{File: f}
+	var r io.Reader = f
+	for _, transform := range t.transforms {
+		if r, err = transform(name, r); err != nil {
+			tf.Close()
+			return nil, err
+		}
+		if c, ok := r.(io.Closer); ok {
+			tf.closers = append(tf.closers, c)
+		}
+	}
+	tf.r = r
+	return tf, nil
+}
+
+// transformFile reads the transformed content. Stat still reports the source size.
+type transformFile struct {
+	fs.File
+	r       io.Reader
+	closers []io.Closer
+}
+
+func (f *transformFile) Read(p []byte) (int, error) {
+	return f.r.Read(p)
+}
+
+func (f *transformFile) Close() error {
+	// Outermost first, so that pipeline goroutines stop before their source is closed
+	for i := len(f.closers) - 1; i >= 0; i-- {
+		_ = f.closers[i].Close()
+	}
+	return f.File.Close()
+}
+
+// transforms returns the transforms applied to copied files: LineEndings, then Options.Transforms.
+func (r *runner) transforms() []Transform {
+	var ts []Transform
+	if r.opt.LineEndings != LineEndingsPreserve {
+		ts = append(ts, LineEndingsTransform(r.opt.LineEndings))
+	}
+	return append(ts, r.opt.Transforms...)
+}
+
+// sourceView returns the current source as it is written to the target, for content comparisons.
+func (r *runner) sourceView() fs.FS {
+	if ts := r.transforms(); len(ts) > 0 {
+		return transformFS{FS: r.src, transforms: ts}
+	}
+	return r.src
+}
+
+// sameContent compares the current source as written to the target with the target file dstRel.
+func (r *runner) sameContent(rel, dstRel string) (bool, error) {
+	if r.enc != nil {
+		return r.enc.sameAsEncrypted(r.sourceView(), rel, r.dst, dstRel)
+	}
+	return sameContent(r.sourceView(), rel, r.dst, dstRel)
+}
@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// xorTransform stands in for encryption: it flips every byte with key.
+func xorTransform(key byte) Transform {
+	return func(_ string, r io.Reader) (io.Reader, error) {
+		return xorReader{r: r, key: key}, nil
+	}
+}
+
+type xorReader struct {
+	r   io.Reader
+	key byte
+}
+
+func (x xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for i := range p[:n] {
+		p[i] ^= x.key
+	}
+	return n, err
+}
+
+func TestTransforms(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	original := strings.Repeat("compress me, then encrypt me\n", 100)
+	writeWithModTime(t, filepath.Join(src, "data.txt"), original, 0o644, time.Now().Add(-time.Hour))
+
+	opt := Options{Source: src, Target: dst, Transforms: []Transform{GzipTransform(gzip.BestCompression), xorTransform(0x5a)}}
+	rep := Sync(opt)
+	if len(rep.Errors) != 0 || rep.Copied != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+
+	// Undo the pipeline in reverse order
+	stored, err := os.ReadFile(filepath.Join(dst, "data.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) >= len(original) {
+		t.Fatalf("expected compressed content, got %d bytes", len(stored))
+	}
+	zr, err := gzip.NewReader(xorReader{r: bytes.NewReader(stored), key: 0x5a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil || string(got) != original {
+		t.Fatalf("round trip failed: %v", err)
+	}
+
+	// Neither comparison may mistake the transformed target for a changed file
+	for _, o := range []Options{opt, func() Options { o := opt; o.IgnoreModTime = true; return o }()} {
+		rep = Sync(o)
+		if len(rep.Errors) != 0 || rep.Copied+rep.Overwritten != 0 || rep.Skipped != 1 {
+			t.Fatalf("re-sync (IgnoreModTime=%v) not stable: %+v", o.IgnoreModTime, *rep)
+		}
+	}
+
+	writeWithModTime(t, filepath.Join(src, "data.txt"), "changed", 0o644, time.Now())
+	if rep = Sync(opt); rep.Overwritten != 1 {
+		t.Fatalf("expected the change to be copied: %+v", *rep)
+	}
+}
+
+func TestTransformError(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+	failing := func(string, io.Reader) (io.Reader, error) { return nil, errors.New("no key") }
+
+	rep := Sync(Options{Source: src, Target: dst, Transforms: []Transform{failing}})
+	if len(rep.Errors) != 1 || rep.Copied != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "a.txt")); err == nil {
+		t.Fatalf("nothing may be written")
+	}
+}
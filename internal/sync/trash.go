@@ -0,0 +1,86 @@
+package sync
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// trashStampLayout names timestamped trash snapshots. It is RFC 3339 in UTC with the colons
+// replaced by dashes (and nanoseconds added) so names are valid on every filesystem and sort chronologically.
+const trashStampLayout = "2006-01-02T15-04-05.000000000Z"
+
+// trashRoot returns the trash directory deleted files are moved into for this run.
+func (r *runner) trashRoot() string {
+	root := path.Clean(r.opt.TrashDir)
+	if r.opt.TrashTimestamped {
+		root = path.Join(root, r.start.UTC().Format(trashStampLayout))
+	}
+	return root
+}
+
+// isTrash reports whether a target name is the trash directory itself.
+func (r *runner) isTrash(rel string) bool {
+	return r.opt.TrashDir != "" && rel == path.Clean(r.opt.TrashDir)
+}
+
+// moveToTrash moves a target file into the trash, keeping its relative path.
+func (r *runner) moveToTrash(rel string) (string, error) {
+	dest := path.Join(r.trashRoot(), rel)
+	if err := r.dst.MkdirAll(path.Dir(dest), 0o755); err != nil {
+		return "", fmt.Errorf("mkdir trash: %w", err)
+	}
+	if err := r.dst.Rename(rel, dest); err != nil {
+		return "", fmt.Errorf("move to trash: %w", err)
+	}
+	return dest, nil
+}
+
+// pruneTrash removes timestamped trash snapshots older than TrashRetention.
+func (r *runner) pruneTrash() {
+	root := path.Clean(r.opt.TrashDir)
+	entries, err := fs.ReadDir(r.dst, root)
+	if err != nil {
+		// Nothing was ever trashed.
+		return
+	}
+	cutoff := r.start.Add(-r.opt.TrashRetention)
+	for _, e := range entries {
+		stamp, err := time.Parse(trashStampLayout, e.Name())
+		if err != nil || !e.IsDir() || !stamp.Before(cutoff) {
+			continue
+		}
+		name := path.Join(root, e.Name())
+		if err := removeAllFS(r.dst, name); err != nil {
+			r.opt.Logger.Printf("ERR: prune trash %s: %v", r.dstPath(name), err)
+			r.rep.addErr(err)
+			continue
+		}
+		r.opt.Logger.Printf("PRUNE: %s (trash older than %v)", r.dstPath(name), r.opt.TrashRetention)
+	}
+}
+
+// removeAllFS removes name and everything below it.
+func removeAllFS(dst WritableFS, name string) error {
+	var names []string
+	err := fs.WalkDir(dst, name, func(p string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		names = append(names, p)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Children sort after their parents, so removing in reverse empties directories first.
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	for _, p := range names {
+		if err := dst.Remove(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
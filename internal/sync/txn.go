@@ -0,0 +1,122 @@
+package sync
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// txn collects the changes of a transactional run until the commit phase.
+type txn struct {
+	files   []stagedFile
+	deletes []string
+	// dirs lists target directories created by this run, in creation order, for rollback.
+	dirs []string
+	// tmps indexes the temp names of files for isStaged.
+	tmps map[string]bool
+}
+
+func newTxn() *txn {
+	return &txn{tmps: map[string]bool{}}
+}
+
+func (t *txn) stage(f stagedFile) {
+	t.files = append(t.files, f)
+	t.tmps[f.tmp] = true
+}
+
+// stagedFile is a fully written temp file waiting to be renamed onto dstRel.
+type stagedFile struct {
+	tmp       string
+	rel       string
+	dstRel    string
+	info      fs.FileInfo
+	overwrite bool
+}
+
+// isStaged reports whether rel is one of this run's temp files, so the delete pass leaves it alone.
+func (t *txn) isStaged(rel string) bool {
+	return t.tmps[rel]
+}
+
+// commit applies the staged changes if the run was clean, or rolls everything back otherwise.
+// Files being overwritten are moved aside first, so that a failed rename can restore the ones
+// already replaced; the commit then stops and the deletions are not applied.
+func (r *runner) commit() {
+	if r.rep.ErrorCount() > 0 {
+		r.rollback()
+		return
+	}
+	asides := make([]string, len(r.txn.files))
+	for i, f := range r.txn.files {
+		aside, err := r.commitFile(f)
+		if err != nil {
+			r.opt.Logger.Printf("ERR: commit %s: %v", r.dstPath(f.dstRel), err)
+			r.rep.addErr(err)
+			r.recordCopy(f.dstRel, 0, f.overwrite, err)
+			r.uncommit(r.txn.files[:i], asides[:i])
+			r.rollback()
+			return
+		}
+		asides[i] = aside
+	}
+	for i, f := range r.txn.files {
+		if asides[i] != "" {
+			_ = r.dst.Remove(asides[i])
+		}
+		r.deferTimes(f.dstRel, f.info)
+		r.logCopied(f.rel, f.dstRel, f.info, f.overwrite)
+	}
+	deletes := r.txn.deletes
+	r.txn = nil // apply the deletions directly from here on
+	for _, rel := range deletes {
+		r.removeEntry(rel)
+	}
+}
+
+// commitFile renames the staged file f into place, returning the temp name its original was
+// moved to ("" if there was none).
+func (r *runner) commitFile(f stagedFile) (string, error) {
+	var aside string
+	if f.overwrite {
+		aside = tempName(f.dstRel)
+		if err := r.dst.Rename(f.dstRel, aside); errors.Is(err, fs.ErrNotExist) {
+			aside = ""
+		} else if err != nil {
+			return "", err
+		}
+	}
+	if err := r.replace(f.tmp, f.dstRel); err != nil {
+		if aside != "" {
+			_ = r.dst.Rename(aside, f.dstRel)
+		}
+		return "", err
+	}
+	return aside, nil
+}
+
+// uncommit undoes the renames of the committed files, newest first, restoring their originals.
+func (r *runner) uncommit(files []stagedFile, asides []string) {
+	for i := len(files) - 1; i >= 0; i-- {
+		err := r.dst.Remove(files[i].dstRel)
+		if err == nil && asides[i] != "" {
+			err = r.dst.Rename(asides[i], files[i].dstRel)
+		}
+		if err != nil {
+			r.opt.Logger.Printf("ERR: restore %s: %v", r.dstPath(files[i].dstRel), err)
+			r.rep.addErr(err)
+		}
+	}
+}
+
+// rollback discards staged temp files and removes directories created by the run.
+func (r *runner) rollback() {
+	for _, f := range r.txn.files {
+		_ = r.dst.Remove(f.tmp)
+	}
+	// Deepest directories were created last, so remove in reverse order.
+	for i := len(r.txn.dirs) - 1; i >= 0; i-- {
+		_ = r.dst.Remove(r.txn.dirs[i])
+	}
+	r.opt.Logger.Printf("ABORT: transaction rolled back after %d error(s); discarded %d staged file(s) and %d deletion(s)",
+		r.rep.ErrorCount(), len(r.txn.files), len(r.txn.deletes))
+}
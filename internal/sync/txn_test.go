@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// failOpenFS wraps an fs.FS and fails to open one specific file.
+type failOpenFS struct {
+	fs.FS
+	fail string
+}
+
+func (f failOpenFS) Open(name string) (fs.File, error) {
+	if name == f.fail {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("injected failure")}
+	}
+	return f.FS.Open(name)
+}
+
+func snapshot(m *memFS) map[string]string {
+	out := map[string]string{}
+	for name, f := range m.MapFS {
+		out[name] = string(f.Data)
+	}
+	return out
+}
+
+func transactionalFixture() (fstest.MapFS, *memFS) {
+	mtime := time.Now().Add(-time.Hour)
+	src := fstest.MapFS{
+		"a.txt":        {Data: []byte("new file"), Mode: 0o644, ModTime: mtime},
+		"b.txt":        {Data: []byte("changed content"), Mode: 0o644, ModTime: mtime},
+		"newdir/x.txt": {Data: []byte("x"), Mode: 0o644, ModTime: mtime},
+		"zz_bad.txt":   {Data: []byte("unreadable"), Mode: 0o644, ModTime: mtime},
+	}
+	dst := newMemFS()
+	dst.MapFS["b.txt"] = &fstest.MapFile{Data: []byte("old"), Mode: 0o644, ModTime: mtime.Add(-time.Hour)}
+	dst.MapFS["orphan.txt"] = &fstest.MapFile{Data: []byte("orphan"), Mode: 0o644}
+	return src, dst
+}
+
+func TestTransactionalRollsBackOnFailure(t *testing.T) {
+	src, dst := transactionalFixture()
+	before := snapshot(dst)
+
+	rep := SyncFS(failOpenFS{FS: src, fail: "zz_bad.txt"}, dst, Options{DeleteMissing: true, Transactional: true})
+	if len(rep.Errors) != 1 {
+		t.Fatalf("expected the injected error only, got %v", rep.Errors)
+	}
+	if rep.Copied != 0 || rep.Overwritten != 0 || rep.Deleted != 0 {
+		t.Fatalf("expected nothing applied, got %+v", *rep)
+	}
+
+	after := snapshot(dst)
+	if len(after) != len(before) {
+		t.Fatalf("target changed: before=%v after=%v", before, after)
+	}
+	for name, data := range before {
+		if after[name] != data {
+			t.Fatalf("target entry %s changed: %q -> %q", name, data, after[name])
+		}
+	}
+}
+
+func TestTransactionalCommitsOnSuccess(t *testing.T) {
+	src, dst := transactionalFixture()
+
+	rep := SyncFS(src, dst, Options{DeleteMissing: true, Transactional: true})
+	if len(rep.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rep.Errors)
+	}
+	if rep.Copied != 3 || rep.Overwritten != 1 || rep.Deleted != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	for name, f := range src {
+		if got := dst.MapFS[name]; got == nil || string(got.Data) != string(f.Data) {
+			t.Fatalf("%s not committed", name)
+		}
+	}
+	if _, ok := dst.MapFS["orphan.txt"]; ok {
+		t.Fatalf("expected orphan.txt deleted")
+	}
+}
+
+// failRenameFS wraps a memFS and fails renames onto one specific name.
+type failRenameFS struct {
+	*memFS
+	fail string
+}
+
+func (f failRenameFS) Rename(oldname, newname string) error {
+	if newname == f.fail {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: errors.New("injected failure")}
+	}
+	return f.memFS.Rename(oldname, newname)
+}
+
+func TestTransactionalCommitFailureRestores(t *testing.T) {
+	src, dst := transactionalFixture()
+	delete(src, "zz_bad.txt")
+	before := snapshot(dst)
+
+	// a.txt and b.txt are in place when newdir/x.txt fails; both are undone and orphan.txt stays
+	rep := SyncFS(src, failRenameFS{memFS: dst, fail: "newdir/x.txt"}, Options{DeleteMissing: true, Transactional: true})
+	if len(rep.Errors) != 1 || rep.Copied != 0 || rep.Overwritten != 0 || rep.Deleted != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if after := snapshot(dst); !reflect.DeepEqual(after, before) {
+		t.Fatalf("target changed: before=%v after=%v", before, after)
+	}
+}
@@ -0,0 +1,106 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// ValidationError is recorded for a source file rejected by Options.ContentValidator.
+type ValidationError struct {
+	Path string
+	Err  error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("validate %s: %v", e.Path, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// errCopyAborted tells a validator that the copy failed for another reason.
+var errCopyAborted = errors.New("copy aborted")
+
+// validatingFS hands every file opened from fsys to validate while it is being read.
+type validatingFS struct {
+	fs.FS
+	validate func(rel string, r io.Reader) error
+}
+
+func (v validatingFS) Open(name string) (fs.File, error) {
+	f, err := v.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	vf := &validatingFile{File: f, name: name, pw: pw, done: make(chan error, 1)}
+	go func() {
+		err := v.validate(name, pr)
+		if err != nil {
+			// Fail the copy at its next read
+			pr.CloseWithError(err)
+		} else {
+			// The validator may have stopped reading early; let the copy finish
+			_, _ = io.Copy(io.Discard, pr)
+		}
+		vf.done <- err
+	}()
+	return vf, nil
+}
+
+// validatingFile tees what is read from File into the validator. Reaching EOF waits for
+// the validator, so a rejection surfaces as a read error before the copy is renamed into place.
+type validatingFile struct {
+	fs.File
+	name     string
+	pw       *io.PipeWriter
+	done     chan error
+	finished bool
+	err      error
+}
+
+func (f *validatingFile) Read(p []byte) (int, error) {
+	if f.finished {
+		if f.err != nil {
+			return 0, f.err
+		}
+		return 0, io.EOF
+	}
+	n, err := f.File.Read(p)
+	if n > 0 {
+		if _, werr := f.pw.Write(p[:n]); werr != nil {
+			return 0, f.wait(werr)
+		}
+	}
+	if err == io.EOF {
+		_ = f.pw.Close()
+		if verr := f.wait(nil); verr != nil {
+			return n, verr
+		}
+	}
+	return n, err
+}
+
+// wait finishes the validator and returns its verdict as a *ValidationError, if any.
+func (f *validatingFile) wait(cause error) error {
+	if f.finished {
+		return f.err
+	}
+	f.finished = true
+	if cause != nil {
+		f.pw.CloseWithError(cause)
+	}
+	if err := <-f.done; err != nil {
+		f.err = &ValidationError{Path: f.name, Err: err}
+	}
+	return f.err
+}
+
+func (f *validatingFile) Close() error {
+	// Unblock a validator still waiting for data
+	_ = f.wait(errCopyAborted)
+	return f.File.Close()
+}
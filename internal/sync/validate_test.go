@@ -0,0 +1,68 @@
+package sync
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var jpegMagic = []byte{0xFF, 0xD8}
+
+// requireJPEG accepts only content starting with the JPEG magic bytes; it reads no further.
+func requireJPEG(rel string, r io.Reader) error {
+	head := make([]byte, len(jpegMagic))
+	if _, err := io.ReadFull(r, head); err != nil || !bytes.Equal(head, jpegMagic) {
+		return errors.New("not a JPEG")
+	}
+	return nil
+}
+
+func TestContentValidator(t *testing.T) {
+	for _, txn := range []bool{false, true} {
+		src := t.TempDir()
+		dst := t.TempDir()
+		big := append(append([]byte{}, jpegMagic...), bytes.Repeat([]byte("x"), 1<<20)...)
+		mustWrite(t, filepath.Join(src, "good.jpg"), string(big))
+		mustWrite(t, filepath.Join(src, "bad.jpg"), "GIF89a")
+		mustWrite(t, filepath.Join(src, "empty.jpg"), "")
+		// An existing target file must survive a rejected overwrite
+		writeWithModTime(t, filepath.Join(dst, "old.jpg"), "old", 0o644, time.Now().Add(-time.Hour))
+		mustWrite(t, filepath.Join(src, "old.jpg"), "broken update")
+
+		rep := Sync(Options{Source: src, Target: dst, Transactional: txn, ContentValidator: requireJPEG})
+		if txn {
+			// Any error rolls the whole run back
+			if rep.ErrorCount() != 3 {
+				t.Fatalf("txn: unexpected rep: %+v", *rep)
+			}
+		} else if rep.Copied != 1 || rep.ErrorCount() != 3 {
+			t.Fatalf("unexpected rep: %+v", *rep)
+		}
+		for _, err := range rep.Errors {
+			var verr *ValidationError
+			if !errors.As(err, &verr) || !strings.Contains(err.Error(), "not a JPEG") {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		if b, err := os.ReadFile(filepath.Join(dst, "good.jpg")); txn != (err != nil) || !txn && !bytes.Equal(b, big) {
+			t.Fatalf("txn=%v: good.jpg: %d bytes, err=%v", txn, len(b), err)
+		}
+		for _, name := range []string{"bad.jpg", "empty.jpg"} {
+			if _, err := os.Stat(filepath.Join(dst, name)); !os.IsNotExist(err) {
+				t.Fatalf("txn=%v: expected %s rejected, err=%v", txn, name, err)
+			}
+		}
+		if b, _ := os.ReadFile(filepath.Join(dst, "old.jpg")); string(b) != "old" {
+			t.Fatalf("txn=%v: old.jpg overwritten with %q", txn, b)
+		}
+		if tmps, _ := filepath.Glob(filepath.Join(dst, "*"+tempSuffix)); len(tmps) != 0 {
+			t.Fatalf("txn=%v: temp files left: %v", txn, tmps)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package sync
+
+import (
+	"errors"
+	"io"
+	"log"
+)
+
+// Verification lists where a target differs from its source(s), as computed by Syncer.Verify.
+// Entries are target paths.
+type Verification struct {
+	// Missing files exist in a source but not in the target.
+	Missing []string
+	// Changed files exist on both sides but differ under the configured comparison.
+	Changed []string
+	// Orphans exist only in the target.
+	Orphans []string
+}
+
+// InSync reports whether no differences were found.
+func (v *Verification) InSync() bool {
+	return len(v.Missing)+len(v.Changed)+len(v.Orphans) == 0
+}
+
+func (v *Verification) addCopy(path string, overwrite bool) {
+	if overwrite {
+		v.Changed = append(v.Changed, path)
+		return
+	}
+	v.Missing = append(v.Missing, path)
+}
+
+func (v *Verification) add(o *Verification) {
+	v.Missing = append(v.Missing, o.Missing...)
+	v.Changed = append(v.Changed, o.Changed...)
+	v.Orphans = append(v.Orphans, o.Orphans...)
+}
+
+// Verify compares source and target(s) with the configured comparison (IgnoreModTime included)
+// and returns every difference, orphans included whether or not DeleteMissing is set.
+// It never modifies anything and logs nothing.
+// The returned error joins the errors hit during the walk; the result is then incomplete.
+func (s *Syncer) Verify() (*Verification, error) {
+	opt := s.opt
+	opt.DryRun = true
+	opt.DeleteMissing = true
+	opt.Syslog = false
+	opt.Logger = log.New(io.Discard, "", 0)
+	opt.Tracer = nil
+
+	targets := opt.Targets
+	if len(targets) == 0 {
+		targets = []string{opt.Target}
+	}
+	total := &Verification{}
+	var errs []error
+	for _, target := range targets {
+		o := opt
+		o.Targets = nil
+		o.Target = target
+		r := newDirRunner(o)
+		r.ver = &Verification{}
+		rep := r.run()
+		total.add(r.ver)
+		errs = append(errs, rep.Errors...)
+	}
+	return total, errors.Join(errs...)
+}
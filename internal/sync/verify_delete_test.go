@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"bytes"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// danglingFS reports the named entries as missing to Stat but present to Lstat,
+// like dangling symlinks.
+type danglingFS struct {
+	fstest.MapFS
+	links map[string]bool
+}
+
+func (d danglingFS) Lstat(name string) (fs.FileInfo, error) {
+	if d.links[name] {
+		return fstest.MapFS{name: {Mode: fs.ModeSymlink, ModTime: time.Now()}}.Stat(name)
+	}
+	return d.MapFS.Stat(name)
+}
+
+func TestVerifyBeforeDelete(t *testing.T) {
+	for _, verify := range []bool{false, true} {
+		src := danglingFS{
+			MapFS: fstest.MapFS{"a.txt": {Data: []byte("a")}},
+			links: map[string]bool{"link.txt": true},
+		}
+		dst := newMemFS()
+		dst.MapFS["link.txt"] = &fstest.MapFile{Data: []byte("l")}
+		dst.MapFS["orphan.txt"] = &fstest.MapFile{Data: []byte("o")}
+
+		var logs bytes.Buffer
+		rep := SyncFS(src, dst, Options{
+			DeleteMissing:      true,
+			VerifyBeforeDelete: verify,
+			Logger:             log.New(&logs, "", 0),
+		})
+		if len(rep.Errors) != 0 {
+			t.Fatalf("unexpected errors: %v", rep.Errors)
+		}
+		_, kept := dst.MapFS["link.txt"]
+		if kept != verify {
+			t.Fatalf("verify=%v: link.txt kept=%v", verify, kept)
+		}
+		if _, ok := dst.MapFS["orphan.txt"]; ok {
+			t.Fatalf("verify=%v: orphan.txt must still be deleted", verify)
+		}
+		if verify && (rep.Deleted != 1 || !strings.Contains(logs.String(), "WARN: link.txt not deleted")) {
+			t.Fatalf("unexpected rep %+v, logs:\n%s", *rep, logs.String())
+		}
+	}
+}
+
+func TestVerifyBeforeDeleteDanglingSymlink(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	if err := os.Symlink(filepath.Join(src, "gone"), filepath.Join(src, "link.txt")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	mustWrite(t, filepath.Join(dst, "link.txt"), "l")
+
+	rep := Sync(Options{Source: src, Target: dst, DeleteMissing: true, VerifyBeforeDelete: true})
+	if rep.Deleted != 0 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "link.txt")); err != nil {
+		t.Fatalf("expected target file to remain, err=%v", err)
+	}
+}
+
+func TestDeleteMissingDanglingSymlink(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mustWrite(t, filepath.Join(src, "a.txt"), "a")
+	mustWrite(t, filepath.Join(dst, "sub", "orphan.txt"), "o")
+	if err := os.Symlink("nowhere", filepath.Join(dst, "dangling")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if err := os.Symlink("../nowhere", filepath.Join(dst, "sub", "dangling")); err != nil {
+		t.Fatal(err)
+	}
+
+	rep := Sync(Options{Source: src, Target: dst, DeleteMissing: true})
+	if len(rep.Errors) != 0 || rep.Deleted != 3 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	for _, rel := range []string{"dangling", "sub/dangling", "sub/orphan.txt"} {
+		if _, err := os.Lstat(filepath.Join(dst, rel)); !os.IsNotExist(err) {
+			t.Fatalf("%s must be deleted, lstat: %v", rel, err)
+		}
+	}
+}
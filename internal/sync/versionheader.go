@@ -0,0 +1,86 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// versionHeaderLen is how much of the start of a file Options.VersionHeaderRegex is applied to.
+const versionHeaderLen = 4096
+
+// readVersion returns the version token found by re in the header of name,
+// or "" if the file has none.
+func readVersion(fsys fs.FS, name string, re *regexp.Regexp) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head, err := io.ReadAll(io.LimitReader(f, versionHeaderLen))
+	if err != nil {
+		return "", err
+	}
+	m := re.FindSubmatch(head)
+	switch {
+	case m == nil:
+		return "", nil
+	case len(m) > 1:
+		return string(m[1]), nil
+	default:
+		return string(m[0]), nil
+	}
+}
+
+// compareVersions compares dot-separated versions field by field, numerically where both
+// fields are numbers and as strings otherwise; a version extending the other is newer.
+// It returns -1, 0 or +1.
+func compareVersions(a, b string) int {
+	af, bf := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(af) && i < len(bf); i++ {
+		an, aErr := strconv.ParseUint(af[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bf[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil && an != bn:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case (aErr != nil || bErr != nil) && af[i] != bf[i]:
+			return strings.Compare(af[i], bf[i])
+		}
+	}
+	switch {
+	case len(af) < len(bf):
+		return -1
+	case len(af) > len(bf):
+		return 1
+	}
+	return 0
+}
+
+// keepNewerVersion reports whether the overwrite of dstRel is skipped because its version header
+// (Options.VersionHeaderRegex) is not older than the source's. Files without a header in either
+// copy are overwritten as usual. Read errors are recorded and leave the target alone.
+func (r *runner) keepNewerVersion(rel, dstRel string) bool {
+	srcVer, err := readVersion(r.src, rel, r.versionRe)
+	if err == nil && srcVer != "" {
+		var dstVer string
+		dstVer, err = readVersion(r.dst, dstRel, r.versionRe)
+		if err == nil && dstVer != "" && compareVersions(dstVer, srcVer) >= 0 {
+			r.opt.Logger.Printf("SKIP: %s (target version %s not older than source version %s)", dstRel, dstVer, srcVer)
+			r.skip(dstRel, SkipTargetVersion)
+			return true
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("read version header of %s: %w", rel, err)
+		r.opt.Logger.Printf("ERR: %v", err)
+		r.rep.addErr(err)
+		return true
+	}
+	return false
+}
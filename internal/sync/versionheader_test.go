@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVersionHeader(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	for _, tc := range []struct {
+		name, src, dst, want string
+		skipped              int
+	}{
+		{"target newer", "# version: 1.9.0\nsource", "# version: 1.10.0\nmanual edit", "# version: 1.10.0\nmanual edit", 1},
+		{"same version", "# version: 2.0\nsource", "# version: 2.0\nmanual edit", "# version: 2.0\nmanual edit", 1},
+		{"source newer", "# version: 2.0.1\nsource", "# version: 2.0\nold", "# version: 2.0.1\nsource", 0},
+		{"no target header", "# version: 1\nsource", "old", "# version: 1\nsource", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src, dst := t.TempDir(), t.TempDir()
+			mustWrite(t, filepath.Join(src, "app.conf"), tc.src)
+			writeWithModTime(t, filepath.Join(dst, "app.conf"), tc.dst, 0o644, old)
+
+			rep := Sync(Options{Source: src, Target: dst, VersionHeaderRegex: `(?m)^# version: (\S+)`, CollectSkipReasons: true})
+			if len(rep.Errors) != 0 || rep.Skipped != tc.skipped || rep.Overwritten != 1-tc.skipped {
+				t.Fatalf("unexpected rep: %+v", *rep)
+			}
+			if rep.SkipReasons[SkipTargetVersion] != tc.skipped {
+				t.Fatalf("skip reasons: %v", rep.SkipReasons)
+			}
+			if b, _ := os.ReadFile(filepath.Join(dst, "app.conf")); string(b) != tc.want {
+				t.Fatalf("target: got %q want %q", b, tc.want)
+			}
+		})
+	}
+
+	rep := Sync(Options{Source: t.TempDir(), Target: t.TempDir(), VersionHeaderRegex: "("})
+	if len(rep.Errors) != 1 {
+		t.Fatalf("expected an invalid regex to stop the run: %v", rep.Errors)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.10", "1.9", 1},
+		{"1.2", "1.2", 0},
+		{"1.2", "1.2.1", -1},
+		{"2", "10", -1},
+		{"1.0-rc1", "1.0-rc2", -1},
+		{"2024-05-01", "2024-04-30", 1},
+	} {
+		if got := compareVersions(tc.a, tc.b); got != tc.want {
+			t.Fatalf("compareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
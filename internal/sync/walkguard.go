@@ -0,0 +1,58 @@
+package sync
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// walkGuard keeps a source walk from descending into the target or into a directory it has
+// already walked (Options.GuardWalk), by resolving every directory to its real path as it is
+// entered: a directory replaced by a symlink while the walk runs is followed when it is read.
+type walkGuard struct {
+	srcRoot string
+	// dstReal is the resolved target root, empty if it cannot be resolved (e.g. it does not exist yet).
+	dstReal string
+	// visited maps the resolved directories walked so far to their source names.
+	visited map[string]string
+}
+
+// newWalkGuard returns a guard for a walk of the source directory srcRoot, or nil if srcRoot
+// or dstRoot is not a directory on disk or the source root cannot be resolved.
+func newWalkGuard(srcRoot, dstRoot string) *walkGuard {
+	if srcRoot == "" || dstRoot == "" || DetectArchive(srcRoot) != NotArchive || ArchiveTargetKind(dstRoot) != NotArchive {
+		return nil
+	}
+	real, err := realPath(srcRoot)
+	if err != nil {
+		return nil
+	}
+	g := &walkGuard{srcRoot: srcRoot, visited: map[string]string{real: "."}}
+	if dstReal, err := realPath(dstRoot); err == nil {
+		g.dstReal = dstReal
+	}
+	return g
+}
+
+// enter checks the source directory rel before the walk descends into it. It returns a
+// reason to skip it, or "" if it may be walked.
+func (g *walkGuard) enter(rel string) string {
+	real, err := realPath(filepath.Join(g.srcRoot, filepath.FromSlash(rel)))
+	if err != nil {
+		// Reading the directory reports the problem
+		return ""
+	}
+	if g.dstReal != "" && within(g.dstReal, real) {
+		return "resolves to " + real + " inside the target"
+	}
+	if prev, ok := g.visited[real]; ok {
+		return "resolves to " + real + ", already walked as " + prev
+	}
+	g.visited[real] = rel
+	return ""
+}
+
+// within reports whether the OS path name is root or lies below it.
+func within(root, name string) bool {
+	rel, err := filepath.Rel(root, name)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,65 @@
+package sync
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGuardWalk(t *testing.T) {
+	base := t.TempDir()
+	src, dst := filepath.Join(base, "src"), filepath.Join(base, "dst")
+	mustWrite(t, filepath.Join(src, "a", "first.txt"), "first")
+	mustWrite(t, filepath.Join(src, "b", "second.txt"), "second")
+	if err := os.Mkdir(dst, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// While a/first.txt is copied, b is replaced by a symlink into the target, which the walk
+	// has already listed as a directory
+	swapped := false
+	hook := func(rel string, r io.Reader) error {
+		if rel != "a/first.txt" || swapped {
+			return nil
+		}
+		swapped = true
+		if err := os.RemoveAll(filepath.Join(src, "b")); err != nil {
+			return err
+		}
+		return os.Symlink(dst, filepath.Join(src, "b"))
+	}
+	rep := Sync(Options{Source: src, Target: dst, GuardWalk: true, ContentValidator: hook})
+	if !swapped {
+		t.Skip("walk order did not allow the swap")
+	}
+	if len(rep.Errors) != 0 || rep.Copied != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	want := map[string]string{"a": "/", "a/first.txt": "first"}
+	if got := treeContents(t, dst); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestGuardWalkNestedTarget(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "data.txt"), "data")
+	dst := filepath.Join(src, "backup")
+	if err := os.Mkdir(dst, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	rep := Sync(Options{Source: src, Target: dst, GuardWalk: true})
+	if len(rep.Errors) != 0 || rep.Copied != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	rep = Sync(Options{Source: src, Target: dst, GuardWalk: true})
+	if len(rep.Errors) != 0 || rep.Copied != 0 || rep.Skipped != 1 {
+		t.Fatalf("unexpected second rep: %+v", *rep)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "backup")); !os.IsNotExist(err) {
+		t.Fatalf("target copied into itself: %v", err)
+	}
+}
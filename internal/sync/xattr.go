@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/fs"
+	"time"
+)
+
+// checksumXattr holds the SHA-256 of a target file written with Options.StoreChecksumXattr,
+// with the size and mod-time it was written with: "<hex sha256> <size> <mtime unix nanoseconds>".
+const checksumXattr = "user.sync.sha256"
+
+// hashingFS hashes the content read from the file it last opened, which is what a copy writes.
+type hashingFS struct {
+	fs.FS
+	h    hash.Hash
+	size int64
+}
+
+func (h *hashingFS) Open(name string) (fs.File, error) {
+	f, err := h.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	// A retried copy starts over
+	h.h.Reset()
+	h.size = 0
+	return &hashingFile{File: f, fs: h}, nil
+}
+
+type hashingFile struct {
+	fs.File
+	fs *hashingFS
+}
+
+func (f *hashingFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.fs.h.Write(p[:n])
+	f.fs.size += int64(n)
+	return n, err
+}
+
+// checksumWriter returns the hashing view of src for a copy when StoreChecksumXattr is set, or nil.
+func (r *runner) checksumWriter(src fs.FS) *hashingFS {
+	if !r.opt.StoreChecksumXattr || !xattrSupported {
+		return nil
+	}
+	if _, ok := r.dst.(dirFS); !ok {
+		return nil
+	}
+	return &hashingFS{FS: src, h: sha256.New()}
+}
+
+// storeChecksum records the hash of the content just written to dstName by way of h.
+// Failures are logged but do not fail the copy; the next comparison then hashes the target.
+func (r *runner) storeChecksum(h *hashingFS, dstName string, info fs.FileInfo) {
+	if h == nil {
+		return
+	}
+	p, err := r.dst.(dirFS).path("setxattr", dstName)
+	if err != nil {
+		return
+	}
+	val := fmt.Sprintf("%x %d %d", h.h.Sum(nil), h.size, info.ModTime().UnixNano())
+	if err := setxattr(p, checksumXattr, []byte(val)); err != nil {
+		r.opt.Logger.Printf("WARN: store checksum %s: %v", r.dstPath(dstName), err)
+	}
+}
+
+// storedChecksum returns the hash recorded on the target file dstRel, provided the file still
+// has the size and mod-time it was written with.
+func (r *runner) storedChecksum(dstRel string, dst fs.FileInfo) ([]byte, bool) {
+	if !r.opt.StoreChecksumXattr || !xattrSupported {
+		return nil, false
+	}
+	d, ok := r.dst.(dirFS)
+	if !ok {
+		return nil, false
+	}
+	p, err := d.path("getxattr", dstRel)
+	if err != nil {
+		return nil, false
+	}
+	val, err := getxattr(p, checksumXattr)
+	if err != nil {
+		return nil, false
+	}
+	var sumHex string
+	var size, mtime int64
+	if _, err := fmt.Sscanf(string(val), "%s %d %d", &sumHex, &size, &mtime); err != nil {
+		return nil, false
+	}
+	sum, err := hex.DecodeString(sumHex)
+	if err != nil || len(sum) != sha256.Size || size != dst.Size() ||
+		!truncateToSeconds(dst.ModTime()).Equal(truncateToSeconds(time.Unix(0, mtime))) {
+		return nil, false
+	}
+	return sum, true
+}
+
+// sameAsTarget compares the source file rel with the target dstRel by content, using the
+// checksum stored on the target when it is still valid instead of reading the target.
+func (r *runner) sameAsTarget(rel, dstRel string, dst fs.FileInfo) (bool, error) {
+	sum, ok := r.storedChecksum(dstRel, dst)
+	if !ok {
+		return sameContent(r.src, rel, r.dst, dstRel)
+	}
+	sh, err := hashFile(r.src, rel)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(sh, sum), nil
+}
@@ -0,0 +1,34 @@
+//go:build linux
+
+package sync
+
+import (
+	"errors"
+	"syscall"
+)
+
+// xattrSupported reports whether extended attributes can be read and written here.
+const xattrSupported = true
+
+func setxattr(p, attr string, val []byte) error {
+	return syscall.Setxattr(p, attr, val, 0)
+}
+
+func getxattr(p, attr string) ([]byte, error) {
+	for {
+		n, err := syscall.Getxattr(p, attr, nil)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		n, err = syscall.Getxattr(p, attr, buf)
+		if errors.Is(err, syscall.ERANGE) {
+			// Grew between the two calls
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
@@ -0,0 +1,59 @@
+//go:build linux
+
+package sync
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreChecksumXattr(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+	writeWithModTime(t, filepath.Join(src, "a.txt"), "alpha", 0o644, mtime)
+	target := filepath.Join(dst, "a.txt")
+
+	opt := Options{Source: src, Target: dst, StoreChecksumXattr: true, IgnoreModTime: true}
+	rep := Sync(opt)
+	if len(rep.Errors) != 0 || rep.Copied != 1 {
+		t.Fatalf("unexpected rep: %+v", *rep)
+	}
+	val, err := getxattr(target, checksumXattr)
+	if err != nil {
+		t.Skipf("user xattrs unsupported here: %v", err)
+	}
+	want := fmt.Sprintf("%x 5 %d", sha256.Sum256([]byte("alpha")), mtime.UnixNano())
+	if string(val) != want {
+		t.Fatalf("xattr %q, want %q", val, want)
+	}
+
+	if rep = Sync(opt); len(rep.Errors) != 0 || rep.Skipped != 1 {
+		t.Fatalf("re-sync not stable: %+v", *rep)
+	}
+
+	// A stored hash that disagrees is trusted over the unchanged target content,
+	// which shows the target is not hashed again
+	bogus := strings.Repeat("0", 2*sha256.Size) + fmt.Sprintf(" 5 %d", mtime.UnixNano())
+	if err := setxattr(target, checksumXattr, []byte(bogus)); err != nil {
+		t.Fatal(err)
+	}
+	if rep = Sync(opt); len(rep.Errors) != 0 || rep.Overwritten != 1 {
+		t.Fatalf("expected the stored hash to decide: %+v", *rep)
+	}
+	if val, _ := getxattr(target, checksumXattr); string(val) != want {
+		t.Fatalf("xattr not rewritten: %q", val)
+	}
+
+	// Once the target changed since it was written, the stored hash is ignored
+	if err := setxattr(target, checksumXattr, []byte(bogus)); err != nil {
+		t.Fatal(err)
+	}
+	writeWithModTime(t, target, "alpha", 0o644, mtime.Add(-time.Hour))
+	if rep = Sync(opt); len(rep.Errors) != 0 || rep.Skipped != 1 {
+		t.Fatalf("expected a content comparison: %+v", *rep)
+	}
+}
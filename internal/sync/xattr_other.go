@@ -0,0 +1,18 @@
+//go:build !linux
+
+package sync
+
+import "errors"
+
+// xattrSupported reports whether extended attributes can be read and written here.
+const xattrSupported = false
+
+var errXattrUnsupported = errors.New("extended attributes not supported on this platform")
+
+func setxattr(p, attr string, val []byte) error {
+	return errXattrUnsupported
+}
+
+func getxattr(p, attr string) ([]byte, error) {
+	return nil, errXattrUnsupported
+}